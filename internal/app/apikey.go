@@ -0,0 +1,69 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"pm-service/internal/config"
+	"pm-service/internal/repository/models"
+	"pm-service/internal/repository/postgres"
+	"pm-service/internal/service/auth"
+	"pm-service/internal/service/validator"
+	"strings"
+)
+
+const apiKeyUsage = "usage: pm-service apikey EMAIL [KEY_NAME]"
+
+// APIKey runs the `apikey` sub-command: it issues an API key for the user
+// with the given email and prints it. If no user has the email yet, one
+// is created with the admin role, which is how a fresh installation gets
+// its first user.
+func APIKey(cfg *config.Config, args []string) error {
+	if len(args) < 1 || len(args) > 2 || !validator.Matches(args[0], validator.EmailRX) {
+		return errors.New(apiKeyUsage)
+	}
+
+	email, name := args[0], "cli"
+	if len(args) == 2 {
+		name = args[1]
+	}
+
+	db, err := config.OpenDB(cfg.DB)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	users := &postgres.UserModel{DB: db, Timeout: cfg.DB.QueryTimeout}
+	accounts := &postgres.AuthModel{DB: db, Timeout: cfg.DB.QueryTimeout}
+
+	filters := models.Filters{Page: 1, PageSize: 1, Sort: []string{"id"}, SortSafelist: []string{"id"}}
+	found, _, err := users.GetAllBy(ctx, "email", email, filters)
+	if err != nil {
+		return err
+	}
+
+	var userID int
+	if len(found) > 0 {
+		userID = found[0].ID
+	} else {
+		input := &models.UserInput{Name: strings.SplitN(email, "@", 2)[0], Email: email, Role: "admin"}
+		if userID, err = users.Insert(ctx, input); err != nil {
+			return err
+		}
+		fmt.Printf("created user %d with the admin role\n", userID)
+	}
+
+	key, hint, err := auth.NewAPIKey()
+	if err != nil {
+		return err
+	}
+
+	if _, err := accounts.InsertKey(ctx, userID, name, hint, auth.HashKey(key)); err != nil {
+		return err
+	}
+
+	fmt.Printf("API key for user %d, shown only once:\n%s\n", userID, key)
+	return nil
+}
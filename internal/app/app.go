@@ -1,26 +1,72 @@
 package app
 
 import (
+	"context"
 	"database/sql"
 	"log"
 	"net/http"
 	"pm-service/internal/config"
 	"pm-service/internal/handlers"
+	"pm-service/internal/repository/migrate"
+	"pm-service/internal/repository/postgres"
+	"pm-service/internal/service/auth"
+	"pm-service/internal/service/events"
+	"pm-service/internal/service/webhook"
+	"time"
 )
 
 type Application struct {
 	Port   int
 	DB     *sql.DB
 	Routes http.Handler
+	Server config.ServerConfig
+	Trash  config.TrashConfig
+	// Webhooks configures the delivery of queued webhook events.
+	Webhooks config.WebhookConfig
+	// Bus hands the domain events relayed from the outbox to the
+	// subscribers registered with it before Serve is called.
+	Bus    *events.Bus
+	Events config.EventConfig
+	// QueryTimeout bounds the queries of background jobs.
+	QueryTimeout time.Duration
 }
 
-func NewApp(port int, scriptPath string) *Application {
-	db, err := config.OpenDB(scriptPath)
+func NewApp(cfg *config.Config) *Application {
+	db, err := config.OpenDB(cfg.DB)
 	if err != nil {
 		log.Fatalln(err)
 	}
 
-	handlers := handlers.New(db)
+	if cfg.AutoMigrate {
+		applied, err := migrate.New(db, cfg.MigrationsDir).Up(context.Background())
+		if err != nil {
+			log.Fatalln(err)
+		}
 
-	return &Application{port, db, config.Routing(handlers)}
+		for _, mig := range applied {
+			log.Printf("applied migration %05d_%s", mig.Version, mig.Name)
+		}
+	}
+
+	tokens := &auth.Tokens{Secret: []byte(cfg.Auth.Secret), AccessTTL: cfg.Auth.AccessTTL, RefreshTTL: cfg.Auth.RefreshTTL}
+	handlers := handlers.New(db, cfg.DB.QueryTimeout, tokens)
+
+	bus := &events.Bus{}
+	bus.Subscribe(events.Recorded{}.Name(), webhook.Enqueue(&postgres.WebhookModel{DB: db, Timeout: cfg.DB.QueryTimeout}))
+	if cfg.Env == "development" {
+		bus.Subscribe(events.All, logEvent)
+	}
+
+	return &Application{
+		Port:   cfg.Port,
+		DB:     db,
+		Routes: config.Routing(handlers),
+		Server: cfg.Server,
+		Trash:  cfg.Trash,
+
+		Webhooks:     cfg.Webhooks,
+		Bus:          bus,
+		Events:       cfg.Events,
+		QueryTimeout: cfg.DB.QueryTimeout,
+	}
 }
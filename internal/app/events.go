@@ -0,0 +1,52 @@
+package app
+
+import (
+	"context"
+	"log"
+	"pm-service/internal/repository/postgres"
+	"pm-service/internal/service/events"
+	"time"
+)
+
+// relayEvents publishes the domain events kept in the outbox on the bus,
+// checking every poll interval and working through a backlog batch by
+// batch, until ctx is cancelled.
+func (app *Application) relayEvents(ctx context.Context) {
+	relay := &events.Relay{
+		Outbox:      &postgres.OutboxModel{DB: app.DB, Timeout: app.QueryTimeout},
+		Bus:         app.Bus,
+		BatchSize:   app.Events.BatchSize,
+		Timeout:     app.Events.Timeout,
+		MaxAttempts: app.Events.MaxAttempts,
+	}
+
+	ticker := time.NewTicker(app.Events.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		for ctx.Err() == nil {
+			relayed, err := relay.Relay(ctx)
+			if err != nil && ctx.Err() == nil {
+				log.Printf("relaying events: %v", err)
+			}
+
+			// a full batch suggests more are waiting
+			if relayed < app.Events.BatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// logEvent logs every event, so that their flow can be followed while
+// developing.
+func logEvent(ctx context.Context, e events.Event) error {
+	log.Printf("event %s %+v", e.Name(), e)
+	return nil
+}
@@ -0,0 +1,87 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"pm-service/internal/config"
+	"pm-service/internal/repository/migrate"
+	"strconv"
+)
+
+const migrateUsage = "usage: pm-service migrate up|down [N]|status|create NAME"
+
+// Migrate runs the `migrate` sub-command with the given arguments.
+func Migrate(cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	if args[0] == "create" {
+		if len(args) != 2 {
+			return errors.New(migrateUsage)
+		}
+
+		paths, err := migrate.New(nil, cfg.MigrationsDir).Create(args[1])
+		if err != nil {
+			return err
+		}
+
+		for _, path := range paths {
+			fmt.Println("created", path)
+		}
+		return nil
+	}
+
+	db, err := config.OpenDB(cfg.DB)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	m := migrate.New(db, cfg.MigrationsDir)
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		applied, err := m.Up(ctx)
+		for _, mig := range applied {
+			fmt.Printf("applied %05d_%s\n", mig.Version, mig.Name)
+		}
+		if err == nil && len(applied) == 0 {
+			fmt.Println("no pending migrations")
+		}
+		return err
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				return errors.New(migrateUsage)
+			}
+		}
+
+		reverted, err := m.Down(ctx, steps)
+		for _, mig := range reverted {
+			fmt.Printf("reverted %05d_%s\n", mig.Version, mig.Name)
+		}
+		return err
+
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, s := range statuses {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%05d_%-40s %s\n", s.Version, s.Name, applied)
+		}
+		return nil
+	}
+
+	return errors.New(migrateUsage)
+}
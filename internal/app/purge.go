@@ -0,0 +1,46 @@
+package app
+
+import (
+	"context"
+	"log"
+	"pm-service/internal/repository/postgres"
+	"time"
+)
+
+// purgeTrash permanently deletes records that have been in the trash for
+// longer than the retention period, once at start and then every purge
+// interval, until ctx is cancelled. Revoked tokens are forgotten on the
+// same schedule once they have expired, and relayed events once they are
+// older than the events retention period.
+func (app *Application) purgeTrash(ctx context.Context) {
+	trash := &postgres.TrashModel{DB: app.DB, Timeout: app.QueryTimeout}
+	auth := &postgres.AuthModel{DB: app.DB, Timeout: app.QueryTimeout}
+	outbox := &postgres.OutboxModel{DB: app.DB, Timeout: app.QueryTimeout}
+
+	ticker := time.NewTicker(app.Trash.PurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := trash.Purge(ctx, time.Now().Add(-app.Trash.Retention))
+		switch {
+		case err != nil && ctx.Err() == nil:
+			log.Printf("purging trash: %v", err)
+		case purged > 0:
+			log.Printf("purged %d records from the trash", purged)
+		}
+
+		if _, err := auth.PurgeRevoked(ctx, time.Now()); err != nil && ctx.Err() == nil {
+			log.Printf("purging revoked tokens: %v", err)
+		}
+
+		if _, err := outbox.Purge(ctx, time.Now().Add(-app.Events.Retention)); err != nil && ctx.Err() == nil {
+			log.Printf("purging relayed events: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// Serve runs the HTTP server until SIGINT or SIGTERM is received, then stops
+// accepting connections and waits for in-flight requests to finish within
+// the configured shutdown timeout. Requests still running then have their
+// connections closed. The trash purge, the webhook deliveries and the
+// event relay run in the background meanwhile. The database is closed only
+// after all of them and every request handler have stopped.
+func (app *Application) Serve() error {
+	// handlers counts the requests being handled, which outlive their
+	// connections when the server is closed
+	var handlers sync.WaitGroup
+
+	srv := &http.Server{
+		Addr: fmt.Sprintf(":%d", app.Port),
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handlers.Add(1)
+			defer handlers.Done()
+			app.Routes.ServeHTTP(w, r)
+		}),
+		ReadTimeout:       app.Server.ReadTimeout,
+		ReadHeaderTimeout: app.Server.ReadHeaderTimeout,
+		WriteTimeout:      app.Server.WriteTimeout,
+		IdleTimeout:       app.Server.IdleTimeout,
+		MaxHeaderBytes:    app.Server.MaxHeaderBytes,
+		ErrorLog:          log.Default(),
+	}
+
+	defer app.DB.Close()
+	defer handlers.Wait()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	jobsCtx, cancelJobs := context.WithCancel(ctx)
+	var jobs sync.WaitGroup
+
+	for _, job := range []func(context.Context){app.purgeTrash, app.deliverWebhooks, app.relayEvents} {
+		jobs.Add(1)
+		go func(job func(context.Context)) {
+			defer jobs.Done()
+			job(jobsCtx)
+		}(job)
+	}
+
+	defer func() {
+		cancelJobs()
+		jobs.Wait()
+	}()
+
+	serveErr := make(chan error, 1)
+
+	go func() {
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	// A second signal while draining terminates the process immediately.
+	stop()
+	log.Printf("shutting down server, waiting up to %s for in-flight requests", app.Server.ShutdownTimeout)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), app.Server.ShutdownTimeout)
+	defer cancel()
+
+	err := srv.Shutdown(shutdownCtx)
+	if err != nil {
+		// closing the connections cancels the contexts of the requests
+		// still running, so that they stop before the database is closed
+		log.Printf("forcing the server to close: %v", err)
+		srv.Close()
+	}
+
+	if err := <-serveErr; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	if err != nil {
+		return err
+	}
+
+	log.Println("server stopped")
+
+	return nil
+}
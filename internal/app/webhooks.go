@@ -0,0 +1,49 @@
+package app
+
+import (
+	"context"
+	"log"
+	"pm-service/internal/repository/postgres"
+	"pm-service/internal/service/webhook"
+	"time"
+)
+
+// deliverWebhooks sends the queued webhook deliveries as they fall due,
+// checking every poll interval and working through a backlog batch by
+// batch, until ctx is cancelled.
+func (app *Application) deliverWebhooks(ctx context.Context) {
+	dispatcher := &webhook.Dispatcher{
+		Queue:       &postgres.WebhookModel{DB: app.DB, Timeout: app.QueryTimeout},
+		Client:      webhook.NewClient(app.Webhooks.Timeout),
+		MaxAttempts: app.Webhooks.MaxAttempts,
+		Backoff:     app.Webhooks.Backoff,
+		MaxBackoff:  app.Webhooks.MaxBackoff,
+		BatchSize:   app.Webhooks.BatchSize,
+	}
+
+	ticker := time.NewTicker(app.Webhooks.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		for ctx.Err() == nil {
+			delivered, failed, err := dispatcher.Dispatch(ctx)
+			if err != nil && ctx.Err() == nil {
+				log.Printf("delivering webhooks: %v", err)
+			}
+			if failed > 0 {
+				log.Printf("delivered %d webhook events, %d failed", delivered, failed)
+			}
+
+			// a full batch suggests more are due
+			if err != nil || delivered+failed < app.Webhooks.BatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
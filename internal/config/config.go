@@ -0,0 +1,433 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const redacted = "*****"
+
+// Config holds every setting the service needs at startup. Values are
+// resolved in increasing order of precedence: built-in defaults, the
+// optional YAML file, environment variables and finally command-line flags.
+type Config struct {
+	Port   int          `yaml:"port"`
+	Env    string       `yaml:"env"`
+	DB     DBConfig     `yaml:"db"`
+	Server ServerConfig `yaml:"server"`
+	Trash  TrashConfig  `yaml:"trash"`
+	Auth   AuthConfig   `yaml:"auth"`
+
+	Webhooks WebhookConfig `yaml:"webhooks"`
+	Events   EventConfig   `yaml:"events"`
+
+	// MigrationsDir holds the NNNNN_name.up.sql/.down.sql scripts.
+	MigrationsDir string `yaml:"migrations_dir"`
+	// AutoMigrate applies pending migrations when the server starts.
+	AutoMigrate bool `yaml:"auto_migrate"`
+
+	args []string
+}
+
+type DBConfig struct {
+	// DSN, when set, is used as is and takes priority over the
+	// individual connection fields below.
+	DSN      string `yaml:"dsn"`
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+	Name     string `yaml:"name"`
+	SSLMode  string `yaml:"sslmode"`
+	// QueryTimeout cancels any single query running longer than this.
+	QueryTimeout time.Duration `yaml:"query_timeout"`
+}
+
+type ServerConfig struct {
+	ReadTimeout       time.Duration `yaml:"read_timeout"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout"`
+	WriteTimeout      time.Duration `yaml:"write_timeout"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout"`
+	// ShutdownTimeout bounds how long in-flight requests may take to
+	// drain once a termination signal has been received.
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+	MaxHeaderBytes  int           `yaml:"max_header_bytes"`
+}
+
+type TrashConfig struct {
+	// Retention is how long deleted records stay restorable before the
+	// background purge removes them for good.
+	Retention     time.Duration `yaml:"retention"`
+	PurgeInterval time.Duration `yaml:"purge_interval"`
+}
+
+type AuthConfig struct {
+	// Secret signs the access and refresh tokens; changing it signs
+	// everybody out.
+	Secret     string        `yaml:"secret"`
+	AccessTTL  time.Duration `yaml:"access_ttl"`
+	RefreshTTL time.Duration `yaml:"refresh_ttl"`
+}
+
+type WebhookConfig struct {
+	// PollInterval is how often the queue is checked for deliveries
+	// that are due.
+	PollInterval time.Duration `yaml:"poll_interval"`
+	// Timeout bounds each delivery request.
+	Timeout time.Duration `yaml:"timeout"`
+	// MaxAttempts is how often a delivery is tried before it is
+	// dead-lettered.
+	MaxAttempts int `yaml:"max_attempts"`
+	// Backoff is the wait after the first failed attempt, doubled after
+	// each further one up to MaxBackoff.
+	Backoff    time.Duration `yaml:"backoff"`
+	MaxBackoff time.Duration `yaml:"max_backoff"`
+	BatchSize  int           `yaml:"batch_size"`
+}
+
+type EventConfig struct {
+	// PollInterval is how often the outbox is checked for events to
+	// relay to their subscribers.
+	PollInterval time.Duration `yaml:"poll_interval"`
+	BatchSize    int           `yaml:"batch_size"`
+	// Timeout bounds the handlers of each relayed event.
+	Timeout time.Duration `yaml:"timeout"`
+	// MaxAttempts is how often an event is relayed before it is failed.
+	MaxAttempts int `yaml:"max_attempts"`
+	// Retention is how long relayed and failed events stay in the outbox
+	// before the background purge removes them.
+	Retention time.Duration `yaml:"retention"`
+}
+
+func defaults() *Config {
+	return &Config{
+		Port: 8080,
+		Env:  "development",
+		DB: DBConfig{
+			Host:         "localhost",
+			Port:         5432,
+			SSLMode:      "disable",
+			QueryTimeout: 5 * time.Second,
+		},
+		Server: ServerConfig{
+			ReadTimeout:       10 * time.Second,
+			ReadHeaderTimeout: 5 * time.Second,
+			WriteTimeout:      30 * time.Second,
+			IdleTimeout:       time.Minute,
+			ShutdownTimeout:   20 * time.Second,
+			MaxHeaderBytes:    1 << 20,
+		},
+		Trash: TrashConfig{
+			Retention:     30 * 24 * time.Hour,
+			PurgeInterval: time.Hour,
+		},
+		Auth: AuthConfig{
+			AccessTTL:  15 * time.Minute,
+			RefreshTTL: 7 * 24 * time.Hour,
+		},
+		Webhooks: WebhookConfig{
+			PollInterval: 5 * time.Second,
+			Timeout:      10 * time.Second,
+			MaxAttempts:  8,
+			Backoff:      30 * time.Second,
+			MaxBackoff:   6 * time.Hour,
+			BatchSize:    20,
+		},
+		Events: EventConfig{
+			PollInterval: time.Second,
+			BatchSize:    100,
+			Timeout:      10 * time.Second,
+			MaxAttempts:  10,
+			Retention:    7 * 24 * time.Hour,
+		},
+		MigrationsDir: "migrations/postgres",
+		AutoMigrate:   true,
+	}
+}
+
+// Load builds the configuration from the given command-line arguments
+// (without the program name) and the process environment.
+func Load(args []string) (*Config, error) {
+	cfg := defaults()
+
+	fs := flag.NewFlagSet("pm-service", flag.ContinueOnError)
+	file := fs.String("config", os.Getenv("CONFIG_FILE"), "path to a YAML configuration file")
+	port := fs.Int("port", cfg.Port, "port for api")
+	env := fs.String("env", cfg.Env, "environment (development|staging|production)")
+	dsn := fs.String("db-dsn", "", "PostgreSQL DSN")
+	migrations := fs.String("migrations", cfg.MigrationsDir, "directory containing migration scripts")
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if *file != "" {
+		if err := cfg.loadFile(*file); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := cfg.loadEnv(); err != nil {
+		return nil, err
+	}
+
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "port":
+			cfg.Port = *port
+		case "env":
+			cfg.Env = *env
+		case "db-dsn":
+			cfg.DB.DSN = *dsn
+		case "migrations":
+			cfg.MigrationsDir = *migrations
+		}
+	})
+
+	cfg.args = fs.Args()
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+
+	if err := yaml.Unmarshal(data, c); err != nil {
+		return fmt.Errorf("config: parse %s: %w", path, err)
+	}
+
+	return nil
+}
+
+func (c *Config) loadEnv() error {
+	strs := map[string]*string{
+		"APP_ENV":        &c.Env,
+		"DB_DSN":         &c.DB.DSN,
+		"DB_HOST":        &c.DB.Host,
+		"DB_USER":        &c.DB.User,
+		"DB_PASSWORD":    &c.DB.Password,
+		"DB_NAME":        &c.DB.Name,
+		"DB_SSLMODE":     &c.DB.SSLMode,
+		"MIGRATIONS_DIR": &c.MigrationsDir,
+		"AUTH_SECRET":    &c.Auth.Secret,
+	}
+
+	for key, dst := range strs {
+		if val, ok := os.LookupEnv(key); ok {
+			*dst = val
+		}
+	}
+
+	ints := map[string]*int{
+		"PORT":                    &c.Port,
+		"DB_PORT":                 &c.DB.Port,
+		"SERVER_MAX_HEADER_BYTES": &c.Server.MaxHeaderBytes,
+		"WEBHOOK_MAX_ATTEMPTS":    &c.Webhooks.MaxAttempts,
+		"WEBHOOK_BATCH_SIZE":      &c.Webhooks.BatchSize,
+		"EVENTS_BATCH_SIZE":       &c.Events.BatchSize,
+		"EVENTS_MAX_ATTEMPTS":     &c.Events.MaxAttempts,
+	}
+
+	for key, dst := range ints {
+		if val, ok := os.LookupEnv(key); ok {
+			n, err := strconv.Atoi(val)
+			if err != nil {
+				return fmt.Errorf("config: %s must be an integer", key)
+			}
+			*dst = n
+		}
+	}
+
+	durations := map[string]*time.Duration{
+		"SERVER_READ_TIMEOUT":        &c.Server.ReadTimeout,
+		"SERVER_READ_HEADER_TIMEOUT": &c.Server.ReadHeaderTimeout,
+		"SERVER_WRITE_TIMEOUT":       &c.Server.WriteTimeout,
+		"SERVER_IDLE_TIMEOUT":        &c.Server.IdleTimeout,
+		"SERVER_SHUTDOWN_TIMEOUT":    &c.Server.ShutdownTimeout,
+		"DB_QUERY_TIMEOUT":           &c.DB.QueryTimeout,
+		"TRASH_RETENTION":            &c.Trash.Retention,
+		"TRASH_PURGE_INTERVAL":       &c.Trash.PurgeInterval,
+		"AUTH_ACCESS_TTL":            &c.Auth.AccessTTL,
+		"AUTH_REFRESH_TTL":           &c.Auth.RefreshTTL,
+		"WEBHOOK_POLL_INTERVAL":      &c.Webhooks.PollInterval,
+		"WEBHOOK_TIMEOUT":            &c.Webhooks.Timeout,
+		"WEBHOOK_BACKOFF":            &c.Webhooks.Backoff,
+		"WEBHOOK_MAX_BACKOFF":        &c.Webhooks.MaxBackoff,
+		"EVENTS_POLL_INTERVAL":       &c.Events.PollInterval,
+		"EVENTS_TIMEOUT":             &c.Events.Timeout,
+		"EVENTS_RETENTION":           &c.Events.Retention,
+	}
+
+	for key, dst := range durations {
+		if val, ok := os.LookupEnv(key); ok {
+			d, err := time.ParseDuration(val)
+			if err != nil {
+				return fmt.Errorf("config: %s must be a duration such as 5s", key)
+			}
+			*dst = d
+		}
+	}
+
+	if val, ok := os.LookupEnv("AUTO_MIGRATE"); ok {
+		b, err := strconv.ParseBool(val)
+		if err != nil {
+			return fmt.Errorf("config: AUTO_MIGRATE must be a boolean")
+		}
+		c.AutoMigrate = b
+	}
+
+	return nil
+}
+
+// Args returns the positional arguments left over after flag parsing.
+func (c *Config) Args() []string {
+	return c.args
+}
+
+func (c *Config) Validate() error {
+	var problems []string
+
+	if c.Port < 1 || c.Port > 65535 {
+		problems = append(problems, "port must be between 1 and 65535")
+	}
+
+	switch c.Env {
+	case "development", "staging", "production":
+	default:
+		problems = append(problems, "env must be one of development, staging, production")
+	}
+
+	if c.DB.DSN == "" {
+		if c.DB.Host == "" {
+			problems = append(problems, "db host must be provided")
+		}
+		if c.DB.Port < 1 || c.DB.Port > 65535 {
+			problems = append(problems, "db port must be between 1 and 65535")
+		}
+		if c.DB.User == "" {
+			problems = append(problems, "db user must be provided")
+		}
+		if c.DB.Name == "" {
+			problems = append(problems, "db name must be provided")
+		}
+	}
+
+	if c.DB.QueryTimeout <= 0 {
+		problems = append(problems, "db query timeout must be positive")
+	}
+
+	timeouts := []time.Duration{c.Server.ReadTimeout, c.Server.ReadHeaderTimeout, c.Server.WriteTimeout, c.Server.IdleTimeout, c.Server.ShutdownTimeout}
+	for _, d := range timeouts {
+		if d <= 0 {
+			problems = append(problems, "server timeouts must be positive")
+			break
+		}
+	}
+
+	if c.Server.MaxHeaderBytes < 1024 {
+		problems = append(problems, "server max header bytes must be at least 1024")
+	}
+
+	if c.Trash.Retention <= 0 || c.Trash.PurgeInterval <= 0 {
+		problems = append(problems, "trash retention and purge interval must be positive")
+	}
+
+	if len(c.Auth.Secret) < 32 {
+		problems = append(problems, "auth secret must be at least 32 characters long")
+	}
+
+	if c.Auth.AccessTTL <= 0 || c.Auth.RefreshTTL < c.Auth.AccessTTL {
+		problems = append(problems, "auth access ttl must be positive and no longer than the refresh ttl")
+	}
+
+	if c.Webhooks.PollInterval <= 0 || c.Webhooks.Timeout <= 0 {
+		problems = append(problems, "webhook poll interval and timeout must be positive")
+	}
+
+	if c.Webhooks.MaxAttempts < 1 || c.Webhooks.BatchSize < 1 {
+		problems = append(problems, "webhook max attempts and batch size must be at least 1")
+	}
+
+	if c.Webhooks.Backoff <= 0 || c.Webhooks.MaxBackoff < c.Webhooks.Backoff {
+		problems = append(problems, "webhook backoff must be positive and no longer than the max backoff")
+	}
+
+	if c.Events.PollInterval <= 0 || c.Events.Timeout <= 0 || c.Events.Retention <= 0 || c.Events.BatchSize < 1 || c.Events.MaxAttempts < 1 {
+		problems = append(problems, "events poll interval, timeout and retention must be positive and batch size and max attempts at least 1")
+	}
+
+	if c.MigrationsDir == "" {
+		problems = append(problems, "migrations dir must be provided")
+	}
+
+	if len(problems) > 0 {
+		return errors.New("config: " + strings.Join(problems, "; "))
+	}
+
+	return nil
+}
+
+// ConnString returns the connection string to hand to sql.Open.
+func (d DBConfig) ConnString() string {
+	if d.DSN != "" {
+		return d.DSN
+	}
+
+	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s", d.Host, d.Port, d.User, d.Password, d.Name, d.SSLMode)
+}
+
+// Redacted returns a printable dump of the configuration with secrets masked.
+func (c *Config) Redacted() string {
+	db := c.DB
+	if db.Password != "" {
+		db.Password = redacted
+	}
+	db.DSN = redactDSN(db.DSN)
+
+	auth := c.Auth
+	if auth.Secret != "" {
+		auth.Secret = redacted
+	}
+
+	return fmt.Sprintf("port=%d env=%s db.dsn=%q db.host=%s db.port=%d db.user=%s db.password=%s db.name=%s db.sslmode=%s db.query_timeout=%s server=%+v trash=%+v auth=%+v webhooks=%+v events=%+v migrations_dir=%s auto_migrate=%t",
+		c.Port, c.Env, db.DSN, db.Host, db.Port, db.User, db.Password, db.Name, db.SSLMode, db.QueryTimeout, c.Server, c.Trash, auth, c.Webhooks, c.Events, c.MigrationsDir, c.AutoMigrate)
+}
+
+func redactDSN(dsn string) string {
+	if dsn == "" {
+		return ""
+	}
+
+	u, err := url.Parse(dsn)
+	if err != nil || u.Scheme == "" {
+		// key=value form, mask the password pair if present
+		fields := strings.Fields(dsn)
+		for i, f := range fields {
+			if strings.HasPrefix(f, "password=") {
+				fields[i] = "password=" + redacted
+			}
+		}
+		return strings.Join(fields, " ")
+	}
+
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), redacted)
+	}
+
+	return u.String()
+}
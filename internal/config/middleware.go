@@ -1,12 +1,39 @@
 package config
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"log"
 	"net/http"
 	"pm-service/internal/handlers/errors"
+	"pm-service/internal/service/audit"
+	"regexp"
 )
 
+// requestIDRX matches the request ids accepted from clients.
+var requestIDRX = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,64}$`)
+
+// requestID tags the request with the X-Request-ID the client sent, or a
+// new one, echoes it in the response and ties the changes the request
+// makes to it in the audit log.
+func requestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if !requestIDRX.MatchString(id) {
+			b := make([]byte, 16)
+			if _, err := rand.Read(b); err != nil {
+				errors.ServerErrorResponse(w, r, err)
+				return
+			}
+			id = hex.EncodeToString(b)
+		}
+
+		w.Header().Set("X-Request-ID", id)
+		next.ServeHTTP(w, r.WithContext(audit.WithRequestID(r.Context(), id)))
+	})
+}
+
 func secureHeaders(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("X-XSS-Protection", "1; mode=block")
@@ -31,16 +31,53 @@ type routes struct {
 //	@license.name	MIT
 //	@license.url	https://opensource.org/licenses/MIT
 
+//	@securityDefinitions.apikey	BearerAuth
+//	@in							header
+//	@name						Authorization
+//	@description				Access token from POST /auth/login, sent as "Bearer TOKEN"
+
+//	@securityDefinitions.apikey	APIKeyAuth
+//	@in							header
+//	@name						X-API-Key
+//	@description				API key from POST /auth/keys
+
+//	@security	BearerAuth
+//	@security	APIKeyAuth
+
 // @BasePath	/health
 func Routing(handlers *handlers.Handler) http.Handler {
 	// Create a middleware chain containing our 'standard' middleware
 	// which will be used for every request our app receives.
-	standardMiddleware := alice.New(recoverPanic, logRequest, secureHeaders)
+	standardMiddleware := alice.New(recoverPanic, requestID, logRequest, secureHeaders, handlers.Authenticate)
 
 	router := mux.NewRouter()
 
-	routes := []routes{
+	// public routes answer anonymous requests, every other route requires
+	// an access token or API key
+	public := []routes{
 		{"/health", handlers.HealthCheckHandler, http.MethodGet},
+		{"/auth/login", handlers.LoginHandler, http.MethodPost},
+		{"/auth/refresh", handlers.RefreshHandler, http.MethodPost},
+	}
+
+	routes := []routes{
+		{"/auth/logout", handlers.LogoutHandler, http.MethodPost},
+		{"/auth/me", handlers.ShowCurrentUserHandler, http.MethodGet},
+		{"/auth/password", handlers.ChangePasswordHandler, http.MethodPut},
+		{"/auth/keys", handlers.ShowAPIKeysHandler, http.MethodGet},
+		{"/auth/keys", handlers.CreateAPIKeyHandler, http.MethodPost},
+		{"/auth/keys/{key_id:[0-9]+}", handlers.DeleteAPIKeyHandler, http.MethodDelete},
+		{"/search", handlers.FullTextSearchHandler, http.MethodGet},
+		{"/trash", handlers.ShowTrashHandler, http.MethodGet},
+		{"/audit", handlers.ShowAuditHandler, http.MethodGet},
+		{"/webhooks", handlers.ShowAllWebhooksHandler, http.MethodGet},
+		{"/webhooks", handlers.CreateWebhookHandler, http.MethodPost},
+		{"/webhooks/{id:[0-9]+}", handlers.ShowWebhookHandler, http.MethodGet},
+		{"/webhooks/{id:[0-9]+}", handlers.UpdateWebhookHandler, http.MethodPut},
+		{"/webhooks/{id:[0-9]+}", handlers.DeleteWebhookHandler, http.MethodDelete},
+		{"/webhooks/{id:[0-9]+}/deliveries", handlers.ShowWebhookDeliveriesHandler, http.MethodGet},
+		{"/webhooks/{id:[0-9]+}/deliveries/{delivery_id:[0-9]+}", handlers.ShowWebhookDeliveryHandler, http.MethodGet},
+		{"/webhooks/{id:[0-9]+}/deliveries/{delivery_id:[0-9]+}/redeliver", handlers.RedeliverWebhookHandler, http.MethodPost},
 		{"/users", handlers.ShowAllUsersHandler, http.MethodGet},
 		{"/users", handlers.CreateUserHandler, http.MethodPost},
 		{"/users/search", handlers.SearchUsersHandler, http.MethodGet},
@@ -52,15 +89,52 @@ func Routing(handlers *handlers.Handler) http.Handler {
 		{"/projects/search", handlers.SearchProjectsHandler, http.MethodGet},
 		{"/users/{id:[0-9]+}", handlers.ShowUserHandler, http.MethodGet},
 		{"/users/{id:[0-9]+}", handlers.UpdateUserHandler, http.MethodPut},
+		{"/users/{id:[0-9]+}", handlers.PatchUserHandler, http.MethodPatch},
 		{"/users/{id:[0-9]+}", handlers.DeleteUserHandler, http.MethodDelete},
+		{"/users/{id:[0-9]+}/restore", handlers.RestoreUserHandler, http.MethodPost},
 		{"/users/{id:[0-9]+}/tasks", handlers.ShowUserTasksHandler, http.MethodGet},
+		{"/users/{id:[0-9]+}/mentions", handlers.ShowUserMentionsHandler, http.MethodGet},
+		{"/users/{id:[0-9]+}/projects", handlers.ShowUserProjectsHandler, http.MethodGet},
 		{"/projects/{id:[0-9]+}", handlers.ShowProjectHandler, http.MethodGet},
 		{"/projects/{id:[0-9]+}", handlers.UpdateProjectHandler, http.MethodPut},
+		{"/projects/{id:[0-9]+}", handlers.PatchProjectHandler, http.MethodPatch},
 		{"/projects/{id:[0-9]+}", handlers.DeleteProjectHandler, http.MethodDelete},
+		{"/projects/{id:[0-9]+}/restore", handlers.RestoreProjectHandler, http.MethodPost},
 		{"/projects/{id:[0-9]+}/tasks", handlers.ShowProjectTasksHandler, http.MethodGet},
+		{"/projects/{id:[0-9]+}/workflow", handlers.ShowWorkflowHandler, http.MethodGet},
+		{"/projects/{id:[0-9]+}/workflow", handlers.UpdateWorkflowHandler, http.MethodPut},
+		{"/projects/{id:[0-9]+}/critical-path", handlers.ShowCriticalPathHandler, http.MethodGet},
+		{"/projects/{id:[0-9]+}/members", handlers.ShowProjectMembersHandler, http.MethodGet},
+		{"/projects/{id:[0-9]+}/members", handlers.AddProjectMemberHandler, http.MethodPost},
+		{"/projects/{id:[0-9]+}/members/{user_id:[0-9]+}", handlers.UpdateProjectMemberHandler, http.MethodPut},
+		{"/projects/{id:[0-9]+}/members/{user_id:[0-9]+}", handlers.RemoveProjectMemberHandler, http.MethodDelete},
+		{"/projects/{id:[0-9]+}/labels", handlers.ShowProjectLabelsHandler, http.MethodGet},
+		{"/projects/{id:[0-9]+}/labels", handlers.CreateLabelHandler, http.MethodPost},
+		{"/projects/{id:[0-9]+}/labels/{label_id:[0-9]+}", handlers.ShowLabelHandler, http.MethodGet},
+		{"/projects/{id:[0-9]+}/labels/{label_id:[0-9]+}", handlers.UpdateLabelHandler, http.MethodPut},
+		{"/projects/{id:[0-9]+}/labels/{label_id:[0-9]+}", handlers.DeleteLabelHandler, http.MethodDelete},
 		{"/tasks/{id:[0-9]+}", handlers.ShowTaskHandler, http.MethodGet},
 		{"/tasks/{id:[0-9]+}", handlers.UpdateTaskHandler, http.MethodPut},
+		{"/tasks/{id:[0-9]+}", handlers.PatchTaskHandler, http.MethodPatch},
 		{"/tasks/{id:[0-9]+}", handlers.DeleteTaskHandler, http.MethodDelete},
+		{"/tasks/{id:[0-9]+}/restore", handlers.RestoreTaskHandler, http.MethodPost},
+		{"/tasks/{id:[0-9]+}/subtasks", handlers.ShowSubtasksHandler, http.MethodGet},
+		{"/tasks/{id:[0-9]+}/tree", handlers.ShowTaskTreeHandler, http.MethodGet},
+		{"/tasks/{id:[0-9]+}/history", handlers.ShowTaskHistoryHandler, http.MethodGet},
+		{"/tasks/{id:[0-9]+}/dependencies", handlers.ShowTaskDependenciesHandler, http.MethodGet},
+		{"/tasks/{id:[0-9]+}/dependencies", handlers.CreateTaskDependencyHandler, http.MethodPost},
+		{"/tasks/{id:[0-9]+}/dependencies/{blocker_id:[0-9]+}", handlers.DeleteTaskDependencyHandler, http.MethodDelete},
+		{"/tasks/{id:[0-9]+}/labels", handlers.ShowTaskLabelsHandler, http.MethodGet},
+		{"/tasks/{id:[0-9]+}/labels/{label_id:[0-9]+}", handlers.AttachLabelHandler, http.MethodPut},
+		{"/tasks/{id:[0-9]+}/labels/{label_id:[0-9]+}", handlers.DetachLabelHandler, http.MethodDelete},
+		{"/tasks/{id:[0-9]+}/transitions", handlers.ShowTaskTransitionsHandler, http.MethodGet},
+		{"/tasks/{id:[0-9]+}/transitions", handlers.TransitionTaskHandler, http.MethodPost},
+		{"/tasks/{id:[0-9]+}/comments", handlers.ShowTaskCommentsHandler, http.MethodGet},
+		{"/tasks/{id:[0-9]+}/comments", handlers.CreateCommentHandler, http.MethodPost},
+		{"/tasks/{id:[0-9]+}/comments/{comment_id:[0-9]+}", handlers.ShowCommentHandler, http.MethodGet},
+		{"/tasks/{id:[0-9]+}/comments/{comment_id:[0-9]+}", handlers.UpdateCommentHandler, http.MethodPut},
+		{"/tasks/{id:[0-9]+}/comments/{comment_id:[0-9]+}", handlers.DeleteCommentHandler, http.MethodDelete},
+		{"/tasks/{id:[0-9]+}/comments/{comment_id:[0-9]+}/history", handlers.ShowCommentHistoryHandler, http.MethodGet},
 	}
 
 	router.PathPrefix("/swagger/").Handler(httpSwagger.WrapHandler).Methods("GET")
@@ -68,9 +142,13 @@ func Routing(handlers *handlers.Handler) http.Handler {
 	router.NotFoundHandler = http.HandlerFunc(errors.NotFoundResponse)
 	router.MethodNotAllowedHandler = http.HandlerFunc(errors.MethodNotAllowedResponse)
 
-	for _, route := range routes {
+	for _, route := range public {
 		router.HandleFunc(route.Path, route.Handler).Methods(route.Method)
 	}
 
+	for _, route := range routes {
+		router.Handle(route.Path, handlers.RequireUser(http.HandlerFunc(route.Handler))).Methods(route.Method)
+	}
+
 	return standardMiddleware.Then(router)
 }
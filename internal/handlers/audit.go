@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"net/http"
+	"pm-service/internal/handlers/errors"
+	"pm-service/internal/repository/models"
+	"pm-service/internal/service/helpers"
+	"pm-service/internal/service/validator"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+var (
+	auditSortSafelist = []string{"id", "created"}
+	auditSearchFields = []searchField{
+		{"resource", "resource", enumSearch, models.AuditResources},
+		{"id", "resource_id", idSearch, nil},
+		{"actor", "actor_id", idSearch, nil},
+		{"action", "action", enumSearch, models.AuditActions},
+		{"request_id", "request_id", enumSearch, nil},
+	}
+)
+
+// readSince adds the since query parameter, if given, to criteria as the
+// earliest time an audit event may have been recorded.
+func readSince(r *http.Request, v *validator.Validator, criteria models.Criteria) models.Criteria {
+	since := r.URL.Query().Get("since")
+	if since == "" {
+		return criteria
+	}
+
+	t, err := models.ParseDate(since)
+	if err != nil {
+		v.AddError("since", "must be a date (YYYY-MM-DD) or an RFC 3339 timestamp")
+		return criteria
+	}
+
+	return append(criteria, models.Condition{Field: "created", Op: models.OpFrom, Values: []string{t.Format(time.RFC3339Nano)}})
+}
+
+// @Summary		Search audit log
+// @Description	List the recorded changes, oldest first: who made each, in which request, and the
+// @Description	fields it changed with their values before and after. Project members are recorded as
+// @Description	changes to their project, a task's dependencies and labels as changes to the task.
+// @Description	Password values are redacted. Admins only.
+// @Tags			Audit
+// @Accept			json
+// @Produce		json
+// @Param			resource	query		string	false	"Resource(s): users, projects, tasks, comments, labels, workflows, api_keys, webhooks"
+// @Param			id			query		int		false	"Resource ID(s)"
+// @Param			actor		query		int		false	"ID(s) of the user who made the change"
+// @Param			action		query		string	false	"Action(s): insert, update, delete, restore"
+// @Param			request_id	query		string	false	"Request ID, as sent back in X-Request-ID"
+// @Param			since		query		string	false	"Recorded on or after (date or RFC 3339)"
+// @Param			page		query		int		false	"Page number (default 1)"
+// @Param			page_size	query		int		false	"Page size (default 20, max 100)"
+// @Param			sort		query		string	false	"Comma-separated sort fields, prefix with - for descending"
+// @Success		200			{object}	map[string]interface{}
+// @Failure		403			{object}	map[string]string
+// @Failure		422			{object}	map[string]string
+// @Failure		500			{object}	map[string]string
+// @Router			/audit [get]
+func (h *Handler) ShowAuditHandler(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, h.policy.Admin(contextGetUser(r))) {
+		return
+	}
+
+	v := validator.New()
+	criteria := readSince(r, v, readCriteria(r, v, auditSearchFields))
+	filters := readFilters(r, v, auditSortSafelist)
+	if !v.Valid() {
+		errors.FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	events, metadata, err := h.audit.Search(r.Context(), criteria, filters)
+	if err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+
+	if err := helpers.WriteJSON(w, http.StatusOK, map[string]interface{}{"events": events, "metadata": metadata}, nil); err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+}
+
+// @Summary		Get task history
+// @Description	List the recorded changes to a task, oldest first, including those to its dependencies
+// @Description	and labels
+// @Tags			Audit
+// @Accept			json
+// @Produce		json
+// @Param			id			path		int		true	"Task ID"
+// @Param			since		query		string	false	"Recorded on or after (date or RFC 3339)"
+// @Param			page		query		int		false	"Page number (default 1)"
+// @Param			page_size	query		int		false	"Page size (default 20, max 100)"
+// @Success		200			{object}	map[string]interface{}
+// @Failure		404			{object}	map[string]string
+// @Failure		422			{object}	map[string]string
+// @Failure		500			{object}	map[string]string
+// @Router			/tasks/{id}/history [get]
+func (h *Handler) ShowTaskHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	task, err := h.tasks.Get(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		if err == h.errors.NoRecordError() {
+			errors.NotFoundResponse(w, r)
+		} else {
+			errors.ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	v := validator.New()
+	criteria := readSince(r, v, models.Criteria{
+		{Field: "resource", Op: models.OpEqual, Values: []string{"tasks"}},
+		{Field: "resource_id", Op: models.OpEqual, Values: []string{strconv.Itoa(task.ID)}},
+	})
+	filters := readFilters(r, v, nil)
+	if !v.Valid() {
+		errors.FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	events, metadata, err := h.audit.Search(r.Context(), criteria, filters)
+	if err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+
+	if err := helpers.WriteJSON(w, http.StatusOK, map[string]interface{}{"history": events, "metadata": metadata}, nil); err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+}
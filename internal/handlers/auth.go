@@ -0,0 +1,411 @@
+package handlers
+
+import (
+	"net/http"
+	"pm-service/internal/handlers/errors"
+	"pm-service/internal/repository/models"
+	"pm-service/internal/service/auth"
+	"pm-service/internal/service/helpers"
+	"pm-service/internal/service/validator"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// @Summary		Sign in
+// @Description	Exchange a user's email and password for a short-lived access token and a refresh token.
+// @Description	Send the access token as "Authorization: Bearer TOKEN".
+// @Tags			Auth
+// @Accept			json
+// @Produce		json
+// @Param			credentials	body		models.LoginInput	true	"Email and password"
+// @Success		200			{object}	map[string]interface{}
+// @Failure		400			{object}	map[string]string
+// @Failure		401			{object}	map[string]string
+// @Failure		422			{object}	map[string]string
+// @Failure		500			{object}	map[string]string
+// @Router			/auth/login [post]
+func (h *Handler) LoginHandler(w http.ResponseWriter, r *http.Request) {
+	input := h.input.NewLoginInput()
+
+	if err := helpers.ReadJSON(w, r, &input); err != nil {
+		errors.BadRequestResponse(w, r)
+		return
+	}
+
+	v := validator.New()
+	validator.LoginInput(v, &input)
+	if !v.Valid() {
+		errors.FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user, hash, err := h.auth.Credentials(r.Context(), input.Email)
+	if err != nil && err != h.errors.NoRecordError() {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+
+	if err != nil || !auth.CheckPassword(input.Password, hash) {
+		errors.InvalidCredentialsResponse(w, r)
+		return
+	}
+
+	h.writeTokens(w, r, user)
+}
+
+// @Summary		Refresh tokens
+// @Description	Exchange a refresh token for a new token pair. The refresh token is revoked, so each one
+// @Description	can be used only once.
+// @Tags			Auth
+// @Accept			json
+// @Produce		json
+// @Param			token	body		models.TokenInput	true	"Refresh token"
+// @Success		200		{object}	map[string]interface{}
+// @Failure		400		{object}	map[string]string
+// @Failure		401		{object}	map[string]string
+// @Failure		422		{object}	map[string]string
+// @Failure		500		{object}	map[string]string
+// @Router			/auth/refresh [post]
+func (h *Handler) RefreshHandler(w http.ResponseWriter, r *http.Request) {
+	input := h.input.NewTokenInput()
+
+	if err := helpers.ReadJSON(w, r, &input); err != nil {
+		errors.BadRequestResponse(w, r)
+		return
+	}
+
+	v := validator.New()
+	validator.TokenInput(v, &input)
+	if !v.Valid() {
+		errors.FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	claims, ok := h.refreshClaims(w, r, input.RefreshToken)
+	if !ok {
+		return
+	}
+
+	user, err := h.users.Get(r.Context(), claims.Subject)
+	if err != nil {
+		if err == h.errors.NoRecordError() {
+			errors.InvalidAuthenticationTokenResponse(w, r)
+		} else {
+			errors.ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// of concurrent requests replaying the token, only the one revoking it
+	// gets a new pair
+	revoked, err := h.auth.Revoke(r.Context(), claims.ID, claims.ExpiresAt())
+	if err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+
+	if !revoked {
+		errors.InvalidAuthenticationTokenResponse(w, r)
+		return
+	}
+
+	h.writeTokens(w, r, user)
+}
+
+// @Summary		Sign out
+// @Description	Revoke the access token the request is made with and, if given, the refresh token
+// @Description	issued with it. Requests authenticated with an API key only revoke the refresh token.
+// @Tags			Auth
+// @Accept			json
+// @Produce		json
+// @Param			token	body		models.TokenInput	false	"Refresh token to revoke as well"
+// @Success		200		{object}	map[string]string
+// @Failure		400		{object}	map[string]string
+// @Failure		401		{object}	map[string]string
+// @Failure		500		{object}	map[string]string
+// @Router			/auth/logout [post]
+func (h *Handler) LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	user := contextGetUser(r)
+	input := h.input.NewTokenInput()
+
+	if r.ContentLength != 0 {
+		if err := helpers.ReadJSON(w, r, &input); err != nil {
+			errors.BadRequestResponse(w, r)
+			return
+		}
+	}
+
+	if input.RefreshToken != "" {
+		claims, ok := h.refreshClaims(w, r, input.RefreshToken)
+		if !ok {
+			return
+		}
+
+		// a token of someone else is as good as a forged one here
+		if claims.UserID() != user.ID {
+			errors.InvalidAuthenticationTokenResponse(w, r)
+			return
+		}
+
+		if _, err := h.auth.Revoke(r.Context(), claims.ID, claims.ExpiresAt()); err != nil {
+			errors.ServerErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	if claims := contextGetClaims(r); claims != nil {
+		if _, err := h.auth.Revoke(r.Context(), claims.ID, claims.ExpiresAt()); err != nil {
+			errors.ServerErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	if err := helpers.WriteJSON(w, http.StatusOK, map[string]interface{}{"status": "OK"}, nil); err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+}
+
+// @Summary		Get current user
+// @Description	Get the user the request is authenticated as
+// @Tags			Auth
+// @Accept			json
+// @Produce		json
+// @Success		200	{object}	map[string]interface{}
+// @Failure		401	{object}	map[string]string
+// @Failure		500	{object}	map[string]string
+// @Router			/auth/me [get]
+func (h *Handler) ShowCurrentUserHandler(w http.ResponseWriter, r *http.Request) {
+	if err := helpers.WriteJSON(w, http.StatusOK, map[string]interface{}{"user": contextGetUser(r)}, nil); err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+}
+
+// @Summary		Change password
+// @Description	Set the current user's password. current_password is required once the user has one.
+// @Description	Every access and refresh token issued to the user before is revoked; sign in again for new ones.
+// @Tags			Auth
+// @Accept			json
+// @Produce		json
+// @Param			password	body		models.PasswordInput	true	"Current and new password"
+// @Success		200			{object}	map[string]string
+// @Failure		400			{object}	map[string]string
+// @Failure		401			{object}	map[string]string
+// @Failure		422			{object}	map[string]string
+// @Failure		500			{object}	map[string]string
+// @Router			/auth/password [put]
+func (h *Handler) ChangePasswordHandler(w http.ResponseWriter, r *http.Request) {
+	user := contextGetUser(r)
+	input := h.input.NewPasswordInput()
+
+	if err := helpers.ReadJSON(w, r, &input); err != nil {
+		errors.BadRequestResponse(w, r)
+		return
+	}
+
+	v := validator.New()
+	validator.PasswordInput(v, &input)
+
+	current, err := h.auth.Password(r.Context(), user.ID)
+	if err != nil {
+		if err == h.errors.NoRecordError() {
+			errors.InvalidAuthenticationTokenResponse(w, r)
+		} else {
+			errors.ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if current != "" {
+		v.Check(input.Current != "", "current_password", "must be provided")
+		v.Check(input.Current == "" || auth.CheckPassword(input.Current, current), "current_password", "is incorrect")
+	}
+
+	if !v.Valid() {
+		errors.FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	hash, err := auth.HashPassword(input.Password)
+	if err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+
+	if err := h.auth.SetPassword(r.Context(), user.ID, hash); err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+
+	if err := helpers.WriteJSON(w, http.StatusOK, map[string]interface{}{"status": "OK"}, nil); err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+}
+
+// @Summary		List API keys
+// @Description	Get the current user's API keys. Only a hint of each key is shown.
+// @Tags			Auth
+// @Accept			json
+// @Produce		json
+// @Success		200	{object}	map[string]interface{}
+// @Failure		401	{object}	map[string]string
+// @Failure		500	{object}	map[string]string
+// @Router			/auth/keys [get]
+func (h *Handler) ShowAPIKeysHandler(w http.ResponseWriter, r *http.Request) {
+	keys, err := h.auth.Keys(r.Context(), contextGetUser(r).ID)
+	if err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+
+	if err := helpers.WriteJSON(w, http.StatusOK, map[string]interface{}{"api_keys": keys}, nil); err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+}
+
+// @Summary		Create API key
+// @Description	Create an API key for the current user. The key is returned once and only its hash is
+// @Description	stored; send it in the X-API-Key header.
+// @Tags			Auth
+// @Accept			json
+// @Produce		json
+// @Param			key	body		models.APIKeyInput	true	"Key name"
+// @Success		201	{object}	map[string]interface{}
+// @Failure		400	{object}	map[string]string
+// @Failure		401	{object}	map[string]string
+// @Failure		422	{object}	map[string]string
+// @Failure		500	{object}	map[string]string
+// @Router			/auth/keys [post]
+func (h *Handler) CreateAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	user := contextGetUser(r)
+	input := h.input.NewAPIKeyInput()
+
+	if err := helpers.ReadJSON(w, r, &input); err != nil {
+		errors.BadRequestResponse(w, r)
+		return
+	}
+
+	v := validator.New()
+	validator.APIKeyInput(v, &input)
+	if !v.Valid() {
+		errors.FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	key, hint, err := auth.NewAPIKey()
+	if err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+
+	id, err := h.auth.InsertKey(r.Context(), user.ID, input.Name, hint, auth.HashKey(key))
+	if err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+
+	data := map[string]interface{}{"id": id, "name": input.Name, "hint": hint, "key": key}
+
+	if err := helpers.WriteJSON(w, http.StatusCreated, data, nil); err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+}
+
+// @Summary		Delete API key
+// @Description	Revoke one of the current user's API keys
+// @Tags			Auth
+// @Accept			json
+// @Produce		json
+// @Param			key_id	path		int	true	"API key ID"
+// @Success		200		{object}	map[string]string
+// @Failure		401		{object}	map[string]string
+// @Failure		404		{object}	map[string]string
+// @Failure		500		{object}	map[string]string
+// @Router			/auth/keys/{key_id} [delete]
+func (h *Handler) DeleteAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	keyID, _ := strconv.Atoi(mux.Vars(r)["key_id"])
+
+	err := h.auth.DeleteKey(r.Context(), contextGetUser(r).ID, keyID)
+	if err != nil {
+		if err == h.errors.NoRecordError() {
+			errors.NotFoundResponse(w, r)
+		} else {
+			errors.ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := helpers.WriteJSON(w, http.StatusOK, map[string]interface{}{"status": "OK"}, nil); err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+}
+
+// refreshClaims verifies a refresh token and answers 401 for tokens that
+// are invalid, expired or already revoked, including those issued before
+// the user's last password change.
+func (h *Handler) refreshClaims(w http.ResponseWriter, r *http.Request, token string) (*auth.Claims, bool) {
+	claims, err := h.tokens.Parse(token, auth.RefreshToken)
+	if err != nil {
+		errors.InvalidAuthenticationTokenResponse(w, r)
+		return nil, false
+	}
+
+	revoked, err := h.auth.Revoked(r.Context(), claims.ID, claims.UserID(), claims.Generation)
+	if err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return nil, false
+	}
+
+	if revoked {
+		errors.InvalidAuthenticationTokenResponse(w, r)
+		return nil, false
+	}
+
+	return claims, true
+}
+
+// writeTokens answers with a fresh access and refresh token for the user.
+func (h *Handler) writeTokens(w http.ResponseWriter, r *http.Request, user *models.User) {
+	generation, err := h.auth.TokenGeneration(r.Context(), user.ID)
+	if err != nil {
+		if err == h.errors.NoRecordError() {
+			errors.InvalidCredentialsResponse(w, r)
+		} else {
+			errors.ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	access, claims, err := h.tokens.Issue(user.ID, generation, auth.AccessToken)
+	if err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+
+	refresh, _, err := h.tokens.Issue(user.ID, generation, auth.RefreshToken)
+	if err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+
+	data := map[string]interface{}{
+		"access_token":  access,
+		"refresh_token": refresh,
+		"token_type":    "Bearer",
+		"expires_in":    claims.Expires - claims.IssuedAt,
+	}
+
+	headers := make(http.Header)
+	headers.Set("Cache-Control", "no-store")
+
+	if err := helpers.WriteJSON(w, http.StatusOK, data, headers); err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+}
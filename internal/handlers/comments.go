@@ -0,0 +1,364 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"pm-service/internal/handlers/errors"
+	"pm-service/internal/repository/models"
+	"pm-service/internal/service/helpers"
+	"pm-service/internal/service/validator"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// @Summary		List task comments
+// @Description	Get a page of a task's top-level comments, each with its replies nested in Replies in
+// @Description	the order they were written
+// @Tags			Comments
+// @Accept			json
+// @Produce		json
+// @Param			id			path		int		true	"Task ID"
+// @Param			page		query		int		false	"Page number (default 1)"
+// @Param			page_size	query		int		false	"Page size (default 20, max 100)"
+// @Param			sort		query		string	false	"Comma-separated sort fields, prefix with - for descending"
+// @Success		200			{object}	map[string]interface{}
+// @Failure		404			{object}	map[string]string
+// @Failure		422			{object}	map[string]string
+// @Failure		500			{object}	map[string]string
+// @Router			/tasks/{id}/comments [get]
+func (h *Handler) ShowTaskCommentsHandler(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	_, err := h.tasks.Get(r.Context(), id)
+	if err != nil {
+		if err == h.errors.NoRecordError() {
+			errors.NotFoundResponse(w, r)
+		} else {
+			errors.ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	v := validator.New()
+	filters := readFilters(r, v, commentSortSafelist)
+	if !v.Valid() {
+		errors.FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	comments, metadata, err := h.comments.Thread(r.Context(), id, filters)
+	if err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+
+	if err := helpers.WriteJSON(w, http.StatusOK, map[string]interface{}{"comments": comments, "metadata": metadata}, nil); err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+}
+
+// @Summary		Comment on a task
+// @Description	Add a comment to a task, or a reply when parent_id names another comment on the same task.
+// @Description	Users mentioned as @name or @email are recorded in Mentions.
+// @Tags			Comments
+// @Accept			json
+// @Produce		json
+// @Param			id		path		int					true	"Task ID"
+// @Param			comment	body		models.CommentInput	true	"Comment"
+// @Success		201		{object}	map[string]string
+// @Failure		400		{object}	map[string]string
+// @Failure		403		{object}	map[string]string
+// @Failure		404		{object}	map[string]string
+// @Failure		422		{object}	map[string]string
+// @Failure		500		{object}	map[string]string
+// @Router			/tasks/{id}/comments [post]
+func (h *Handler) CreateCommentHandler(w http.ResponseWriter, r *http.Request) {
+	task, err := h.tasks.Get(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		if err == h.errors.NoRecordError() {
+			errors.NotFoundResponse(w, r)
+		} else {
+			errors.ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	input := h.input.NewCommentInput()
+
+	if err := helpers.ReadJSON(w, r, &input); err != nil {
+		errors.BadRequestResponse(w, r)
+		return
+	}
+
+	input.TaskID = task.ID
+
+	v, err := h.validateCommentInput(r.Context(), &input)
+	if err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+
+	if !v.Valid() {
+		errors.FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if !authorize(w, r, h.policy.Comment(contextGetUser(r), &input)) {
+		return
+	}
+
+	id, err := h.comments.Insert(r.Context(), &input)
+	if err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+
+	if err := helpers.WriteJSON(w, http.StatusCreated, map[string]interface{}{"id": id}, nil); err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+}
+
+// @Summary		Get comment by ID
+// @Description	Get a comment on a task by its ID
+// @Tags			Comments
+// @Accept			json
+// @Produce		json
+// @Param			id				path		int		true	"Task ID"
+// @Param			comment_id		path		int		true	"Comment ID"
+// @Param			If-None-Match	header		string	false	"ETag from a previous response"
+// @Success		200				{object}	models.Comment
+// @Success		304				"Not modified"
+// @Failure		404				{object}	map[string]string
+// @Failure		500				{object}	map[string]string
+// @Router			/tasks/{id}/comments/{comment_id} [get]
+func (h *Handler) ShowCommentHandler(w http.ResponseWriter, r *http.Request) {
+	_, comment, ok := h.taskComment(w, r)
+	if !ok {
+		return
+	}
+
+	if notModified(w, r, etag(comment.Version)) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(comment)
+}
+
+// @Summary		Edit comment
+// @Description	Replace the text of a comment. The previous text is kept in the comment's history and
+// @Description	mentions are parsed again. Honours If-Match and returns the new ETag.
+// @Tags			Comments
+// @Accept			json
+// @Produce		json
+// @Param			id			path		int					true	"Task ID"
+// @Param			comment_id	path		int					true	"Comment ID"
+// @Param			comment		body		map[string]string	true	"New text, e.g. {\"body\": \"...\"}"
+// @Param			If-Match	header		string				false	"ETag the edit is based on"
+// @Success		200			{object}	map[string]string
+// @Failure		400			{object}	map[string]string
+// @Failure		403			{object}	map[string]string
+// @Failure		404			{object}	map[string]string
+// @Failure		409			{object}	map[string]string
+// @Failure		412			{object}	map[string]string
+// @Failure		422			{object}	map[string]string
+// @Failure		500			{object}	map[string]string
+// @Router			/tasks/{id}/comments/{comment_id} [put]
+func (h *Handler) UpdateCommentHandler(w http.ResponseWriter, r *http.Request) {
+	_, comment, ok := h.taskComment(w, r)
+	if !ok {
+		return
+	}
+
+	if !authorize(w, r, h.policy.EditComment(contextGetUser(r), comment)) {
+		return
+	}
+
+	if !checkIfMatch(w, r, etag(comment.Version)) {
+		return
+	}
+
+	var body struct {
+		Body string `json:"body"`
+	}
+
+	if err := helpers.ReadJSON(w, r, &body); err != nil {
+		errors.BadRequestResponse(w, r)
+		return
+	}
+
+	// only the text can change
+	input := models.CommentInput{TaskID: comment.TaskID, AuthorID: comment.AuthorID, ParentID: comment.ParentID, Body: body.Body}
+
+	v := validator.New()
+	validator.CommentInput(v, &input)
+
+	if !v.Valid() {
+		errors.FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	version, err := h.comments.Update(r.Context(), strconv.Itoa(comment.ID), &input, comment.Version)
+	if err != nil {
+		switch err {
+		case h.errors.EditConflictError():
+			editConflict(w, r)
+		case h.errors.NoRecordError():
+			errors.NotFoundResponse(w, r)
+		default:
+			errors.ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("ETag", etag(version))
+
+	if err := helpers.WriteJSON(w, http.StatusOK, map[string]interface{}{"status": "OK"}, headers); err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+}
+
+// @Summary		Delete comment
+// @Description	Delete a comment together with its replies
+// @Tags			Comments
+// @Accept			json
+// @Produce		json
+// @Param			id			path		int		true	"Task ID"
+// @Param			comment_id	path		int		true	"Comment ID"
+// @Param			If-Match	header		string	false	"ETag the delete is based on"
+// @Success		200			{object}	map[string]string
+// @Failure		403			{object}	map[string]string
+// @Failure		404			{object}	map[string]string
+// @Failure		412			{object}	map[string]string
+// @Failure		500			{object}	map[string]string
+// @Router			/tasks/{id}/comments/{comment_id} [delete]
+func (h *Handler) DeleteCommentHandler(w http.ResponseWriter, r *http.Request) {
+	task, comment, ok := h.taskComment(w, r)
+	if !ok {
+		return
+	}
+
+	if !authorize(w, r, h.policy.DeleteComment(r.Context(), contextGetUser(r), comment, task)) {
+		return
+	}
+
+	if !checkIfMatch(w, r, etag(comment.Version)) {
+		return
+	}
+
+	if err := h.comments.Delete(r.Context(), strconv.Itoa(comment.ID)); err != nil {
+		if err == h.errors.NoRecordError() {
+			errors.NotFoundResponse(w, r)
+		} else {
+			errors.ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := helpers.WriteJSON(w, http.StatusOK, map[string]interface{}{"status": "OK"}, nil); err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+}
+
+// @Summary		Get comment history
+// @Description	Get the earlier texts of an edited comment, oldest first
+// @Tags			Comments
+// @Accept			json
+// @Produce		json
+// @Param			id			path		int	true	"Task ID"
+// @Param			comment_id	path		int	true	"Comment ID"
+// @Success		200			{object}	map[string]interface{}
+// @Failure		404			{object}	map[string]string
+// @Failure		500			{object}	map[string]string
+// @Router			/tasks/{id}/comments/{comment_id}/history [get]
+func (h *Handler) ShowCommentHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	_, comment, ok := h.taskComment(w, r)
+	if !ok {
+		return
+	}
+
+	revisions, err := h.comments.History(r.Context(), strconv.Itoa(comment.ID))
+	if err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+
+	if err := helpers.WriteJSON(w, http.StatusOK, map[string]interface{}{"history": revisions}, nil); err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+}
+
+// @Summary		Get user mentions
+// @Description	Get the comments mentioning a user, newest first
+// @Tags			Comments
+// @Accept			json
+// @Produce		json
+// @Param			id			path		int	true	"User ID"
+// @Param			page		query		int	false	"Page number (default 1)"
+// @Param			page_size	query		int	false	"Page size (default 20, max 100)"
+// @Success		200			{object}	map[string]interface{}
+// @Failure		404			{object}	map[string]string
+// @Failure		422			{object}	map[string]string
+// @Failure		500			{object}	map[string]string
+// @Router			/users/{id}/mentions [get]
+func (h *Handler) ShowUserMentionsHandler(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	_, err := h.users.Get(r.Context(), id)
+	if err != nil {
+		if err == h.errors.NoRecordError() {
+			errors.NotFoundResponse(w, r)
+		} else {
+			errors.ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	v := validator.New()
+	filters := readFilters(r, v, nil)
+	if !v.Valid() {
+		errors.FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	comments, metadata, err := h.comments.Mentions(r.Context(), id, filters)
+	if err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+
+	if err := helpers.WriteJSON(w, http.StatusOK, map[string]interface{}{"comments": comments, "metadata": metadata}, nil); err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+}
+
+// taskComment loads the task and comment named in the URL. It answers 404
+// and returns false unless the comment belongs to the task.
+func (h *Handler) taskComment(w http.ResponseWriter, r *http.Request) (*models.Task, *models.Comment, bool) {
+	vars := mux.Vars(r)
+
+	task, err := h.tasks.Get(r.Context(), vars["id"])
+	if err == nil {
+		var comment *models.Comment
+		comment, err = h.comments.Get(r.Context(), vars["comment_id"])
+		if err == nil && comment.TaskID == task.ID {
+			return task, comment, true
+		}
+	}
+
+	if err == nil || err == h.errors.NoRecordError() {
+		errors.NotFoundResponse(w, r)
+	} else {
+		errors.ServerErrorResponse(w, r, err)
+	}
+
+	return nil, nil, false
+}
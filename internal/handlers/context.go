@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"pm-service/internal/repository/models"
+	"pm-service/internal/service/audit"
+	"pm-service/internal/service/auth"
+)
+
+type contextKey string
+
+const (
+	userContextKey   = contextKey("user")
+	claimsContextKey = contextKey("claims")
+)
+
+// contextSetUser returns a copy of the request carrying the authenticated
+// user and, when they signed in with a token, its claims. The changes the
+// request makes are recorded as the user's.
+func contextSetUser(r *http.Request, user *models.User, claims *auth.Claims) *http.Request {
+	ctx := context.WithValue(audit.WithActor(r.Context(), user.ID), userContextKey, user)
+	if claims != nil {
+		ctx = context.WithValue(ctx, claimsContextKey, claims)
+	}
+	return r.WithContext(ctx)
+}
+
+// contextGetUser returns the authenticated user, nil for anonymous requests.
+func contextGetUser(r *http.Request) *models.User {
+	user, _ := r.Context().Value(userContextKey).(*models.User)
+	return user
+}
+
+// contextGetClaims returns the claims of the access token the request was
+// authenticated with, nil if it used an API key or no credentials.
+func contextGetClaims(r *http.Request) *auth.Claims {
+	claims, _ := r.Context().Value(claimsContextKey).(*auth.Claims)
+	return claims
+}
@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"net/http"
+	"pm-service/internal/handlers/errors"
+	"pm-service/internal/repository/models"
+	"pm-service/internal/service/helpers"
+	"pm-service/internal/service/validator"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// @Summary		Get task dependencies
+// @Description	Get the tasks the task waits for (blocked_by) and the tasks waiting for it (blocks). The
+// @Description	task is blocked while any task it waits for is incomplete.
+// @Tags			Dependencies
+// @Accept			json
+// @Produce		json
+// @Param			id	path		int	true	"Task ID"
+// @Success		200	{object}	map[string]interface{}
+// @Failure		404	{object}	map[string]string
+// @Failure		500	{object}	map[string]string
+// @Router			/tasks/{id}/dependencies [get]
+func (h *Handler) ShowTaskDependenciesHandler(w http.ResponseWriter, r *http.Request) {
+	task, err := h.tasks.Get(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		if err == h.errors.NoRecordError() {
+			errors.NotFoundResponse(w, r)
+		} else {
+			errors.ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	blockers, err := h.dependencies.Blockers(r.Context(), task.ID)
+	if err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+
+	dependents, err := h.dependencies.Dependents(r.Context(), task.ID)
+	if err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+
+	data := map[string]interface{}{"blocked_by": blockers, "blocks": dependents, "blocked": task.Blocked}
+	if err := helpers.WriteJSON(w, http.StatusOK, data, nil); err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+}
+
+// @Summary		Add task dependency
+// @Description	Make the task wait for the task named by blocker_id. Links that would make a task wait
+// @Description	for itself, directly or through other tasks, are rejected.
+// @Tags			Dependencies
+// @Accept			json
+// @Produce		json
+// @Param			id			path		int				true	"Task ID"
+// @Param			dependency	body		map[string]int	true	"Blocking task, e.g. {\"blocker_id\": 2}"
+// @Success		201			{object}	map[string]interface{}
+// @Failure		400			{object}	map[string]string
+// @Failure		403			{object}	map[string]string
+// @Failure		404			{object}	map[string]string
+// @Failure		422			{object}	map[string]string
+// @Failure		500			{object}	map[string]string
+// @Router			/tasks/{id}/dependencies [post]
+func (h *Handler) CreateTaskDependencyHandler(w http.ResponseWriter, r *http.Request) {
+	task, err := h.tasks.Get(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		if err == h.errors.NoRecordError() {
+			errors.NotFoundResponse(w, r)
+		} else {
+			errors.ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if !authorize(w, r, h.policy.ManageProject(r.Context(), contextGetUser(r), task.ProjectID)) {
+		return
+	}
+
+	var body struct {
+		BlockerID int `json:"blocker_id"`
+	}
+
+	if err := helpers.ReadJSON(w, r, &body); err != nil {
+		errors.BadRequestResponse(w, r)
+		return
+	}
+
+	v := validator.New()
+	v.Check(body.BlockerID > 0, "blocker_id", "must be provided")
+	v.Check(body.BlockerID != task.ID, "blocker_id", "must not be the task itself")
+
+	if v.Valid() {
+		_, err := h.tasks.Get(r.Context(), strconv.Itoa(body.BlockerID))
+		if err == h.errors.NoRecordError() {
+			v.AddError("blocker_id", "must reference an existing task")
+		} else if err != nil {
+			errors.ServerErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	if !v.Valid() {
+		errors.FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if err := h.dependencies.Add(r.Context(), task.ID, body.BlockerID); err != nil {
+		if err == h.errors.DependencyCycleError() {
+			v.AddError("blocker_id", "must not wait for the task, directly or through other tasks")
+			errors.FailedValidationResponse(w, r, v.Errors)
+		} else {
+			errors.ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	data := map[string]interface{}{"task_id": task.ID, "blocker_id": body.BlockerID}
+	if err := helpers.WriteJSON(w, http.StatusCreated, data, nil); err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+}
+
+// @Summary		Remove task dependency
+// @Description	Stop the task waiting for the given blocker
+// @Tags			Dependencies
+// @Accept			json
+// @Produce		json
+// @Param			id			path		int	true	"Task ID"
+// @Param			blocker_id	path		int	true	"Blocking task ID"
+// @Success		200			{object}	map[string]string
+// @Failure		403			{object}	map[string]string
+// @Failure		404			{object}	map[string]string
+// @Failure		500			{object}	map[string]string
+// @Router			/tasks/{id}/dependencies/{blocker_id} [delete]
+func (h *Handler) DeleteTaskDependencyHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	task, err := h.tasks.Get(r.Context(), vars["id"])
+	if err != nil {
+		if err == h.errors.NoRecordError() {
+			errors.NotFoundResponse(w, r)
+		} else {
+			errors.ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if !authorize(w, r, h.policy.ManageProject(r.Context(), contextGetUser(r), task.ProjectID)) {
+		return
+	}
+
+	// the route only matches digits
+	blockerID, _ := strconv.Atoi(vars["blocker_id"])
+
+	if err := h.dependencies.Remove(r.Context(), task.ID, blockerID); err != nil {
+		if err == h.errors.NoRecordError() {
+			errors.NotFoundResponse(w, r)
+		} else {
+			errors.ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := helpers.WriteJSON(w, http.StatusOK, map[string]interface{}{"status": "OK"}, nil); err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+}
+
+// @Summary		Get critical path
+// @Description	Get the chain of dependent tasks in the project with the largest total estimate, in the
+// @Description	order they must be done. Remaining sums the estimates of its incomplete tasks.
+// @Tags			Dependencies
+// @Accept			json
+// @Produce		json
+// @Param			id	path		int	true	"Project ID"
+// @Success		200	{object}	map[string]interface{}
+// @Failure		404	{object}	map[string]string
+// @Failure		500	{object}	map[string]string
+// @Router			/projects/{id}/critical-path [get]
+func (h *Handler) ShowCriticalPathHandler(w http.ResponseWriter, r *http.Request) {
+	project, err := h.projects.Get(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		if err == h.errors.NoRecordError() {
+			errors.NotFoundResponse(w, r)
+		} else {
+			errors.ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	tasks, links, err := h.dependencies.Graph(r.Context(), project.ID)
+	if err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+
+	path, estimate := models.CriticalPath(tasks, links)
+
+	remaining := 0
+	for _, s := range path {
+		if s.Completed == nil {
+			remaining += s.Estimate
+		}
+	}
+
+	data := map[string]interface{}{"critical_path": path, "estimate": estimate, "remaining": remaining}
+	if err := helpers.WriteJSON(w, http.StatusOK, data, nil); err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"context"
+	"pm-service/internal/repository/models"
+	"strconv"
+)
+
+// blockingFilters caps how many blocking records are listed in a 409 response.
+var blockingFilters = models.Filters{Page: 1, PageSize: models.MaxPageSize}
+
+// userDependents lists the tasks assigned to and projects managed by the
+// user, which keep the user from being deleted.
+func (h *Handler) userDependents(ctx context.Context, id string) (map[string]interface{}, error) {
+	tasks, _, err := h.tasks.GetAllBy(ctx, "assignee_id", id, blockingFilters)
+	if err != nil {
+		return nil, err
+	}
+
+	projects, _, err := h.projects.GetAllBy(ctx, "manager_id", id, blockingFilters)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(tasks) == 0 && len(projects) == 0 {
+		return nil, nil
+	}
+
+	return map[string]interface{}{
+		"message":  "the user is still referenced; delete with mode=reassign&to=ID to hand over their work",
+		"tasks":    taskIDs(tasks),
+		"projects": projectIDs(projects),
+	}, nil
+}
+
+// projectDependents lists the tasks that keep the project from being deleted.
+func (h *Handler) projectDependents(ctx context.Context, id string) (map[string]interface{}, error) {
+	tasks, _, err := h.tasks.GetAllBy(ctx, "project_id", id, blockingFilters)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(tasks) == 0 {
+		return nil, nil
+	}
+
+	return map[string]interface{}{
+		"message": "the project still has tasks; delete with mode=cascade to remove them as well",
+		"tasks":   taskIDs(tasks),
+	}, nil
+}
+
+// taskDependents lists the subtasks that keep the task from being deleted.
+func (h *Handler) taskDependents(ctx context.Context, id string) (map[string]interface{}, error) {
+	tasks, _, err := h.tasks.GetAllBy(ctx, "parent_task_id", id, blockingFilters)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(tasks) == 0 {
+		return nil, nil
+	}
+
+	return map[string]interface{}{
+		"message": "the task still has subtasks; delete them or move them to another parent first",
+		"tasks":   taskIDs(tasks),
+	}, nil
+}
+
+// memberDependents lists what keeps the user's membership of the project
+// from being removed, when role is empty, or from changing to role: the
+// project the user manages and the tasks assigned to them there.
+func (h *Handler) memberDependents(ctx context.Context, project *models.Project, userID int, role string) (map[string]interface{}, error) {
+	next := &models.Member{Role: role}
+
+	if project.ManagerID == userID && (role == "" || !next.Manages()) {
+		return map[string]interface{}{
+			"message":  "the user manages the project; hand it to another owner or manager first",
+			"projects": []int{project.ID},
+		}, nil
+	}
+
+	if role != "" && next.Assignable() {
+		return nil, nil
+	}
+
+	criteria := models.Criteria{
+		{Field: "project_id", Op: models.OpEqual, Values: []string{strconv.Itoa(project.ID)}},
+		{Field: "assignee_id", Op: models.OpEqual, Values: []string{strconv.Itoa(userID)}},
+	}
+
+	tasks, _, err := h.tasks.Search(ctx, criteria, blockingFilters)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(tasks) == 0 {
+		return nil, nil
+	}
+
+	return map[string]interface{}{
+		"message": "the user is still assigned tasks in the project; reassign them first",
+		"tasks":   taskIDs(tasks),
+	}, nil
+}
+
+func taskIDs(tasks []*models.Task) []int {
+	ids := make([]int, len(tasks))
+	for i, s := range tasks {
+		ids[i] = s.ID
+	}
+	return ids
+}
+
+func projectIDs(projects []*models.Project) []int {
+	ids := make([]int, len(projects))
+	for i, s := range projects {
+		ids[i] = s.ID
+	}
+	return ids
+}
@@ -1,10 +1,16 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
 	"pm-service/internal/repository/mock"
 	"pm-service/internal/repository/models"
 	"pm-service/internal/repository/postgres"
+	"pm-service/internal/service/auth"
+	"pm-service/internal/service/events"
+	"pm-service/internal/service/policy"
+	"pm-service/internal/service/webhook"
+	"time"
 )
 
 type Handler struct {
@@ -12,52 +18,206 @@ type Handler struct {
 		NewUserInput() models.UserInput
 		NewTaskInput() models.TaskInput
 		NewProjectInput() models.ProjectInput
+		NewCommentInput() models.CommentInput
+		NewLabelInput() models.LabelInput
+		NewMemberInput() models.MemberInput
+		NewAPIKeyInput() models.APIKeyInput
+		NewLoginInput() models.LoginInput
+		NewTokenInput() models.TokenInput
+		NewPasswordInput() models.PasswordInput
+		NewWebhookInput() models.WebhookInput
 	}
 	errors interface {
 		NoRecordError() error
+		ReferencedError() error
+		TrashedReferenceError() error
+		EditConflictError() error
+		IllegalTransitionError() error
+		OpenSubtasksError() error
+		DependencyCycleError() error
+		DuplicateNameError() error
 	}
 	users interface {
-		Insert(*models.UserInput) (int, error)
-		Get(string) (*models.User, error)
-		Delete(string) error
-		Update(string, *models.UserInput) error
-		GetAll() ([]*models.User, error)
-		GetAllBy(string, string) ([]*models.User, error)
+		Insert(context.Context, *models.UserInput) (int, error)
+		Get(context.Context, string) (*models.User, error)
+		Delete(context.Context, string) error
+		DeleteReassign(context.Context, string, string) error
+		Restore(context.Context, string) error
+		Update(context.Context, string, *models.UserInput, int) (int, error)
+		Patch(context.Context, string, *models.UserInput, []string, int) (int, error)
+		GetAll(context.Context, models.Filters) ([]*models.User, models.Metadata, error)
+		GetAllBy(context.Context, string, string, models.Filters) ([]*models.User, models.Metadata, error)
+		Search(context.Context, models.Criteria, models.Filters) ([]*models.User, models.Metadata, error)
 	}
 	projects interface {
-		Insert(*models.ProjectInput) (int, error)
-		Get(string) (*models.Project, error)
-		Delete(string) error
-		Update(string, *models.ProjectInput) error
-		GetAll() ([]*models.Project, error)
-		GetAllBy(string, string) ([]*models.Project, error)
+		Insert(context.Context, *models.ProjectInput) (int, error)
+		Get(context.Context, string) (*models.Project, error)
+		Delete(context.Context, string) error
+		DeleteCascade(context.Context, string) error
+		Restore(context.Context, string) error
+		Update(context.Context, string, *models.ProjectInput, int) (int, error)
+		Patch(context.Context, string, *models.ProjectInput, []string, int) (int, error)
+		GetAll(context.Context, models.Filters) ([]*models.Project, models.Metadata, error)
+		GetAllBy(context.Context, string, string, models.Filters) ([]*models.Project, models.Metadata, error)
+		Search(context.Context, models.Criteria, models.Filters) ([]*models.Project, models.Metadata, error)
 	}
 	tasks interface {
-		Insert(*models.TaskInput) (int, error)
-		Get(string) (*models.Task, error)
-		Delete(string) error
-		Update(string, *models.TaskInput) error
-		GetAll() ([]*models.Task, error)
-		GetAllBy(string, string) ([]*models.Task, error)
+		Insert(context.Context, *models.TaskInput) (int, error)
+		Get(context.Context, string) (*models.Task, error)
+		Delete(context.Context, string) error
+		Restore(context.Context, string) error
+		Update(context.Context, string, *models.TaskInput, int) (int, error)
+		Patch(context.Context, string, *models.TaskInput, []string, int) (int, error)
+		Tree(context.Context, string) ([]*models.Task, error)
+		GetAll(context.Context, models.Filters) ([]*models.Task, models.Metadata, error)
+		GetAllBy(context.Context, string, string, models.Filters) ([]*models.Task, models.Metadata, error)
+		Search(context.Context, models.Criteria, models.Filters) ([]*models.Task, models.Metadata, error)
 	}
+	search interface {
+		FullText(context.Context, string, []string, models.Filters) ([]*models.SearchResult, models.Metadata, error)
+	}
+	trash interface {
+		List(context.Context, []string, models.Filters) ([]*models.TrashItem, models.Metadata, error)
+	}
+	workflows interface {
+		Get(context.Context, int) (*models.Workflow, error)
+		Set(context.Context, int, *models.Workflow) error
+		States(context.Context) ([]string, error)
+		StatusesInUse(context.Context, int) ([]string, error)
+	}
+	comments interface {
+		Insert(context.Context, *models.CommentInput) (int, error)
+		Get(context.Context, string) (*models.Comment, error)
+		Update(context.Context, string, *models.CommentInput, int) (int, error)
+		Delete(context.Context, string) error
+		Thread(context.Context, string, models.Filters) ([]*models.Comment, models.Metadata, error)
+		History(context.Context, string) ([]*models.CommentRevision, error)
+		Mentions(context.Context, string, models.Filters) ([]*models.Comment, models.Metadata, error)
+	}
+	dependencies interface {
+		Add(context.Context, int, int) error
+		Remove(context.Context, int, int) error
+		Blockers(context.Context, int) ([]*models.Task, error)
+		Dependents(context.Context, int) ([]*models.Task, error)
+		Graph(context.Context, int) ([]*models.Task, []models.Dependency, error)
+	}
+	labels interface {
+		Insert(context.Context, *models.LabelInput) (int, error)
+		Get(context.Context, string) (*models.Label, error)
+		Update(context.Context, string, *models.LabelInput, int) (int, error)
+		Delete(context.Context, string) error
+		GetAllBy(context.Context, string, string, models.Filters) ([]*models.Label, models.Metadata, error)
+		Attach(context.Context, int, int) error
+		Detach(context.Context, int, int) error
+		OfTask(context.Context, int) ([]*models.Label, error)
+	}
+	members interface {
+		Get(context.Context, int, int) (*models.Member, error)
+		Set(context.Context, int, int, string) error
+		Remove(context.Context, int, int) error
+		List(context.Context, int, models.Filters) ([]*models.Member, models.Metadata, error)
+		OfUser(context.Context, int, models.Filters) ([]*models.Membership, models.Metadata, error)
+	}
+	auth interface {
+		Credentials(context.Context, string) (*models.User, string, error)
+		Password(context.Context, int) (string, error)
+		SetPassword(context.Context, int, string) error
+		InsertKey(context.Context, int, string, string, string) (int, error)
+		Keys(context.Context, int) ([]*models.APIKey, error)
+		DeleteKey(context.Context, int, int) error
+		KeyOwner(context.Context, string) (*models.User, error)
+		TokenGeneration(context.Context, int) (int, error)
+		Revoke(context.Context, string, time.Time) (bool, error)
+		Revoked(context.Context, string, int, int) (bool, error)
+	}
+	audit interface {
+		Search(context.Context, models.Criteria, models.Filters) ([]*models.AuditEvent, models.Metadata, error)
+	}
+	webhooks interface {
+		Insert(context.Context, *models.WebhookInput) (int, error)
+		Get(context.Context, string) (*models.Webhook, error)
+		GetAll(context.Context, models.Filters) ([]*models.Webhook, models.Metadata, error)
+		Update(context.Context, string, *models.WebhookInput, int) (int, error)
+		Delete(context.Context, string) error
+		Deliveries(context.Context, int, models.Criteria, models.Filters) ([]*models.Delivery, models.Metadata, error)
+		Delivery(context.Context, int, int) (*models.Delivery, error)
+		Redeliver(context.Context, int, int) error
+	}
+	tokens *auth.Tokens
+	policy *policy.Policy
 }
 
-func New(db *sql.DB) *Handler {
+// MockAPIKey signs requests to a Mock handler in as an administrator who
+// is not one of the mock's users, so fixtures keep their ids.
+const MockAPIKey = auth.KeyPrefix + "mock"
+
+func New(db *sql.DB, queryTimeout time.Duration, tokens *auth.Tokens) *Handler {
+	projects := &postgres.ProjectModel{DB: db, Timeout: queryTimeout}
+	members := &postgres.MemberModel{DB: db, Timeout: queryTimeout}
+
 	return &Handler{
 		&models.Input{},
 		&models.Errors{},
-		&postgres.UserModel{DB: db},
-		&postgres.ProjectModel{DB: db},
-		&postgres.TaskModel{DB: db},
+		&postgres.UserModel{DB: db, Timeout: queryTimeout},
+		projects,
+		&postgres.TaskModel{DB: db, Timeout: queryTimeout},
+		&postgres.SearchModel{DB: db, Timeout: queryTimeout},
+		&postgres.TrashModel{DB: db, Timeout: queryTimeout},
+		&postgres.WorkflowModel{DB: db, Timeout: queryTimeout},
+		&postgres.CommentModel{DB: db, Timeout: queryTimeout},
+		&postgres.DependencyModel{DB: db, Timeout: queryTimeout},
+		&postgres.LabelModel{DB: db, Timeout: queryTimeout},
+		members,
+		&postgres.AuthModel{DB: db, Timeout: queryTimeout},
+		&postgres.AuditModel{DB: db, Timeout: queryTimeout},
+		&postgres.WebhookModel{DB: db, Timeout: queryTimeout},
+		tokens,
+		&policy.Policy{Projects: projects, Members: members},
 	}
 }
 
 func Mock() *Handler {
+	tasks := &mock.TaskModel{DB: make([]*models.Task, 0)}
+	projects := &mock.ProjectModel{DB: make([]*models.Project, 0), Tasks: tasks}
+	users := &mock.UserModel{DB: make([]*models.User, 0), Tasks: tasks, Projects: projects}
+	dependencies := &mock.DependencyModel{Tasks: tasks}
+	labels := &mock.LabelModel{Tasks: tasks}
+	members := &mock.MemberModel{Users: users, Projects: projects}
+	tasks.Users, tasks.Projects, tasks.Dependencies, tasks.Labels, projects.Users = users, projects, dependencies, labels, users
+	projects.Members, users.Members = members, members
+	workflows := &mock.WorkflowModel{Tasks: tasks}
+	comments := &mock.CommentModel{Users: users, Tasks: tasks}
+
+	accounts := &mock.AuthModel{Users: users}
+	accounts.Grant(&models.User{Name: "admin", Role: "admin", Created: time.Now(), Version: 1}, auth.HashKey(MockAPIKey))
+
+	webhooks := &mock.WebhookModel{}
+
+	bus := &events.Bus{}
+	bus.Subscribe(events.Recorded{}.Name(), webhook.Enqueue(webhooks))
+	outbox := &mock.OutboxModel{Bus: bus}
+
+	audit := &mock.AuditModel{Users: users, Projects: projects, Tasks: tasks, Comments: comments, Labels: labels, Members: members, Dependencies: dependencies, Workflows: workflows, Auth: accounts, Webhooks: webhooks, Outbox: outbox}
+	users.Audit, projects.Audit, tasks.Audit, comments.Audit, labels.Audit = audit, audit, audit, audit, audit
+	members.Audit, dependencies.Audit, workflows.Audit, accounts.Audit, webhooks.Audit = audit, audit, audit, audit, audit
+
 	return &Handler{
 		&models.Input{},
 		&models.Errors{},
-		&mock.UserModel{DB: make([]*models.User, 0)},
-		&mock.ProjectModel{DB: make([]*models.Project, 0)},
-		&mock.TaskModel{DB: make([]*models.Task, 0)},
+		users,
+		projects,
+		tasks,
+		&mock.SearchModel{Tasks: tasks, Projects: projects},
+		&mock.TrashModel{Users: users, Projects: projects, Tasks: tasks},
+		workflows,
+		comments,
+		dependencies,
+		labels,
+		members,
+		accounts,
+		audit,
+		webhooks,
+		&auth.Tokens{Secret: []byte("mock secret, never use outside tests"), AccessTTL: 15 * time.Minute, RefreshTTL: 24 * time.Hour},
+		&policy.Policy{Projects: projects, Members: members},
 	}
 }
@@ -41,3 +41,52 @@ func BadRequestResponse(w http.ResponseWriter, r *http.Request) {
 	message := "invalid request"
 	errorResponse(w, http.StatusBadRequest, message)
 }
+
+func FailedValidationResponse(w http.ResponseWriter, r *http.Request, errors map[string]string) {
+	errorResponse(w, http.StatusUnprocessableEntity, errors)
+}
+
+func ConflictResponse(w http.ResponseWriter, r *http.Request, message interface{}) {
+	errorResponse(w, http.StatusConflict, message)
+}
+
+func EditConflictResponse(w http.ResponseWriter, r *http.Request) {
+	message := "unable to update the record due to an edit conflict, please try again"
+	errorResponse(w, http.StatusConflict, message)
+}
+
+func PreconditionFailedResponse(w http.ResponseWriter, r *http.Request) {
+	message := "the record has been modified since it was retrieved, fetch it again to get the current ETag"
+	errorResponse(w, http.StatusPreconditionFailed, message)
+}
+
+func UnsupportedMediaTypeResponse(w http.ResponseWriter, r *http.Request, message string) {
+	errorResponse(w, http.StatusUnsupportedMediaType, message)
+}
+
+// unauthorizedResponse answers 401, telling the client to authenticate
+// with a bearer token.
+func unauthorizedResponse(w http.ResponseWriter, message string) {
+	w.Header().Set("WWW-Authenticate", "Bearer")
+	errorResponse(w, http.StatusUnauthorized, message)
+}
+
+func InvalidCredentialsResponse(w http.ResponseWriter, r *http.Request) {
+	message := "invalid authentication credentials"
+	unauthorizedResponse(w, message)
+}
+
+func InvalidAuthenticationTokenResponse(w http.ResponseWriter, r *http.Request) {
+	message := "invalid or expired authentication token or API key"
+	unauthorizedResponse(w, message)
+}
+
+func AuthenticationRequiredResponse(w http.ResponseWriter, r *http.Request) {
+	message := "you must be authenticated to access this resource"
+	unauthorizedResponse(w, message)
+}
+
+func NotPermittedResponse(w http.ResponseWriter, r *http.Request) {
+	message := "your user account doesn't have the necessary permissions to access this resource"
+	errorResponse(w, http.StatusForbidden, message)
+}
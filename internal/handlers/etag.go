@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"net/http"
+	"pm-service/internal/handlers/errors"
+	"strconv"
+	"strings"
+)
+
+// etag is the entity tag of a record at the given version.
+func etag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// taskETag is the entity tag of a task. Whether the task is blocked
+// depends on other tasks rather than its version, so it is part of the tag.
+func taskETag(version int, blocked bool) string {
+	if blocked {
+		return `"` + strconv.Itoa(version) + `-blocked"`
+	}
+	return etag(version)
+}
+
+// matchETag reports whether the comma-separated If-Match or If-None-Match
+// header value lists tag or is "*". Weak comparison, used for
+// If-None-Match, ignores the W/ prefix.
+func matchETag(header, tag string, weak bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+
+		if weak {
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+
+		if candidate == tag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkIfMatch sends 412 and returns false when the request carries an
+// If-Match header that does not match the record's current tag.
+func checkIfMatch(w http.ResponseWriter, r *http.Request, tag string) bool {
+	header := r.Header.Get("If-Match")
+	if header == "" || matchETag(header, tag, false) {
+		return true
+	}
+
+	errors.PreconditionFailedResponse(w, r)
+	return false
+}
+
+// notModified answers 304 and returns true when the If-None-Match header
+// matches the record's current tag. The ETag header is set either way.
+func notModified(w http.ResponseWriter, r *http.Request, tag string) bool {
+	w.Header().Set("ETag", tag)
+
+	header := r.Header.Get("If-None-Match")
+	if header == "" || !matchETag(header, tag, true) {
+		return false
+	}
+
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// editConflict reports a version mismatch found while writing: 412 when the
+// client sent If-Match, 409 when the record changed under a plain update.
+func editConflict(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("If-Match") != "" {
+		errors.PreconditionFailedResponse(w, r)
+		return
+	}
+
+	errors.EditConflictResponse(w, r)
+}
@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"net/http"
+	"pm-service/internal/repository/models"
+	"pm-service/internal/service/helpers"
+	"pm-service/internal/service/validator"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Fields each list endpoint may be sorted by through ?sort=field,-field.
+var (
+	userSortSafelist    = []string{"id", "name", "email", "role", "created"}
+	projectSortSafelist = []string{"id", "title", "manager_id", "created", "completed"}
+	taskSortSafelist    = []string{"id", "title", "priority", "status", "assignee_id", "project_id", "estimate", "created", "completed"}
+	commentSortSafelist = []string{"id", "author_id", "created", "edited"}
+	labelSortSafelist   = []string{"id", "name", "created"}
+)
+
+// readFilters parses the page, page_size and sort query parameters and
+// validates them against the given sort safelist. Results are ordered by id
+// when no sort is requested.
+func readFilters(r *http.Request, v *validator.Validator, safelist []string) models.Filters {
+	qs := r.URL.Query()
+
+	filters := models.Filters{
+		Page:         helpers.ReadInt(qs, "page", 1, v),
+		PageSize:     helpers.ReadInt(qs, "page_size", models.DefaultPageSize, v),
+		Sort:         helpers.ReadCSV(qs, "sort", nil),
+		SortSafelist: safelist,
+	}
+
+	validator.Filters(v, filters)
+
+	return filters
+}
+
+type searchKind int
+
+const (
+	textSearch searchKind = iota
+	enumSearch
+	idSearch
+	dateSearch
+	// setSearch, anySearch and allSearch match fields holding several
+	// values per record against one value, any of a comma-separated list
+	// or all of it.
+	setSearch
+	anySearch
+	allSearch
+)
+
+// searchField maps a query parameter onto a repository field. Prefixing a
+// value with "!" negates the condition, and for enum and id fields a
+// comma-separated value matches any of the listed values. Date fields are
+// queried through <param>_from and <param>_to.
+type searchField struct {
+	param     string
+	field     string
+	kind      searchKind
+	permitted []string
+}
+
+var (
+	userSearchFields = []searchField{
+		{"name", "name", textSearch, nil},
+		{"email", "email", textSearch, nil},
+		{"role", "role", enumSearch, models.UserRoles},
+		{"created", "created", dateSearch, nil},
+	}
+	projectSearchFields = []searchField{
+		{"title", "title", textSearch, nil},
+		{"manager", "manager_id", idSearch, nil},
+		{"created", "created", dateSearch, nil},
+		{"completed", "completed", dateSearch, nil},
+	}
+	taskSearchFields = []searchField{
+		{"title", "title", textSearch, nil},
+		// the permitted statuses come from the project workflows, see permit
+		{"status", "status", enumSearch, nil},
+		{"priority", "priority", enumSearch, models.Priorities},
+		{"assignee", "assignee_id", idSearch, nil},
+		{"project", "project_id", idSearch, nil},
+		{"created", "created", dateSearch, nil},
+		{"completed", "completed", dateSearch, nil},
+		{"label", "labels", setSearch, nil},
+		{"label_any", "labels", anySearch, nil},
+		{"label_all", "labels", allSearch, nil},
+	}
+)
+
+// permit returns a copy of fields in which param only accepts the given
+// values, compared case-insensitively.
+func permit(fields []searchField, param string, values []string) []searchField {
+	fields = append([]searchField{}, fields...)
+
+	for i := range fields {
+		if fields[i].param != param {
+			continue
+		}
+
+		fields[i].permitted = make([]string, len(values))
+		for j, val := range values {
+			fields[i].permitted[j] = strings.ToLower(val)
+		}
+	}
+
+	return fields
+}
+
+// readCriteria turns the search query parameters into repository criteria,
+// recording malformed values on the validator.
+func readCriteria(r *http.Request, v *validator.Validator, fields []searchField) models.Criteria {
+	qs := r.URL.Query()
+	criteria := models.Criteria{}
+
+	for _, f := range fields {
+		if f.kind == dateSearch {
+			for _, bound := range []string{"from", "to"} {
+				key := f.param + "_" + bound
+				if qs.Get(key) == "" {
+					continue
+				}
+
+				t, err := models.ParseDate(qs.Get(key))
+				if err != nil {
+					v.AddError(key, "must be a date (YYYY-MM-DD) or an RFC 3339 timestamp")
+					continue
+				}
+
+				c := models.Condition{Field: f.field, Op: models.OpFrom}
+				if bound == "to" {
+					// a bare date includes the whole day
+					if _, err := time.Parse("2006-01-02", qs.Get(key)); err == nil {
+						*t = t.AddDate(0, 0, 1)
+					} else {
+						*t = t.Add(time.Nanosecond)
+					}
+					c.Op = models.OpBefore
+				}
+				c.Values = []string{t.Format(time.RFC3339Nano)}
+
+				criteria = append(criteria, c)
+			}
+			continue
+		}
+
+		raw := qs.Get(f.param)
+		if raw == "" {
+			continue
+		}
+
+		c := models.Condition{Field: f.field}
+		if strings.HasPrefix(raw, "!") {
+			c.Negate = true
+			raw = strings.TrimPrefix(raw, "!")
+		}
+
+		switch f.kind {
+		case textSearch:
+			c.Op = models.OpContains
+			c.Values = []string{raw}
+
+		case enumSearch:
+			c.Op = models.OpEqualFold
+			for _, val := range strings.Split(raw, ",") {
+				val = strings.TrimSpace(val)
+				if f.permitted != nil && !validator.PermittedValue(strings.ToLower(val), f.permitted...) {
+					v.AddError(f.param, "must be one of: "+strings.Join(f.permitted, ", "))
+				}
+				c.Values = append(c.Values, val)
+			}
+
+		case setSearch:
+			c.Op = models.OpHasAny
+			c.Values = []string{strings.TrimSpace(raw)}
+
+		case anySearch, allSearch:
+			c.Op = models.OpHasAny
+			if f.kind == allSearch {
+				c.Op = models.OpHasAll
+			}
+			for _, val := range strings.Split(raw, ",") {
+				c.Values = append(c.Values, strings.TrimSpace(val))
+			}
+
+		case idSearch:
+			c.Op = models.OpEqual
+			for _, val := range strings.Split(raw, ",") {
+				val = strings.TrimSpace(val)
+				if _, err := strconv.Atoi(val); err != nil {
+					v.AddError(f.param, "must be an integer or a comma-separated list of integers")
+				}
+				c.Values = append(c.Values, val)
+			}
+		}
+
+		if c.Values[0] == "" {
+			v.AddError(f.param, "must not be empty")
+		}
+
+		criteria = append(criteria, c)
+	}
+
+	return criteria
+}
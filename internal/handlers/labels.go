@@ -0,0 +1,429 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"pm-service/internal/handlers/errors"
+	"pm-service/internal/repository/models"
+	"pm-service/internal/service/helpers"
+	"pm-service/internal/service/validator"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// @Summary		Get project labels
+// @Description	Get a page of the labels defined in a project
+// @Tags			Labels
+// @Accept			json
+// @Produce		json
+// @Param			id			path		int		true	"Project ID"
+// @Param			page		query		int		false	"Page number (default 1)"
+// @Param			page_size	query		int		false	"Page size (default 20, max 100)"
+// @Param			sort		query		string	false	"Comma-separated sort fields, prefix with - for descending"
+// @Success		200			{object}	map[string]interface{}
+// @Failure		404			{object}	map[string]string
+// @Failure		422			{object}	map[string]string
+// @Failure		500			{object}	map[string]string
+// @Router			/projects/{id}/labels [get]
+func (h *Handler) ShowProjectLabelsHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	filters := readFilters(r, v, labelSortSafelist)
+	if !v.Valid() {
+		errors.FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	project, err := h.projects.Get(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		if err == h.errors.NoRecordError() {
+			errors.NotFoundResponse(w, r)
+		} else {
+			errors.ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	labels, metadata, err := h.labels.GetAllBy(r.Context(), "project_id", strconv.Itoa(project.ID), filters)
+	if err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+
+	if err := helpers.WriteJSON(w, http.StatusOK, map[string]interface{}{"labels": labels, "metadata": metadata}, nil); err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+}
+
+// @Summary		Create label
+// @Description	Define a label in a project. Names are unique within the project, ignoring case.
+// @Tags			Labels
+// @Accept			json
+// @Produce		json
+// @Param			id		path		int					true	"Project ID"
+// @Param			label	body		models.LabelInput	true	"Label"
+// @Success		201		{object}	map[string]string
+// @Failure		400		{object}	map[string]string
+// @Failure		403		{object}	map[string]string
+// @Failure		404		{object}	map[string]string
+// @Failure		422		{object}	map[string]string
+// @Failure		500		{object}	map[string]string
+// @Router			/projects/{id}/labels [post]
+func (h *Handler) CreateLabelHandler(w http.ResponseWriter, r *http.Request) {
+	project, err := h.projects.Get(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		if err == h.errors.NoRecordError() {
+			errors.NotFoundResponse(w, r)
+		} else {
+			errors.ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if !authorize(w, r, h.policy.ManageProject(r.Context(), contextGetUser(r), project.ID)) {
+		return
+	}
+
+	input := h.input.NewLabelInput()
+
+	if err := helpers.ReadJSON(w, r, &input); err != nil {
+		errors.BadRequestResponse(w, r)
+		return
+	}
+
+	input.ProjectID = project.ID
+
+	v := validator.New()
+	validator.LabelInput(v, &input)
+
+	if !v.Valid() {
+		errors.FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	id, err := h.labels.Insert(r.Context(), &input)
+	if err != nil {
+		if err == h.errors.DuplicateNameError() {
+			v.AddError("name", "is already used by another label of the project")
+			errors.FailedValidationResponse(w, r, v.Errors)
+		} else {
+			errors.ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := helpers.WriteJSON(w, http.StatusCreated, map[string]interface{}{"id": id}, nil); err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+}
+
+// @Summary		Get label by ID
+// @Description	Get a label of a project by its ID
+// @Tags			Labels
+// @Accept			json
+// @Produce		json
+// @Param			id				path		int		true	"Project ID"
+// @Param			label_id		path		int		true	"Label ID"
+// @Param			If-None-Match	header		string	false	"ETag from a previous response"
+// @Success		200				{object}	models.Label
+// @Success		304				"Not modified"
+// @Failure		404				{object}	map[string]string
+// @Failure		500				{object}	map[string]string
+// @Router			/projects/{id}/labels/{label_id} [get]
+func (h *Handler) ShowLabelHandler(w http.ResponseWriter, r *http.Request) {
+	label, ok := h.projectLabel(w, r)
+	if !ok {
+		return
+	}
+
+	if notModified(w, r, etag(label.Version)) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(label)
+}
+
+// @Summary		Update label
+// @Description	Rename or recolour a label. Honours If-Match and returns the new ETag.
+// @Tags			Labels
+// @Accept			json
+// @Produce		json
+// @Param			id			path		int					true	"Project ID"
+// @Param			label_id	path		int					true	"Label ID"
+// @Param			label		body		models.LabelInput	true	"Label"
+// @Param			If-Match	header		string				false	"ETag the update is based on"
+// @Success		200			{object}	map[string]string
+// @Failure		400			{object}	map[string]string
+// @Failure		403			{object}	map[string]string
+// @Failure		404			{object}	map[string]string
+// @Failure		409			{object}	map[string]string
+// @Failure		412			{object}	map[string]string
+// @Failure		422			{object}	map[string]string
+// @Failure		500			{object}	map[string]string
+// @Router			/projects/{id}/labels/{label_id} [put]
+func (h *Handler) UpdateLabelHandler(w http.ResponseWriter, r *http.Request) {
+	label, ok := h.projectLabel(w, r)
+	if !ok {
+		return
+	}
+
+	if !authorize(w, r, h.policy.ManageProject(r.Context(), contextGetUser(r), label.ProjectID)) {
+		return
+	}
+
+	if !checkIfMatch(w, r, etag(label.Version)) {
+		return
+	}
+
+	input := h.input.NewLabelInput()
+
+	if err := helpers.ReadJSON(w, r, &input); err != nil {
+		errors.BadRequestResponse(w, r)
+		return
+	}
+
+	input.ProjectID = label.ProjectID
+
+	v := validator.New()
+	validator.LabelInput(v, &input)
+
+	if !v.Valid() {
+		errors.FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	version, err := h.labels.Update(r.Context(), strconv.Itoa(label.ID), &input, label.Version)
+	if err != nil {
+		switch err {
+		case h.errors.EditConflictError():
+			editConflict(w, r)
+		case h.errors.NoRecordError():
+			errors.NotFoundResponse(w, r)
+		case h.errors.DuplicateNameError():
+			v.AddError("name", "is already used by another label of the project")
+			errors.FailedValidationResponse(w, r, v.Errors)
+		default:
+			errors.ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("ETag", etag(version))
+
+	if err := helpers.WriteJSON(w, http.StatusOK, map[string]interface{}{"status": "OK"}, headers); err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+}
+
+// @Summary		Delete label
+// @Description	Delete a label and take it off all of its tasks
+// @Tags			Labels
+// @Accept			json
+// @Produce		json
+// @Param			id			path		int		true	"Project ID"
+// @Param			label_id	path		int		true	"Label ID"
+// @Param			If-Match	header		string	false	"ETag the delete is based on"
+// @Success		200			{object}	map[string]string
+// @Failure		403			{object}	map[string]string
+// @Failure		404			{object}	map[string]string
+// @Failure		412			{object}	map[string]string
+// @Failure		500			{object}	map[string]string
+// @Router			/projects/{id}/labels/{label_id} [delete]
+func (h *Handler) DeleteLabelHandler(w http.ResponseWriter, r *http.Request) {
+	label, ok := h.projectLabel(w, r)
+	if !ok {
+		return
+	}
+
+	if !authorize(w, r, h.policy.ManageProject(r.Context(), contextGetUser(r), label.ProjectID)) {
+		return
+	}
+
+	if !checkIfMatch(w, r, etag(label.Version)) {
+		return
+	}
+
+	if err := h.labels.Delete(r.Context(), strconv.Itoa(label.ID)); err != nil {
+		if err == h.errors.NoRecordError() {
+			errors.NotFoundResponse(w, r)
+		} else {
+			errors.ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := helpers.WriteJSON(w, http.StatusOK, map[string]interface{}{"status": "OK"}, nil); err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+}
+
+// @Summary		Get task labels
+// @Description	Get the labels on a task, ordered by name
+// @Tags			Labels
+// @Accept			json
+// @Produce		json
+// @Param			id	path		int	true	"Task ID"
+// @Success		200	{object}	map[string]interface{}
+// @Failure		404	{object}	map[string]string
+// @Failure		500	{object}	map[string]string
+// @Router			/tasks/{id}/labels [get]
+func (h *Handler) ShowTaskLabelsHandler(w http.ResponseWriter, r *http.Request) {
+	task, err := h.tasks.Get(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		if err == h.errors.NoRecordError() {
+			errors.NotFoundResponse(w, r)
+		} else {
+			errors.ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	labels, err := h.labels.OfTask(r.Context(), task.ID)
+	if err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+
+	if err := helpers.WriteJSON(w, http.StatusOK, map[string]interface{}{"labels": labels}, nil); err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+}
+
+// @Summary		Label task
+// @Description	Put a label of the task's project on the task. Labelling a task twice has no effect.
+// @Tags			Labels
+// @Accept			json
+// @Produce		json
+// @Param			id			path		int	true	"Task ID"
+// @Param			label_id	path		int	true	"Label ID"
+// @Success		200			{object}	map[string]interface{}
+// @Failure		403			{object}	map[string]string
+// @Failure		404			{object}	map[string]string
+// @Failure		422			{object}	map[string]string
+// @Failure		500			{object}	map[string]string
+// @Router			/tasks/{id}/labels/{label_id} [put]
+func (h *Handler) AttachLabelHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	task, err := h.tasks.Get(r.Context(), vars["id"])
+	if err != nil {
+		if err == h.errors.NoRecordError() {
+			errors.NotFoundResponse(w, r)
+		} else {
+			errors.ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if !authorize(w, r, h.policy.ManageProject(r.Context(), contextGetUser(r), task.ProjectID)) {
+		return
+	}
+
+	label, err := h.labels.Get(r.Context(), vars["label_id"])
+	if err != nil {
+		if err == h.errors.NoRecordError() {
+			errors.NotFoundResponse(w, r)
+		} else {
+			errors.ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if label.ProjectID != task.ProjectID {
+		errors.FailedValidationResponse(w, r, map[string]string{"label_id": "must reference a label of the task's project"})
+		return
+	}
+
+	if err := h.labels.Attach(r.Context(), task.ID, label.ID); err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+
+	labels, err := h.labels.OfTask(r.Context(), task.ID)
+	if err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+
+	if err := helpers.WriteJSON(w, http.StatusOK, map[string]interface{}{"labels": labels}, nil); err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+}
+
+// @Summary		Unlabel task
+// @Description	Take a label off a task
+// @Tags			Labels
+// @Accept			json
+// @Produce		json
+// @Param			id			path		int	true	"Task ID"
+// @Param			label_id	path		int	true	"Label ID"
+// @Success		200			{object}	map[string]string
+// @Failure		403			{object}	map[string]string
+// @Failure		404			{object}	map[string]string
+// @Failure		500			{object}	map[string]string
+// @Router			/tasks/{id}/labels/{label_id} [delete]
+func (h *Handler) DetachLabelHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	task, err := h.tasks.Get(r.Context(), vars["id"])
+	if err != nil {
+		if err == h.errors.NoRecordError() {
+			errors.NotFoundResponse(w, r)
+		} else {
+			errors.ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if !authorize(w, r, h.policy.ManageProject(r.Context(), contextGetUser(r), task.ProjectID)) {
+		return
+	}
+
+	// the route only matches digits
+	labelID, _ := strconv.Atoi(vars["label_id"])
+
+	if err := h.labels.Detach(r.Context(), task.ID, labelID); err != nil {
+		if err == h.errors.NoRecordError() {
+			errors.NotFoundResponse(w, r)
+		} else {
+			errors.ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := helpers.WriteJSON(w, http.StatusOK, map[string]interface{}{"status": "OK"}, nil); err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+}
+
+// projectLabel loads the label named in the URL, answering 404 unless both
+// the project and the label exist and the label belongs to the project.
+func (h *Handler) projectLabel(w http.ResponseWriter, r *http.Request) (*models.Label, bool) {
+	vars := mux.Vars(r)
+
+	project, err := h.projects.Get(r.Context(), vars["id"])
+	if err == nil {
+		var label *models.Label
+		label, err = h.labels.Get(r.Context(), vars["label_id"])
+		if err == nil && label.ProjectID == project.ID {
+			return label, true
+		}
+	}
+
+	if err == nil || err == h.errors.NoRecordError() {
+		errors.NotFoundResponse(w, r)
+	} else {
+		errors.ServerErrorResponse(w, r, err)
+	}
+
+	return nil, false
+}
@@ -0,0 +1,315 @@
+package handlers
+
+import (
+	"net/http"
+	"pm-service/internal/handlers/errors"
+	"pm-service/internal/repository/models"
+	"pm-service/internal/service/helpers"
+	"pm-service/internal/service/validator"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// @Summary		Get project members
+// @Description	Get a page of the members of a project, strongest role first
+// @Tags			Members
+// @Accept			json
+// @Produce		json
+// @Param			id			path		int	true	"Project ID"
+// @Param			page		query		int	false	"Page number (default 1)"
+// @Param			page_size	query		int	false	"Page size (default 20, max 100)"
+// @Success		200			{object}	map[string]interface{}
+// @Failure		404			{object}	map[string]string
+// @Failure		422			{object}	map[string]string
+// @Failure		500			{object}	map[string]string
+// @Router			/projects/{id}/members [get]
+func (h *Handler) ShowProjectMembersHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	filters := readFilters(r, v, nil)
+	if !v.Valid() {
+		errors.FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	project, err := h.projects.Get(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		if err == h.errors.NoRecordError() {
+			errors.NotFoundResponse(w, r)
+		} else {
+			errors.ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	members, metadata, err := h.members.List(r.Context(), project.ID, filters)
+	if err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+
+	if err := helpers.WriteJSON(w, http.StatusOK, map[string]interface{}{"members": members, "metadata": metadata}, nil); err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+}
+
+// @Summary		Add project member
+// @Description	Add a user to a project as owner, manager, member or viewer. Only members other than
+// @Description	viewers can be assigned the project's tasks.
+// @Tags			Members
+// @Accept			json
+// @Produce		json
+// @Param			id		path		int					true	"Project ID"
+// @Param			member	body		models.MemberInput	true	"Member"
+// @Success		201		{object}	map[string]interface{}
+// @Failure		400		{object}	map[string]string
+// @Failure		403		{object}	map[string]string
+// @Failure		404		{object}	map[string]string
+// @Failure		422		{object}	map[string]string
+// @Failure		500		{object}	map[string]string
+// @Router			/projects/{id}/members [post]
+func (h *Handler) AddProjectMemberHandler(w http.ResponseWriter, r *http.Request) {
+	project, err := h.projects.Get(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		if err == h.errors.NoRecordError() {
+			errors.NotFoundResponse(w, r)
+		} else {
+			errors.ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if !authorize(w, r, h.policy.ManageProject(r.Context(), contextGetUser(r), project.ID)) {
+		return
+	}
+
+	input := h.input.NewMemberInput()
+
+	if err := helpers.ReadJSON(w, r, &input); err != nil {
+		errors.BadRequestResponse(w, r)
+		return
+	}
+
+	v := validator.New()
+	validator.MemberInput(v, &input)
+
+	if err := h.checkUserExists(r.Context(), v, "user_id", input.UserID); err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+
+	if _, invalid := v.Errors["user_id"]; !invalid {
+		_, err := h.members.Get(r.Context(), project.ID, input.UserID)
+		if err != nil && err != h.errors.NoRecordError() {
+			errors.ServerErrorResponse(w, r, err)
+			return
+		}
+		v.Check(err != nil, "user_id", "is already a member of the project")
+	}
+
+	if !v.Valid() {
+		errors.FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	h.writeMember(w, r, http.StatusCreated, project.ID, input.UserID, input.Role)
+}
+
+// @Summary		Change member role
+// @Description	Change the role of a project member. The project's manager must stay an owner or
+// @Description	manager, and a member assigned tasks in the project cannot become a viewer.
+// @Tags			Members
+// @Accept			json
+// @Produce		json
+// @Param			id		path		int					true	"Project ID"
+// @Param			user_id	path		int					true	"User ID"
+// @Param			member	body		map[string]string	true	"New role, e.g. {\"role\": \"manager\"}"
+// @Success		200		{object}	map[string]interface{}
+// @Failure		400		{object}	map[string]string
+// @Failure		403		{object}	map[string]string
+// @Failure		404		{object}	map[string]string
+// @Failure		409		{object}	map[string]string
+// @Failure		422		{object}	map[string]string
+// @Failure		500		{object}	map[string]string
+// @Router			/projects/{id}/members/{user_id} [put]
+func (h *Handler) UpdateProjectMemberHandler(w http.ResponseWriter, r *http.Request) {
+	project, member, ok := h.projectMember(w, r)
+	if !ok {
+		return
+	}
+
+	if !authorize(w, r, h.policy.ManageProject(r.Context(), contextGetUser(r), project.ID)) {
+		return
+	}
+
+	var body struct {
+		Role string `json:"role"`
+	}
+
+	if err := helpers.ReadJSON(w, r, &body); err != nil {
+		errors.BadRequestResponse(w, r)
+		return
+	}
+
+	input := models.MemberInput{UserID: member.UserID, Role: body.Role}
+
+	v := validator.New()
+	validator.MemberInput(v, &input)
+
+	if !v.Valid() {
+		errors.FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	blocking, err := h.memberDependents(r.Context(), project, member.UserID, input.Role)
+	if err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+
+	if blocking != nil {
+		errors.ConflictResponse(w, r, blocking)
+		return
+	}
+
+	h.writeMember(w, r, http.StatusOK, project.ID, member.UserID, input.Role)
+}
+
+// @Summary		Remove project member
+// @Description	Remove a user from a project. The project's manager and members still assigned tasks
+// @Description	in the project cannot be removed.
+// @Tags			Members
+// @Accept			json
+// @Produce		json
+// @Param			id		path		int	true	"Project ID"
+// @Param			user_id	path		int	true	"User ID"
+// @Success		200		{object}	map[string]string
+// @Failure		403		{object}	map[string]string
+// @Failure		404		{object}	map[string]string
+// @Failure		409		{object}	map[string]string
+// @Failure		500		{object}	map[string]string
+// @Router			/projects/{id}/members/{user_id} [delete]
+func (h *Handler) RemoveProjectMemberHandler(w http.ResponseWriter, r *http.Request) {
+	project, member, ok := h.projectMember(w, r)
+	if !ok {
+		return
+	}
+
+	if !authorize(w, r, h.policy.ManageProject(r.Context(), contextGetUser(r), project.ID)) {
+		return
+	}
+
+	blocking, err := h.memberDependents(r.Context(), project, member.UserID, "")
+	if err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+
+	if blocking != nil {
+		errors.ConflictResponse(w, r, blocking)
+		return
+	}
+
+	if err := h.members.Remove(r.Context(), project.ID, member.UserID); err != nil {
+		if err == h.errors.NoRecordError() {
+			errors.NotFoundResponse(w, r)
+		} else {
+			errors.ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := helpers.WriteJSON(w, http.StatusOK, map[string]interface{}{"status": "OK"}, nil); err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+}
+
+// @Summary		Get user projects
+// @Description	Get a page of the projects a user is a member of, each with the user's Role
+// @Tags			Members
+// @Accept			json
+// @Produce		json
+// @Param			id			path		int	true	"User ID"
+// @Param			page		query		int	false	"Page number (default 1)"
+// @Param			page_size	query		int	false	"Page size (default 20, max 100)"
+// @Success		200			{object}	map[string]interface{}
+// @Failure		404			{object}	map[string]string
+// @Failure		422			{object}	map[string]string
+// @Failure		500			{object}	map[string]string
+// @Router			/users/{id}/projects [get]
+func (h *Handler) ShowUserProjectsHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	filters := readFilters(r, v, nil)
+	if !v.Valid() {
+		errors.FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user, err := h.users.Get(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		if err == h.errors.NoRecordError() {
+			errors.NotFoundResponse(w, r)
+		} else {
+			errors.ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	projects, metadata, err := h.members.OfUser(r.Context(), user.ID, filters)
+	if err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+
+	if err := helpers.WriteJSON(w, http.StatusOK, map[string]interface{}{"projects": projects, "metadata": metadata}, nil); err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+}
+
+// projectMember loads the project and the membership named in the URL,
+// answering 404 unless both exist.
+func (h *Handler) projectMember(w http.ResponseWriter, r *http.Request) (*models.Project, *models.Member, bool) {
+	vars := mux.Vars(r)
+
+	project, err := h.projects.Get(r.Context(), vars["id"])
+	if err == nil {
+		// the route only matches digits
+		userID, _ := strconv.Atoi(vars["user_id"])
+
+		var member *models.Member
+		member, err = h.members.Get(r.Context(), project.ID, userID)
+		if err == nil {
+			return project, member, true
+		}
+	}
+
+	if err == h.errors.NoRecordError() {
+		errors.NotFoundResponse(w, r)
+	} else {
+		errors.ServerErrorResponse(w, r, err)
+	}
+
+	return nil, nil, false
+}
+
+// writeMember stores the membership and answers with it.
+func (h *Handler) writeMember(w http.ResponseWriter, r *http.Request, status, projectID, userID int, role string) {
+	if err := h.members.Set(r.Context(), projectID, userID, role); err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+
+	member, err := h.members.Get(r.Context(), projectID, userID)
+	if err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+
+	if err := helpers.WriteJSON(w, status, map[string]interface{}{"member": member}, nil); err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+}
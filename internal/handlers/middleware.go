@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"net/http"
+	"pm-service/internal/handlers/errors"
+	"pm-service/internal/service/auth"
+	"strconv"
+	"strings"
+)
+
+// Authenticate identifies the caller from an X-API-Key header or an
+// "Authorization: Bearer" access token and puts them on the request
+// context. Requests without credentials pass through anonymously;
+// credentials that do not check out are answered with 401 straight away.
+func (h *Handler) Authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Authorization")
+		w.Header().Add("Vary", "X-API-Key")
+
+		if key := r.Header.Get("X-API-Key"); key != "" {
+			user, err := h.auth.KeyOwner(r.Context(), auth.HashKey(key))
+			if err != nil {
+				if err == h.errors.NoRecordError() {
+					errors.InvalidAuthenticationTokenResponse(w, r)
+				} else {
+					errors.ServerErrorResponse(w, r, err)
+				}
+				return
+			}
+
+			next.ServeHTTP(w, contextSetUser(r, user, nil))
+			return
+		}
+
+		header := r.Header.Get("Authorization")
+		if header == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		scheme, token, ok := strings.Cut(header, " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") {
+			errors.InvalidAuthenticationTokenResponse(w, r)
+			return
+		}
+
+		claims, err := h.tokens.Parse(token, auth.AccessToken)
+		if err != nil {
+			errors.InvalidAuthenticationTokenResponse(w, r)
+			return
+		}
+
+		revoked, err := h.auth.Revoked(r.Context(), claims.ID, claims.UserID(), claims.Generation)
+		if err != nil {
+			errors.ServerErrorResponse(w, r, err)
+			return
+		}
+
+		if revoked {
+			errors.InvalidAuthenticationTokenResponse(w, r)
+			return
+		}
+
+		user, err := h.users.Get(r.Context(), strconv.Itoa(claims.UserID()))
+		if err != nil {
+			if err == h.errors.NoRecordError() {
+				errors.InvalidAuthenticationTokenResponse(w, r)
+			} else {
+				errors.ServerErrorResponse(w, r, err)
+			}
+			return
+		}
+
+		next.ServeHTTP(w, contextSetUser(r, user, claims))
+	})
+}
+
+// RequireUser answers anonymous requests with 401.
+func (h *Handler) RequireUser(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if contextGetUser(r) == nil {
+			errors.AuthenticationRequiredResponse(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
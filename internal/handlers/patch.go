@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"pm-service/internal/handlers/errors"
+	"pm-service/internal/service/patch"
+	"reflect"
+	"sort"
+)
+
+// readPatch applies the request body to current, as a JSON Merge Patch or a
+// JSON Patch depending on the Content-Type, and decodes the result into
+// dst. It returns the JSON names of the fields whose values changed.
+func readPatch(w http.ResponseWriter, r *http.Request, current, dst interface{}) ([]string, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	apply, err := patch.For(mediaType)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1_048_576))
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := json.Marshal(current)
+	if err != nil {
+		return nil, err
+	}
+
+	patched, err := apply(doc, body)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(patched))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(dst); err != nil {
+		return nil, err
+	}
+
+	return changedFields(current, dst)
+}
+
+// changedFields returns the JSON names of the fields whose values differ
+// between two values of the same input type.
+func changedFields(before, after interface{}) ([]string, error) {
+	var a, b map[string]interface{}
+
+	for _, v := range []struct {
+		src interface{}
+		dst *map[string]interface{}
+	}{{before, &a}, {after, &b}} {
+		js, err := json.Marshal(v.src)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(js, v.dst); err != nil {
+			return nil, err
+		}
+	}
+
+	var fields []string
+	for key := range a {
+		if !reflect.DeepEqual(a[key], b[key]) {
+			fields = append(fields, key)
+		}
+	}
+	sort.Strings(fields)
+
+	return fields, nil
+}
+
+// patchError answers a failed readPatch.
+func patchError(w http.ResponseWriter, r *http.Request, err error) {
+	switch err {
+	case patch.ErrUnsupportedType:
+		errors.UnsupportedMediaTypeResponse(w, r, "Content-Type must be "+patch.MergePatchType+" or "+patch.JSONPatchType)
+	case patch.ErrTestFailed:
+		errors.ConflictResponse(w, r, "a test operation of the patch did not hold")
+	default:
+		errors.BadRequestResponse(w, r)
+	}
+}
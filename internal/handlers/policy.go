@@ -0,0 +1,23 @@
+package handlers
+
+import (
+	"net/http"
+	"pm-service/internal/handlers/errors"
+	"pm-service/internal/service/policy"
+)
+
+// authorize answers the request when the policy decision err denies it,
+// with 403 or with 500 when the policy could not decide, and reports
+// whether the handler may go on.
+func authorize(w http.ResponseWriter, r *http.Request, err error) bool {
+	switch err {
+	case nil:
+		return true
+	case policy.ErrForbidden:
+		errors.NotPermittedResponse(w, r)
+	default:
+		errors.ServerErrorResponse(w, r, err)
+	}
+
+	return false
+}
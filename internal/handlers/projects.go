@@ -5,7 +5,7 @@ import (
 	"net/http"
 	"pm-service/internal/handlers/errors"
 	"pm-service/internal/service/helpers"
-	"strings"
+	"pm-service/internal/service/validator"
 
 	"github.com/gorilla/mux"
 )
@@ -15,28 +15,43 @@ import (
 // @Tags			Projects
 // @Accept			json
 // @Produce		json
-// @Success		200	{array}		models.Project
+// @Param			page		query		int		false	"Page number (default 1)"
+// @Param			page_size	query		int		false	"Page size (default 20, max 100)"
+// @Param			sort		query		string	false	"Comma-separated sort fields, prefix with - for descending"
+// @Success		200	{object}	map[string]interface{}
 // @Failure		500	{object}	map[string]string
 // @Router			/projects [get]
 func (h *Handler) ShowAllProjectsHandler(w http.ResponseWriter, r *http.Request) {
-	projects, err := h.projects.GetAll()
+	v := validator.New()
+	filters := readFilters(r, v, projectSortSafelist)
+	if !v.Valid() {
+		errors.FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	projects, metadata, err := h.projects.GetAll(r.Context(), filters)
 	if err != nil {
 		errors.ServerErrorResponse(w, r, err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(projects)
+	if err := helpers.WriteJSON(w, http.StatusOK, map[string]interface{}{"projects": projects, "metadata": metadata}, nil); err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
 }
 
 // @Summary		Create a new project
-// @Description	Create a new project with the given details
+// @Description	Create a new project with the given details. Admins may create any project, managers
+// @Description	only projects they manage themselves.
 // @Tags			Projects
 // @Accept			json
 // @Produce		json
 // @Param			project	body		models.ProjectInput	true	"Project details"
 // @Success		201		{object}	map[string]string
 // @Failure		400		{object}	map[string]string
+// @Failure		403		{object}	map[string]string
+// @Failure		422		{object}	map[string]string
 // @Failure		500		{object}	map[string]string
 // @Router			/projects [post]
 func (h *Handler) CreateProjectHandler(w http.ResponseWriter, r *http.Request) {
@@ -47,12 +62,22 @@ func (h *Handler) CreateProjectHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if !input.IsValid() {
-		errors.BadRequestResponse(w, r)
+	if !authorize(w, r, h.policy.CreateProject(contextGetUser(r), &input)) {
 		return
 	}
 
-	id, err := h.projects.Insert(&input)
+	v, err := h.validateProjectInput(r.Context(), nil, &input)
+	if err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+
+	if !v.Valid() {
+		errors.FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	id, err := h.projects.Insert(r.Context(), &input)
 	if err != nil {
 		errors.ServerErrorResponse(w, r, err)
 		return
@@ -70,14 +95,16 @@ func (h *Handler) CreateProjectHandler(w http.ResponseWriter, r *http.Request) {
 // @Accept			json
 // @Produce		json
 // @Param			id	path		int	true	"Project ID"
+// @Param			If-None-Match	header		string	false	"ETag from a previous response"
 // @Success		200	{object}	models.Project
+// @Success		304	"Not modified"
 // @Failure		500	{object}	map[string]string
 // @Failure		404	{object}	map[string]string
 // @Router			/projects/{id} [get]
 func (h *Handler) ShowProjectHandler(w http.ResponseWriter, r *http.Request) {
 	id := mux.Vars(r)["id"]
 
-	project, err := h.projects.Get(id)
+	project, err := h.projects.Get(r.Context(), id)
 	if err != nil {
 		if err == h.errors.NoRecordError() {
 			errors.NotFoundResponse(w, r)
@@ -87,25 +114,53 @@ func (h *Handler) ShowProjectHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if notModified(w, r, etag(project.Version)) {
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(project)
 }
 
 // @Summary		Update project details
-// @Description	Update project details by ID
+// @Description	Update project details by ID. Send the ETag from GET /projects/{id} in If-Match to fail with 412
+// @Description	instead of overwriting someone else's changes; the response carries the new ETag.
 // @Tags			Projects
 // @Accept			json
 // @Produce		json
 // @Param			id		path		int					true	"Project ID"
 // @Param			project	body		models.ProjectInput	true	"Project details"
+// @Param			If-Match	header		string	false	"ETag the update is based on"
 // @Success		200		{object}	map[string]string
 // @Failure		400		{object}	map[string]string
+// @Failure		403		{object}	map[string]string
+// @Failure		422		{object}	map[string]string
 // @Failure		404		{object}	map[string]string
+// @Failure		409		{object}	map[string]string
+// @Failure		412		{object}	map[string]string
 // @Failure		500		{object}	map[string]string
 // @Router			/projects/{id} [put]
 func (h *Handler) UpdateProjectHandler(w http.ResponseWriter, r *http.Request) {
 	id := mux.Vars(r)["id"]
 
+	project, err := h.projects.Get(r.Context(), id)
+	if err != nil {
+		if err == h.errors.NoRecordError() {
+			errors.NotFoundResponse(w, r)
+		} else {
+			errors.ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if !authorize(w, r, h.policy.OwnProject(contextGetUser(r), project)) {
+		return
+	}
+
+	if !checkIfMatch(w, r, etag(project.Version)) {
+		return
+	}
+
 	input := h.input.NewProjectInput()
 
 	if err := helpers.ReadJSON(w, r, &input); err != nil {
@@ -113,12 +168,65 @@ func (h *Handler) UpdateProjectHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if !input.IsValid() {
-		errors.BadRequestResponse(w, r)
+	v, err := h.validateProjectInput(r.Context(), project, &input)
+	if err != nil {
+		errors.ServerErrorResponse(w, r, err)
 		return
 	}
 
-	if err := h.projects.Update(id, &input); err != nil {
+	if !v.Valid() {
+		errors.FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	version, err := h.projects.Update(r.Context(), id, &input, project.Version)
+	if err != nil {
+		switch err {
+		case h.errors.EditConflictError():
+			editConflict(w, r)
+		case h.errors.NoRecordError():
+			errors.NotFoundResponse(w, r)
+		default:
+			errors.ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("ETag", etag(version))
+
+	if err := helpers.WriteJSON(w, http.StatusOK, map[string]interface{}{"status": "OK"}, headers); err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+}
+
+// @Summary		Partially update project
+// @Description	Change only some fields of a project. Send a JSON Merge Patch (application/merge-patch+json,
+// @Description	null clears a field) or a JSON Patch (application/json-patch+json) addressing top-level
+// @Description	fields such as /title. Only the fields that change are validated and written. Honours
+// @Description	If-Match like PUT and returns the new ETag.
+// @Tags			Projects
+// @Accept			json
+// @Produce		json
+// @Param			id			path		int		true	"Project ID"
+// @Param			patch		body		object	true	"Merge patch or JSON Patch"
+// @Param			If-Match	header		string	false	"ETag the update is based on"
+// @Success		200			{object}	map[string]string
+// @Failure		400			{object}	map[string]string
+// @Failure		403			{object}	map[string]string
+// @Failure		404			{object}	map[string]string
+// @Failure		409			{object}	map[string]string
+// @Failure		412			{object}	map[string]string
+// @Failure		415			{object}	map[string]string
+// @Failure		422			{object}	map[string]string
+// @Failure		500			{object}	map[string]string
+// @Router			/projects/{id} [patch]
+func (h *Handler) PatchProjectHandler(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	project, err := h.projects.Get(r.Context(), id)
+	if err != nil {
 		if err == h.errors.NoRecordError() {
 			errors.NotFoundResponse(w, r)
 		} else {
@@ -127,26 +235,92 @@ func (h *Handler) UpdateProjectHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := helpers.WriteJSON(w, http.StatusOK, map[string]interface{}{"status": "OK"}, nil); err != nil {
+	if !authorize(w, r, h.policy.OwnProject(contextGetUser(r), project)) {
+		return
+	}
+
+	if !checkIfMatch(w, r, etag(project.Version)) {
+		return
+	}
+
+	current := project.Input()
+	input := h.input.NewProjectInput()
+
+	fields, err := readPatch(w, r, &current, &input)
+	if err != nil {
+		patchError(w, r, err)
+		return
+	}
+
+	v, err := h.validateProjectInput(r.Context(), project, &input)
+	if err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+
+	v.Keep(fields...)
+	if !v.Valid() {
+		errors.FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	version := project.Version
+	if len(fields) > 0 {
+		version, err = h.projects.Patch(r.Context(), id, &input, fields, project.Version)
+		if err != nil {
+			switch err {
+			case h.errors.EditConflictError():
+				editConflict(w, r)
+			case h.errors.NoRecordError():
+				errors.NotFoundResponse(w, r)
+			default:
+				errors.ServerErrorResponse(w, r, err)
+			}
+			return
+		}
+	}
+
+	headers := make(http.Header)
+	headers.Set("ETag", etag(version))
+
+	if err := helpers.WriteJSON(w, http.StatusOK, map[string]interface{}{"status": "OK"}, headers); err != nil {
 		errors.ServerErrorResponse(w, r, err)
 		return
 	}
 }
 
 // @Summary		Delete project by ID
-// @Description	Delete a project by its ID
+// @Description	Move a project to the trash by its ID. With mode=restrict (default) the request fails with
+// @Description	409 and lists the blocking tasks if the project still has any. With mode=cascade the
+// @Description	project's tasks are trashed together with it in a single transaction.
 // @Tags			Projects
 // @Accept			json
 // @Produce		json
-// @Param			id	path		int	true	"Project ID"
-// @Success		200	{object}	map[string]string
-// @Failure		404	{object}	map[string]string
-// @Failure		500	{object}	map[string]string
+// @Param			id		path		int		true	"Project ID"
+// @Param			mode	query		string	false	"restrict (default) or cascade"
+// @Param			If-Match	header		string	false	"ETag the delete is based on"
+// @Success		200		{object}	map[string]string
+// @Failure		403		{object}	map[string]string
+// @Failure		404		{object}	map[string]string
+// @Failure		409		{object}	map[string]interface{}
+// @Failure		422		{object}	map[string]string
+// @Failure		412	{object}	map[string]string
+// @Failure		500		{object}	map[string]string
 // @Router			/projects/{id} [delete]
 func (h *Handler) DeleteProjectHandler(w http.ResponseWriter, r *http.Request) {
 	id := mux.Vars(r)["id"]
 
-	if err := h.projects.Delete(id); err != nil {
+	v := validator.New()
+	mode := helpers.ReadString(r.URL.Query(), "mode", "restrict")
+	v.Check(validator.PermittedValue(mode, "restrict", "cascade"), "mode", "must be one of: restrict, cascade")
+
+	if !v.Valid() {
+		errors.FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	project, err := h.projects.Get(r.Context(), id)
+	if err != nil {
 		if err == h.errors.NoRecordError() {
 			errors.NotFoundResponse(w, r)
 		} else {
@@ -155,6 +329,44 @@ func (h *Handler) DeleteProjectHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !authorize(w, r, h.policy.OwnProject(contextGetUser(r), project)) {
+		return
+	}
+
+	if !checkIfMatch(w, r, etag(project.Version)) {
+		return
+	}
+
+	switch mode {
+	case "cascade":
+		err = h.projects.DeleteCascade(r.Context(), id)
+	default:
+		var blocking map[string]interface{}
+		if blocking, err = h.projectDependents(r.Context(), id); err != nil {
+			errors.ServerErrorResponse(w, r, err)
+			return
+		}
+
+		if blocking != nil {
+			errors.ConflictResponse(w, r, blocking)
+			return
+		}
+
+		err = h.projects.Delete(r.Context(), id)
+	}
+
+	if err != nil {
+		switch err {
+		case h.errors.NoRecordError():
+			errors.NotFoundResponse(w, r)
+		case h.errors.ReferencedError():
+			errors.ConflictResponse(w, r, "the project is still referenced by other records")
+		default:
+			errors.ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
 	if err := helpers.WriteJSON(w, http.StatusOK, map[string]interface{}{"status": "OK"}, nil); err != nil {
 		errors.ServerErrorResponse(w, r, err)
 		return
@@ -167,14 +379,17 @@ func (h *Handler) DeleteProjectHandler(w http.ResponseWriter, r *http.Request) {
 // @Accept			json
 // @Produce		json
 // @Param			id	path		int	true	"Project ID"
-// @Success		200	{array}		models.Task
+// @Param			page		query		int		false	"Page number (default 1)"
+// @Param			page_size	query		int		false	"Page size (default 20, max 100)"
+// @Param			sort		query		string	false	"Comma-separated sort fields, prefix with - for descending"
+// @Success		200	{object}	map[string]interface{}
 // @Failure		404	{object}	map[string]string
 // @Failure		500	{object}	map[string]string
 // @Router			/projects/{id}/tasks [get]
 func (h *Handler) ShowProjectTasksHandler(w http.ResponseWriter, r *http.Request) {
 	id := mux.Vars(r)["id"]
 
-	_, err := h.projects.Get(id)
+	_, err := h.projects.Get(r.Context(), id)
 	if err != nil {
 		if err == h.errors.NoRecordError() {
 			errors.NotFoundResponse(w, r)
@@ -184,49 +399,68 @@ func (h *Handler) ShowProjectTasksHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	tasks, err := h.tasks.GetAllBy("project_id", id)
+	v := validator.New()
+	filters := readFilters(r, v, taskSortSafelist)
+	if !v.Valid() {
+		errors.FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	tasks, metadata, err := h.tasks.GetAllBy(r.Context(), "project_id", id, filters)
 	if err != nil {
 		errors.ServerErrorResponse(w, r, err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(tasks)
+	if err := helpers.WriteJSON(w, http.StatusOK, map[string]interface{}{"tasks": tasks, "metadata": metadata}, nil); err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
 }
 
 // @Summary		Search projects by query
-// @Description	Search projects by title or manager ID
+// @Description	Search projects by any combination of title, manager and date ranges.
+// @Description	Conditions are combined with AND. Prefix a value with ! to negate it; manager accepts
+// @Description	a comma-separated list. Title matches case-insensitive substrings.
 // @Tags			Projects
 // @Accept			json
 // @Produce		json
-// @Param			title	query		string	false	"Project title"
-// @Param			manager	query		string	false	"Manager ID"
-// @Success		200		{array}		models.Project
-// @Failure		400		{object}	map[string]string
-// @Failure		500		{object}	map[string]string
+// @Param			title			query		string	false	"Project title (substring)"
+// @Param			manager			query		string	false	"Manager ID(s)"
+// @Param			created_from	query		string	false	"Created on or after (date or RFC 3339)"
+// @Param			created_to		query		string	false	"Created on or before (date or RFC 3339)"
+// @Param			completed_from	query		string	false	"Completed on or after (date or RFC 3339)"
+// @Param			completed_to	query		string	false	"Completed on or before (date or RFC 3339)"
+// @Param			page			query		int		false	"Page number (default 1)"
+// @Param			page_size		query		int		false	"Page size (default 20, max 100)"
+// @Param			sort			query		string	false	"Comma-separated sort fields, prefix with - for descending"
+// @Success		200				{object}	map[string]interface{}
+// @Failure		400				{object}	map[string]string
+// @Failure		422				{object}	map[string]string
+// @Failure		500				{object}	map[string]string
 // @Router			/projects/search [get]
 func (h *Handler) SearchProjectsHandler(w http.ResponseWriter, r *http.Request) {
-	var query string
-
-	if r.URL.Query().Get("title") != "" {
-		query = "title"
-	} else if r.URL.Query().Get("manager") != "" {
-		query = "manager_id"
+	v := validator.New()
+	criteria := readCriteria(r, v, projectSearchFields)
+	filters := readFilters(r, v, projectSortSafelist)
+	if !v.Valid() {
+		errors.FailedValidationResponse(w, r, v.Errors)
+		return
 	}
 
-	queryURL := strings.ReplaceAll(query, "_id", "")
-
-	if query == "" || r.URL.Query().Get(queryURL) == "" {
+	if len(criteria) == 0 {
 		errors.BadRequestResponse(w, r)
 		return
 	}
 
-	projects, err := h.projects.GetAllBy(query, r.URL.Query().Get(queryURL))
+	projects, metadata, err := h.projects.Search(r.Context(), criteria, filters)
 	if err != nil {
 		errors.ServerErrorResponse(w, r, err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(projects)
+	if err := helpers.WriteJSON(w, http.StatusOK, map[string]interface{}{"projects": projects, "metadata": metadata}, nil); err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
 }
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"net/http"
+	"pm-service/internal/handlers/errors"
+	"pm-service/internal/service/helpers"
+	"pm-service/internal/service/validator"
+	"strings"
+)
+
+var searchTypes = []string{"tasks", "projects"}
+
+// @Summary		Full-text search
+// @Description	Search tasks and projects by title and description. Title matches rank above description
+// @Description	matches; snippets wrap matched terms in <mark></mark>. The query supports quoted phrases,
+// @Description	OR and -term exclusion.
+// @Tags			Search
+// @Accept			json
+// @Produce		json
+// @Param			q			query		string	true	"Search query"
+// @Param			type		query		string	false	"Comma-separated resource types: tasks, projects (default both)"
+// @Param			page		query		int		false	"Page number (default 1)"
+// @Param			page_size	query		int		false	"Page size (default 20, max 100)"
+// @Success		200			{object}	map[string]interface{}
+// @Failure		422			{object}	map[string]string
+// @Failure		500			{object}	map[string]string
+// @Router			/search [get]
+func (h *Handler) FullTextSearchHandler(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+
+	v := validator.New()
+	q := helpers.ReadString(qs, "q", "")
+	types := helpers.ReadCSV(qs, "type", searchTypes)
+	filters := readFilters(r, v, nil)
+
+	v.Check(validator.NotBlank(q), "q", "must be provided")
+	v.Check(validator.MaxChars(q, 200), "q", "must not be more than 200 characters long")
+	for i, t := range types {
+		types[i] = strings.TrimSpace(t)
+		v.Check(validator.PermittedValue(types[i], searchTypes...), "type", "must be one of: "+strings.Join(searchTypes, ", "))
+	}
+
+	if !v.Valid() {
+		errors.FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	results, metadata, err := h.search.FullText(r.Context(), q, types, filters)
+	if err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+
+	if err := helpers.WriteJSON(w, http.StatusOK, map[string]interface{}{"results": results, "metadata": metadata}, nil); err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"net/http"
+	"pm-service/internal/handlers/errors"
+	"pm-service/internal/repository/models"
+	"pm-service/internal/service/helpers"
+	"pm-service/internal/service/validator"
+
+	"github.com/gorilla/mux"
+)
+
+// @Summary		Get subtasks
+// @Description	Get a page of a task's direct subtasks, together with the roll-up of the completion of
+// @Description	all of its subtasks, direct or nested
+// @Tags			Tasks
+// @Accept			json
+// @Produce		json
+// @Param			id			path		int		true	"Task ID"
+// @Param			page		query		int		false	"Page number (default 1)"
+// @Param			page_size	query		int		false	"Page size (default 20, max 100)"
+// @Param			sort		query		string	false	"Comma-separated sort fields, prefix with - for descending"
+// @Success		200			{object}	map[string]interface{}
+// @Failure		404			{object}	map[string]string
+// @Failure		422			{object}	map[string]string
+// @Failure		500			{object}	map[string]string
+// @Router			/tasks/{id}/subtasks [get]
+func (h *Handler) ShowSubtasksHandler(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	v := validator.New()
+	filters := readFilters(r, v, taskSortSafelist)
+	if !v.Valid() {
+		errors.FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	tree, err := h.tasks.Tree(r.Context(), id)
+	if err != nil {
+		if err == h.errors.NoRecordError() {
+			errors.NotFoundResponse(w, r)
+		} else {
+			errors.ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	subtasks, metadata, err := h.tasks.GetAllBy(r.Context(), "parent_task_id", id, filters)
+	if err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+
+	progress := models.BuildTree(tree[0].ID, tree).Progress
+
+	if err := helpers.WriteJSON(w, http.StatusOK, map[string]interface{}{"subtasks": subtasks, "progress": progress, "metadata": metadata}, nil); err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+}
+
+// @Summary		Get task tree
+// @Description	Get a task with all of its subtasks nested below it. Every node carries the roll-up of
+// @Description	the completion of its own subtasks in Progress.
+// @Tags			Tasks
+// @Accept			json
+// @Produce		json
+// @Param			id	path		int	true	"Task ID"
+// @Success		200	{object}	map[string]interface{}
+// @Failure		404	{object}	map[string]string
+// @Failure		500	{object}	map[string]string
+// @Router			/tasks/{id}/tree [get]
+func (h *Handler) ShowTaskTreeHandler(w http.ResponseWriter, r *http.Request) {
+	tree, err := h.tasks.Tree(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		if err == h.errors.NoRecordError() {
+			errors.NotFoundResponse(w, r)
+		} else {
+			errors.ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := helpers.WriteJSON(w, http.StatusOK, map[string]interface{}{"tree": models.BuildTree(tree[0].ID, tree)}, nil); err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+}
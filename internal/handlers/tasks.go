@@ -4,8 +4,9 @@ import (
 	"encoding/json"
 	"net/http"
 	"pm-service/internal/handlers/errors"
+	"pm-service/internal/repository/models"
 	"pm-service/internal/service/helpers"
-	"strings"
+	"pm-service/internal/service/validator"
 
 	"github.com/gorilla/mux"
 )
@@ -15,18 +16,30 @@ import (
 // @Tags			Tasks
 // @Accept			json
 // @Produce		json
-// @Success		200	{array}		models.Task
+// @Param			page		query		int		false	"Page number (default 1)"
+// @Param			page_size	query		int		false	"Page size (default 20, max 100)"
+// @Param			sort		query		string	false	"Comma-separated sort fields, prefix with - for descending"
+// @Success		200	{object}	map[string]interface{}
 // @Failure		500	{object}	map[string]string
 // @Router			/tasks [get]
 func (h *Handler) ShowAllTasksHandler(w http.ResponseWriter, r *http.Request) {
-	tasks, err := h.tasks.GetAll()
+	v := validator.New()
+	filters := readFilters(r, v, taskSortSafelist)
+	if !v.Valid() {
+		errors.FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	tasks, metadata, err := h.tasks.GetAll(r.Context(), filters)
 	if err != nil {
 		errors.ServerErrorResponse(w, r, err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(tasks)
+	if err := helpers.WriteJSON(w, http.StatusOK, map[string]interface{}{"tasks": tasks, "metadata": metadata}, nil); err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
 }
 
 // @Summary		Create a new task
@@ -37,6 +50,8 @@ func (h *Handler) ShowAllTasksHandler(w http.ResponseWriter, r *http.Request) {
 // @Param			task	body		models.TaskInput	true	"Task details"
 // @Success		201		{object}	map[string]string
 // @Failure		400		{object}	map[string]string
+// @Failure		403		{object}	map[string]string
+// @Failure		422		{object}	map[string]string
 // @Failure		500		{object}	map[string]string
 // @Router			/tasks [post]
 func (h *Handler) CreateTaskHandler(w http.ResponseWriter, r *http.Request) {
@@ -47,12 +62,27 @@ func (h *Handler) CreateTaskHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if !input.IsValid() {
-		errors.BadRequestResponse(w, r)
+	if !authorize(w, r, h.policy.CreateTask(r.Context(), contextGetUser(r), input.ProjectID)) {
+		return
+	}
+
+	v, err := h.validateTaskInput(r.Context(), nil, &input)
+	if err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+
+	if err := h.applyWorkflow(r.Context(), v, nil, &input); err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+
+	if !v.Valid() {
+		errors.FailedValidationResponse(w, r, v.Errors)
 		return
 	}
 
-	id, err := h.tasks.Insert(&input)
+	id, err := h.tasks.Insert(r.Context(), &input)
 	if err != nil {
 		errors.ServerErrorResponse(w, r, err)
 		return
@@ -70,14 +100,16 @@ func (h *Handler) CreateTaskHandler(w http.ResponseWriter, r *http.Request) {
 // @Accept			json
 // @Produce		json
 // @Param			id	path		int	true	"Task ID"
+// @Param			If-None-Match	header		string	false	"ETag from a previous response"
 // @Success		200	{object}	models.Task
+// @Success		304	"Not modified"
 // @Failure		500	{object}	map[string]string
 // @Failure		404	{object}	map[string]string
 // @Router			/tasks/{id} [get]
 func (h *Handler) ShowTaskHandler(w http.ResponseWriter, r *http.Request) {
 	id := mux.Vars(r)["id"]
 
-	task, err := h.tasks.Get(id)
+	task, err := h.tasks.Get(r.Context(), id)
 	if err != nil {
 		if err == h.errors.NoRecordError() {
 			errors.NotFoundResponse(w, r)
@@ -87,25 +119,45 @@ func (h *Handler) ShowTaskHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if notModified(w, r, taskETag(task.Version, task.Blocked)) {
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(task)
 }
 
 // @Summary		Update task details
-// @Description	Update task details by ID
+// @Description	Update task details by ID. Send the ETag from GET /tasks/{id} in If-Match to fail with 412
+// @Description	instead of overwriting someone else's changes; the response carries the new ETag.
 // @Tags			Tasks
 // @Accept			json
 // @Produce		json
 // @Param			id		path		int					true	"Task ID"
 // @Param			task	body		models.TaskInput	true	"Task details"
+// @Param			If-Match	header		string	false	"ETag the update is based on"
 // @Success		200		{object}	map[string]string
 // @Failure		400		{object}	map[string]string
+// @Failure		403		{object}	map[string]string
+// @Failure		422		{object}	map[string]string
 // @Failure		404		{object}	map[string]string
+// @Failure		409		{object}	map[string]string
+// @Failure		412		{object}	map[string]string
 // @Failure		500		{object}	map[string]string
 // @Router			/tasks/{id} [put]
 func (h *Handler) UpdateTaskHandler(w http.ResponseWriter, r *http.Request) {
 	id := mux.Vars(r)["id"]
 
+	task, err := h.tasks.Get(r.Context(), id)
+	if err != nil {
+		if err == h.errors.NoRecordError() {
+			errors.NotFoundResponse(w, r)
+		} else {
+			errors.ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
 	input := h.input.NewTaskInput()
 
 	if err := helpers.ReadJSON(w, r, &input); err != nil {
@@ -113,12 +165,91 @@ func (h *Handler) UpdateTaskHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if !input.IsValid() {
-		errors.BadRequestResponse(w, r)
+	current := task.Input()
+
+	fields, err := changedFields(&current, &input)
+	if err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+
+	if !authorize(w, r, h.policy.UpdateTask(r.Context(), contextGetUser(r), task, &input, fields)) {
+		return
+	}
+
+	if !checkIfMatch(w, r, taskETag(task.Version, task.Blocked)) {
+		return
+	}
+
+	v, err := h.validateTaskInput(r.Context(), task, &input)
+	if err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+
+	if err := h.applyWorkflow(r.Context(), v, task, &input); err != nil {
+		h.taskConflict(w, r, task, &input, err)
+		return
+	}
+
+	if !v.Valid() {
+		errors.FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if err := h.checkSubtasks(r.Context(), task, &input); err != nil {
+		h.taskConflict(w, r, task, &input, err)
+		return
+	}
+
+	version, err := h.tasks.Update(r.Context(), id, &input, task.Version)
+	if err != nil {
+		switch err {
+		case h.errors.EditConflictError():
+			editConflict(w, r)
+		case h.errors.NoRecordError():
+			errors.NotFoundResponse(w, r)
+		default:
+			errors.ServerErrorResponse(w, r, err)
+		}
 		return
 	}
 
-	if err := h.tasks.Update(id, &input); err != nil {
+	headers := make(http.Header)
+	headers.Set("ETag", taskETag(version, task.Blocked))
+
+	if err := helpers.WriteJSON(w, http.StatusOK, map[string]interface{}{"status": "OK"}, headers); err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+}
+
+// @Summary		Partially update task
+// @Description	Change only some fields of a task. Send a JSON Merge Patch (application/merge-patch+json,
+// @Description	null clears a field) or a JSON Patch (application/json-patch+json) addressing top-level
+// @Description	fields such as /title. Only the fields that change are validated and written. Honours
+// @Description	If-Match like PUT and returns the new ETag.
+// @Tags			Tasks
+// @Accept			json
+// @Produce		json
+// @Param			id			path		int		true	"Task ID"
+// @Param			patch		body		object	true	"Merge patch or JSON Patch"
+// @Param			If-Match	header		string	false	"ETag the update is based on"
+// @Success		200			{object}	map[string]string
+// @Failure		400			{object}	map[string]string
+// @Failure		403			{object}	map[string]string
+// @Failure		404			{object}	map[string]string
+// @Failure		409			{object}	map[string]string
+// @Failure		412			{object}	map[string]string
+// @Failure		415			{object}	map[string]string
+// @Failure		422			{object}	map[string]string
+// @Failure		500			{object}	map[string]string
+// @Router			/tasks/{id} [patch]
+func (h *Handler) PatchTaskHandler(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	task, err := h.tasks.Get(r.Context(), id)
+	if err != nil {
 		if err == h.errors.NoRecordError() {
 			errors.NotFoundResponse(w, r)
 		} else {
@@ -127,26 +258,125 @@ func (h *Handler) UpdateTaskHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := helpers.WriteJSON(w, http.StatusOK, map[string]interface{}{"status": "OK"}, nil); err != nil {
+	current := task.Input()
+	input := h.input.NewTaskInput()
+
+	fields, err := readPatch(w, r, &current, &input)
+	if err != nil {
+		patchError(w, r, err)
+		return
+	}
+
+	if !authorize(w, r, h.policy.UpdateTask(r.Context(), contextGetUser(r), task, &input, fields)) {
+		return
+	}
+
+	if !checkIfMatch(w, r, taskETag(task.Version, task.Blocked)) {
+		return
+	}
+
+	v, err := h.validateTaskInput(r.Context(), task, &input)
+	if err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+
+	v.Keep(fields...)
+
+	if err := h.applyWorkflow(r.Context(), v, task, &input); err != nil {
+		h.taskConflict(w, r, task, &input, err)
+		return
+	}
+
+	if !v.Valid() {
+		errors.FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if err := h.checkSubtasks(r.Context(), task, &input); err != nil {
+		h.taskConflict(w, r, task, &input, err)
+		return
+	}
+
+	// the workflow may have normalised the status and set the completion date
+	if fields, err = changedFields(&current, &input); err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+
+	version := task.Version
+	if len(fields) > 0 {
+		version, err = h.tasks.Patch(r.Context(), id, &input, fields, task.Version)
+		if err != nil {
+			switch err {
+			case h.errors.EditConflictError():
+				editConflict(w, r)
+			case h.errors.NoRecordError():
+				errors.NotFoundResponse(w, r)
+			default:
+				errors.ServerErrorResponse(w, r, err)
+			}
+			return
+		}
+	}
+
+	headers := make(http.Header)
+	headers.Set("ETag", taskETag(version, task.Blocked))
+
+	if err := helpers.WriteJSON(w, http.StatusOK, map[string]interface{}{"status": "OK"}, headers); err != nil {
 		errors.ServerErrorResponse(w, r, err)
 		return
 	}
 }
 
 // @Summary		Delete task by ID
-// @Description	Delete a task by its ID
+// @Description	Move a task to the trash by its ID. Fails with 409 and lists the blocking subtasks while the
+// @Description	task still has any.
 // @Tags			Tasks
 // @Accept			json
 // @Produce		json
 // @Param			id	path		int	true	"Task ID"
+// @Param			If-Match	header		string	false	"ETag the delete is based on"
 // @Success		200	{object}	map[string]string
+// @Failure		403	{object}	map[string]string
 // @Failure		404	{object}	map[string]string
+// @Failure		409	{object}	map[string]interface{}
+// @Failure		412	{object}	map[string]string
 // @Failure		500	{object}	map[string]string
 // @Router			/tasks/{id} [delete]
 func (h *Handler) DeleteTaskHandler(w http.ResponseWriter, r *http.Request) {
 	id := mux.Vars(r)["id"]
 
-	if err := h.tasks.Delete(id); err != nil {
+	task, err := h.tasks.Get(r.Context(), id)
+	if err != nil {
+		if err == h.errors.NoRecordError() {
+			errors.NotFoundResponse(w, r)
+		} else {
+			errors.ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if !authorize(w, r, h.policy.ManageProject(r.Context(), contextGetUser(r), task.ProjectID)) {
+		return
+	}
+
+	if !checkIfMatch(w, r, taskETag(task.Version, task.Blocked)) {
+		return
+	}
+
+	blocking, err := h.taskDependents(r.Context(), id)
+	if err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+
+	if blocking != nil {
+		errors.ConflictResponse(w, r, blocking)
+		return
+	}
+
+	if err := h.tasks.Delete(r.Context(), id); err != nil {
 		if err == h.errors.NoRecordError() {
 			errors.NotFoundResponse(w, r)
 		} else {
@@ -162,47 +392,73 @@ func (h *Handler) DeleteTaskHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 // @Summary		Search tasks by query
-// @Description	Search tasks by title, status, priority, assignee, or project
+// @Description	Search tasks by any combination of title, status, priority, assignee, project and date ranges.
+// @Description	Conditions are combined with AND. Prefix a value with ! to negate it; status, priority,
+// @Description	assignee and project accept comma-separated lists. Title matches case-insensitive substrings.
 // @Tags			Tasks
 // @Accept			json
 // @Produce		json
-// @Param			title		query		string	false	"Task title"
-// @Param			status		query		string	false	"Task status"
-// @Param			priority	query		string	false	"Task priority"
-// @Param			assignee	query		string	false	"Task assignee"
-// @Param			project		query		string	false	"Project ID"
-// @Success		200			{array}		models.Task
-// @Failure		400			{object}	map[string]string
-// @Failure		500			{object}	map[string]string
+// @Param			title			query		string	false	"Task title (substring)"
+// @Param			status			query		string	false	"Task status, e.g. to do,in progress or !completed"
+// @Param			priority		query		string	false	"Task priority, e.g. high,medium"
+// @Param			assignee		query		string	false	"Assignee ID(s)"
+// @Param			project			query		string	false	"Project ID(s)"
+// @Param			created_from	query		string	false	"Created on or after (date or RFC 3339)"
+// @Param			created_to		query		string	false	"Created on or before (date or RFC 3339)"
+// @Param			completed_from	query		string	false	"Completed on or after (date or RFC 3339)"
+// @Param			completed_to	query		string	false	"Completed on or before (date or RFC 3339)"
+// @Param			label			query		string	false	"Label name, e.g. bug or !bug"
+// @Param			label_any		query		string	false	"Label names, any of which the task carries, e.g. bug,ui"
+// @Param			label_all		query		string	false	"Label names, all of which the task carries, e.g. bug,urgent"
+// @Param			page			query		int		false	"Page number (default 1)"
+// @Param			page_size		query		int		false	"Page size (default 20, max 100)"
+// @Param			sort			query		string	false	"Comma-separated sort fields, prefix with - for descending"
+// @Success		200				{object}	map[string]interface{}
+// @Failure		400				{object}	map[string]string
+// @Failure		422				{object}	map[string]string
+// @Failure		500				{object}	map[string]string
 // @Router			/tasks/search [get]
 func (h *Handler) SearchTasksHandler(w http.ResponseWriter, r *http.Request) {
-	var query string
-
-	if r.URL.Query().Get("title") != "" {
-		query = "title"
-	} else if r.URL.Query().Get("status") != "" {
-		query = "status"
-	} else if r.URL.Query().Get("priority") != "" {
-		query = "priority"
-	} else if r.URL.Query().Get("assignee") != "" {
-		query = "assignee_id"
-	} else if r.URL.Query().Get("project") != "" {
-		query = "project_id"
+	states, err := h.workflows.States(r.Context())
+	if err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
 	}
 
-	queryURL := strings.ReplaceAll(query, "_id", "")
+	v := validator.New()
+	criteria := readCriteria(r, v, permit(taskSearchFields, "status", states))
+	filters := readFilters(r, v, taskSortSafelist)
+	if !v.Valid() {
+		errors.FailedValidationResponse(w, r, v.Errors)
+		return
+	}
 
-	if query == "" || r.URL.Query().Get(queryURL) == "" {
+	if len(criteria) == 0 {
 		errors.BadRequestResponse(w, r)
 		return
 	}
 
-	tasks, err := h.tasks.GetAllBy(query, r.URL.Query().Get(queryURL))
+	tasks, metadata, err := h.tasks.Search(r.Context(), criteria, filters)
 	if err != nil {
 		errors.ServerErrorResponse(w, r, err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(tasks)
+	if err := helpers.WriteJSON(w, http.StatusOK, map[string]interface{}{"tasks": tasks, "metadata": metadata}, nil); err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+}
+
+// taskConflict answers the errors applyWorkflow and checkSubtasks return
+// for an update of current.
+func (h *Handler) taskConflict(w http.ResponseWriter, r *http.Request, current *models.Task, input *models.TaskInput, err error) {
+	switch err {
+	case h.errors.IllegalTransitionError():
+		errors.ConflictResponse(w, r, "the project workflow does not allow moving the task from "+current.Status+" to "+input.Status)
+	case h.errors.OpenSubtasksError():
+		errors.ConflictResponse(w, r, "the task cannot be completed while it has open subtasks")
+	default:
+		errors.ServerErrorResponse(w, r, err)
+	}
 }
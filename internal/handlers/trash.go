@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"pm-service/internal/handlers/errors"
+	"pm-service/internal/service/helpers"
+	"pm-service/internal/service/validator"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+var trashTypes = []string{"users", "projects", "tasks"}
+
+// @Summary		List trash
+// @Description	List deleted users, projects and tasks, most recently deleted first. Trashed items are
+// @Description	permanently purged once they are older than the configured retention period. Admins only.
+// @Tags			Trash
+// @Accept			json
+// @Produce		json
+// @Param			type		query		string	false	"Comma-separated resource types: users, projects, tasks (default all)"
+// @Param			page		query		int		false	"Page number (default 1)"
+// @Param			page_size	query		int		false	"Page size (default 20, max 100)"
+// @Success		200			{object}	map[string]interface{}
+// @Failure		403			{object}	map[string]string
+// @Failure		422			{object}	map[string]string
+// @Failure		500			{object}	map[string]string
+// @Router			/trash [get]
+func (h *Handler) ShowTrashHandler(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, h.policy.Admin(contextGetUser(r))) {
+		return
+	}
+
+	v := validator.New()
+	types := helpers.ReadCSV(r.URL.Query(), "type", trashTypes)
+	filters := readFilters(r, v, nil)
+
+	for i, t := range types {
+		types[i] = strings.TrimSpace(t)
+		v.Check(validator.PermittedValue(types[i], trashTypes...), "type", "must be one of: "+strings.Join(trashTypes, ", "))
+	}
+
+	if !v.Valid() {
+		errors.FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	items, metadata, err := h.trash.List(r.Context(), types, filters)
+	if err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+
+	if err := helpers.WriteJSON(w, http.StatusOK, map[string]interface{}{"trash": items, "metadata": metadata}, nil); err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+}
+
+// @Summary		Restore user
+// @Description	Take a deleted user out of the trash
+// @Tags			Trash
+// @Accept			json
+// @Produce		json
+// @Param			id	path		int	true	"User ID"
+// @Success		200	{object}	map[string]string
+// @Failure		403	{object}	map[string]string
+// @Failure		404	{object}	map[string]string
+// @Failure		500	{object}	map[string]string
+// @Router			/users/{id}/restore [post]
+func (h *Handler) RestoreUserHandler(w http.ResponseWriter, r *http.Request) {
+	h.restore(w, r, h.users.Restore)
+}
+
+// @Summary		Restore project
+// @Description	Take a deleted project out of the trash, together with the tasks removed by the same
+// @Description	cascading delete. Fails with 409 while the project's manager is in the trash.
+// @Tags			Trash
+// @Accept			json
+// @Produce		json
+// @Param			id	path		int	true	"Project ID"
+// @Success		200	{object}	map[string]string
+// @Failure		403	{object}	map[string]string
+// @Failure		404	{object}	map[string]string
+// @Failure		409	{object}	map[string]string
+// @Failure		500	{object}	map[string]string
+// @Router			/projects/{id}/restore [post]
+func (h *Handler) RestoreProjectHandler(w http.ResponseWriter, r *http.Request) {
+	h.restore(w, r, h.projects.Restore)
+}
+
+// @Summary		Restore task
+// @Description	Take a deleted task out of the trash. Fails with 409 while its project, assignee or parent
+// @Description	task is in the trash.
+// @Tags			Trash
+// @Accept			json
+// @Produce		json
+// @Param			id	path		int	true	"Task ID"
+// @Success		200	{object}	map[string]string
+// @Failure		403	{object}	map[string]string
+// @Failure		404	{object}	map[string]string
+// @Failure		409	{object}	map[string]string
+// @Failure		500	{object}	map[string]string
+// @Router			/tasks/{id}/restore [post]
+func (h *Handler) RestoreTaskHandler(w http.ResponseWriter, r *http.Request) {
+	h.restore(w, r, h.tasks.Restore)
+}
+
+func (h *Handler) restore(w http.ResponseWriter, r *http.Request, restore func(context.Context, string) error) {
+	if !authorize(w, r, h.policy.Admin(contextGetUser(r))) {
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+
+	if err := restore(r.Context(), id); err != nil {
+		switch err {
+		case h.errors.NoRecordError():
+			errors.NotFoundResponse(w, r)
+		case h.errors.TrashedReferenceError():
+			errors.ConflictResponse(w, r, "a referenced record is in the trash, restore it first")
+		default:
+			errors.ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := helpers.WriteJSON(w, http.StatusOK, map[string]interface{}{"status": "OK"}, nil); err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+}
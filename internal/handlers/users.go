@@ -4,7 +4,10 @@ import (
 	"encoding/json"
 	"net/http"
 	"pm-service/internal/handlers/errors"
+	"pm-service/internal/service/auth"
 	"pm-service/internal/service/helpers"
+	"pm-service/internal/service/validator"
+	"strconv"
 
 	"github.com/gorilla/mux"
 )
@@ -14,31 +17,50 @@ import (
 // @Tags			Users
 // @Accept			json
 // @Produce		json
-// @Success		200	{array}		models.User
+// @Param			page		query		int		false	"Page number (default 1)"
+// @Param			page_size	query		int		false	"Page size (default 20, max 100)"
+// @Param			sort		query		string	false	"Comma-separated sort fields, prefix with - for descending"
+// @Success		200	{object}	map[string]interface{}
 // @Failure		500	{object}	map[string]string
 // @Router			/users [get]
 func (h *Handler) ShowAllUsersHandler(w http.ResponseWriter, r *http.Request) {
-	users, err := h.users.GetAll()
+	v := validator.New()
+	filters := readFilters(r, v, userSortSafelist)
+	if !v.Valid() {
+		errors.FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	users, metadata, err := h.users.GetAll(r.Context(), filters)
 	if err != nil {
 		errors.ServerErrorResponse(w, r, err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(users)
+	if err := helpers.WriteJSON(w, http.StatusOK, map[string]interface{}{"users": users, "metadata": metadata}, nil); err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
 }
 
 // @Summary		Create a new user
-// @Description	Create a new user with the given details
+// @Description	Create a new user with the given details. The optional password lets the user sign in
+// @Description	through POST /auth/login. Only admins may create users.
 // @Tags			Users
 // @Accept			json
 // @Produce		json
 // @Param			user	body		models.UserInput	true	"User details"
 // @Success		201		{object}	map[string]string
 // @Failure		400		{object}	map[string]string
+// @Failure		403		{object}	map[string]string
+// @Failure		422		{object}	map[string]string
 // @Failure		500		{object}	map[string]string
 // @Router			/users [post]
 func (h *Handler) CreateUserHandler(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, h.policy.Admin(contextGetUser(r))) {
+		return
+	}
+
 	input := h.input.NewUserInput()
 
 	if err := helpers.ReadJSON(w, r, &input); err != nil {
@@ -46,12 +68,25 @@ func (h *Handler) CreateUserHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if !input.IsValid() {
-		errors.BadRequestResponse(w, r)
+	v, err := h.validateUserInput(r.Context(), nil, &input)
+	if err != nil {
+		errors.ServerErrorResponse(w, r, err)
 		return
 	}
 
-	id, err := h.users.Insert(&input)
+	if !v.Valid() {
+		errors.FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if input.Password != "" {
+		if input.PasswordHash, err = auth.HashPassword(input.Password); err != nil {
+			errors.ServerErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	id, err := h.users.Insert(r.Context(), &input)
 	if err != nil {
 		errors.ServerErrorResponse(w, r, err)
 		return
@@ -69,14 +104,16 @@ func (h *Handler) CreateUserHandler(w http.ResponseWriter, r *http.Request) {
 // @Accept			json
 // @Produce		json
 // @Param			id	path		int	true	"User ID"
+// @Param			If-None-Match	header		string	false	"ETag from a previous response"
 // @Success		200	{object}	models.User
+// @Success		304	"Not modified"
 // @Failure		500	{object}	map[string]string
 // @Failure		404	{object}	map[string]string
 // @Router			/users/{id} [get]
 func (h *Handler) ShowUserHandler(w http.ResponseWriter, r *http.Request) {
 	id := mux.Vars(r)["id"]
 
-	user, err := h.users.Get(id)
+	user, err := h.users.Get(r.Context(), id)
 	if err != nil {
 		if err == h.errors.NoRecordError() {
 			errors.NotFoundResponse(w, r)
@@ -86,25 +123,49 @@ func (h *Handler) ShowUserHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if notModified(w, r, etag(user.Version)) {
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(user)
 }
 
 // @Summary		Update user details
-// @Description	Update user details by ID
+// @Description	Update user details by ID. Send the ETag from GET /users/{id} in If-Match to fail with 412
+// @Description	instead of overwriting someone else's changes; the response carries the new ETag.
 // @Tags			Users
 // @Accept			json
 // @Produce		json
 // @Param			id		path		int					true	"User ID"
 // @Param			user	body		models.UserInput	true	"User details"
+// @Param			If-Match	header		string	false	"ETag the update is based on"
 // @Success		200		{object}	map[string]string
 // @Failure		400		{object}	map[string]string
+// @Failure		403		{object}	map[string]string
+// @Failure		422		{object}	map[string]string
 // @Failure		404		{object}	map[string]string
+// @Failure		409		{object}	map[string]string
+// @Failure		412		{object}	map[string]string
 // @Failure		500		{object}	map[string]string
 // @Router			/users/{id} [put]
 func (h *Handler) UpdateUserHandler(w http.ResponseWriter, r *http.Request) {
 	id := mux.Vars(r)["id"]
 
+	user, err := h.users.Get(r.Context(), id)
+	if err != nil {
+		if err == h.errors.NoRecordError() {
+			errors.NotFoundResponse(w, r)
+		} else {
+			errors.ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if !checkIfMatch(w, r, etag(user.Version)) {
+		return
+	}
+
 	input := h.input.NewUserInput()
 
 	if err := helpers.ReadJSON(w, r, &input); err != nil {
@@ -112,12 +173,69 @@ func (h *Handler) UpdateUserHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if !input.IsValid() {
-		errors.BadRequestResponse(w, r)
+	if !authorize(w, r, h.policy.UpdateUser(contextGetUser(r), user, &input)) {
+		return
+	}
+
+	v, err := h.validateUserInput(r.Context(), user, &input)
+	if err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+
+	if !v.Valid() {
+		errors.FailedValidationResponse(w, r, v.Errors)
 		return
 	}
 
-	if err := h.users.Update(id, &input); err != nil {
+	version, err := h.users.Update(r.Context(), id, &input, user.Version)
+	if err != nil {
+		switch err {
+		case h.errors.EditConflictError():
+			editConflict(w, r)
+		case h.errors.NoRecordError():
+			errors.NotFoundResponse(w, r)
+		default:
+			errors.ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("ETag", etag(version))
+
+	if err := helpers.WriteJSON(w, http.StatusOK, map[string]interface{}{"status": "OK"}, headers); err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+}
+
+// @Summary		Partially update user
+// @Description	Change only some fields of a user. Send a JSON Merge Patch (application/merge-patch+json,
+// @Description	null clears a field) or a JSON Patch (application/json-patch+json) addressing top-level
+// @Description	fields such as /title. Only the fields that change are validated and written. Honours
+// @Description	If-Match like PUT and returns the new ETag.
+// @Tags			Users
+// @Accept			json
+// @Produce		json
+// @Param			id			path		int		true	"User ID"
+// @Param			patch		body		object	true	"Merge patch or JSON Patch"
+// @Param			If-Match	header		string	false	"ETag the update is based on"
+// @Success		200			{object}	map[string]string
+// @Failure		400			{object}	map[string]string
+// @Failure		403			{object}	map[string]string
+// @Failure		404			{object}	map[string]string
+// @Failure		409			{object}	map[string]string
+// @Failure		412			{object}	map[string]string
+// @Failure		415			{object}	map[string]string
+// @Failure		422			{object}	map[string]string
+// @Failure		500			{object}	map[string]string
+// @Router			/users/{id} [patch]
+func (h *Handler) PatchUserHandler(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	user, err := h.users.Get(r.Context(), id)
+	if err != nil {
 		if err == h.errors.NoRecordError() {
 			errors.NotFoundResponse(w, r)
 		} else {
@@ -126,26 +244,104 @@ func (h *Handler) UpdateUserHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := helpers.WriteJSON(w, http.StatusOK, map[string]interface{}{"status": "OK"}, nil); err != nil {
+	if !checkIfMatch(w, r, etag(user.Version)) {
+		return
+	}
+
+	current := user.Input()
+	input := h.input.NewUserInput()
+
+	fields, err := readPatch(w, r, &current, &input)
+	if err != nil {
+		patchError(w, r, err)
+		return
+	}
+
+	if !authorize(w, r, h.policy.UpdateUser(contextGetUser(r), user, &input)) {
+		return
+	}
+
+	v, err := h.validateUserInput(r.Context(), user, &input)
+	if err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+
+	v.Keep(fields...)
+	if !v.Valid() {
+		errors.FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	version := user.Version
+	if len(fields) > 0 {
+		version, err = h.users.Patch(r.Context(), id, &input, fields, user.Version)
+		if err != nil {
+			switch err {
+			case h.errors.EditConflictError():
+				editConflict(w, r)
+			case h.errors.NoRecordError():
+				errors.NotFoundResponse(w, r)
+			default:
+				errors.ServerErrorResponse(w, r, err)
+			}
+			return
+		}
+	}
+
+	headers := make(http.Header)
+	headers.Set("ETag", etag(version))
+
+	if err := helpers.WriteJSON(w, http.StatusOK, map[string]interface{}{"status": "OK"}, headers); err != nil {
 		errors.ServerErrorResponse(w, r, err)
 		return
 	}
 }
 
 // @Summary		Delete user by ID
-// @Description	Delete a user by their ID
+// @Description	Move a user to the trash by their ID. With mode=restrict (default) the request fails with 409 and
+// @Description	lists the blocking tasks and projects if the user is still referenced. With
+// @Description	mode=reassign&to=ID their tasks and managed projects are handed over to another user first.
 // @Tags			Users
 // @Accept			json
 // @Produce		json
-// @Param			id	path		int	true	"User ID"
-// @Success		200	{object}	map[string]string
-// @Failure		404	{object}	map[string]string
-// @Failure		500	{object}	map[string]string
+// @Param			id		path		int		true	"User ID"
+// @Param			mode	query		string	false	"restrict (default) or reassign"
+// @Param			to		query		int		false	"User ID receiving the work when mode=reassign"
+// @Param			If-Match	header		string	false	"ETag the delete is based on"
+// @Success		200		{object}	map[string]string
+// @Failure		403		{object}	map[string]string
+// @Failure		404		{object}	map[string]string
+// @Failure		409		{object}	map[string]interface{}
+// @Failure		422		{object}	map[string]string
+// @Failure		412	{object}	map[string]string
+// @Failure		500		{object}	map[string]string
 // @Router			/users/{id} [delete]
 func (h *Handler) DeleteUserHandler(w http.ResponseWriter, r *http.Request) {
 	id := mux.Vars(r)["id"]
+	qs := r.URL.Query()
+
+	v := validator.New()
+	mode := helpers.ReadString(qs, "mode", "restrict")
+	to := helpers.ReadInt(qs, "to", 0, v)
 
-	if err := h.users.Delete(id); err != nil {
+	v.Check(validator.PermittedValue(mode, "restrict", "reassign"), "mode", "must be one of: restrict, reassign")
+	if mode == "reassign" {
+		v.Check(to > 0, "to", "must be provided when mode is reassign")
+		v.Check(strconv.Itoa(to) != id, "to", "must be a different user")
+		if err := h.checkUserExists(r.Context(), v, "to", to); err != nil {
+			errors.ServerErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	if !v.Valid() {
+		errors.FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user, err := h.users.Get(r.Context(), id)
+	if err != nil {
 		if err == h.errors.NoRecordError() {
 			errors.NotFoundResponse(w, r)
 		} else {
@@ -154,6 +350,44 @@ func (h *Handler) DeleteUserHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !authorize(w, r, h.policy.Admin(contextGetUser(r))) {
+		return
+	}
+
+	if !checkIfMatch(w, r, etag(user.Version)) {
+		return
+	}
+
+	switch mode {
+	case "reassign":
+		err = h.users.DeleteReassign(r.Context(), id, strconv.Itoa(to))
+	default:
+		var blocking map[string]interface{}
+		if blocking, err = h.userDependents(r.Context(), id); err != nil {
+			errors.ServerErrorResponse(w, r, err)
+			return
+		}
+
+		if blocking != nil {
+			errors.ConflictResponse(w, r, blocking)
+			return
+		}
+
+		err = h.users.Delete(r.Context(), id)
+	}
+
+	if err != nil {
+		switch err {
+		case h.errors.NoRecordError():
+			errors.NotFoundResponse(w, r)
+		case h.errors.ReferencedError():
+			errors.ConflictResponse(w, r, "the user is still referenced by other records")
+		default:
+			errors.ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
 	if err := helpers.WriteJSON(w, http.StatusOK, map[string]interface{}{"status": "OK"}, nil); err != nil {
 		errors.ServerErrorResponse(w, r, err)
 		return
@@ -166,14 +400,17 @@ func (h *Handler) DeleteUserHandler(w http.ResponseWriter, r *http.Request) {
 // @Accept			json
 // @Produce		json
 // @Param			id	path		int	true	"User ID"
-// @Success		200	{array}		models.Task
+// @Param			page		query		int		false	"Page number (default 1)"
+// @Param			page_size	query		int		false	"Page size (default 20, max 100)"
+// @Param			sort		query		string	false	"Comma-separated sort fields, prefix with - for descending"
+// @Success		200	{object}	map[string]interface{}
 // @Failure		404	{object}	map[string]string
 // @Failure		500	{object}	map[string]string
 // @Router			/users/{id}/tasks [get]
 func (h *Handler) ShowUserTasksHandler(w http.ResponseWriter, r *http.Request) {
 	id := mux.Vars(r)["id"]
 
-	_, err := h.users.Get(id)
+	_, err := h.users.Get(r.Context(), id)
 	if err != nil {
 		if err == h.errors.NoRecordError() {
 			errors.NotFoundResponse(w, r)
@@ -183,48 +420,67 @@ func (h *Handler) ShowUserTasksHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tasks, err := h.tasks.GetAllBy("assignee_id", id)
+	v := validator.New()
+	filters := readFilters(r, v, taskSortSafelist)
+	if !v.Valid() {
+		errors.FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	tasks, metadata, err := h.tasks.GetAllBy(r.Context(), "assignee_id", id, filters)
 	if err != nil {
 		errors.ServerErrorResponse(w, r, err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(tasks)
+	if err := helpers.WriteJSON(w, http.StatusOK, map[string]interface{}{"tasks": tasks, "metadata": metadata}, nil); err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
 }
 
-// @Summary		Search users by name or email
-// @Description	Search users by name or email
+// @Summary		Search users by query
+// @Description	Search users by any combination of name, email, role and creation date range.
+// @Description	Conditions are combined with AND. Prefix a value with ! to negate it; role accepts a
+// @Description	comma-separated list. Name and email match case-insensitive substrings.
 // @Tags			Users
 // @Accept			json
 // @Produce		json
-// @Param			name	query		string	false	"User name"
-// @Param			email	query		string	false	"User email"
-// @Success		200		{array}		models.User
-// @Failure		400		{object}	map[string]string
-// @Failure		500		{object}	map[string]string
+// @Param			name			query		string	false	"User name (substring)"
+// @Param			email			query		string	false	"User email (substring)"
+// @Param			role			query		string	false	"User role(s)"
+// @Param			created_from	query		string	false	"Created on or after (date or RFC 3339)"
+// @Param			created_to		query		string	false	"Created on or before (date or RFC 3339)"
+// @Param			page			query		int		false	"Page number (default 1)"
+// @Param			page_size		query		int		false	"Page size (default 20, max 100)"
+// @Param			sort			query		string	false	"Comma-separated sort fields, prefix with - for descending"
+// @Success		200				{object}	map[string]interface{}
+// @Failure		400				{object}	map[string]string
+// @Failure		422				{object}	map[string]string
+// @Failure		500				{object}	map[string]string
 // @Router			/users/search [get]
 func (h *Handler) SearchUsersHandler(w http.ResponseWriter, r *http.Request) {
-	var query string
-
-	if r.URL.Query().Get("name") != "" {
-		query = "name"
-	} else if r.URL.Query().Get("email") != "" {
-		query = "email"
+	v := validator.New()
+	criteria := readCriteria(r, v, userSearchFields)
+	filters := readFilters(r, v, userSortSafelist)
+	if !v.Valid() {
+		errors.FailedValidationResponse(w, r, v.Errors)
+		return
 	}
 
-	if query == "" || r.URL.Query().Get(query) == "" {
+	if len(criteria) == 0 {
 		errors.BadRequestResponse(w, r)
 		return
 	}
 
-	users, err := h.users.GetAllBy(query, r.URL.Query().Get(query))
+	users, metadata, err := h.users.Search(r.Context(), criteria, filters)
 	if err != nil {
 		errors.ServerErrorResponse(w, r, err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(users)
-
+	if err := helpers.WriteJSON(w, http.StatusOK, map[string]interface{}{"users": users, "metadata": metadata}, nil); err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
 }
@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"context"
+	"pm-service/internal/repository/models"
+	"pm-service/internal/service/validator"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// validateUserInput checks the input. current is the user being updated,
+// nil on create; passwords of existing users are changed through
+// PUT /auth/password, which asks for the current one.
+func (h *Handler) validateUserInput(ctx context.Context, current *models.User, input *models.UserInput) (*validator.Validator, error) {
+	v := validator.New()
+	validator.UserInput(v, input)
+
+	if current != nil {
+		v.Check(input.Password == "", "password", "can only be changed through PUT /auth/password")
+	}
+
+	return v, nil
+}
+
+// validateProjectInput checks the input's references. current is the
+// project being updated, nil on create; a new manager must already be an
+// owner or manager of the project.
+func (h *Handler) validateProjectInput(ctx context.Context, current *models.Project, input *models.ProjectInput) (*validator.Validator, error) {
+	v := validator.New()
+	validator.ProjectInput(v, input)
+
+	if err := h.checkUserExists(ctx, v, "manager_id", input.ManagerID); err != nil {
+		return nil, err
+	}
+
+	if current != nil && current.ManagerID != input.ManagerID {
+		err := h.checkMember(ctx, v, "manager_id", current.ID, input.ManagerID, (*models.Member).Manages, "must be an owner or manager of the project")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return v, nil
+}
+
+// validateTaskInput checks the input's references. current is the task
+// being updated, nil on create.
+func (h *Handler) validateTaskInput(ctx context.Context, current *models.Task, input *models.TaskInput) (*validator.Validator, error) {
+	v := validator.New()
+	validator.TaskInput(v, input)
+
+	if err := h.checkUserExists(ctx, v, "assignee_id", input.AssigneeID); err != nil {
+		return nil, err
+	}
+
+	if err := h.checkProjectExists(ctx, v, "project_id", input.ProjectID); err != nil {
+		return nil, err
+	}
+
+	// blame the field that changed, so that partial updates report it
+	key, message := "assignee_id", "must be a member of the project"
+	if current != nil && current.AssigneeID == input.AssigneeID {
+		key, message = "project_id", "must be a project the assignee is a member of"
+	}
+
+	_, badAssignee := v.Errors["assignee_id"]
+	_, badProject := v.Errors["project_id"]
+	if !badAssignee && !badProject {
+		if err := h.checkMember(ctx, v, key, input.ProjectID, input.AssigneeID, (*models.Member).Assignable, message); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := h.checkParentTask(ctx, v, current, input.ParentTaskID); err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
+
+// validateCommentInput checks the author and, for replies, that the parent
+// is a comment on the same task.
+func (h *Handler) validateCommentInput(ctx context.Context, input *models.CommentInput) (*validator.Validator, error) {
+	v := validator.New()
+	validator.CommentInput(v, input)
+
+	if err := h.checkUserExists(ctx, v, "author_id", input.AuthorID); err != nil {
+		return nil, err
+	}
+
+	if _, invalid := v.Errors["parent_id"]; input.ParentID != nil && !invalid {
+		parent, err := h.comments.Get(ctx, strconv.Itoa(*input.ParentID))
+		if err != nil && err != h.errors.NoRecordError() {
+			return nil, err
+		}
+		v.Check(err == nil && parent.TaskID == input.TaskID, "parent_id", "must reference a comment on the same task")
+	}
+
+	return v, nil
+}
+
+// applyWorkflow checks the input's status against the workflow of its
+// project and sets or clears the completion date to match. current is the
+// task being updated, nil on create; when neither its status nor its
+// project changes there is nothing to check. A status change the workflow
+// does not allow yields the illegal transition error.
+func (h *Handler) applyWorkflow(ctx context.Context, v *validator.Validator, current *models.Task, input *models.TaskInput) error {
+	if current != nil && current.ProjectID == input.ProjectID && strings.EqualFold(current.Status, input.Status) {
+		return nil
+	}
+
+	for _, key := range []string{"status", "project_id"} {
+		if _, invalid := v.Errors[key]; invalid {
+			return nil
+		}
+	}
+
+	w, err := h.workflows.Get(ctx, input.ProjectID)
+	if err != nil {
+		return err
+	}
+
+	status, ok := w.State(input.Status)
+	if !ok {
+		v.AddError("status", "must be one of: "+strings.Join(w.Names(), ", "))
+		return nil
+	}
+
+	// a task moving to another project only needs a status of its workflow
+	if current != nil && current.ProjectID == input.ProjectID && !w.Allows(current.Status, status) {
+		return h.errors.IllegalTransitionError()
+	}
+
+	input.Status = status
+	input.Completed = w.CompletedFor(status, input.Completed, time.Now())
+
+	return nil
+}
+
+// checkParentTask records an error unless parentID, when set, references a
+// live task outside the subtree of current, so that the hierarchy stays
+// free of cycles.
+func (h *Handler) checkParentTask(ctx context.Context, v *validator.Validator, current *models.Task, parentID *int) error {
+	if _, invalid := v.Errors["parent_task_id"]; invalid || parentID == nil {
+		return nil
+	}
+
+	_, err := h.tasks.Get(ctx, strconv.Itoa(*parentID))
+	if err == h.errors.NoRecordError() {
+		v.AddError("parent_task_id", "must reference an existing task")
+		return nil
+	}
+	if err != nil || current == nil {
+		return err
+	}
+
+	tree, err := h.tasks.Tree(ctx, strconv.Itoa(current.ID))
+	if err != nil {
+		return err
+	}
+
+	for _, s := range tree {
+		v.Check(s.ID != *parentID, "parent_task_id", "must not be the task itself or one of its subtasks")
+	}
+
+	return nil
+}
+
+// checkSubtasks returns the open subtasks error when the input completes
+// current while any of its subtasks, direct or nested, is still open.
+func (h *Handler) checkSubtasks(ctx context.Context, current *models.Task, input *models.TaskInput) error {
+	if current == nil || current.Completed != nil || input.Completed == "" {
+		return nil
+	}
+
+	tree, err := h.tasks.Tree(ctx, strconv.Itoa(current.ID))
+	if err != nil {
+		return err
+	}
+
+	if models.BuildTree(current.ID, tree).Open() > 0 {
+		return h.errors.OpenSubtasksError()
+	}
+
+	return nil
+}
+
+// checkUserExists records an error under key when id does not reference a
+// user. Fields that already failed validation are not looked up.
+func (h *Handler) checkUserExists(ctx context.Context, v *validator.Validator, key string, id int) error {
+	if _, invalid := v.Errors[key]; invalid {
+		return nil
+	}
+
+	_, err := h.users.Get(ctx, strconv.Itoa(id))
+	if err == h.errors.NoRecordError() {
+		v.AddError(key, "must reference an existing user")
+		return nil
+	}
+
+	return err
+}
+
+// checkMember records message under key unless the user is a member of
+// the project whose role passes allowed. Fields that already failed
+// validation are not looked up.
+func (h *Handler) checkMember(ctx context.Context, v *validator.Validator, key string, projectID, userID int, allowed func(*models.Member) bool, message string) error {
+	if _, invalid := v.Errors[key]; invalid {
+		return nil
+	}
+
+	member, err := h.members.Get(ctx, projectID, userID)
+	if err != nil && err != h.errors.NoRecordError() {
+		return err
+	}
+
+	v.Check(err == nil && allowed(member), key, message)
+
+	return nil
+}
+
+// checkProjectExists records an error under key when id does not reference a project.
+func (h *Handler) checkProjectExists(ctx context.Context, v *validator.Validator, key string, id int) error {
+	if _, invalid := v.Errors[key]; invalid {
+		return nil
+	}
+
+	_, err := h.projects.Get(ctx, strconv.Itoa(id))
+	if err == h.errors.NoRecordError() {
+		v.AddError(key, "must reference an existing project")
+		return nil
+	}
+
+	return err
+}
@@ -0,0 +1,389 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"pm-service/internal/handlers/errors"
+	"pm-service/internal/repository/models"
+	"pm-service/internal/service/helpers"
+	"pm-service/internal/service/validator"
+	"pm-service/internal/service/webhook"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+var (
+	webhookSortSafelist  = []string{"id", "url", "active", "created"}
+	deliverySortSafelist = []string{"id", "event", "status", "attempts", "next_attempt", "created", "delivered"}
+	deliverySearchFields = []searchField{
+		{"event", "event", enumSearch, models.WebhookEvents},
+		{"status", "status", enumSearch, models.DeliveryStatuses},
+	}
+)
+
+// @Summary		Get all webhooks
+// @Description	Get a page of the webhook subscriptions. Admins only.
+// @Tags			Webhooks
+// @Accept			json
+// @Produce		json
+// @Param			page		query		int		false	"Page number (default 1)"
+// @Param			page_size	query		int		false	"Page size (default 20, max 100)"
+// @Param			sort		query		string	false	"Comma-separated sort fields, prefix with - for descending"
+// @Success		200			{object}	map[string]interface{}
+// @Failure		403			{object}	map[string]string
+// @Failure		422			{object}	map[string]string
+// @Failure		500			{object}	map[string]string
+// @Router			/webhooks [get]
+func (h *Handler) ShowAllWebhooksHandler(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, h.policy.Admin(contextGetUser(r))) {
+		return
+	}
+
+	v := validator.New()
+	filters := readFilters(r, v, webhookSortSafelist)
+	if !v.Valid() {
+		errors.FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	webhooks, metadata, err := h.webhooks.GetAll(r.Context(), filters)
+	if err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+
+	if err := helpers.WriteJSON(w, http.StatusOK, map[string]interface{}{"webhooks": webhooks, "metadata": metadata}, nil); err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+}
+
+// @Summary		Create webhook
+// @Description	Subscribe a URL to events. Every delivery is POSTed with its HMAC-SHA256 signature under
+// @Description	the webhook's secret; when no secret is given one is generated and returned, once only.
+// @Description	Admins only.
+// @Tags			Webhooks
+// @Accept			json
+// @Produce		json
+// @Param			webhook	body		models.WebhookInput	true	"Webhook"
+// @Success		201		{object}	map[string]interface{}
+// @Failure		400		{object}	map[string]string
+// @Failure		403		{object}	map[string]string
+// @Failure		422		{object}	map[string]string
+// @Failure		500		{object}	map[string]string
+// @Router			/webhooks [post]
+func (h *Handler) CreateWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r, h.policy.Admin(contextGetUser(r))) {
+		return
+	}
+
+	input := h.input.NewWebhookInput()
+
+	if err := helpers.ReadJSON(w, r, &input); err != nil {
+		errors.BadRequestResponse(w, r)
+		return
+	}
+
+	v := validator.New()
+	validator.WebhookInput(v, &input)
+
+	if !v.Valid() {
+		errors.FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	generated := input.Secret == ""
+	if generated {
+		secret, err := webhook.NewSecret()
+		if err != nil {
+			errors.ServerErrorResponse(w, r, err)
+			return
+		}
+		input.Secret = secret
+	}
+
+	id, err := h.webhooks.Insert(r.Context(), &input)
+	if err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+
+	body := map[string]interface{}{"id": id}
+	if generated {
+		body["secret"] = input.Secret
+	}
+
+	if err := helpers.WriteJSON(w, http.StatusCreated, body, nil); err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+}
+
+// @Summary		Get webhook by ID
+// @Description	Get a webhook subscription by its ID, without its secret. Admins only.
+// @Tags			Webhooks
+// @Accept			json
+// @Produce		json
+// @Param			id				path		int		true	"Webhook ID"
+// @Param			If-None-Match	header		string	false	"ETag from a previous response"
+// @Success		200				{object}	models.Webhook
+// @Success		304				"Not modified"
+// @Failure		403				{object}	map[string]string
+// @Failure		404				{object}	map[string]string
+// @Failure		500				{object}	map[string]string
+// @Router			/webhooks/{id} [get]
+func (h *Handler) ShowWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	hook, ok := h.webhook(w, r)
+	if !ok {
+		return
+	}
+
+	if notModified(w, r, etag(hook.Version)) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(hook)
+}
+
+// @Summary		Update webhook
+// @Description	Replace a webhook subscription; a blank secret keeps the current one. Honours If-Match
+// @Description	and returns the new ETag. Admins only.
+// @Tags			Webhooks
+// @Accept			json
+// @Produce		json
+// @Param			id			path		int					true	"Webhook ID"
+// @Param			webhook		body		models.WebhookInput	true	"Webhook"
+// @Param			If-Match	header		string				false	"ETag the update is based on"
+// @Success		200			{object}	map[string]string
+// @Failure		400			{object}	map[string]string
+// @Failure		403			{object}	map[string]string
+// @Failure		404			{object}	map[string]string
+// @Failure		409			{object}	map[string]string
+// @Failure		412			{object}	map[string]string
+// @Failure		422			{object}	map[string]string
+// @Failure		500			{object}	map[string]string
+// @Router			/webhooks/{id} [put]
+func (h *Handler) UpdateWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	hook, ok := h.webhook(w, r)
+	if !ok {
+		return
+	}
+
+	if !checkIfMatch(w, r, etag(hook.Version)) {
+		return
+	}
+
+	input := h.input.NewWebhookInput()
+
+	if err := helpers.ReadJSON(w, r, &input); err != nil {
+		errors.BadRequestResponse(w, r)
+		return
+	}
+
+	v := validator.New()
+	validator.WebhookInput(v, &input)
+
+	if !v.Valid() {
+		errors.FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	version, err := h.webhooks.Update(r.Context(), strconv.Itoa(hook.ID), &input, hook.Version)
+	if err != nil {
+		switch err {
+		case h.errors.EditConflictError():
+			editConflict(w, r)
+		case h.errors.NoRecordError():
+			errors.NotFoundResponse(w, r)
+		default:
+			errors.ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("ETag", etag(version))
+
+	if err := helpers.WriteJSON(w, http.StatusOK, map[string]interface{}{"status": "OK"}, headers); err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+}
+
+// @Summary		Delete webhook
+// @Description	Delete a webhook subscription together with its deliveries. Admins only.
+// @Tags			Webhooks
+// @Accept			json
+// @Produce		json
+// @Param			id			path		int		true	"Webhook ID"
+// @Param			If-Match	header		string	false	"ETag the delete is based on"
+// @Success		200			{object}	map[string]string
+// @Failure		403			{object}	map[string]string
+// @Failure		404			{object}	map[string]string
+// @Failure		412			{object}	map[string]string
+// @Failure		500			{object}	map[string]string
+// @Router			/webhooks/{id} [delete]
+func (h *Handler) DeleteWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	hook, ok := h.webhook(w, r)
+	if !ok {
+		return
+	}
+
+	if !checkIfMatch(w, r, etag(hook.Version)) {
+		return
+	}
+
+	if err := h.webhooks.Delete(r.Context(), strconv.Itoa(hook.ID)); err != nil {
+		if err == h.errors.NoRecordError() {
+			errors.NotFoundResponse(w, r)
+		} else {
+			errors.ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := helpers.WriteJSON(w, http.StatusOK, map[string]interface{}{"status": "OK"}, nil); err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+}
+
+// @Summary		Get webhook deliveries
+// @Description	Get a page of the deliveries queued for a webhook, newest first unless sorted otherwise.
+// @Description	Admins only.
+// @Tags			Webhooks
+// @Accept			json
+// @Produce		json
+// @Param			id			path		int		true	"Webhook ID"
+// @Param			event		query		string	false	"Event(s), e.g. task.created"
+// @Param			status		query		string	false	"Status(es): pending, delivered, dead"
+// @Param			page		query		int		false	"Page number (default 1)"
+// @Param			page_size	query		int		false	"Page size (default 20, max 100)"
+// @Param			sort		query		string	false	"Comma-separated sort fields, prefix with - for descending"
+// @Success		200			{object}	map[string]interface{}
+// @Failure		403			{object}	map[string]string
+// @Failure		404			{object}	map[string]string
+// @Failure		422			{object}	map[string]string
+// @Failure		500			{object}	map[string]string
+// @Router			/webhooks/{id}/deliveries [get]
+func (h *Handler) ShowWebhookDeliveriesHandler(w http.ResponseWriter, r *http.Request) {
+	hook, ok := h.webhook(w, r)
+	if !ok {
+		return
+	}
+
+	v := validator.New()
+	criteria := readCriteria(r, v, deliverySearchFields)
+	filters := readFilters(r, v, deliverySortSafelist)
+	if !v.Valid() {
+		errors.FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if len(filters.Sort) == 0 {
+		filters.Sort = []string{"-id"}
+	}
+
+	deliveries, metadata, err := h.webhooks.Deliveries(r.Context(), hook.ID, criteria, filters)
+	if err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+
+	if err := helpers.WriteJSON(w, http.StatusOK, map[string]interface{}{"deliveries": deliveries, "metadata": metadata}, nil); err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+}
+
+// @Summary		Get webhook delivery
+// @Description	Get a delivery of a webhook with the log of its attempts. Admins only.
+// @Tags			Webhooks
+// @Accept			json
+// @Produce		json
+// @Param			id			path		int		true	"Webhook ID"
+// @Param			delivery_id	path		int		true	"Delivery ID"
+// @Success		200			{object}	models.Delivery
+// @Failure		403			{object}	map[string]string
+// @Failure		404			{object}	map[string]string
+// @Failure		500			{object}	map[string]string
+// @Router			/webhooks/{id}/deliveries/{delivery_id} [get]
+func (h *Handler) ShowWebhookDeliveryHandler(w http.ResponseWriter, r *http.Request) {
+	hook, ok := h.webhook(w, r)
+	if !ok {
+		return
+	}
+
+	deliveryID, _ := strconv.Atoi(mux.Vars(r)["delivery_id"])
+
+	delivery, err := h.webhooks.Delivery(r.Context(), hook.ID, deliveryID)
+	if err != nil {
+		if err == h.errors.NoRecordError() {
+			errors.NotFoundResponse(w, r)
+		} else {
+			errors.ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(delivery)
+}
+
+// @Summary		Redeliver webhook delivery
+// @Description	Queue a delivery to be sent again right away with a fresh set of attempts, whether it
+// @Description	was delivered, is dead or is still pending. Admins only.
+// @Tags			Webhooks
+// @Accept			json
+// @Produce		json
+// @Param			id			path		int		true	"Webhook ID"
+// @Param			delivery_id	path		int		true	"Delivery ID"
+// @Success		202			{object}	map[string]string
+// @Failure		403			{object}	map[string]string
+// @Failure		404			{object}	map[string]string
+// @Failure		500			{object}	map[string]string
+// @Router			/webhooks/{id}/deliveries/{delivery_id}/redeliver [post]
+func (h *Handler) RedeliverWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	hook, ok := h.webhook(w, r)
+	if !ok {
+		return
+	}
+
+	deliveryID, _ := strconv.Atoi(mux.Vars(r)["delivery_id"])
+
+	if err := h.webhooks.Redeliver(r.Context(), hook.ID, deliveryID); err != nil {
+		if err == h.errors.NoRecordError() {
+			errors.NotFoundResponse(w, r)
+		} else {
+			errors.ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err := helpers.WriteJSON(w, http.StatusAccepted, map[string]interface{}{"status": "queued"}, nil); err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+}
+
+// webhook loads the webhook named in the URL for an admin, answering 403
+// or 404 otherwise.
+func (h *Handler) webhook(w http.ResponseWriter, r *http.Request) (*models.Webhook, bool) {
+	if !authorize(w, r, h.policy.Admin(contextGetUser(r))) {
+		return nil, false
+	}
+
+	hook, err := h.webhooks.Get(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		if err == h.errors.NoRecordError() {
+			errors.NotFoundResponse(w, r)
+		} else {
+			errors.ServerErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	return hook, true
+}
@@ -0,0 +1,271 @@
+package handlers
+
+import (
+	"net/http"
+	"pm-service/internal/handlers/errors"
+	"pm-service/internal/repository/models"
+	"pm-service/internal/service/helpers"
+	"pm-service/internal/service/validator"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// @Summary		Get project workflow
+// @Description	Get the statuses the project's tasks may take and the allowed status changes. Projects
+// @Description	without a workflow of their own use the default: to do, in progress and completed.
+// @Tags			Workflows
+// @Accept			json
+// @Produce		json
+// @Param			id	path		int	true	"Project ID"
+// @Success		200	{object}	map[string]interface{}
+// @Failure		404	{object}	map[string]string
+// @Failure		500	{object}	map[string]string
+// @Router			/projects/{id}/workflow [get]
+func (h *Handler) ShowWorkflowHandler(w http.ResponseWriter, r *http.Request) {
+	project, err := h.projects.Get(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		if err == h.errors.NoRecordError() {
+			errors.NotFoundResponse(w, r)
+		} else {
+			errors.ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	workflow, err := h.workflows.Get(r.Context(), project.ID)
+	if err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+
+	if err := helpers.WriteJSON(w, http.StatusOK, map[string]interface{}{"workflow": workflow}, nil); err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+}
+
+// @Summary		Update project workflow
+// @Description	Replace the project's workflow. At least one state must be terminal: tasks entering a
+// @Description	terminal state are completed. Every status currently used by the project's tasks must
+// @Description	remain a state of the workflow.
+// @Tags			Workflows
+// @Accept			json
+// @Produce		json
+// @Param			id			path		int				true	"Project ID"
+// @Param			workflow	body		models.Workflow	true	"Workflow"
+// @Success		200			{object}	map[string]interface{}
+// @Failure		400			{object}	map[string]string
+// @Failure		403			{object}	map[string]string
+// @Failure		404			{object}	map[string]string
+// @Failure		422			{object}	map[string]string
+// @Failure		500			{object}	map[string]string
+// @Router			/projects/{id}/workflow [put]
+func (h *Handler) UpdateWorkflowHandler(w http.ResponseWriter, r *http.Request) {
+	project, err := h.projects.Get(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		if err == h.errors.NoRecordError() {
+			errors.NotFoundResponse(w, r)
+		} else {
+			errors.ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if !authorize(w, r, h.policy.ManageProject(r.Context(), contextGetUser(r), project.ID)) {
+		return
+	}
+
+	var workflow models.Workflow
+
+	if err := helpers.ReadJSON(w, r, &workflow); err != nil {
+		errors.BadRequestResponse(w, r)
+		return
+	}
+
+	v := validator.New()
+	validator.Workflow(v, &workflow)
+
+	if v.Valid() {
+		inUse, err := h.workflows.StatusesInUse(r.Context(), project.ID)
+		if err != nil {
+			errors.ServerErrorResponse(w, r, err)
+			return
+		}
+
+		var missing []string
+		for _, status := range inUse {
+			if _, ok := workflow.State(status); !ok {
+				missing = append(missing, status)
+			}
+		}
+		v.Check(len(missing) == 0, "states", "must include the statuses in use: "+strings.Join(missing, ", "))
+	}
+
+	if !v.Valid() {
+		errors.FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if workflow.Transitions == nil {
+		workflow.Transitions = []models.WorkflowTransition{}
+	}
+
+	if err := h.workflows.Set(r.Context(), project.ID, &workflow); err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+
+	if err := helpers.WriteJSON(w, http.StatusOK, map[string]interface{}{"workflow": workflow}, nil); err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+}
+
+// @Summary		List task transitions
+// @Description	Get the task's status and the statuses its project's workflow allows it to move to
+// @Tags			Workflows
+// @Accept			json
+// @Produce		json
+// @Param			id	path		int	true	"Task ID"
+// @Success		200	{object}	map[string]interface{}
+// @Failure		404	{object}	map[string]string
+// @Failure		500	{object}	map[string]string
+// @Router			/tasks/{id}/transitions [get]
+func (h *Handler) ShowTaskTransitionsHandler(w http.ResponseWriter, r *http.Request) {
+	task, err := h.tasks.Get(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		if err == h.errors.NoRecordError() {
+			errors.NotFoundResponse(w, r)
+		} else {
+			errors.ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	workflow, err := h.workflows.Get(r.Context(), task.ProjectID)
+	if err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+
+	data := map[string]interface{}{"status": task.Status, "transitions": workflow.Next(task.Status)}
+	if err := helpers.WriteJSON(w, http.StatusOK, data, nil); err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+}
+
+// @Summary		Transition task
+// @Description	Move a task to another status allowed by its project's workflow. Entering a terminal
+// @Description	status sets the completion date, leaving one clears it. Honours If-Match and returns the
+// @Description	updated task with its new ETag.
+// @Tags			Workflows
+// @Accept			json
+// @Produce		json
+// @Param			id			path		int					true	"Task ID"
+// @Param			transition	body		map[string]string	true	"Target status, e.g. {\"to\": \"in progress\"}"
+// @Param			If-Match	header		string				false	"ETag the transition is based on"
+// @Success		200			{object}	map[string]interface{}
+// @Failure		400			{object}	map[string]string
+// @Failure		403			{object}	map[string]string
+// @Failure		404			{object}	map[string]string
+// @Failure		409			{object}	map[string]string
+// @Failure		412			{object}	map[string]string
+// @Failure		422			{object}	map[string]string
+// @Failure		500			{object}	map[string]string
+// @Router			/tasks/{id}/transitions [post]
+func (h *Handler) TransitionTaskHandler(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	task, err := h.tasks.Get(r.Context(), id)
+	if err != nil {
+		if err == h.errors.NoRecordError() {
+			errors.NotFoundResponse(w, r)
+		} else {
+			errors.ServerErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	current := task.Input()
+	if !authorize(w, r, h.policy.UpdateTask(r.Context(), contextGetUser(r), task, &current, []string{"status"})) {
+		return
+	}
+
+	if !checkIfMatch(w, r, taskETag(task.Version, task.Blocked)) {
+		return
+	}
+
+	var body struct {
+		To string `json:"to"`
+	}
+
+	if err := helpers.ReadJSON(w, r, &body); err != nil {
+		errors.BadRequestResponse(w, r)
+		return
+	}
+
+	workflow, err := h.workflows.Get(r.Context(), task.ProjectID)
+	if err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	to, ok := workflow.State(body.To)
+	v.Check(ok, "to", "must be one of: "+strings.Join(workflow.Names(), ", "))
+
+	if !v.Valid() {
+		errors.FailedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if !workflow.Allows(task.Status, to) {
+		next := workflow.Next(task.Status)
+		msg := "the project workflow does not allow moving the task from " + task.Status + " to " + to
+		if len(next) > 0 {
+			msg += ", allowed: " + strings.Join(next, ", ")
+		}
+		errors.ConflictResponse(w, r, msg)
+		return
+	}
+
+	if !strings.EqualFold(task.Status, to) {
+		input := task.Input()
+		input.Status = to
+		input.Completed = workflow.CompletedFor(to, input.Completed, time.Now())
+
+		if err := h.checkSubtasks(r.Context(), task, &input); err != nil {
+			h.taskConflict(w, r, task, &input, err)
+			return
+		}
+
+		if _, err := h.tasks.Patch(r.Context(), id, &input, []string{"completed", "status"}, task.Version); err != nil {
+			switch err {
+			case h.errors.EditConflictError():
+				editConflict(w, r)
+			case h.errors.NoRecordError():
+				errors.NotFoundResponse(w, r)
+			default:
+				errors.ServerErrorResponse(w, r, err)
+			}
+			return
+		}
+	}
+
+	task, err = h.tasks.Get(r.Context(), id)
+	if err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("ETag", taskETag(task.Version, task.Blocked))
+
+	if err := helpers.WriteJSON(w, http.StatusOK, map[string]interface{}{"task": task}, headers); err != nil {
+		errors.ServerErrorResponse(w, r, err)
+		return
+	}
+}
@@ -0,0 +1,316 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// lockKey identifies the advisory lock held while migrations run, so that
+// several replicas starting at once apply each migration exactly once.
+const lockKey int64 = 7_310_402_001
+
+var (
+	fileRX = regexp.MustCompile(`^([0-9]+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+	nameRX = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+var ErrNoMigrations = errors.New("migrate: no migrations found")
+
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+type Status struct {
+	Version   int64
+	Name      string
+	AppliedAt *time.Time
+}
+
+type Migrator struct {
+	DB  *sql.DB
+	Dir string
+}
+
+func New(db *sql.DB, dir string) *Migrator {
+	return &Migrator{DB: db, Dir: dir}
+}
+
+// Load reads the migration directory and returns the migrations sorted by version.
+func (m *Migrator) Load() ([]*Migration, error) {
+	entries, err := os.ReadDir(m.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int64]*Migration{}
+
+	for _, entry := range entries {
+		match := fileRX.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = mig
+		} else if mig.Name != match[2] {
+			return nil, fmt.Errorf("migrate: version %d used by both %q and %q", version, mig.Name, match[2])
+		}
+
+		path := filepath.Join(m.Dir, entry.Name())
+		if match[3] == "up" {
+			mig.Up = path
+		} else {
+			mig.Down = path
+		}
+	}
+
+	migrations := make([]*Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == "" {
+			return nil, fmt.Errorf("migrate: version %d has no up script", mig.Version)
+		}
+		migrations = append(migrations, mig)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+// Up applies every pending migration in order and returns the ones applied.
+func (m *Migrator) Up(ctx context.Context) ([]*Migration, error) {
+	migrations, err := m.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(migrations) == 0 {
+		return nil, ErrNoMigrations
+	}
+
+	var done []*Migration
+
+	err = m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, mig := range migrations {
+			if _, ok := applied[mig.Version]; ok {
+				continue
+			}
+
+			err := run(ctx, conn, mig.Up, `INSERT INTO schema_migrations (version, name) VALUES ($1, $2);`, mig.Version, mig.Name)
+			if err != nil {
+				return fmt.Errorf("migrate: %05d_%s: %w", mig.Version, mig.Name, err)
+			}
+
+			done = append(done, mig)
+		}
+
+		return nil
+	})
+
+	return done, err
+}
+
+// Down rolls back the given number of most recently applied migrations.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]*Migration, error) {
+	migrations, err := m.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	var done []*Migration
+
+	err = m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(migrations) - 1; i >= 0 && len(done) < steps; i-- {
+			mig := migrations[i]
+			if _, ok := applied[mig.Version]; !ok {
+				continue
+			}
+
+			if mig.Down == "" {
+				return fmt.Errorf("migrate: %05d_%s has no down script", mig.Version, mig.Name)
+			}
+
+			err := run(ctx, conn, mig.Down, `DELETE FROM schema_migrations WHERE version = $1;`, mig.Version)
+			if err != nil {
+				return fmt.Errorf("migrate: %05d_%s: %w", mig.Version, mig.Name, err)
+			}
+
+			done = append(done, mig)
+		}
+
+		return nil
+	})
+
+	return done, err
+}
+
+// Status lists every known migration together with the time it was applied, if any.
+func (m *Migrator) Status(ctx context.Context) ([]*Status, error) {
+	migrations, err := m.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []*Status
+
+	err = m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, mig := range migrations {
+			s := &Status{Version: mig.Version, Name: mig.Name}
+			if at, ok := applied[mig.Version]; ok {
+				s.AppliedAt = &at
+			}
+			statuses = append(statuses, s)
+		}
+
+		return nil
+	})
+
+	return statuses, err
+}
+
+// Create writes an empty up/down pair named after the next free version.
+func (m *Migrator) Create(name string) ([]string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	name = nameRX.ReplaceAllString(name, "_")
+	name = strings.Trim(name, "_")
+	if name == "" {
+		return nil, errors.New("migrate: migration name must not be empty")
+	}
+
+	migrations, err := m.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	var next int64 = 1
+	if len(migrations) > 0 {
+		next = migrations[len(migrations)-1].Version + 1
+	}
+
+	var paths []string
+	for _, direction := range []string{"up", "down"} {
+		path := filepath.Join(m.Dir, fmt.Sprintf("%05d_%s.%s.sql", next, name, direction))
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		f.Close()
+		paths = append(paths, path)
+	}
+
+	return paths, nil
+}
+
+func (m *Migrator) withLock(ctx context.Context, fn func(*sql.Conn) error) error {
+	// Advisory locks belong to the session, so everything has to run on
+	// the same connection rather than through the pool.
+	conn, err := m.DB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1);`, lockKey); err != nil {
+		return err
+	}
+	defer conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1);`, lockKey)
+
+	stmt := `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version BIGINT PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	);`
+	if _, err := conn.ExecContext(ctx, stmt); err != nil {
+		return err
+	}
+
+	return fn(conn)
+}
+
+func appliedVersions(ctx context.Context, conn *sql.Conn) (map[int64]time.Time, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations;`)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	applied := map[int64]time.Time{}
+
+	for rows.Next() {
+		var version int64
+		var at time.Time
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		applied[version] = at
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return applied, nil
+}
+
+// run executes a migration script and the bookkeeping statement in a single transaction.
+func run(ctx context.Context, conn *sql.Conn, path, record string, args ...interface{}) error {
+	script, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if strings.TrimSpace(string(script)) != "" {
+		// Without arguments lib/pq uses the simple query protocol,
+		// which accepts several statements in one call.
+		if _, err := tx.ExecContext(ctx, string(script)); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, record, args...); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,285 @@
+package mock
+
+import (
+	"context"
+	"encoding/json"
+	"pm-service/internal/repository/models"
+	"pm-service/internal/service/audit"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// AuditModel records the changes made through the mock models pointing at
+// it. The models it holds, when set, are read to view records the way the
+// postgres audit views do.
+type AuditModel struct {
+	DB []*models.AuditEvent
+
+	Users        *UserModel
+	Projects     *ProjectModel
+	Tasks        *TaskModel
+	Comments     *CommentModel
+	Labels       *LabelModel
+	Members      *MemberModel
+	Dependencies *DependencyModel
+	Workflows    *WorkflowModel
+	Auth         *AuthModel
+	Webhooks     *WebhookModel
+	// Outbox, when set, keeps the domain events the changes raise.
+	Outbox *OutboxModel
+}
+
+// change is an audit event waiting for its record to change, see track.
+type change struct {
+	audit    *AuditModel
+	ctx      context.Context
+	resource string
+	id       int
+	action   string
+	before   map[string]interface{}
+}
+
+// track views the record before the action changes it. Calling record on
+// the result logs the change; on a nil AuditModel nothing is logged.
+func (m *AuditModel) track(ctx context.Context, resource string, id int, action string) *change {
+	if m == nil {
+		return nil
+	}
+
+	return &change{m, ctx, resource, id, action, m.view(resource, id)}
+}
+
+func (c *change) record() {
+	if c == nil {
+		return
+	}
+
+	before, after := c.before, c.audit.view(c.resource, c.id)
+	switch c.action {
+	case models.AuditInsert, models.AuditRestore:
+		before = nil
+	case models.AuditDelete:
+		after = nil
+	}
+
+	diff := audit.Diff(before, after)
+	if len(diff) == 0 {
+		return
+	}
+
+	c.audit.DB = append(c.audit.DB, &models.AuditEvent{
+		ID: len(c.audit.DB) + 1, ActorID: audit.Actor(c.ctx), Resource: c.resource, ResourceID: c.id,
+		Action: c.action, Diff: diff, RequestID: audit.RequestID(c.ctx), Created: time.Now(),
+	})
+
+	view := audit.Redact(after)
+	if c.action == models.AuditDelete {
+		view = audit.Redact(before)
+	}
+	c.audit.Outbox.emit(c.ctx, c.resource, c.id, c.action, view, diff)
+}
+
+func (m *AuditModel) Search(ctx context.Context, criteria models.Criteria, filters models.Filters) ([]*models.AuditEvent, models.Metadata, error) {
+	events := []*models.AuditEvent{}
+
+	for _, e := range m.DB {
+		if matches(criteria, func(f string) interface{} { return auditField(e, f) }) {
+			events = append(events, e)
+		}
+	}
+
+	events, metadata := paginate(events, filters, auditField)
+
+	return events, metadata, nil
+}
+
+func auditField(e *models.AuditEvent, field string) interface{} {
+	switch field {
+	case "actor_id":
+		if e.ActorID == nil {
+			return nil
+		}
+		return *e.ActorID
+	case "resource":
+		return e.Resource
+	case "resource_id":
+		return e.ResourceID
+	case "action":
+		return e.Action
+	case "request_id":
+		return e.RequestID
+	case "created":
+		return e.Created
+	}
+	return e.ID
+}
+
+// setCount stands in for a secret in a view as the postgres views do, with
+// the count of how often it was set, nil when there is none.
+func setCount(secret string, count int) interface{} {
+	if secret == "" {
+		return nil
+	}
+	return count
+}
+
+// atoi reads a record id given as a string, 0 when it is not one.
+func atoi(id string) int {
+	n, _ := strconv.Atoi(id)
+	return n
+}
+
+// view returns the record, trashed or not, as the postgres audit views
+// show it, nil when there is none.
+func (m *AuditModel) view(resource string, id int) map[string]interface{} {
+	var v interface{}
+
+	switch resource {
+	case "users":
+		if s := m.user(id); s != nil {
+			v = map[string]interface{}{"name": s.Name, "email": s.Email, "role": s.Role, "password": setCount(m.Users.passwords[id], m.Auth.generation(id))}
+		}
+
+	case "projects":
+		if s := m.project(id); s != nil {
+			members := map[string]string{}
+			if m.Members != nil {
+				for _, mb := range m.Members.DB {
+					if mb.ProjectID == id {
+						members[strconv.Itoa(mb.UserID)] = mb.Role
+					}
+				}
+			}
+			v = map[string]interface{}{"title": s.Title, "description": s.Description, "manager_id": s.ManagerID, "completed": s.Completed, "members": members}
+		}
+
+	case "tasks":
+		if s := m.task(id); s != nil {
+			blockers, labels := []int{}, []int{}
+			if m.Dependencies != nil {
+				for _, d := range m.Dependencies.DB {
+					if d.TaskID == id {
+						blockers = append(blockers, d.BlockerID)
+					}
+				}
+			}
+			if m.Labels != nil {
+				for _, tl := range m.Labels.links {
+					if tl.taskID == id {
+						labels = append(labels, tl.labelID)
+					}
+				}
+			}
+			sort.Ints(blockers)
+			sort.Ints(labels)
+			v = map[string]interface{}{
+				"title": s.Title, "description": s.Description, "priority": s.Priority, "status": s.Status,
+				"assignee_id": s.AssigneeID, "project_id": s.ProjectID, "parent_task_id": s.ParentTaskID, "estimate": s.Estimate, "completed": s.Completed,
+				"blockers": blockers, "labels": labels,
+			}
+		}
+
+	case "comments":
+		if m.Comments != nil {
+			if c, err := m.Comments.Get(context.Background(), strconv.Itoa(id)); err == nil {
+				v = map[string]interface{}{"task_id": c.TaskID, "author_id": c.AuthorID, "parent_id": c.ParentID, "body": c.Body}
+			}
+		}
+
+	case "labels":
+		if m.Labels != nil {
+			if l, err := m.Labels.Get(context.Background(), strconv.Itoa(id)); err == nil {
+				v = map[string]interface{}{"project_id": l.ProjectID, "name": l.Name, "color": l.Color}
+			}
+		}
+
+	case "workflows":
+		if m.Workflows != nil {
+			v = map[string]interface{}{"states": nil, "transitions": nil}
+			if w, ok := m.Workflows.DB[id]; ok {
+				v = w
+			}
+		}
+
+	case "webhooks":
+		if m.Webhooks != nil {
+			if w, err := m.Webhooks.Get(context.Background(), strconv.Itoa(id)); err == nil {
+				v = map[string]interface{}{"url": w.URL, "secret": setCount(m.Webhooks.secrets[id], m.Webhooks.secretVersions[id]), "events": w.Events, "active": w.Active}
+			}
+		}
+
+	case "api_keys":
+		if m.Auth != nil {
+			for _, k := range m.Auth.keys {
+				if k.owner == nil && k.record.ID == id {
+					v = map[string]interface{}{"user_id": k.record.UserID, "name": k.record.Name, "hint": k.record.Hint}
+				}
+			}
+		}
+	}
+
+	if v == nil {
+		return nil
+	}
+
+	// round-trip through JSON so values compare like the decoded postgres views
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+
+	var view map[string]interface{}
+	if err := json.Unmarshal(b, &view); err != nil {
+		return nil
+	}
+
+	return view
+}
+
+func (m *AuditModel) user(id int) *models.User {
+	if m.Users == nil {
+		return nil
+	}
+
+	for _, s := range m.Users.DB {
+		if s.ID == id {
+			return s
+		}
+	}
+	for _, t := range m.Users.trash {
+		if t.record.ID == id {
+			return t.record
+		}
+	}
+
+	return nil
+}
+
+func (m *AuditModel) project(id int) *models.Project {
+	if m.Projects == nil {
+		return nil
+	}
+
+	for _, s := range m.Projects.all() {
+		if s.ID == id {
+			return s
+		}
+	}
+
+	return nil
+}
+
+func (m *AuditModel) task(id int) *models.Task {
+	if m.Tasks == nil {
+		return nil
+	}
+
+	for _, s := range m.Tasks.all() {
+		if s.ID == id {
+			return s
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,180 @@
+package mock
+
+import (
+	"context"
+	"pm-service/internal/repository/models"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type AuthModel struct {
+	// Users holds the accounts and their passwords.
+	Users *UserModel
+	// Audit, when set, records every change to passwords and API keys.
+	Audit *AuditModel
+
+	keys    []apiKey
+	revoked map[string]time.Time
+	// mu serialises Revoke and Revoked, so that of concurrent refreshes
+	// with one token only one revokes it.
+	mu sync.Mutex
+	// generations holds the users' token generations, 0 until they first
+	// change their password.
+	generations map[int]int
+}
+
+type apiKey struct {
+	record *models.APIKey
+	hash   string
+	// owner, when set, stands in for a user that is not one of Users.
+	owner *models.User
+}
+
+// Grant registers an API key for owner, who need not be one of Users, so
+// that a caller can sign in without adding to the fixtures.
+func (m *AuthModel) Grant(owner *models.User, hash string) {
+	m.keys = append(m.keys, apiKey{&models.APIKey{UserID: owner.ID, Name: "mock", Created: time.Now()}, hash, owner})
+}
+
+func (m *AuthModel) Credentials(ctx context.Context, email string) (*models.User, string, error) {
+	for _, s := range m.Users.DB {
+		if hash := m.Users.passwords[s.ID]; hash != "" && strings.EqualFold(s.Email, email) {
+			return s, hash, nil
+		}
+	}
+
+	return &models.User{}, "", models.ErrNoRecord
+}
+
+func (m *AuthModel) Password(ctx context.Context, userID int) (string, error) {
+	if !m.Users.live(userID) {
+		return "", models.ErrNoRecord
+	}
+
+	return m.Users.passwords[userID], nil
+}
+
+func (m *AuthModel) SetPassword(ctx context.Context, userID int, hash string) error {
+	if !m.Users.live(userID) {
+		return models.ErrNoRecord
+	}
+
+	defer m.Audit.track(ctx, "users", userID, models.AuditUpdate).record()
+
+	if m.Users.passwords == nil {
+		m.Users.passwords = make(map[int]string)
+	}
+	m.Users.passwords[userID] = hash
+
+	if m.generations == nil {
+		m.generations = make(map[int]int)
+	}
+	m.generations[userID]++
+
+	return nil
+}
+
+// generation returns the user's token generation, 0 on a nil AuthModel.
+func (m *AuthModel) generation(userID int) int {
+	if m == nil {
+		return 0
+	}
+	return m.generations[userID]
+}
+
+func (m *AuthModel) TokenGeneration(ctx context.Context, userID int) (int, error) {
+	if !m.Users.live(userID) {
+		return 0, models.ErrNoRecord
+	}
+
+	return m.generations[userID], nil
+}
+
+func (m *AuthModel) InsertKey(ctx context.Context, userID int, name, hint, hash string) (int, error) {
+	id := 1
+	for _, k := range m.keys {
+		if k.record.ID >= id {
+			id = k.record.ID + 1
+		}
+	}
+
+	defer m.Audit.track(ctx, "api_keys", id, models.AuditInsert).record()
+
+	m.keys = append(m.keys, apiKey{record: &models.APIKey{ID: id, UserID: userID, Name: name, Hint: hint, Created: time.Now()}, hash: hash})
+
+	return id, nil
+}
+
+func (m *AuthModel) Keys(ctx context.Context, userID int) ([]*models.APIKey, error) {
+	keys := []*models.APIKey{}
+
+	for _, k := range m.keys {
+		if k.owner == nil && k.record.UserID == userID {
+			keys = append(keys, k.record)
+		}
+	}
+
+	return keys, nil
+}
+
+func (m *AuthModel) DeleteKey(ctx context.Context, userID, keyID int) error {
+	for i, k := range m.keys {
+		if k.owner == nil && k.record.ID == keyID && k.record.UserID == userID {
+			defer m.Audit.track(ctx, "api_keys", keyID, models.AuditDelete).record()
+			m.keys = append(m.keys[:i], m.keys[i+1:]...)
+			return nil
+		}
+	}
+
+	return models.ErrNoRecord
+}
+
+func (m *AuthModel) KeyOwner(ctx context.Context, hash string) (*models.User, error) {
+	for _, k := range m.keys {
+		if k.hash != hash {
+			continue
+		}
+
+		if k.owner != nil {
+			return k.owner, nil
+		}
+
+		s, err := m.Users.Get(ctx, strconv.Itoa(k.record.UserID))
+		if err != nil {
+			return s, err
+		}
+
+		now := time.Now()
+		k.record.LastUsed = &now
+
+		return s, nil
+	}
+
+	return &models.User{}, models.ErrNoRecord
+}
+
+func (m *AuthModel) Revoke(ctx context.Context, jti string, expires time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.revoked[jti]; ok {
+		return false, nil
+	}
+
+	if m.revoked == nil {
+		m.revoked = make(map[string]time.Time)
+	}
+	m.revoked[jti] = expires
+
+	return true, nil
+}
+
+func (m *AuthModel) Revoked(ctx context.Context, jti string, userID, generation int) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, ok := m.revoked[jti]
+	return ok || m.generations[userID] != generation, nil
+}
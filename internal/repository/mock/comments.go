@@ -0,0 +1,198 @@
+package mock
+
+import (
+	"context"
+	"pm-service/internal/repository/models"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type CommentModel struct {
+	DB []*models.Comment
+	// Users, when set, resolves mentions; Tasks, when set, hides comments
+	// on trashed tasks from the mentions feed.
+	Users *UserModel
+	Tasks *TaskModel
+	// Audit, when set, records every change.
+	Audit *AuditModel
+
+	lastID    int
+	revisions map[int][]*models.CommentRevision
+}
+
+func (m *CommentModel) Insert(ctx context.Context, input *models.CommentInput) (int, error) {
+	m.lastID++
+	defer m.Audit.track(ctx, "comments", m.lastID, models.AuditInsert).record()
+
+	m.DB = append(m.DB, &models.Comment{ID: m.lastID, TaskID: input.TaskID, AuthorID: input.AuthorID, ParentID: input.ParentID, Body: input.Body, Mentions: m.mentioned(input.Mentions()), Created: time.Now(), Version: 1})
+
+	return m.lastID, nil
+}
+
+func (m *CommentModel) Get(ctx context.Context, id string) (*models.Comment, error) {
+	for _, c := range m.DB {
+		if strconv.Itoa(c.ID) == id {
+			return c, nil
+		}
+	}
+
+	return &models.Comment{}, models.ErrNoRecord
+}
+
+func (m *CommentModel) Update(ctx context.Context, id string, input *models.CommentInput, version int) (int, error) {
+	defer m.Audit.track(ctx, "comments", atoi(id), models.AuditUpdate).record()
+
+	c, err := m.Get(ctx, id)
+	if err != nil {
+		return 0, err
+	}
+	if c.Version != version {
+		return 0, models.ErrEditConflict
+	}
+
+	written := c.Created
+	if c.Edited != nil {
+		written = *c.Edited
+	}
+
+	if m.revisions == nil {
+		m.revisions = make(map[int][]*models.CommentRevision)
+	}
+	m.revisions[c.ID] = append(m.revisions[c.ID], &models.CommentRevision{Version: c.Version, Body: c.Body, Written: written})
+
+	now := time.Now()
+	c.Body, c.Mentions, c.Edited = input.Body, m.mentioned(input.Mentions()), &now
+	c.Version++
+
+	return c.Version, nil
+}
+
+// Delete removes the comment together with its replies.
+func (m *CommentModel) Delete(ctx context.Context, id string) error {
+	c, err := m.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	removed := map[int]bool{c.ID: true}
+	for changed := true; changed; {
+		changed = false
+		for _, r := range m.DB {
+			if r.ParentID != nil && removed[*r.ParentID] && !removed[r.ID] {
+				removed[r.ID], changed = true, true
+			}
+		}
+	}
+
+	var changes []*change
+	for _, r := range m.DB {
+		if removed[r.ID] {
+			changes = append(changes, m.Audit.track(ctx, "comments", r.ID, models.AuditDelete))
+		}
+	}
+
+	kept := m.DB[:0]
+	for _, r := range m.DB {
+		if !removed[r.ID] {
+			kept = append(kept, r)
+		}
+	}
+	m.DB = kept
+
+	for _, c := range changes {
+		c.record()
+	}
+
+	return nil
+}
+
+func (m *CommentModel) Thread(ctx context.Context, taskID string, filters models.Filters) ([]*models.Comment, models.Metadata, error) {
+	var roots, replies []*models.Comment
+
+	// threads are assembled on copies so that stored comments keep no replies
+	for _, c := range m.DB {
+		if strconv.Itoa(c.TaskID) != taskID {
+			continue
+		}
+
+		c := *c
+		if c.ParentID == nil {
+			roots = append(roots, &c)
+		} else {
+			replies = append(replies, &c)
+		}
+	}
+
+	roots, metadata := paginate(roots, filters, commentField)
+
+	return models.Thread(roots, replies), metadata, nil
+}
+
+func (m *CommentModel) History(ctx context.Context, id string) ([]*models.CommentRevision, error) {
+	commentID, _ := strconv.Atoi(id)
+
+	return append([]*models.CommentRevision{}, m.revisions[commentID]...), nil
+}
+
+func (m *CommentModel) Mentions(ctx context.Context, userID string, filters models.Filters) ([]*models.Comment, models.Metadata, error) {
+	comments := []*models.Comment{}
+
+	for _, c := range m.DB {
+		if m.Tasks != nil {
+			if _, err := m.Tasks.Get(ctx, strconv.Itoa(c.TaskID)); err != nil {
+				continue
+			}
+		}
+
+		for _, id := range c.Mentions {
+			if strconv.Itoa(id) == userID {
+				comments = append(comments, c)
+				break
+			}
+		}
+	}
+
+	// newest first, like the postgres model
+	filters.Sort, filters.SortSafelist = []string{"-id"}, []string{"id"}
+	comments, metadata := paginate(comments, filters, commentField)
+
+	return comments, metadata, nil
+}
+
+// mentioned resolves handles to the IDs of the live users whose name or
+// email address matches one of them.
+func (m *CommentModel) mentioned(handles []string) []int {
+	ids := []int{}
+	if m.Users == nil {
+		return ids
+	}
+
+	for _, u := range m.Users.DB {
+		for _, h := range handles {
+			if strings.EqualFold(u.Name, h) || strings.EqualFold(u.Email, h) {
+				ids = append(ids, u.ID)
+				break
+			}
+		}
+	}
+	sort.Ints(ids)
+
+	return ids
+}
+
+func commentField(c *models.Comment, name string) interface{} {
+	switch name {
+	case "id":
+		return c.ID
+	case "author_id":
+		return c.AuthorID
+	case "created":
+		return c.Created
+	case "edited":
+		return c.Edited
+	}
+
+	return nil
+}
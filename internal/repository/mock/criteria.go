@@ -0,0 +1,80 @@
+package mock
+
+import (
+	"fmt"
+	"pm-service/internal/repository/models"
+	"strings"
+	"time"
+)
+
+// matches reports whether a record, exposed through field, satisfies every
+// condition, mirroring the WHERE clause the postgres models build.
+func matches(criteria models.Criteria, field func(string) interface{}) bool {
+	for _, c := range criteria {
+		if match(c, field(c.Field)) == c.Negate {
+			return false
+		}
+	}
+
+	return true
+}
+
+func match(c models.Condition, value interface{}) bool {
+	switch c.Op {
+	case models.OpEqual, models.OpEqualFold:
+		for _, v := range c.Values {
+			if c.Op == models.OpEqual && fmt.Sprint(value) == v {
+				return true
+			}
+			if c.Op == models.OpEqualFold && strings.EqualFold(fmt.Sprint(value), v) {
+				return true
+			}
+		}
+		return false
+
+	case models.OpHasAny, models.OpHasAll:
+		held, _ := value.([]string)
+		for _, v := range c.Values {
+			found := false
+			for _, h := range held {
+				found = found || strings.EqualFold(h, v)
+			}
+			if found && c.Op == models.OpHasAny {
+				return true
+			}
+			if !found && c.Op == models.OpHasAll {
+				return false
+			}
+		}
+		return c.Op == models.OpHasAll
+
+	case models.OpContains:
+		return strings.Contains(strings.ToLower(fmt.Sprint(value)), strings.ToLower(c.Values[0]))
+
+	case models.OpFrom, models.OpBefore:
+		var t time.Time
+		switch v := value.(type) {
+		case time.Time:
+			t = v
+		case *time.Time:
+			if v == nil {
+				return false
+			}
+			t = *v
+		default:
+			return false
+		}
+
+		bound, err := time.Parse(time.RFC3339, c.Values[0])
+		if err != nil {
+			return false
+		}
+
+		if c.Op == models.OpFrom {
+			return !t.Before(bound)
+		}
+		return t.Before(bound)
+	}
+
+	return false
+}
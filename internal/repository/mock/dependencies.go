@@ -0,0 +1,140 @@
+package mock
+
+import (
+	"context"
+	"pm-service/internal/repository/models"
+	"sort"
+)
+
+type DependencyModel struct {
+	DB    []models.Dependency
+	Tasks *TaskModel
+	// Audit, when set, records every change as one to the dependent task.
+	Audit *AuditModel
+}
+
+func (m *DependencyModel) Add(ctx context.Context, taskID, blockerID int) error {
+	defer m.Audit.track(ctx, "tasks", taskID, models.AuditUpdate).record()
+
+	if m.waitsOn(blockerID, taskID) {
+		return models.ErrDependencyCycle
+	}
+
+	for _, d := range m.DB {
+		if d.TaskID == taskID && d.BlockerID == blockerID {
+			return nil
+		}
+	}
+
+	m.DB = append(m.DB, models.Dependency{TaskID: taskID, BlockerID: blockerID})
+
+	return nil
+}
+
+func (m *DependencyModel) Remove(ctx context.Context, taskID, blockerID int) error {
+	defer m.Audit.track(ctx, "tasks", taskID, models.AuditUpdate).record()
+
+	for i, d := range m.DB {
+		if d.TaskID == taskID && d.BlockerID == blockerID {
+			m.DB = append(m.DB[:i], m.DB[i+1:]...)
+			return nil
+		}
+	}
+
+	return models.ErrNoRecord
+}
+
+func (m *DependencyModel) Blockers(ctx context.Context, taskID int) ([]*models.Task, error) {
+	return m.linked(func(d models.Dependency) (int, bool) { return d.BlockerID, d.TaskID == taskID }), nil
+}
+
+func (m *DependencyModel) Dependents(ctx context.Context, taskID int) ([]*models.Task, error) {
+	return m.linked(func(d models.Dependency) (int, bool) { return d.TaskID, d.BlockerID == taskID }), nil
+}
+
+func (m *DependencyModel) Graph(ctx context.Context, projectID int) ([]*models.Task, []models.Dependency, error) {
+	m.Tasks.flag()
+
+	tasks := []*models.Task{}
+	in := map[int]bool{}
+	for _, s := range m.Tasks.DB {
+		if s.ProjectID == projectID {
+			tasks = append(tasks, s)
+			in[s.ID] = true
+		}
+	}
+
+	links := []models.Dependency{}
+	for _, d := range m.DB {
+		if in[d.TaskID] && in[d.BlockerID] {
+			links = append(links, d)
+		}
+	}
+
+	return tasks, links, nil
+}
+
+// linked returns the live tasks picked out of the links by pick, ordered by
+// id.
+func (m *DependencyModel) linked(pick func(models.Dependency) (int, bool)) []*models.Task {
+	m.Tasks.flag()
+
+	ids := map[int]bool{}
+	for _, d := range m.DB {
+		if id, ok := pick(d); ok {
+			ids[id] = true
+		}
+	}
+
+	tasks := []*models.Task{}
+	for _, s := range m.Tasks.DB {
+		if ids[s.ID] {
+			tasks = append(tasks, s)
+		}
+	}
+
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].ID < tasks[j].ID })
+
+	return tasks
+}
+
+// waitsOn reports whether taskID depends on blockerID, directly or through
+// other tasks.
+func (m *DependencyModel) waitsOn(taskID, blockerID int) bool {
+	seen := map[int]bool{taskID: true}
+	queue := []int{taskID}
+
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if id == blockerID {
+			return true
+		}
+
+		for _, d := range m.DB {
+			if d.TaskID == id && !seen[d.BlockerID] {
+				seen[d.BlockerID] = true
+				queue = append(queue, d.BlockerID)
+			}
+		}
+	}
+
+	return false
+}
+
+// blocked reports whether any live task the task depends on is incomplete.
+func (m *DependencyModel) blocked(taskID int) bool {
+	for _, d := range m.DB {
+		if d.TaskID != taskID {
+			continue
+		}
+
+		for _, s := range m.Tasks.DB {
+			if s.ID == d.BlockerID && s.Completed == nil {
+				return true
+			}
+		}
+	}
+
+	return false
+}
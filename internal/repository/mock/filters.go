@@ -0,0 +1,91 @@
+package mock
+
+import (
+	"fmt"
+	"pm-service/internal/repository/models"
+	"sort"
+	"time"
+)
+
+// paginate orders items by the filters' sort fields and cuts out the
+// requested page, mirroring what the postgres models do in SQL.
+func paginate[T any](items []T, filters models.Filters, field func(T, string) interface{}) ([]T, models.Metadata) {
+	fields, desc := filters.SortFields()
+
+	sorted := append([]T{}, items...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		for k, name := range fields {
+			c := compare(field(sorted[i], name), field(sorted[j], name))
+			if c == 0 {
+				continue
+			}
+			if desc[k] {
+				return c > 0
+			}
+			return c < 0
+		}
+		return false
+	})
+
+	start, end, metadata := page(len(sorted), filters)
+
+	return sorted[start:end], metadata
+}
+
+// page returns the bounds of the requested page among total items and its
+// metadata. A page past the last is empty.
+func page(total int, filters models.Filters) (start, end int, metadata models.Metadata) {
+	start, end = filters.Offset(), filters.Offset()+filters.Limit()
+	if start > total {
+		start = total
+	}
+	if end > total {
+		end = total
+	}
+
+	return start, end, models.CalculateMetadata(total, filters.Page, filters.PageSize)
+}
+
+func compare(a, b interface{}) int {
+	switch a := a.(type) {
+	case int:
+		b := b.(int)
+		switch {
+		case a < b:
+			return -1
+		case a > b:
+			return 1
+		}
+		return 0
+	case time.Time:
+		b := b.(time.Time)
+		switch {
+		case a.Before(b):
+			return -1
+		case a.After(b):
+			return 1
+		}
+		return 0
+	case *time.Time:
+		// like PostgreSQL, NULL sorts after any value in ascending order
+		b := b.(*time.Time)
+		switch {
+		case a == nil && b == nil:
+			return 0
+		case a == nil:
+			return 1
+		case b == nil:
+			return -1
+		}
+		return compare(*a, *b)
+	}
+
+	as, bs := fmt.Sprint(a), fmt.Sprint(b)
+	switch {
+	case as < bs:
+		return -1
+	case as > bs:
+		return 1
+	}
+	return 0
+}
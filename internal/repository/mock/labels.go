@@ -0,0 +1,222 @@
+package mock
+
+import (
+	"context"
+	"fmt"
+	"pm-service/internal/repository/models"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type LabelModel struct {
+	DB []*models.Label
+	// Tasks, when set, decides which labels still apply to a task that
+	// moved to another project.
+	Tasks *TaskModel
+	// Audit, when set, records every change.
+	Audit *AuditModel
+
+	lastID int
+	links  []taskLabel
+}
+
+type taskLabel struct {
+	taskID  int
+	labelID int
+}
+
+func (m *LabelModel) Insert(ctx context.Context, input *models.LabelInput) (int, error) {
+	if m.taken(input.ProjectID, input.Name, 0) {
+		return -1, models.ErrDuplicateName
+	}
+
+	m.lastID++
+	defer m.Audit.track(ctx, "labels", m.lastID, models.AuditInsert).record()
+
+	m.DB = append(m.DB, &models.Label{ID: m.lastID, ProjectID: input.ProjectID, Name: input.Name, Color: input.Color, Created: time.Now(), Version: 1})
+
+	return m.lastID, nil
+}
+
+func (m *LabelModel) Get(ctx context.Context, id string) (*models.Label, error) {
+	for _, l := range m.DB {
+		if strconv.Itoa(l.ID) == id {
+			return l, nil
+		}
+	}
+
+	return &models.Label{}, models.ErrNoRecord
+}
+
+func (m *LabelModel) Update(ctx context.Context, id string, input *models.LabelInput, version int) (int, error) {
+	defer m.Audit.track(ctx, "labels", atoi(id), models.AuditUpdate).record()
+
+	l, err := m.Get(ctx, id)
+	if err != nil {
+		return 0, err
+	}
+	if l.Version != version {
+		return 0, models.ErrEditConflict
+	}
+
+	if m.taken(l.ProjectID, input.Name, l.ID) {
+		return 0, models.ErrDuplicateName
+	}
+
+	l.Name, l.Color = input.Name, input.Color
+	l.Version++
+
+	return l.Version, nil
+}
+
+func (m *LabelModel) Delete(ctx context.Context, id string) error {
+	defer m.Audit.track(ctx, "labels", atoi(id), models.AuditDelete).record()
+
+	for i, l := range m.DB {
+		if strconv.Itoa(l.ID) != id {
+			continue
+		}
+
+		var changes []*change
+		for _, tl := range m.links {
+			if tl.labelID == l.ID {
+				changes = append(changes, m.Audit.track(ctx, "tasks", tl.taskID, models.AuditUpdate))
+			}
+		}
+
+		m.DB = append(m.DB[:i], m.DB[i+1:]...)
+
+		links := m.links[:0]
+		for _, tl := range m.links {
+			if tl.labelID != l.ID {
+				links = append(links, tl)
+			}
+		}
+		m.links = links
+
+		for _, c := range changes {
+			c.record()
+		}
+
+		return nil
+	}
+
+	return models.ErrNoRecord
+}
+
+func (m *LabelModel) GetAllBy(ctx context.Context, arg, val string, filters models.Filters) ([]*models.Label, models.Metadata, error) {
+	labels := []*models.Label{}
+
+	for _, l := range m.DB {
+		if fmt.Sprint(labelField(l, arg)) == val {
+			labels = append(labels, l)
+		}
+	}
+
+	labels, metadata := paginate(labels, filters, labelField)
+
+	return labels, metadata, nil
+}
+
+func (m *LabelModel) Attach(ctx context.Context, taskID, labelID int) error {
+	defer m.Audit.track(ctx, "tasks", taskID, models.AuditUpdate).record()
+
+	for _, tl := range m.links {
+		if tl.taskID == taskID && tl.labelID == labelID {
+			return nil
+		}
+	}
+
+	m.links = append(m.links, taskLabel{taskID, labelID})
+
+	return nil
+}
+
+func (m *LabelModel) Detach(ctx context.Context, taskID, labelID int) error {
+	defer m.Audit.track(ctx, "tasks", taskID, models.AuditUpdate).record()
+
+	for i, tl := range m.links {
+		if tl.taskID == taskID && tl.labelID == labelID {
+			m.links = append(m.links[:i], m.links[i+1:]...)
+			return nil
+		}
+	}
+
+	return models.ErrNoRecord
+}
+
+func (m *LabelModel) OfTask(ctx context.Context, taskID int) ([]*models.Label, error) {
+	projectID := -1
+	if m.Tasks != nil {
+		if s, err := m.Tasks.Get(ctx, strconv.Itoa(taskID)); err == nil {
+			projectID = s.ProjectID
+		}
+	}
+
+	labels := []*models.Label{}
+	for _, tl := range m.links {
+		if tl.taskID != taskID {
+			continue
+		}
+
+		for _, l := range m.DB {
+			if l.ID == tl.labelID && (projectID == -1 || l.ProjectID == projectID) {
+				labels = append(labels, l)
+			}
+		}
+	}
+
+	sort.Slice(labels, func(i, j int) bool { return strings.ToLower(labels[i].Name) < strings.ToLower(labels[j].Name) })
+
+	return labels, nil
+}
+
+// names returns the lowercased names of the labels on the task, for
+// matching label filters.
+func (m *LabelModel) names(s *models.Task) []string {
+	names := []string{}
+	for _, tl := range m.links {
+		if tl.taskID != s.ID {
+			continue
+		}
+
+		for _, l := range m.DB {
+			if l.ID == tl.labelID && l.ProjectID == s.ProjectID {
+				names = append(names, strings.ToLower(l.Name))
+			}
+		}
+	}
+
+	return names
+}
+
+// taken reports whether another label of the project, other than except,
+// already has the name.
+func (m *LabelModel) taken(projectID int, name string, except int) bool {
+	for _, l := range m.DB {
+		if l.ProjectID == projectID && l.ID != except && strings.EqualFold(l.Name, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func labelField(l *models.Label, name string) interface{} {
+	switch name {
+	case "id":
+		return l.ID
+	case "project_id":
+		return l.ProjectID
+	case "name":
+		return l.Name
+	case "color":
+		return l.Color
+	case "created":
+		return l.Created
+	}
+
+	return nil
+}
@@ -0,0 +1,132 @@
+package mock
+
+import (
+	"context"
+	"pm-service/internal/repository/models"
+	"sort"
+	"time"
+)
+
+type MemberModel struct {
+	DB []*models.Member
+	// Users, when set, hides trashed users; Projects, when set, lists a
+	// user's projects.
+	Users    *UserModel
+	Projects *ProjectModel
+	// Audit, when set, records every change as one to the project.
+	Audit *AuditModel
+}
+
+func (m *MemberModel) Get(ctx context.Context, projectID, userID int) (*models.Member, error) {
+	for _, mb := range m.DB {
+		if mb.ProjectID == projectID && mb.UserID == userID && (m.Users == nil || m.Users.live(userID)) {
+			return mb, nil
+		}
+	}
+
+	return &models.Member{}, models.ErrNoRecord
+}
+
+func (m *MemberModel) Set(ctx context.Context, projectID, userID int, role string) error {
+	defer m.Audit.track(ctx, "projects", projectID, models.AuditUpdate).record()
+
+	m.put(projectID, userID, role)
+
+	return nil
+}
+
+// put adds the member or changes its role, leaving the recording of the
+// change to the caller.
+func (m *MemberModel) put(projectID, userID int, role string) {
+	for _, mb := range m.DB {
+		if mb.ProjectID == projectID && mb.UserID == userID {
+			mb.Role = role
+			return
+		}
+	}
+
+	m.DB = append(m.DB, &models.Member{ProjectID: projectID, UserID: userID, Role: role, Added: time.Now()})
+}
+
+func (m *MemberModel) Remove(ctx context.Context, projectID, userID int) error {
+	defer m.Audit.track(ctx, "projects", projectID, models.AuditUpdate).record()
+
+	for i, mb := range m.DB {
+		if mb.ProjectID == projectID && mb.UserID == userID {
+			m.DB = append(m.DB[:i], m.DB[i+1:]...)
+			return nil
+		}
+	}
+
+	return models.ErrNoRecord
+}
+
+func (m *MemberModel) List(ctx context.Context, projectID int, filters models.Filters) ([]*models.Member, models.Metadata, error) {
+	members := []*models.Member{}
+
+	for _, mb := range m.DB {
+		if mb.ProjectID == projectID && (m.Users == nil || m.Users.live(mb.UserID)) {
+			members = append(members, mb)
+		}
+	}
+
+	// strongest role first, like the postgres model
+	sort.SliceStable(members, func(i, j int) bool {
+		ri, rj := rank(members[i].Role), rank(members[j].Role)
+		return ri < rj || ri == rj && members[i].UserID < members[j].UserID
+	})
+
+	filters.Sort, filters.SortSafelist = nil, nil
+	members, metadata := paginate(members, filters, func(*models.Member, string) interface{} { return 0 })
+
+	return members, metadata, nil
+}
+
+func (m *MemberModel) OfUser(ctx context.Context, userID int, filters models.Filters) ([]*models.Membership, models.Metadata, error) {
+	memberships := []*models.Membership{}
+
+	for _, mb := range m.DB {
+		if mb.UserID != userID || m.Projects == nil {
+			continue
+		}
+
+		for _, s := range m.Projects.DB {
+			if s.ID == mb.ProjectID {
+				memberships = append(memberships, &models.Membership{Project: *s, Role: mb.Role, Added: mb.Added})
+			}
+		}
+	}
+
+	filters.Sort, filters.SortSafelist = nil, nil
+	memberships, metadata := paginate(memberships, filters, func(s *models.Membership, name string) interface{} { return s.ID })
+
+	return memberships, metadata, nil
+}
+
+// reassign hands the memberships of user from over to user to, keeping
+// the stronger role where both are members of a project.
+func (m *MemberModel) reassign(from, to int) {
+	for _, mb := range m.DB {
+		if mb.UserID != from {
+			continue
+		}
+
+		current, err := m.Get(context.Background(), mb.ProjectID, to)
+		if err != nil {
+			m.put(mb.ProjectID, to, mb.Role)
+		} else if rank(mb.Role) < rank(current.Role) {
+			current.Role = mb.Role
+		}
+	}
+}
+
+// rank orders roles from the strongest.
+func rank(role string) int {
+	for i, r := range models.ProjectRoles {
+		if r == role {
+			return i
+		}
+	}
+
+	return len(models.ProjectRoles)
+}
@@ -0,0 +1,106 @@
+package mock
+
+import (
+	"context"
+	"encoding/json"
+	"pm-service/internal/repository/models"
+	"pm-service/internal/service/audit"
+	"pm-service/internal/service/events"
+	"time"
+)
+
+type OutboxModel struct {
+	DB []*models.OutboxEvent
+	// Bus, when set, has the events relayed to it as soon as they are
+	// kept, standing in for the relay the app runs in the background.
+	Bus *events.Bus
+
+	lastID int
+}
+
+// emit keeps the domain events a change raises. view is the record after
+// the change, or before a delete.
+func (m *OutboxModel) emit(ctx context.Context, resource string, id int, action string, view map[string]interface{}, diff map[string]models.Change) {
+	if m == nil {
+		return
+	}
+
+	for _, e := range events.FromChange(resource, id, action, view, diff) {
+		payload, err := json.Marshal(e)
+		if err != nil {
+			continue
+		}
+
+		m.lastID++
+		m.DB = append(m.DB, &models.OutboxEvent{
+			ID: m.lastID, Name: e.Name(), Payload: payload,
+			ActorID: audit.Actor(ctx), RequestID: audit.RequestID(ctx), Created: time.Now(), NextAttempt: time.Now(),
+		})
+	}
+
+	if m.Bus != nil {
+		relay := &events.Relay{Outbox: m, Bus: m.Bus, BatchSize: len(m.DB)}
+		relay.Relay(ctx)
+	}
+}
+
+func (m *OutboxModel) Claim(ctx context.Context, limit int, lease time.Duration) ([]*models.OutboxEvent, error) {
+	claimed := []*models.OutboxEvent{}
+	now := time.Now()
+
+	for _, e := range m.DB {
+		if len(claimed) == limit {
+			break
+		}
+		if e.Published != nil || e.Failed != nil || e.NextAttempt.After(now) {
+			continue
+		}
+
+		e.Attempts++
+		e.NextAttempt = now.Add(lease)
+		claimed = append(claimed, e)
+	}
+
+	return claimed, nil
+}
+
+func (m *OutboxModel) Published(ctx context.Context, id int) error {
+	for _, e := range m.DB {
+		if e.ID == id {
+			now := time.Now()
+			e.Published = &now
+		}
+	}
+
+	return nil
+}
+
+func (m *OutboxModel) Failed(ctx context.Context, id int, next time.Time, dead bool) error {
+	for _, e := range m.DB {
+		if e.ID == id && e.Published == nil {
+			e.NextAttempt = next
+			if dead {
+				now := time.Now()
+				e.Failed = &now
+			}
+		}
+	}
+
+	return nil
+}
+
+func (m *OutboxModel) Purge(ctx context.Context, before time.Time) (int64, error) {
+	var purged int64
+
+	kept := m.DB[:0]
+	for _, e := range m.DB {
+		if e.Published != nil && e.Published.Before(before) || e.Failed != nil && e.Failed.Before(before) {
+			purged++
+			continue
+		}
+		kept = append(kept, e)
+	}
+	m.DB = kept
+
+	return purged, nil
+}
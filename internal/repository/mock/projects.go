@@ -1,45 +1,229 @@
 package mock
 
 import (
+	"context"
+	"fmt"
 	"pm-service/internal/repository/models"
+	"sort"
+	"strconv"
+	"time"
 )
 
 type ProjectModel struct {
 	DB []*models.Project
+	// Tasks, when set, receives cascading deletes and restores.
+	Tasks *TaskModel
+	// Users, when set, is checked on restore.
+	Users *UserModel
+	// Members, when set, receives the manager as the project's owner.
+	Members *MemberModel
+	// Audit, when set, records every change.
+	Audit *AuditModel
+
+	trash []trashed[*models.Project]
 }
 
-func (m *ProjectModel) Insert(input *models.ProjectInput) (int, error) {
-	var id int
+func (m *ProjectModel) Insert(ctx context.Context, input *models.ProjectInput) (int, error) {
+	id := len(m.DB) + len(m.trash) + 1
+	defer m.Audit.track(ctx, "projects", id, models.AuditInsert).record()
+
+	m.DB = append(m.DB, &models.Project{ID: id, Title: input.Title, Description: input.Description, ManagerID: input.ManagerID, Created: time.Now(), Completed: input.CompletedAt(), Version: 1})
+
+	if m.Members != nil {
+		m.Members.put(id, input.ManagerID, "owner")
+	}
 
 	return id, nil
 }
 
-func (m *ProjectModel) Get(id string) (*models.Project, error) {
-	s := &models.Project{}
+func (m *ProjectModel) Get(ctx context.Context, id string) (*models.Project, error) {
+	for _, s := range m.DB {
+		if strconv.Itoa(s.ID) == id {
+			return s, nil
+		}
+	}
 
-	return s, nil
+	return &models.Project{}, models.ErrNoRecord
 }
 
-func (m *ProjectModel) Delete(id string) error {
+func (m *ProjectModel) Delete(ctx context.Context, id string) error {
+	defer m.Audit.track(ctx, "projects", atoi(id), models.AuditDelete).record()
 
-	return nil
+	return m.discard(id, time.Now())
 }
 
-func (m *ProjectModel) Update(id string, input *models.ProjectInput) error {
+func (m *ProjectModel) discard(id string, at time.Time) error {
+	for i, s := range m.DB {
+		if strconv.Itoa(s.ID) == id {
+			m.DB = append(m.DB[:i], m.DB[i+1:]...)
+			m.trash = append(m.trash, trashed[*models.Project]{s, at})
+			return nil
+		}
+	}
 
-	return nil
+	return models.ErrNoRecord
 }
 
-func (m *ProjectModel) GetAll() ([]*models.Project, error) {
+func (m *ProjectModel) DeleteCascade(ctx context.Context, id string) error {
+	if _, err := m.Get(ctx, id); err != nil {
+		return err
+	}
 
-	projects := []*models.Project{}
+	defer m.Audit.track(ctx, "projects", atoi(id), models.AuditDelete).record()
+
+	at := time.Now()
+
+	if m.Tasks != nil {
+		for _, s := range append([]*models.Task{}, m.Tasks.DB...) {
+			if strconv.Itoa(s.ProjectID) == id {
+				c := m.Audit.track(ctx, "tasks", s.ID, models.AuditDelete)
+				m.Tasks.discard(strconv.Itoa(s.ID), at)
+				c.record()
+			}
+		}
+	}
+
+	return m.discard(id, at)
+}
 
-	return projects, nil
+func (m *ProjectModel) Restore(ctx context.Context, id string) error {
+	defer m.Audit.track(ctx, "projects", atoi(id), models.AuditRestore).record()
+
+	for i, t := range m.trash {
+		if strconv.Itoa(t.record.ID) != id {
+			continue
+		}
+
+		if m.Users != nil && !m.Users.live(t.record.ManagerID) {
+			return models.ErrTrashedReference
+		}
+
+		m.trash = append(m.trash[:i], m.trash[i+1:]...)
+		m.DB = append(m.DB, t.record)
+
+		var restored []int
+
+		if m.Tasks != nil {
+			tasks := m.Tasks.trash[:0]
+			for _, s := range m.Tasks.trash {
+				if s.record.ProjectID == t.record.ID && s.deleted.Equal(t.deleted) && (m.Users == nil || m.Users.live(s.record.AssigneeID)) {
+					m.Tasks.DB = append(m.Tasks.DB, s.record)
+					restored = append(restored, s.record.ID)
+					continue
+				}
+				tasks = append(tasks, s)
+			}
+			m.Tasks.trash = tasks
+		}
+
+		sort.Ints(restored)
+		for _, taskID := range restored {
+			m.Audit.track(ctx, "tasks", taskID, models.AuditRestore).record()
+		}
+
+		return nil
+	}
+
+	return models.ErrNoRecord
 }
 
-func (m *ProjectModel) GetAllBy(arg, val string) ([]*models.Project, error) {
+func (m *ProjectModel) live(id int) bool {
+	for _, s := range m.DB {
+		if s.ID == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (m *ProjectModel) Update(ctx context.Context, id string, input *models.ProjectInput, version int) (int, error) {
+	defer m.Audit.track(ctx, "projects", atoi(id), models.AuditUpdate).record()
+
+	s, err := m.Get(ctx, id)
+	if err != nil {
+		return 0, err
+	}
+	if s.Version != version {
+		return 0, models.ErrEditConflict
+	}
+
+	s.Title, s.Description, s.ManagerID, s.Completed = input.Title, input.Description, input.ManagerID, input.CompletedAt()
+	s.Version++
+
+	return s.Version, nil
+}
+
+// Patch writes the whole input: for the fields left out it already holds
+// the record's current values.
+func (m *ProjectModel) Patch(ctx context.Context, id string, input *models.ProjectInput, fields []string, version int) (int, error) {
+	return m.Update(ctx, id, input, version)
+}
 
+func (m *ProjectModel) GetAll(ctx context.Context, filters models.Filters) ([]*models.Project, models.Metadata, error) {
+	projects, metadata := paginate(m.DB, filters, projectField)
+
+	return projects, metadata, nil
+}
+
+func (m *ProjectModel) GetAllBy(ctx context.Context, arg, val string, filters models.Filters) ([]*models.Project, models.Metadata, error) {
+	projects := []*models.Project{}
+
+	for _, s := range m.DB {
+		if fmt.Sprint(projectField(s, arg)) == val {
+			projects = append(projects, s)
+		}
+	}
+
+	projects, metadata := paginate(projects, filters, projectField)
+
+	return projects, metadata, nil
+}
+
+func (m *ProjectModel) Search(ctx context.Context, criteria models.Criteria, filters models.Filters) ([]*models.Project, models.Metadata, error) {
 	projects := []*models.Project{}
 
-	return projects, nil
+	for _, s := range m.DB {
+		s := s
+		if matches(criteria, func(name string) interface{} { return projectField(s, name) }) {
+			projects = append(projects, s)
+		}
+	}
+
+	projects, metadata := paginate(projects, filters, projectField)
+
+	return projects, metadata, nil
+}
+
+func projectField(s *models.Project, name string) interface{} {
+	switch name {
+	case "id":
+		return s.ID
+	case "title":
+		return s.Title
+	case "description":
+		return s.Description
+	case "manager_id":
+		return s.ManagerID
+	case "created":
+		return s.Created
+	case "completed":
+		return s.Completed
+	}
+
+	return nil
+}
+
+// referenced reports whether any task, trashed or not, still belongs to the
+// project.
+func (m *ProjectModel) referenced(id int) bool {
+	if m.Tasks != nil {
+		for _, s := range m.Tasks.all() {
+			if s.ProjectID == id {
+				return true
+			}
+		}
+	}
+
+	return false
 }
@@ -0,0 +1,88 @@
+package mock
+
+import (
+	"context"
+	"pm-service/internal/repository/models"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// SearchModel is a naive in-memory stand-in for the PostgreSQL full-text
+// search: every term of q must appear in the title or description, and
+// title matches rank higher than description matches.
+type SearchModel struct {
+	Tasks    *TaskModel
+	Projects *ProjectModel
+}
+
+func (m *SearchModel) FullText(ctx context.Context, q string, types []string, filters models.Filters) ([]*models.SearchResult, models.Metadata, error) {
+	terms := strings.Fields(strings.ToLower(q))
+	results := []*models.SearchResult{}
+
+	add := func(kind string, id int, title, description string) {
+		rank := 0.0
+		for _, term := range terms {
+			inTitle := strings.Contains(strings.ToLower(title), term)
+			inDescription := strings.Contains(strings.ToLower(description), term)
+
+			switch {
+			case inTitle:
+				rank += 1.0
+			case inDescription:
+				rank += 0.4
+			default:
+				return
+			}
+		}
+
+		results = append(results, &models.SearchResult{Type: kind, ID: id, Title: title, Snippet: highlight(title+": "+description, terms), Rank: rank})
+	}
+
+	for _, kind := range types {
+		switch kind {
+		case "tasks":
+			for _, s := range m.Tasks.DB {
+				add(kind, s.ID, s.Title, s.Description)
+			}
+		case "projects":
+			for _, s := range m.Projects.DB {
+				add(kind, s.ID, s.Title, s.Description)
+			}
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Rank != results[j].Rank {
+			return results[i].Rank > results[j].Rank
+		}
+		if results[i].Type != results[j].Type {
+			return results[i].Type < results[j].Type
+		}
+		return results[i].ID < results[j].ID
+	})
+
+	start, end, metadata := page(len(results), filters)
+
+	return results[start:end], metadata, nil
+}
+
+// highlight marks the terms in text in a single pass, so that a term found
+// inside another, or inside the markup, is not marked twice. Longer terms
+// come first in the pattern to win over their prefixes.
+func highlight(text string, terms []string) string {
+	if len(terms) == 0 {
+		return text
+	}
+
+	sorted := append([]string{}, terms...)
+	sort.SliceStable(sorted, func(i, j int) bool { return len(sorted[i]) > len(sorted[j]) })
+
+	quoted := make([]string, len(sorted))
+	for i, term := range sorted {
+		quoted[i] = regexp.QuoteMeta(term)
+	}
+
+	rx := regexp.MustCompile("(?i)" + strings.Join(quoted, "|"))
+	return rx.ReplaceAllString(text, "<mark>$0</mark>")
+}
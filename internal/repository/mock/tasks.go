@@ -1,37 +1,235 @@
 package mock
 
 import (
+	"context"
+	"fmt"
 	"pm-service/internal/repository/models"
+	"sort"
+	"strconv"
+	"time"
 )
 
 type TaskModel struct {
 	DB []*models.Task
+	// Users and Projects, when set, are checked on restore.
+	Users    *UserModel
+	Projects *ProjectModel
+	// Dependencies, when set, decides which tasks are blocked; Labels,
+	// when set, is searched for label filters.
+	Dependencies *DependencyModel
+	Labels       *LabelModel
+	// Audit, when set, records every change.
+	Audit *AuditModel
+
+	trash []trashed[*models.Task]
 }
 
-func (m *TaskModel) Insert(input *models.TaskInput) (int, error) {
-	var id int
+func (m *TaskModel) Insert(ctx context.Context, input *models.TaskInput) (int, error) {
+	id := len(m.DB) + len(m.trash) + 1
+	defer m.Audit.track(ctx, "tasks", id, models.AuditInsert).record()
+
+	m.DB = append(m.DB, &models.Task{ID: id, Title: input.Title, Description: input.Description, Priority: input.Priority, Status: input.Status, AssigneeID: input.AssigneeID, ProjectID: input.ProjectID, ParentTaskID: input.ParentTaskID, Estimate: input.Estimate, Created: time.Now(), Completed: input.CompletedAt(), Version: 1})
 
 	return id, nil
 }
 
-func (m *TaskModel) Get(id string) (*models.Task, error) {
-	s := &models.Task{}
+func (m *TaskModel) Get(ctx context.Context, id string) (*models.Task, error) {
+	m.flag()
+
+	for _, s := range m.DB {
+		if strconv.Itoa(s.ID) == id {
+			return s, nil
+		}
+	}
 
-	return s, nil
+	return &models.Task{}, models.ErrNoRecord
 }
 
-func (m *TaskModel) Delete(id string) error {
-	return nil
+func (m *TaskModel) Delete(ctx context.Context, id string) error {
+	defer m.Audit.track(ctx, "tasks", atoi(id), models.AuditDelete).record()
+
+	return m.discard(id, time.Now())
 }
 
-func (m *TaskModel) Update(id string, input *models.TaskInput) error {
-	return nil
+func (m *TaskModel) discard(id string, at time.Time) error {
+	for i, s := range m.DB {
+		if strconv.Itoa(s.ID) == id {
+			m.DB = append(m.DB[:i], m.DB[i+1:]...)
+			m.trash = append(m.trash, trashed[*models.Task]{s, at})
+			return nil
+		}
+	}
+
+	return models.ErrNoRecord
+}
+
+func (m *TaskModel) Restore(ctx context.Context, id string) error {
+	defer m.Audit.track(ctx, "tasks", atoi(id), models.AuditRestore).record()
+
+	for i, t := range m.trash {
+		if strconv.Itoa(t.record.ID) != id {
+			continue
+		}
+
+		if m.Users != nil && !m.Users.live(t.record.AssigneeID) {
+			return models.ErrTrashedReference
+		}
+		if m.Projects != nil && !m.Projects.live(t.record.ProjectID) {
+			return models.ErrTrashedReference
+		}
+		if t.record.ParentTaskID != nil && !m.live(*t.record.ParentTaskID) {
+			return models.ErrTrashedReference
+		}
+
+		m.trash = append(m.trash[:i], m.trash[i+1:]...)
+		m.DB = append(m.DB, t.record)
+		return nil
+	}
+
+	return models.ErrNoRecord
+}
+
+func (m *TaskModel) live(id int) bool {
+	for _, s := range m.DB {
+		if s.ID == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+// flag derives Blocked for every live task, as the postgres model does
+// when reading.
+func (m *TaskModel) flag() {
+	for _, s := range m.DB {
+		s.Blocked = m.Dependencies != nil && m.Dependencies.blocked(s.ID)
+	}
+}
+
+func (m *TaskModel) Update(ctx context.Context, id string, input *models.TaskInput, version int) (int, error) {
+	defer m.Audit.track(ctx, "tasks", atoi(id), models.AuditUpdate).record()
+
+	s, err := m.Get(ctx, id)
+	if err != nil {
+		return 0, err
+	}
+	if s.Version != version {
+		return 0, models.ErrEditConflict
+	}
+
+	s.Title, s.Description, s.Priority, s.Status = input.Title, input.Description, input.Priority, input.Status
+	s.AssigneeID, s.ProjectID, s.ParentTaskID, s.Estimate, s.Completed = input.AssigneeID, input.ProjectID, input.ParentTaskID, input.Estimate, input.CompletedAt()
+	s.Version++
+
+	return s.Version, nil
 }
 
-func (m *TaskModel) GetAll() ([]*models.Task, error) {
-	return nil, nil
+// Patch writes the whole input: for the fields left out it already holds
+// the record's current values.
+func (m *TaskModel) Patch(ctx context.Context, id string, input *models.TaskInput, fields []string, version int) (int, error) {
+	return m.Update(ctx, id, input, version)
 }
 
-func (m *TaskModel) GetAllBy(arg, val string) ([]*models.Task, error) {
-	return nil, nil
+func (m *TaskModel) Tree(ctx context.Context, id string) ([]*models.Task, error) {
+	root, err := m.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	tree := []*models.Task{root}
+	in := map[int]bool{root.ID: true}
+
+	for changed := true; changed; {
+		changed = false
+		for _, s := range m.DB {
+			if s.ParentTaskID != nil && in[*s.ParentTaskID] && !in[s.ID] {
+				tree = append(tree, s)
+				in[s.ID], changed = true, true
+			}
+		}
+	}
+
+	sort.Slice(tree, func(i, j int) bool { return tree[i].ID < tree[j].ID })
+
+	return tree, nil
+}
+
+func (m *TaskModel) GetAll(ctx context.Context, filters models.Filters) ([]*models.Task, models.Metadata, error) {
+	m.flag()
+	tasks, metadata := paginate(m.DB, filters, taskField)
+
+	return tasks, metadata, nil
+}
+
+func (m *TaskModel) GetAllBy(ctx context.Context, arg, val string, filters models.Filters) ([]*models.Task, models.Metadata, error) {
+	m.flag()
+	tasks := []*models.Task{}
+
+	for _, s := range m.DB {
+		if fmt.Sprint(taskField(s, arg)) == val {
+			tasks = append(tasks, s)
+		}
+	}
+
+	tasks, metadata := paginate(tasks, filters, taskField)
+
+	return tasks, metadata, nil
+}
+
+func (m *TaskModel) Search(ctx context.Context, criteria models.Criteria, filters models.Filters) ([]*models.Task, models.Metadata, error) {
+	m.flag()
+	tasks := []*models.Task{}
+
+	for _, s := range m.DB {
+		s := s
+		field := func(name string) interface{} {
+			if name == "labels" && m.Labels != nil {
+				return m.Labels.names(s)
+			}
+			return taskField(s, name)
+		}
+
+		if matches(criteria, field) {
+			tasks = append(tasks, s)
+		}
+	}
+
+	tasks, metadata := paginate(tasks, filters, taskField)
+
+	return tasks, metadata, nil
+}
+
+func taskField(s *models.Task, name string) interface{} {
+	switch name {
+	case "id":
+		return s.ID
+	case "title":
+		return s.Title
+	case "description":
+		return s.Description
+	case "priority":
+		return s.Priority
+	case "status":
+		return s.Status
+	case "assignee_id":
+		return s.AssigneeID
+	case "project_id":
+		return s.ProjectID
+	case "parent_task_id":
+		if s.ParentTaskID == nil {
+			return nil
+		}
+		return *s.ParentTaskID
+	case "estimate":
+		return s.Estimate
+	case "created":
+		return s.Created
+	case "completed":
+		return s.Completed
+	case "blocked":
+		return s.Blocked
+	}
+
+	return nil
 }
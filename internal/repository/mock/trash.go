@@ -0,0 +1,107 @@
+package mock
+
+import (
+	"context"
+	"pm-service/internal/repository/models"
+	"sort"
+	"time"
+)
+
+// trashed is a soft-deleted record together with the time it was deleted.
+type trashed[T any] struct {
+	record  T
+	deleted time.Time
+}
+
+func (m *TaskModel) all() []*models.Task {
+	tasks := append([]*models.Task{}, m.DB...)
+	for _, t := range m.trash {
+		tasks = append(tasks, t.record)
+	}
+	return tasks
+}
+
+func (m *ProjectModel) all() []*models.Project {
+	projects := append([]*models.Project{}, m.DB...)
+	for _, t := range m.trash {
+		projects = append(projects, t.record)
+	}
+	return projects
+}
+
+type TrashModel struct {
+	Users    *UserModel
+	Projects *ProjectModel
+	Tasks    *TaskModel
+}
+
+func (m *TrashModel) List(ctx context.Context, types []string, filters models.Filters) ([]*models.TrashItem, models.Metadata, error) {
+	items := []*models.TrashItem{}
+
+	for _, kind := range types {
+		switch kind {
+		case "users":
+			for _, t := range m.Users.trash {
+				items = append(items, &models.TrashItem{Type: kind, ID: t.record.ID, Title: t.record.Name, Deleted: t.deleted})
+			}
+		case "projects":
+			for _, t := range m.Projects.trash {
+				items = append(items, &models.TrashItem{Type: kind, ID: t.record.ID, Title: t.record.Title, Deleted: t.deleted})
+			}
+		case "tasks":
+			for _, t := range m.Tasks.trash {
+				items = append(items, &models.TrashItem{Type: kind, ID: t.record.ID, Title: t.record.Title, Deleted: t.deleted})
+			}
+		}
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		if !items[i].Deleted.Equal(items[j].Deleted) {
+			return items[i].Deleted.After(items[j].Deleted)
+		}
+		if items[i].Type != items[j].Type {
+			return items[i].Type < items[j].Type
+		}
+		return items[i].ID < items[j].ID
+	})
+
+	start, end, metadata := page(len(items), filters)
+
+	return items[start:end], metadata, nil
+}
+
+func (m *TrashModel) Purge(ctx context.Context, before time.Time) (int64, error) {
+	var purged int64
+
+	tasks := m.Tasks.trash[:0]
+	for _, t := range m.Tasks.trash {
+		if t.deleted.Before(before) {
+			purged++
+			continue
+		}
+		tasks = append(tasks, t)
+	}
+	m.Tasks.trash = tasks
+
+	projects := m.Projects.trash[:0]
+	for _, t := range m.Projects.trash {
+		if t.deleted.Before(before) && !m.Projects.referenced(t.record.ID) {
+			purged++
+			continue
+		}
+		projects = append(projects, t)
+	}
+	m.Projects.trash = projects
+
+	users := m.Users.trash[:0]
+	for _, t := range m.Users.trash {
+		if t.deleted.Before(before) && !m.Users.referenced(t.record.ID) {
+			purged++
+			continue
+		}
+		users = append(users, t)
+	}
+	m.Users.trash = users
+
+	return purged, nil
+}
@@ -1,39 +1,241 @@
 package mock
 
 import (
+	"context"
+	"fmt"
 	"pm-service/internal/repository/models"
+	"strconv"
 	"time"
 )
 
 type UserModel struct {
 	DB []*models.User
+	// Tasks and Projects, when set, receive reassignments and are checked
+	// for references on purge the way the foreign keys in PostgreSQL would.
+	Tasks    *TaskModel
+	Projects *ProjectModel
+	// Members, when set, receives reassigned memberships.
+	Members *MemberModel
+	// Audit, when set, records every change.
+	Audit *AuditModel
+
+	trash []trashed[*models.User]
+	// passwords holds the password hashes by user id.
+	passwords map[int]string
 }
 
-func (m *UserModel) Insert(input *models.UserInput) (int, error) {
-	id := len(m.DB) + 1
-	m.DB = append(m.DB, &models.User{id, input.Name, input.Email, input.Role, time.Now().Format("2000-01-01")})
+func (m *UserModel) Insert(ctx context.Context, input *models.UserInput) (int, error) {
+	id := len(m.DB) + len(m.trash) + 1
+	defer m.Audit.track(ctx, "users", id, models.AuditInsert).record()
+
+	m.DB = append(m.DB, &models.User{ID: id, Name: input.Name, Email: input.Email, Role: input.Role, Created: time.Now(), Version: 1})
+
+	if input.PasswordHash != "" {
+		if m.passwords == nil {
+			m.passwords = make(map[int]string)
+		}
+		m.passwords[id] = input.PasswordHash
+	}
 
 	return id, nil
 }
 
-func (m *UserModel) Get(id string) (*models.User, error) {
-	s := &models.User{}
+func (m *UserModel) Get(ctx context.Context, id string) (*models.User, error) {
+	for _, s := range m.DB {
+		if strconv.Itoa(s.ID) == id {
+			return s, nil
+		}
+	}
 
-	return s, nil
+	return &models.User{}, models.ErrNoRecord
 }
 
-func (m *UserModel) Delete(id string) error {
-	return nil
+func (m *UserModel) Delete(ctx context.Context, id string) error {
+	defer m.Audit.track(ctx, "users", atoi(id), models.AuditDelete).record()
+
+	for i, s := range m.DB {
+		if strconv.Itoa(s.ID) == id {
+			m.DB = append(m.DB[:i], m.DB[i+1:]...)
+			m.trash = append(m.trash, trashed[*models.User]{s, time.Now()})
+			return nil
+		}
+	}
+
+	return models.ErrNoRecord
 }
 
-func (m *UserModel) Update(id string, input *models.UserInput) error {
-	return nil
+func (m *UserModel) Restore(ctx context.Context, id string) error {
+	defer m.Audit.track(ctx, "users", atoi(id), models.AuditRestore).record()
+
+	for i, t := range m.trash {
+		if strconv.Itoa(t.record.ID) == id {
+			m.trash = append(m.trash[:i], m.trash[i+1:]...)
+			m.DB = append(m.DB, t.record)
+			return nil
+		}
+	}
+
+	return models.ErrNoRecord
+}
+
+func (m *UserModel) live(id int) bool {
+	for _, s := range m.DB {
+		if s.ID == id {
+			return true
+		}
+	}
+
+	return false
 }
 
-func (m *UserModel) GetAll() ([]*models.User, error) {
-	return nil, nil
+func (m *UserModel) DeleteReassign(ctx context.Context, id, to string) error {
+	if _, err := m.Get(ctx, id); err != nil {
+		return err
+	}
+
+	fromID, toID := atoi(id), atoi(to)
+
+	// the projects and tasks handed over, recorded once all have moved
+	var changes []*change
+
+	if m.Projects != nil {
+		for _, s := range m.Projects.all() {
+			handed := s.ManagerID == fromID
+			if m.Members != nil {
+				if _, err := m.Members.Get(ctx, s.ID, fromID); err == nil {
+					handed = true
+				}
+			}
+
+			if handed {
+				changes = append(changes, m.Audit.track(ctx, "projects", s.ID, models.AuditUpdate))
+			}
+		}
+	}
+
+	if m.Tasks != nil {
+		for _, s := range m.Tasks.all() {
+			if s.AssigneeID == fromID {
+				changes = append(changes, m.Audit.track(ctx, "tasks", s.ID, models.AuditUpdate))
+				s.AssigneeID = toID
+				s.Version++
+			}
+		}
+	}
+
+	if m.Members != nil {
+		m.Members.reassign(fromID, toID)
+	}
+
+	if m.Projects != nil {
+		for _, s := range m.Projects.all() {
+			if s.ManagerID == fromID {
+				s.ManagerID = toID
+				s.Version++
+			}
+		}
+	}
+
+	for _, c := range changes {
+		c.record()
+	}
+
+	return m.Delete(ctx, id)
 }
 
-func (m *UserModel) GetAllBy(arg, val string) ([]*models.User, error) {
-	return nil, nil
+// referenced reports whether any task or project, trashed or not, still
+// points at the user.
+func (m *UserModel) referenced(id int) bool {
+	if m.Tasks != nil {
+		for _, s := range m.Tasks.all() {
+			if s.AssigneeID == id {
+				return true
+			}
+		}
+	}
+
+	if m.Projects != nil {
+		for _, s := range m.Projects.all() {
+			if s.ManagerID == id {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (m *UserModel) Update(ctx context.Context, id string, input *models.UserInput, version int) (int, error) {
+	defer m.Audit.track(ctx, "users", atoi(id), models.AuditUpdate).record()
+
+	s, err := m.Get(ctx, id)
+	if err != nil {
+		return 0, err
+	}
+	if s.Version != version {
+		return 0, models.ErrEditConflict
+	}
+
+	s.Name, s.Email, s.Role = input.Name, input.Email, input.Role
+	s.Version++
+
+	return s.Version, nil
+}
+
+// Patch writes the whole input: for the fields left out it already holds
+// the record's current values.
+func (m *UserModel) Patch(ctx context.Context, id string, input *models.UserInput, fields []string, version int) (int, error) {
+	return m.Update(ctx, id, input, version)
+}
+
+func (m *UserModel) GetAll(ctx context.Context, filters models.Filters) ([]*models.User, models.Metadata, error) {
+	users, metadata := paginate(m.DB, filters, userField)
+
+	return users, metadata, nil
+}
+
+func (m *UserModel) GetAllBy(ctx context.Context, arg, val string, filters models.Filters) ([]*models.User, models.Metadata, error) {
+	users := []*models.User{}
+
+	for _, s := range m.DB {
+		if fmt.Sprint(userField(s, arg)) == val {
+			users = append(users, s)
+		}
+	}
+
+	users, metadata := paginate(users, filters, userField)
+
+	return users, metadata, nil
+}
+
+func (m *UserModel) Search(ctx context.Context, criteria models.Criteria, filters models.Filters) ([]*models.User, models.Metadata, error) {
+	users := []*models.User{}
+
+	for _, s := range m.DB {
+		s := s
+		if matches(criteria, func(name string) interface{} { return userField(s, name) }) {
+			users = append(users, s)
+		}
+	}
+
+	users, metadata := paginate(users, filters, userField)
+
+	return users, metadata, nil
+}
+
+func userField(s *models.User, name string) interface{} {
+	switch name {
+	case "id":
+		return s.ID
+	case "name":
+		return s.Name
+	case "email":
+		return s.Email
+	case "role":
+		return s.Role
+	case "created":
+		return s.Created
+	}
+
+	return nil
 }
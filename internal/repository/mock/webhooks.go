@@ -0,0 +1,277 @@
+package mock
+
+import (
+	"context"
+	"pm-service/internal/repository/models"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type WebhookModel struct {
+	DB []*models.Webhook
+	// Audit, when set, records every change to the webhooks.
+	Audit *AuditModel
+
+	lastID int
+	// secrets holds the webhooks' secrets by webhook id.
+	secrets map[int]string
+	// secretVersions counts the secrets set on each webhook.
+	secretVersions map[int]int
+	deliveries     []*models.Delivery
+	// queued holds the source events, webhooks and events deliveries were
+	// queued for, see Enqueue.
+	queued map[string]bool
+	// mu serialises Enqueue, Claim and Attempted, which relays and
+	// dispatchers call from several goroutines at once.
+	mu sync.Mutex
+}
+
+// Enqueue queues a delivery of the event to each active webhook subscribed
+// to it. Deliveries already queued for the same source outbox event are
+// not queued again; a source of 0 names none.
+func (m *WebhookModel) Enqueue(ctx context.Context, event string, payload []byte, source int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, w := range m.DB {
+		if !w.Active || !subscribed(w, event) {
+			continue
+		}
+
+		key := strconv.Itoa(source) + " " + strconv.Itoa(w.ID) + " " + event
+		if source != 0 && m.queued[key] {
+			continue
+		}
+		if m.queued == nil {
+			m.queued = make(map[string]bool)
+		}
+		m.queued[key] = true
+
+		m.deliveries = append(m.deliveries, &models.Delivery{
+			ID: len(m.deliveries) + 1, WebhookID: w.ID, Event: event, Payload: payload,
+			Status: models.DeliveryPending, NextAttempt: time.Now(), Created: time.Now(), Log: []*models.DeliveryAttempt{},
+		})
+	}
+
+	return nil
+}
+
+func subscribed(w *models.Webhook, event string) bool {
+	for _, e := range w.Events {
+		if e == event {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (m *WebhookModel) Insert(ctx context.Context, input *models.WebhookInput) (int, error) {
+	m.lastID++
+	defer m.Audit.track(ctx, "webhooks", m.lastID, models.AuditInsert).record()
+
+	m.DB = append(m.DB, &models.Webhook{ID: m.lastID, URL: input.URL, Events: append([]string{}, input.Events...), Active: input.IsActive(), Created: time.Now(), Version: 1})
+
+	if m.secrets == nil {
+		m.secrets = make(map[int]string)
+		m.secretVersions = make(map[int]int)
+	}
+	m.secrets[m.lastID] = input.Secret
+	m.secretVersions[m.lastID] = 1
+
+	return m.lastID, nil
+}
+
+func (m *WebhookModel) Get(ctx context.Context, id string) (*models.Webhook, error) {
+	for _, w := range m.DB {
+		if strconv.Itoa(w.ID) == id {
+			return w, nil
+		}
+	}
+
+	return &models.Webhook{}, models.ErrNoRecord
+}
+
+func (m *WebhookModel) GetAll(ctx context.Context, filters models.Filters) ([]*models.Webhook, models.Metadata, error) {
+	webhooks, metadata := paginate(m.DB, filters, webhookField)
+
+	return webhooks, metadata, nil
+}
+
+func webhookField(w *models.Webhook, field string) interface{} {
+	switch field {
+	case "url":
+		return w.URL
+	case "active":
+		return w.Active
+	case "created":
+		return w.Created
+	}
+	return w.ID
+}
+
+func (m *WebhookModel) Update(ctx context.Context, id string, input *models.WebhookInput, version int) (int, error) {
+	defer m.Audit.track(ctx, "webhooks", atoi(id), models.AuditUpdate).record()
+
+	w, err := m.Get(ctx, id)
+	if err != nil {
+		return 0, err
+	}
+	if w.Version != version {
+		return 0, models.ErrEditConflict
+	}
+
+	w.URL, w.Events, w.Active = input.URL, append([]string{}, input.Events...), input.IsActive()
+	if input.Secret != "" {
+		m.secrets[w.ID] = input.Secret
+		m.secretVersions[w.ID]++
+	}
+	w.Version++
+
+	return w.Version, nil
+}
+
+// Delete removes the webhook together with its deliveries.
+func (m *WebhookModel) Delete(ctx context.Context, id string) error {
+	defer m.Audit.track(ctx, "webhooks", atoi(id), models.AuditDelete).record()
+
+	for i, w := range m.DB {
+		if strconv.Itoa(w.ID) != id {
+			continue
+		}
+
+		m.DB = append(m.DB[:i], m.DB[i+1:]...)
+		delete(m.secrets, w.ID)
+		delete(m.secretVersions, w.ID)
+
+		kept := m.deliveries[:0]
+		for _, d := range m.deliveries {
+			if d.WebhookID != w.ID {
+				kept = append(kept, d)
+			}
+		}
+		m.deliveries = kept
+
+		return nil
+	}
+
+	return models.ErrNoRecord
+}
+
+func (m *WebhookModel) Deliveries(ctx context.Context, webhookID int, criteria models.Criteria, filters models.Filters) ([]*models.Delivery, models.Metadata, error) {
+	deliveries := []*models.Delivery{}
+
+	for _, d := range m.deliveries {
+		if d.WebhookID == webhookID && matches(criteria, func(f string) interface{} { return deliveryField(d, f) }) {
+			// lists leave out the attempts, like the postgres model
+			d := *d
+			d.Log = nil
+			deliveries = append(deliveries, &d)
+		}
+	}
+
+	deliveries, metadata := paginate(deliveries, filters, deliveryField)
+
+	return deliveries, metadata, nil
+}
+
+func deliveryField(d *models.Delivery, field string) interface{} {
+	switch field {
+	case "event":
+		return d.Event
+	case "status":
+		return d.Status
+	case "attempts":
+		return d.Attempts
+	case "next_attempt":
+		return d.NextAttempt
+	case "created":
+		return d.Created
+	case "delivered":
+		return d.Delivered
+	}
+	return d.ID
+}
+
+func (m *WebhookModel) Delivery(ctx context.Context, webhookID, deliveryID int) (*models.Delivery, error) {
+	for _, d := range m.deliveries {
+		if d.ID == deliveryID && d.WebhookID == webhookID {
+			return d, nil
+		}
+	}
+
+	return &models.Delivery{}, models.ErrNoRecord
+}
+
+func (m *WebhookModel) Redeliver(ctx context.Context, webhookID, deliveryID int) error {
+	d, err := m.Delivery(ctx, webhookID, deliveryID)
+	if err != nil {
+		return err
+	}
+
+	d.Status, d.Attempts, d.NextAttempt, d.Delivered = models.DeliveryPending, 0, time.Now(), nil
+
+	return nil
+}
+
+// Claim returns up to limit pending deliveries of active webhooks that
+// are due, oldest due first, and postpones them by the lease.
+func (m *WebhookModel) Claim(ctx context.Context, limit int, lease time.Duration) ([]*models.DeliveryJob, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	due := []*models.Delivery{}
+
+	for _, d := range m.deliveries {
+		w, err := m.Get(ctx, strconv.Itoa(d.WebhookID))
+		if err == nil && w.Active && d.Status == models.DeliveryPending && !d.NextAttempt.After(now) {
+			due = append(due, d)
+		}
+	}
+
+	due, _ = paginate(due, models.Filters{Page: 1, PageSize: limit, Sort: []string{"next_attempt", "id"}, SortSafelist: []string{"next_attempt", "id"}}, deliveryField)
+
+	jobs := []*models.DeliveryJob{}
+
+	for _, d := range due {
+		d.NextAttempt = now.Add(lease)
+
+		w, _ := m.Get(ctx, strconv.Itoa(d.WebhookID))
+		job := &models.DeliveryJob{Delivery: *d, URL: w.URL, Secret: m.secrets[w.ID]}
+		job.Log = nil
+		jobs = append(jobs, job)
+	}
+
+	return jobs, nil
+}
+
+func (m *WebhookModel) Attempted(ctx context.Context, job *models.DeliveryJob, attempt *models.DeliveryAttempt, status string, next time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, d := range m.deliveries {
+		if d.ID != job.ID {
+			continue
+		}
+
+		if d.Status != models.DeliveryPending || !d.NextAttempt.Equal(job.NextAttempt) {
+			return models.ErrLeaseLost
+		}
+
+		d.Log = append(d.Log, attempt)
+		d.Status, d.NextAttempt = status, next
+		d.Attempts++
+
+		d.Delivered = nil
+		if status == models.DeliveryDelivered {
+			now := time.Now()
+			d.Delivered = &now
+		}
+
+		return nil
+	}
+
+	return models.ErrNoRecord
+}
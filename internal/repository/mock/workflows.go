@@ -0,0 +1,73 @@
+package mock
+
+import (
+	"context"
+	"pm-service/internal/repository/models"
+	"sort"
+	"strings"
+)
+
+type WorkflowModel struct {
+	DB map[int]*models.Workflow
+	// Tasks, when set, is consulted for the statuses in use.
+	Tasks *TaskModel
+	// Audit, when set, records every change.
+	Audit *AuditModel
+}
+
+func (m *WorkflowModel) Get(ctx context.Context, projectID int) (*models.Workflow, error) {
+	if w, ok := m.DB[projectID]; ok {
+		return w, nil
+	}
+
+	return models.DefaultWorkflow(), nil
+}
+
+func (m *WorkflowModel) Set(ctx context.Context, projectID int, w *models.Workflow) error {
+	defer m.Audit.track(ctx, "workflows", projectID, models.AuditUpdate).record()
+
+	if m.DB == nil {
+		m.DB = make(map[int]*models.Workflow)
+	}
+	m.DB[projectID] = w
+
+	return nil
+}
+
+func (m *WorkflowModel) States(ctx context.Context) ([]string, error) {
+	states := models.DefaultWorkflow().Names()
+	seen := map[string]bool{}
+	for _, s := range states {
+		seen[s] = true
+	}
+
+	for _, w := range m.DB {
+		for _, s := range w.States {
+			name := strings.ToLower(s.Name)
+			if !seen[name] {
+				seen[name] = true
+				states = append(states, name)
+			}
+		}
+	}
+
+	return states, nil
+}
+
+func (m *WorkflowModel) StatusesInUse(ctx context.Context, projectID int) ([]string, error) {
+	statuses := []string{}
+	if m.Tasks == nil {
+		return statuses, nil
+	}
+
+	seen := map[string]bool{}
+	for _, s := range m.Tasks.all() {
+		if s.ProjectID == projectID && !seen[s.Status] {
+			seen[s.Status] = true
+			statuses = append(statuses, s.Status)
+		}
+	}
+	sort.Strings(statuses)
+
+	return statuses, nil
+}
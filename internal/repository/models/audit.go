@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// Audit event actions.
+const (
+	AuditInsert  = "insert"
+	AuditUpdate  = "update"
+	AuditDelete  = "delete"
+	AuditRestore = "restore"
+)
+
+var (
+	AuditActions = []string{AuditInsert, AuditUpdate, AuditDelete, AuditRestore}
+	// AuditResources lists the audited resources. Project members are
+	// recorded as changes to their project, and a task's dependencies and
+	// labels as changes to the task.
+	AuditResources = []string{"users", "projects", "tasks", "comments", "labels", "workflows", "api_keys", "webhooks"}
+)
+
+// AuditEvent records one change to a resource: who made it, in which
+// request, and the fields it changed. ActorID is nil for changes made
+// outside of requests.
+type AuditEvent struct {
+	ID         int
+	ActorID    *int
+	Resource   string
+	ResourceID int
+	Action     string
+	Diff       map[string]Change
+	RequestID  string
+	Created    time.Time
+}
+
+// Change holds a field's value before and after an event, nil where the
+// record did not exist.
+type Change struct {
+	From interface{}
+	To   interface{}
+}
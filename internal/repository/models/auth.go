@@ -0,0 +1,54 @@
+package models
+
+import (
+	"time"
+)
+
+// APIKey describes one of a user's API keys. The key itself is never
+// stored; Hint holds its first characters so the user can tell keys apart.
+type APIKey struct {
+	ID       int
+	UserID   int
+	Name     string
+	Hint     string
+	Created  time.Time
+	LastUsed *time.Time
+}
+
+type APIKeyInput struct {
+	Name string `json:"name"`
+}
+
+// LoginInput holds the credentials exchanged for a token pair.
+type LoginInput struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// TokenInput carries a refresh token to rotate or revoke.
+type TokenInput struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// PasswordInput changes the current user's password. Current may be left
+// out while the user has no password yet.
+type PasswordInput struct {
+	Current  string `json:"current_password"`
+	Password string `json:"password"`
+}
+
+func (i *Input) NewAPIKeyInput() APIKeyInput {
+	return APIKeyInput{}
+}
+
+func (i *Input) NewLoginInput() LoginInput {
+	return LoginInput{}
+}
+
+func (i *Input) NewTokenInput() TokenInput {
+	return TokenInput{}
+}
+
+func (i *Input) NewPasswordInput() PasswordInput {
+	return PasswordInput{}
+}
@@ -0,0 +1,87 @@
+package models
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Comment is a remark on a task. A reply names the comment it answers in
+// ParentID; when a thread is listed replies are nested under their parent
+// in Replies. Mentions holds the IDs of the users mentioned in Body.
+type Comment struct {
+	ID       int
+	TaskID   int
+	AuthorID int
+	ParentID *int
+	Body     string
+	Mentions []int
+	Created  time.Time
+	Edited   *time.Time
+	Version  int
+	Replies  []*Comment
+}
+
+// CommentRevision is an earlier text of an edited comment, written at the
+// given time.
+type CommentRevision struct {
+	Version int
+	Body    string
+	Written time.Time
+}
+
+type CommentInput struct {
+	TaskID   int    `json:"-"`
+	AuthorID int    `json:"author_id"`
+	ParentID *int   `json:"parent_id"`
+	Body     string `json:"body"`
+}
+
+func (i *Input) NewCommentInput() CommentInput {
+	return CommentInput{}
+}
+
+// mentionRx matches @handle, where the handle is a user's name or email
+// address. An @ inside a word, as in an email address, does not start a
+// mention.
+var mentionRx = regexp.MustCompile(`(?:^|[^\w@.+-])@([\w.+-]+(?:@[\w-]+(?:\.[\w-]+)+)?)`)
+
+// Mentions returns the distinct handles mentioned in the body, lowercased
+// and sorted.
+func (i *CommentInput) Mentions() []string {
+	seen := map[string]bool{}
+	handles := []string{}
+
+	for _, m := range mentionRx.FindAllStringSubmatch(i.Body, -1) {
+		handle := strings.ToLower(strings.TrimRight(m[1], ".+-"))
+		if handle != "" && !seen[handle] {
+			seen[handle] = true
+			handles = append(handles, handle)
+		}
+	}
+	sort.Strings(handles)
+
+	return handles
+}
+
+// Thread nests the replies under the comments they answer and returns
+// roots. Replies are attached in the order given; those whose parent is
+// neither in roots nor among the replies are dropped.
+func Thread(roots, replies []*Comment) []*Comment {
+	byID := make(map[int]*Comment, len(roots)+len(replies))
+	for _, list := range [][]*Comment{roots, replies} {
+		for _, c := range list {
+			c.Replies = []*Comment{}
+			byID[c.ID] = c
+		}
+	}
+
+	for _, c := range replies {
+		if parent, ok := byID[*c.ParentID]; ok {
+			parent.Replies = append(parent.Replies, c)
+		}
+	}
+
+	return roots
+}
@@ -0,0 +1,33 @@
+package models
+
+// Operators understood by the repositories when matching a Condition.
+const (
+	// OpEqual matches any of the values exactly.
+	OpEqual = "eq"
+	// OpEqualFold matches any of the values ignoring case.
+	OpEqualFold = "eqfold"
+	// OpContains matches a case-insensitive substring of the first value.
+	OpContains = "contains"
+	// OpFrom matches timestamps at or after the first value.
+	OpFrom = "from"
+	// OpBefore matches timestamps strictly before the first value.
+	OpBefore = "before"
+	// OpHasAny matches records holding any of the values ignoring case,
+	// for fields with several values per record.
+	OpHasAny = "hasany"
+	// OpHasAll matches records holding all of the values ignoring case.
+	OpHasAll = "hasall"
+)
+
+// Condition restricts a list to records whose Field satisfies Op for the
+// given values. Negate inverts the match. Field is an API field name which
+// each repository maps onto its own whitelisted columns.
+type Condition struct {
+	Field  string
+	Op     string
+	Values []string
+	Negate bool
+}
+
+// Criteria are combined with AND.
+type Criteria []Condition
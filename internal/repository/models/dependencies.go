@@ -0,0 +1,82 @@
+package models
+
+import "sort"
+
+// Dependency records that a task cannot start before its blocker is
+// completed.
+type Dependency struct {
+	TaskID    int
+	BlockerID int
+}
+
+// CriticalPath returns the chain of dependent tasks with the largest total
+// estimate, blockers first, together with that total. Only links between
+// the given tasks are followed; ties go to the chain ending in, and then
+// passing through, the lowest task IDs.
+func CriticalPath(tasks []*Task, links []Dependency) ([]*Task, int) {
+	byID := make(map[int]*Task, len(tasks))
+	for _, s := range tasks {
+		byID[s.ID] = s
+	}
+
+	blockers := map[int][]int{}
+	dependents := map[int][]int{}
+	pending := map[int]int{}
+	for _, l := range links {
+		if byID[l.TaskID] == nil || byID[l.BlockerID] == nil {
+			continue
+		}
+		blockers[l.TaskID] = append(blockers[l.TaskID], l.BlockerID)
+		dependents[l.BlockerID] = append(dependents[l.BlockerID], l.TaskID)
+		pending[l.TaskID]++
+	}
+
+	var ready []int
+	for _, s := range tasks {
+		if pending[s.ID] == 0 {
+			ready = append(ready, s.ID)
+		}
+	}
+
+	// walk the tasks in topological order, keeping for each the longest
+	// chain ending in it
+	length := map[int]int{}
+	prev := map[int]int{}
+	for len(ready) > 0 {
+		sort.Ints(ready)
+		id := ready[0]
+		ready = ready[1:]
+
+		best := 0
+		for _, b := range blockers[id] {
+			if p, ok := prev[id]; !ok || length[b] > best || length[b] == best && b < p {
+				best, prev[id] = length[b], b
+			}
+		}
+		length[id] = best + byID[id].Estimate
+
+		for _, d := range dependents[id] {
+			if pending[d]--; pending[d] == 0 {
+				ready = append(ready, d)
+			}
+		}
+	}
+
+	end, total := 0, -1
+	for _, s := range tasks {
+		if l, ok := length[s.ID]; ok && (l > total || l == total && s.ID < end) {
+			end, total = s.ID, l
+		}
+	}
+
+	if total < 0 {
+		return []*Task{}, 0
+	}
+
+	path := []*Task{}
+	for id, ok := end, true; ok; id, ok = prev[id] {
+		path = append([]*Task{byID[id]}, path...)
+	}
+
+	return path, total
+}
@@ -7,8 +7,60 @@ type Errors struct {
 
 var (
 	ErrNoRecord = errors.New("models: no matching record found")
+	// ErrReferenced is returned when a delete is blocked by rows that
+	// still reference the record through a foreign key.
+	ErrReferenced = errors.New("models: record is still referenced")
+	// ErrTrashedReference is returned when a record cannot be restored
+	// because a user or project it references is itself in the trash.
+	ErrTrashedReference = errors.New("models: record references a trashed record")
+	// ErrEditConflict is returned when a record changed since the version
+	// the caller based its update on.
+	ErrEditConflict = errors.New("models: edit conflict")
+	// ErrIllegalTransition is returned when a task's status change is not
+	// allowed by its project's workflow.
+	ErrIllegalTransition = errors.New("models: illegal status transition")
+	// ErrOpenSubtasks is returned when a task is completed while some of
+	// its subtasks are still open.
+	ErrOpenSubtasks = errors.New("models: task has open subtasks")
+	// ErrDependencyCycle is returned when a dependency would make a task
+	// wait for itself.
+	ErrDependencyCycle = errors.New("models: dependency cycle")
+	// ErrDuplicateName is returned when a name that must be unique is
+	// already taken.
+	ErrDuplicateName = errors.New("models: duplicate name")
+	// ErrLeaseLost is returned when a claimed webhook delivery is logged
+	// after another dispatcher has claimed it again.
+	ErrLeaseLost = errors.New("models: lease lost")
 )
 
 func (e *Errors) NoRecordError() error {
 	return ErrNoRecord
 }
+
+func (e *Errors) ReferencedError() error {
+	return ErrReferenced
+}
+
+func (e *Errors) TrashedReferenceError() error {
+	return ErrTrashedReference
+}
+
+func (e *Errors) EditConflictError() error {
+	return ErrEditConflict
+}
+
+func (e *Errors) IllegalTransitionError() error {
+	return ErrIllegalTransition
+}
+
+func (e *Errors) OpenSubtasksError() error {
+	return ErrOpenSubtasks
+}
+
+func (e *Errors) DependencyCycleError() error {
+	return ErrDependencyCycle
+}
+
+func (e *Errors) DuplicateNameError() error {
+	return ErrDuplicateName
+}
@@ -0,0 +1,88 @@
+package models
+
+import (
+	"math"
+	"strings"
+)
+
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+// Filters describes which page of a list to return and how to order it.
+// Sort holds field names, each optionally prefixed with "-" for
+// descending order; only names present in SortSafelist are ever used.
+type Filters struct {
+	Page         int
+	PageSize     int
+	Sort         []string
+	SortSafelist []string
+}
+
+// Metadata accompanies every paginated list response. NextPage is 0 on the
+// last page; pages are numbered rather than keyed by cursor because lists
+// are sorted on arbitrary fields, with id breaking ties so that numbered
+// pages stay stable.
+type Metadata struct {
+	CurrentPage  int `json:"current_page,omitempty"`
+	PageSize     int `json:"page_size,omitempty"`
+	FirstPage    int `json:"first_page,omitempty"`
+	LastPage     int `json:"last_page,omitempty"`
+	NextPage     int `json:"next_page,omitempty"`
+	TotalRecords int `json:"total_records"`
+}
+
+func (f Filters) Limit() int {
+	return f.PageSize
+}
+
+func (f Filters) Offset() int {
+	return (f.Page - 1) * f.PageSize
+}
+
+// SortFields returns the safelisted sort fields paired with their direction,
+// always ending with id so that pages are stable.
+func (f Filters) SortFields() (fields []string, desc []bool) {
+	seen, safe := map[string]bool{}, map[string]bool{}
+	for _, name := range f.SortSafelist {
+		safe[name] = true
+	}
+
+	for _, s := range f.Sort {
+		name := strings.TrimPrefix(s, "-")
+		if seen[name] || !safe[name] {
+			continue
+		}
+		seen[name] = true
+		fields = append(fields, name)
+		desc = append(desc, strings.HasPrefix(s, "-"))
+	}
+
+	if !seen["id"] {
+		fields = append(fields, "id")
+		desc = append(desc, false)
+	}
+
+	return fields, desc
+}
+
+func CalculateMetadata(totalRecords, page, pageSize int) Metadata {
+	if totalRecords == 0 {
+		return Metadata{}
+	}
+
+	m := Metadata{
+		CurrentPage:  page,
+		PageSize:     pageSize,
+		FirstPage:    1,
+		LastPage:     int(math.Ceil(float64(totalRecords) / float64(pageSize))),
+		TotalRecords: totalRecords,
+	}
+
+	if page < m.LastPage {
+		m.NextPage = page + 1
+	}
+
+	return m
+}
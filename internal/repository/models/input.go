@@ -1,18 +1,12 @@
 package models
 
-import (
-	"regexp"
-	"strconv"
-	"strings"
-)
-
-var (
-	dateRegex  = regexp.MustCompile("^[0-9]{4}-(0[1-9]|1[0-2])-(0[1-9]|[1-2][0-9]|3[0-1])$")
-	priorRegex = regexp.MustCompile("^(high|medium|low)$")
-	idRegex    = regexp.MustCompile("^([0-9]+)$")
-	EmailRX    = regexp.MustCompile("^[a-zA-Z0-9.!#$%&'*+/=?^_`{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$")
-	statusRX   = regexp.MustCompile("^(to do|in progress|completed)$")
-)
+import "time"
+
+var Priorities = []string{"high", "medium", "low"}
+
+// UserRoles lists the roles a user can hold across the service, see
+// package policy for what each may do.
+var UserRoles = []string{"admin", "manager", "developer", "viewer"}
 
 type Input struct {
 }
@@ -21,16 +15,22 @@ type UserInput struct {
 	Name  string `json:"name"`
 	Email string `json:"email"`
 	Role  string `json:"role"`
+	// Password is optional and only accepted when the user is created;
+	// the handler stores its hash in PasswordHash.
+	Password     string `json:"password"`
+	PasswordHash string `json:"-"`
 }
 
 type TaskInput struct {
-	Title       string `json:"title"`
-	Description string `json:"description"`
-	Priority    string `json:"priority"`
-	Status      string `json:"status"`
-	AssigneeID  int    `json:"assignee_id"`
-	ProjectID   int    `json:"project_id"`
-	Completed   string `json:"completed"`
+	Title        string `json:"title"`
+	Description  string `json:"description"`
+	Priority     string `json:"priority"`
+	Status       string `json:"status"`
+	AssigneeID   int    `json:"assignee_id"`
+	ProjectID    int    `json:"project_id"`
+	ParentTaskID *int   `json:"parent_task_id"`
+	Estimate     int    `json:"estimate"`
+	Completed    string `json:"completed"`
 }
 
 type ProjectInput struct {
@@ -52,14 +52,50 @@ func (i *Input) NewProjectInput() ProjectInput {
 	return ProjectInput{}
 }
 
-func (i *ProjectInput) IsValid() bool {
-	return dateRegex.MatchString(i.Completed) || idRegex.MatchString(strconv.Itoa(i.ManagerID))
+// ParseDate accepts either a calendar date (2006-01-02) or an RFC 3339
+// timestamp. An empty string yields nil, meaning "not completed".
+func ParseDate(s string) (*time.Time, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		t, err = time.Parse("2006-01-02", s)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &t, nil
+}
+
+// CompletedAt returns the parsed completion date, nil when it is empty or malformed.
+func (i *ProjectInput) CompletedAt() *time.Time {
+	t, _ := ParseDate(i.Completed)
+	return t
+}
+
+// CompletedAt returns the parsed completion date, nil when it is empty or malformed.
+func (i *TaskInput) CompletedAt() *time.Time {
+	t, _ := ParseDate(i.Completed)
+	return t
+}
+
+// Values returns the input's column values keyed by column name.
+func (i *UserInput) Values() map[string]interface{} {
+	return map[string]interface{}{"name": i.Name, "email": i.Email, "role": i.Role}
 }
 
-func (i *TaskInput) IsValid() bool {
-	return dateRegex.MatchString(i.Completed) || priorRegex.MatchString(strings.ToLower(i.Priority)) || statusRX.MatchString(strings.ToLower(i.Status)) || idRegex.MatchString(strconv.Itoa(i.AssigneeID)) || idRegex.MatchString(strconv.Itoa(i.ProjectID))
+// Values returns the input's column values keyed by column name.
+func (i *ProjectInput) Values() map[string]interface{} {
+	return map[string]interface{}{"title": i.Title, "description": i.Description, "manager_id": i.ManagerID, "completed": i.CompletedAt()}
 }
 
-func (i *UserInput) IsValid() bool {
-	return EmailRX.MatchString(i.Email)
+// Values returns the input's column values keyed by column name.
+func (i *TaskInput) Values() map[string]interface{} {
+	return map[string]interface{}{
+		"title": i.Title, "description": i.Description, "priority": i.Priority, "status": i.Status,
+		"assignee_id": i.AssigneeID, "project_id": i.ProjectID, "parent_task_id": i.ParentTaskID, "estimate": i.Estimate, "completed": i.CompletedAt(),
+	}
 }
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// Label categorises tasks of its project. Names are unique within a
+// project, ignoring case; Color is a hex code such as #1e90ff.
+type Label struct {
+	ID        int
+	ProjectID int
+	Name      string
+	Color     string
+	Created   time.Time
+	Version   int
+}
+
+type LabelInput struct {
+	ProjectID int    `json:"-"`
+	Name      string `json:"name"`
+	Color     string `json:"color"`
+}
+
+func (i *Input) NewLabelInput() LabelInput {
+	return LabelInput{}
+}
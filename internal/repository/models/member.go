@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+// ProjectRoles lists the roles a user can hold in a project, strongest
+// first.
+var ProjectRoles = []string{"owner", "manager", "member", "viewer"}
+
+// Member is a user's membership of a project.
+type Member struct {
+	ProjectID int
+	UserID    int
+	Role      string
+	Added     time.Time
+}
+
+// Manages reports whether the member may manage the project, as its
+// owner or a manager.
+func (m *Member) Manages() bool {
+	return m.Role == "owner" || m.Role == "manager"
+}
+
+// Assignable reports whether the member may be assigned the project's
+// tasks; viewers may not.
+func (m *Member) Assignable() bool {
+	return m.Role != "viewer"
+}
+
+// Membership is a project as seen by one of its members, with the member's
+// Role.
+type Membership struct {
+	Project
+	Role  string
+	Added time.Time
+}
+
+type MemberInput struct {
+	UserID int    `json:"user_id"`
+	Role   string `json:"role"`
+}
+
+func (i *Input) NewMemberInput() MemberInput {
+	return MemberInput{}
+}
@@ -1,23 +1,51 @@
 package models
 
+import "time"
+
+// formatDate renders an optional timestamp the way inputs expect it.
+func formatDate(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
 type User struct {
 	ID      int
 	Name    string
 	Email   string
 	Role    string
-	Created string
+	Created time.Time
+	Version int
+}
+
+// Input returns the user as the input that would recreate it.
+func (s *User) Input() UserInput {
+	return UserInput{Name: s.Name, Email: s.Email, Role: s.Role}
 }
 
 type Task struct {
-	ID          int
-	Title       string
-	Description string
-	Priority    string
-	Status      string
-	AssigneeID  int
-	ProjectID   int
-	Created     string
-	Completed   string
+	ID           int
+	Title        string
+	Description  string
+	Priority     string
+	Status       string
+	AssigneeID   int
+	ProjectID    int
+	ParentTaskID *int
+	Estimate     int
+	Created      time.Time
+	Completed    *time.Time
+	Version      int
+	Blocked      bool
+}
+
+// Input returns the task as the input that would recreate it.
+func (s *Task) Input() TaskInput {
+	return TaskInput{
+		Title: s.Title, Description: s.Description, Priority: s.Priority, Status: s.Status,
+		AssigneeID: s.AssigneeID, ProjectID: s.ProjectID, ParentTaskID: s.ParentTaskID, Estimate: s.Estimate, Completed: formatDate(s.Completed),
+	}
 }
 
 type Project struct {
@@ -25,6 +53,31 @@ type Project struct {
 	Title       string
 	Description string
 	ManagerID   int
-	Created     string
-	Completed   string
+	Created     time.Time
+	Completed   *time.Time
+	Version     int
+}
+
+// Input returns the project as the input that would recreate it.
+func (s *Project) Input() ProjectInput {
+	return ProjectInput{Title: s.Title, Description: s.Description, ManagerID: s.ManagerID, Completed: formatDate(s.Completed)}
+}
+
+// SearchResult is a single full-text search hit. Snippet holds the matching
+// text with the matched terms wrapped in <mark></mark>.
+type SearchResult struct {
+	Type    string
+	ID      int
+	Title   string
+	Snippet string
+	Rank    float64
+}
+
+// TrashItem is a soft-deleted user, project or task. Title holds the
+// user's name for users.
+type TrashItem struct {
+	Type    string
+	ID      int
+	Title   string
+	Deleted time.Time
 }
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// OutboxEvent is a domain event as kept in the outbox until it is relayed
+// to the subscribers. ActorID and RequestID are those of the change that
+// raised it. Published is nil until it has been relayed, and Failed until
+// the relay gave up on it; until either, the event is relayed again from
+// NextAttempt on. Attempts counts the times it was claimed for relaying.
+type OutboxEvent struct {
+	ID          int
+	Name        string
+	Payload     json.RawMessage
+	ActorID     *int
+	RequestID   string
+	Created     time.Time
+	Attempts    int
+	NextAttempt time.Time
+	Published   *time.Time
+	Failed      *time.Time
+}
@@ -0,0 +1,71 @@
+package models
+
+// Progress rolls up the completion of all of a task's subtasks, direct or
+// nested. Percent is 0 for a task without subtasks.
+type Progress struct {
+	Total     int
+	Completed int
+	Percent   int
+}
+
+// TaskNode is a task with its subtasks nested below it.
+type TaskNode struct {
+	*Task
+	Progress Progress
+	Subtasks []*TaskNode
+}
+
+// BuildTree nests tasks under their parents, starting from the task with
+// the given id, and rolls up each node's progress. Subtasks keep the order
+// of tasks. It returns nil when root is not among tasks.
+func BuildTree(root int, tasks []*Task) *TaskNode {
+	nodes := make(map[int]*TaskNode, len(tasks))
+	for _, s := range tasks {
+		nodes[s.ID] = &TaskNode{Task: s, Subtasks: []*TaskNode{}}
+	}
+
+	for _, s := range tasks {
+		if s.ID == root || s.ParentTaskID == nil {
+			continue
+		}
+		if parent, ok := nodes[*s.ParentTaskID]; ok {
+			parent.Subtasks = append(parent.Subtasks, nodes[s.ID])
+		}
+	}
+
+	node, ok := nodes[root]
+	if !ok {
+		return nil
+	}
+
+	node.rollUp(map[int]bool{})
+
+	return node
+}
+
+func (n *TaskNode) rollUp(seen map[int]bool) {
+	seen[n.ID] = true
+
+	for _, sub := range n.Subtasks {
+		if seen[sub.ID] {
+			continue
+		}
+		sub.rollUp(seen)
+
+		n.Progress.Total += sub.Progress.Total + 1
+		n.Progress.Completed += sub.Progress.Completed
+		if sub.Completed != nil {
+			n.Progress.Completed++
+		}
+	}
+
+	if n.Progress.Total > 0 {
+		n.Progress.Percent = n.Progress.Completed * 100 / n.Progress.Total
+	}
+}
+
+// Open reports how many of the node's subtasks, direct or nested, are not
+// completed.
+func (n *TaskNode) Open() int {
+	return n.Progress.Total - n.Progress.Completed
+}
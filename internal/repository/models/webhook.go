@@ -0,0 +1,86 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// WebhookEvents lists the events a webhook can subscribe to.
+var WebhookEvents = []string{
+	"task.created", "task.updated", "task.status_changed", "task.completed", "task.deleted", "task.restored",
+	"project.created", "project.updated", "project.completed", "project.deleted", "project.restored",
+	"comment.created", "comment.updated", "comment.deleted",
+}
+
+// Delivery statuses. Pending deliveries are retried until they are
+// delivered or, having used up their attempts, dead.
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryDead      = "dead"
+)
+
+var DeliveryStatuses = []string{DeliveryPending, DeliveryDelivered, DeliveryDead}
+
+// Webhook subscribes a URL to events. Its secret, which signs the
+// deliveries, is never returned.
+type Webhook struct {
+	ID      int
+	URL     string
+	Events  []string
+	Active  bool
+	Created time.Time
+	Version int
+}
+
+// Delivery is one event queued for, or sent to, a webhook. Log lists the
+// requests made so far when the delivery is read on its own.
+type Delivery struct {
+	ID          int
+	WebhookID   int
+	Event       string
+	Payload     json.RawMessage `swaggertype:"object"`
+	Status      string
+	Attempts    int
+	NextAttempt time.Time
+	Created     time.Time
+	Delivered   *time.Time
+	Log         []*DeliveryAttempt
+}
+
+// DeliveryAttempt records one request made for a delivery. StatusCode is 0
+// when the receiver could not be reached.
+type DeliveryAttempt struct {
+	StatusCode int
+	Error      string
+	DurationMS int
+	Created    time.Time
+}
+
+// DeliveryJob is a delivery claimed for sending, with where to send it and
+// the secret to sign it with. Its NextAttempt is when the claim runs out,
+// and identifies the claim.
+type DeliveryJob struct {
+	Delivery
+	URL    string
+	Secret string
+}
+
+// WebhookInput creates or replaces a webhook. A blank Secret keeps the
+// current one on update and is generated on create; Active defaults to
+// true.
+type WebhookInput struct {
+	URL    string   `json:"url"`
+	Secret string   `json:"secret"`
+	Events []string `json:"events"`
+	Active *bool    `json:"active"`
+}
+
+func (i *Input) NewWebhookInput() WebhookInput {
+	return WebhookInput{}
+}
+
+// IsActive reports whether the webhook is to receive deliveries.
+func (i *WebhookInput) IsActive() bool {
+	return i.Active == nil || *i.Active
+}
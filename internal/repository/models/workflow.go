@@ -0,0 +1,109 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// Workflow lists the statuses a project's tasks may take and which status
+// changes are allowed. A task entering a terminal status is completed.
+type Workflow struct {
+	States      []WorkflowState      `json:"states"`
+	Transitions []WorkflowTransition `json:"transitions"`
+}
+
+type WorkflowState struct {
+	Name     string `json:"name"`
+	Terminal bool   `json:"terminal"`
+}
+
+type WorkflowTransition struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// DefaultWorkflow is used by projects that have not configured their own:
+// to do -> in progress -> completed, with the way back open.
+func DefaultWorkflow() *Workflow {
+	return &Workflow{
+		States: []WorkflowState{
+			{Name: "to do"},
+			{Name: "in progress"},
+			{Name: "completed", Terminal: true},
+		},
+		Transitions: []WorkflowTransition{
+			{From: "to do", To: "in progress"},
+			{From: "in progress", To: "to do"},
+			{From: "in progress", To: "completed"},
+			{From: "completed", To: "in progress"},
+		},
+	}
+}
+
+// State returns the name of the state matching name case-insensitively.
+func (w *Workflow) State(name string) (string, bool) {
+	for _, s := range w.States {
+		if strings.EqualFold(s.Name, name) {
+			return s.Name, true
+		}
+	}
+	return "", false
+}
+
+func (w *Workflow) Names() []string {
+	names := make([]string, len(w.States))
+	for i, s := range w.States {
+		names[i] = s.Name
+	}
+	return names
+}
+
+func (w *Workflow) Terminal(name string) bool {
+	for _, s := range w.States {
+		if strings.EqualFold(s.Name, name) {
+			return s.Terminal
+		}
+	}
+	return false
+}
+
+// Allows reports whether a task may move from one status to another.
+// Staying in the same status is always allowed.
+func (w *Workflow) Allows(from, to string) bool {
+	if strings.EqualFold(from, to) {
+		return true
+	}
+
+	for _, t := range w.Transitions {
+		if strings.EqualFold(t.From, from) && strings.EqualFold(t.To, to) {
+			return true
+		}
+	}
+	return false
+}
+
+// CompletedFor returns the completion date of a task entering status: the
+// given date, or now when it is empty, for terminal statuses and no date
+// otherwise.
+func (w *Workflow) CompletedFor(status, completed string, now time.Time) string {
+	if !w.Terminal(status) {
+		return ""
+	}
+
+	if completed == "" {
+		return now.UTC().Format(time.RFC3339)
+	}
+
+	return completed
+}
+
+// Next lists the statuses reachable from the given one.
+func (w *Workflow) Next(from string) []string {
+	next := []string{}
+	for _, t := range w.Transitions {
+		if strings.EqualFold(t.From, from) {
+			next = append(next, t.To)
+		}
+	}
+	return next
+}
@@ -0,0 +1,240 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"pm-service/internal/repository/models"
+	"pm-service/internal/repository/query"
+	"pm-service/internal/service/audit"
+	"sort"
+	"time"
+)
+
+// auditViews select what the audit log records of a resource's row with
+// the id $1, as one JSON object keyed like the resource's input. Rows are
+// locked so that the view taken before a change is the one it applies to.
+// Password hashes and webhook secrets stand in as the counters of how
+// often they were set, enough to tell that they changed without telling
+// anything about them.
+var auditViews = map[string]string{
+	"users": `SELECT jsonb_build_object('name', name, 'email', email, 'role', role, 'password', CASE WHEN password_hash <> '' THEN token_generation END)
+		FROM users WHERE id = $1 FOR UPDATE;`,
+	"projects": `SELECT jsonb_build_object('title', title, 'description', description, 'manager_id', manager_id, 'completed', completed,
+		'members', (SELECT COALESCE(jsonb_object_agg(user_id, role), '{}') FROM project_members WHERE project_id = projects.id))
+		FROM projects WHERE id = $1 FOR UPDATE;`,
+	"tasks": `SELECT jsonb_build_object('title', title, 'description', description, 'priority', priority, 'status', status,
+		'assignee_id', assignee_id, 'project_id', project_id, 'parent_task_id', parent_task_id, 'estimate', estimate, 'completed', completed,
+		'blockers', (SELECT COALESCE(jsonb_agg(blocker_id ORDER BY blocker_id), '[]') FROM task_dependencies WHERE task_id = tasks.id),
+		'labels', (SELECT COALESCE(jsonb_agg(label_id ORDER BY label_id), '[]') FROM task_labels WHERE task_id = tasks.id))
+		FROM tasks WHERE id = $1 FOR UPDATE;`,
+	"comments": `SELECT jsonb_build_object('task_id', task_id, 'author_id', author_id, 'parent_id', parent_id, 'body', body)
+		FROM comments WHERE id = $1 FOR UPDATE;`,
+	"labels": `SELECT jsonb_build_object('project_id', project_id, 'name', name, 'color', color)
+		FROM labels WHERE id = $1 FOR UPDATE;`,
+	"workflows": `SELECT jsonb_build_object(
+		'states', (SELECT jsonb_agg(jsonb_build_object('name', name, 'terminal', terminal) ORDER BY position) FROM workflow_states WHERE project_id = p.id),
+		'transitions', (SELECT jsonb_agg(jsonb_build_object('from', from_state, 'to', to_state) ORDER BY from_state, to_state) FROM workflow_transitions WHERE project_id = p.id))
+		FROM projects p WHERE id = $1 FOR UPDATE;`,
+	"api_keys": `SELECT jsonb_build_object('user_id', user_id, 'name', name, 'hint', hint)
+		FROM api_keys WHERE id = $1 FOR UPDATE;`,
+	"webhooks": `SELECT jsonb_build_object('url', url, 'secret', secret_version, 'events', events, 'active', active)
+		FROM webhooks WHERE id = $1 FOR UPDATE;`,
+}
+
+// snapshot returns the audit view of the resource's row with the given id,
+// nil when there is none.
+func snapshot(ctx context.Context, tx *sql.Tx, resource string, id interface{}) (map[string]interface{}, error) {
+	var raw []byte
+
+	err := tx.QueryRowContext(ctx, auditViews[resource], id).Scan(&raw)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	var view map[string]interface{}
+	if err := json.Unmarshal(raw, &view); err != nil {
+		return nil, err
+	}
+
+	return view, nil
+}
+
+// snapshots returns the audit views of the resource's rows whose ids stmt
+// selects, keyed by id.
+func snapshots(ctx context.Context, tx *sql.Tx, resource, stmt string, args ...interface{}) (map[int]map[string]interface{}, error) {
+	rows, err := tx.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []int
+
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	views := make(map[int]map[string]interface{}, len(ids))
+
+	for _, id := range ids {
+		if views[id], err = snapshot(ctx, tx, resource, id); err != nil {
+			return nil, err
+		}
+	}
+
+	return views, nil
+}
+
+// record writes an audit event for the change made to the resource's row
+// since before was taken and keeps the domain events it raises in the
+// outbox, from where a bus handler queues the webhook deliveries.
+// Inserts and restores are recorded against no previous state and deletes
+// against none after. Changes that leave the view as it was are not
+// recorded.
+func record(ctx context.Context, tx *sql.Tx, resource string, id interface{}, action string, before map[string]interface{}) error {
+	var after map[string]interface{}
+
+	if action != models.AuditDelete {
+		var err error
+		if after, err = snapshot(ctx, tx, resource, id); err != nil {
+			return err
+		}
+	}
+
+	if action == models.AuditInsert || action == models.AuditRestore {
+		before = nil
+	}
+
+	diff := audit.Diff(before, after)
+	if len(diff) == 0 {
+		return nil
+	}
+
+	js, err := json.Marshal(diff)
+	if err != nil {
+		return err
+	}
+
+	stmt := `INSERT INTO audit_events (actor_id, resource, resource_id, action, diff, request_id) VALUES ($1, $2, $3, $4, $5, $6) RETURNING resource_id;`
+
+	// read back as an int, as callers pass the id either way
+	var rid int
+	if err := tx.QueryRowContext(ctx, stmt, audit.Actor(ctx), resource, id, action, js, audit.RequestID(ctx)).Scan(&rid); err != nil {
+		return err
+	}
+
+	view := audit.Redact(after)
+	if action == models.AuditDelete {
+		view = audit.Redact(before)
+	}
+
+	return emit(ctx, tx, resource, rid, action, view, diff)
+}
+
+// recordAll records the action for every row in views, in order of id.
+func recordAll(ctx context.Context, tx *sql.Tx, resource, action string, views map[int]map[string]interface{}) error {
+	ids := make([]int, 0, len(views))
+	for id := range views {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	for _, id := range ids {
+		if err := record(ctx, tx, resource, id, action, views[id]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// audited runs fn in a transaction and records the change it makes to the
+// resource's row with the given id in the same transaction.
+func audited(ctx context.Context, db *sql.DB, resource string, id interface{}, action string, fn func(*sql.Tx) error) error {
+	return withTx(ctx, db, func(tx *sql.Tx) error {
+		before, err := snapshot(ctx, tx, resource, id)
+		if err != nil {
+			return err
+		}
+
+		if err := fn(tx); err != nil {
+			return err
+		}
+
+		return record(ctx, tx, resource, id, action, before)
+	})
+}
+
+// auditTable whitelists the columns audit queries may select, filter and sort on.
+var auditTable = query.Table{
+	Name:    "audit_events",
+	Columns: []string{"id", "actor_id", "resource", "resource_id", "action", "diff", "request_id", "created"},
+}
+
+type AuditModel struct {
+	DB      *sql.DB
+	Timeout time.Duration
+}
+
+// Search returns a page of the audit events matching criteria, oldest
+// first unless sorted otherwise.
+func (m *AuditModel) Search(ctx context.Context, criteria models.Criteria, filters models.Filters) ([]*models.AuditEvent, models.Metadata, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt, args, err := auditTable.Select().WithCount().Match(criteria).Page(filters).Build()
+	if err != nil {
+		return nil, models.Metadata{}, err
+	}
+
+	rows, err := m.DB.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, models.Metadata{}, err
+	}
+
+	defer rows.Close()
+
+	totalRecords := 0
+	events := []*models.AuditEvent{}
+
+	for rows.Next() {
+		e := &models.AuditEvent{}
+		var diff []byte
+
+		err = rows.Scan(&totalRecords, &e.ID, &e.ActorID, &e.Resource, &e.ResourceID, &e.Action, &diff, &e.RequestID, &e.Created)
+		if err != nil {
+			return nil, models.Metadata{}, err
+		}
+
+		if err := json.Unmarshal(diff, &e.Diff); err != nil {
+			return nil, models.Metadata{}, err
+		}
+
+		events = append(events, e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, models.Metadata{}, err
+	}
+
+	metadata, err := pageMetadata(ctx, m.DB, filters, totalRecords, len(events), stmt, args...)
+	if err != nil {
+		return nil, models.Metadata{}, err
+	}
+
+	return events, metadata, nil
+}
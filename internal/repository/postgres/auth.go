@@ -0,0 +1,250 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"pm-service/internal/repository/models"
+	"time"
+)
+
+// AuthModel stores the credentials users authenticate with: password
+// hashes, hashed API keys and the ids of revoked tokens.
+type AuthModel struct {
+	DB      *sql.DB
+	Timeout time.Duration
+}
+
+// Credentials returns the live user signing in with the email, ignoring
+// case, together with their password hash. Users without a password
+// cannot sign in this way; should several share the email, the oldest
+// wins.
+func (m *AuthModel) Credentials(ctx context.Context, email string) (*models.User, string, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	s := &models.User{}
+	var hash string
+
+	stmt := `SELECT id, name, email, role, created, version, password_hash FROM users
+		WHERE lower(email) = lower($1) AND password_hash <> '' AND deleted_at IS NULL
+		ORDER BY id LIMIT 1;`
+
+	err := m.DB.QueryRowContext(ctx, stmt, email).Scan(&s.ID, &s.Name, &s.Email, &s.Role, &s.Created, &s.Version, &hash)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return s, "", models.ErrNoRecord
+		}
+
+		return s, "", err
+	}
+
+	return s, hash, nil
+}
+
+// Password returns the live user's password hash, empty if they have none.
+func (m *AuthModel) Password(ctx context.Context, userID int) (string, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	var hash string
+	stmt := `SELECT password_hash FROM users WHERE id = $1 AND deleted_at IS NULL;`
+
+	err := m.DB.QueryRowContext(ctx, stmt, userID).Scan(&hash)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", models.ErrNoRecord
+		}
+
+		return "", err
+	}
+
+	return hash, nil
+}
+
+// SetPassword sets the live user's password hash and moves their token
+// generation on, so that the tokens issued to them before are rejected.
+func (m *AuthModel) SetPassword(ctx context.Context, userID int, hash string) error {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	return audited(ctx, m.DB, "users", userID, models.AuditUpdate, func(tx *sql.Tx) error {
+		var row int
+		stmt := `UPDATE users SET password_hash = $1, token_generation = token_generation + 1
+			WHERE id = $2 AND deleted_at IS NULL RETURNING id;`
+
+		err := tx.QueryRowContext(ctx, stmt, hash, userID).Scan(&row)
+		if err != nil {
+			return deleteError(err)
+		}
+
+		return nil
+	})
+}
+
+// TokenGeneration returns the generation of the tokens issued to the live
+// user now.
+func (m *AuthModel) TokenGeneration(ctx context.Context, userID int) (int, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	var generation int
+	stmt := `SELECT token_generation FROM users WHERE id = $1 AND deleted_at IS NULL;`
+
+	err := m.DB.QueryRowContext(ctx, stmt, userID).Scan(&generation)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, models.ErrNoRecord
+		}
+
+		return 0, err
+	}
+
+	return generation, nil
+}
+
+// InsertKey stores the hash of a new API key for the user.
+func (m *AuthModel) InsertKey(ctx context.Context, userID int, name, hint, hash string) (int, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	var id int
+
+	err := withTx(ctx, m.DB, func(tx *sql.Tx) error {
+		stmt := `INSERT INTO api_keys (user_id, name, hint, key_hash) VALUES ($1, $2, $3, $4) RETURNING id;`
+
+		if err := tx.QueryRowContext(ctx, stmt, userID, name, hint, hash).Scan(&id); err != nil {
+			return err
+		}
+
+		return record(ctx, tx, "api_keys", id, models.AuditInsert, nil)
+	})
+	if err != nil {
+		return -1, err
+	}
+
+	return id, nil
+}
+
+// Keys returns the user's API keys, oldest first.
+func (m *AuthModel) Keys(ctx context.Context, userID int) ([]*models.APIKey, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `SELECT id, user_id, name, hint, created, last_used FROM api_keys WHERE user_id = $1 ORDER BY id;`
+
+	rows, err := m.DB.QueryContext(ctx, stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	keys := []*models.APIKey{}
+
+	for rows.Next() {
+		k := &models.APIKey{}
+		if err := rows.Scan(&k.ID, &k.UserID, &k.Name, &k.Hint, &k.Created, &k.LastUsed); err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// DeleteKey removes one of the user's API keys. Keys of other users yield
+// ErrNoRecord.
+func (m *AuthModel) DeleteKey(ctx context.Context, userID, keyID int) error {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	return audited(ctx, m.DB, "api_keys", keyID, models.AuditDelete, func(tx *sql.Tx) error {
+		var row int
+		stmt := `DELETE FROM api_keys WHERE id = $1 AND user_id = $2 RETURNING id;`
+
+		err := tx.QueryRowContext(ctx, stmt, keyID, userID).Scan(&row)
+		if err != nil {
+			return deleteError(err)
+		}
+
+		return nil
+	})
+}
+
+// KeyOwner returns the live user owning the API key with the given hash
+// and records that the key was used.
+func (m *AuthModel) KeyOwner(ctx context.Context, hash string) (*models.User, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	s := &models.User{}
+
+	stmt := `UPDATE api_keys k SET last_used = now() FROM users u
+		WHERE k.key_hash = $1 AND u.id = k.user_id AND u.deleted_at IS NULL
+		RETURNING u.id, u.name, u.email, u.role, u.created, u.version;`
+
+	err := m.DB.QueryRowContext(ctx, stmt, hash).Scan(&s.ID, &s.Name, &s.Email, &s.Role, &s.Created, &s.Version)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return s, models.ErrNoRecord
+		}
+
+		return s, err
+	}
+
+	return s, nil
+}
+
+// Revoke blacklists the token with the given id until it expires. It
+// reports whether this call revoked it, false when it already was.
+func (m *AuthModel) Revoke(ctx context.Context, jti string, expires time.Time) (bool, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `INSERT INTO revoked_tokens (jti, expires) VALUES ($1, $2) ON CONFLICT (jti) DO NOTHING;`
+
+	res, err := m.DB.ExecContext(ctx, stmt, jti, expires)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rows == 1, nil
+}
+
+// Revoked reports whether the token with the given id, issued to the user
+// in the given generation, was revoked or predates the user's token
+// generation.
+func (m *AuthModel) Revoked(ctx context.Context, jti string, userID, generation int) (bool, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	var revoked bool
+	stmt := `SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = $1)
+		OR EXISTS (SELECT 1 FROM users WHERE id = $2 AND token_generation <> $3);`
+
+	err := m.DB.QueryRowContext(ctx, stmt, jti, userID, generation).Scan(&revoked)
+	return revoked, err
+}
+
+// PurgeRevoked forgets revoked tokens that expired before the given time
+// and would be rejected anyway.
+func (m *AuthModel) PurgeRevoked(ctx context.Context, before time.Time) (int64, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	res, err := m.DB.ExecContext(ctx, `DELETE FROM revoked_tokens WHERE expires < $1;`, before)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
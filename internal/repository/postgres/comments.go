@@ -0,0 +1,320 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"pm-service/internal/repository/models"
+	"pm-service/internal/repository/query"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// commentsTable whitelists the columns comment queries may select, filter and sort on.
+var commentsTable = query.Table{
+	Name:    "comments",
+	Columns: []string{"id", "task_id", "author_id", "parent_id", "body", "created", "edited", "version"},
+}
+
+type CommentModel struct {
+	DB      *sql.DB
+	Timeout time.Duration
+}
+
+// Insert adds the comment and records the live users it mentions.
+func (m *CommentModel) Insert(ctx context.Context, input *models.CommentInput) (int, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	var id int
+
+	err := withTx(ctx, m.DB, func(tx *sql.Tx) error {
+		stmt := `INSERT INTO comments (task_id, author_id, parent_id, body) VALUES ($1, $2, $3, $4) RETURNING id;`
+
+		if err := tx.QueryRowContext(ctx, stmt, input.TaskID, input.AuthorID, input.ParentID, input.Body).Scan(&id); err != nil {
+			return err
+		}
+
+		if err := mention(ctx, tx, id, input.Mentions()); err != nil {
+			return err
+		}
+
+		return record(ctx, tx, "comments", id, models.AuditInsert, nil)
+	})
+	if err != nil {
+		return -1, err
+	}
+
+	return id, nil
+}
+
+func (m *CommentModel) Get(ctx context.Context, id string) (*models.Comment, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	c := &models.Comment{}
+
+	stmt := `SELECT id, task_id, author_id, parent_id, body, created, edited, version FROM comments WHERE id = $1;`
+	err := m.DB.QueryRowContext(ctx, stmt, id).Scan(&c.ID, &c.TaskID, &c.AuthorID, &c.ParentID, &c.Body, &c.Created, &c.Edited, &c.Version)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return c, models.ErrNoRecord
+		}
+
+		return c, err
+	}
+
+	if err := m.withMentions(ctx, []*models.Comment{c}); err != nil {
+		return c, err
+	}
+
+	return c, nil
+}
+
+// Update replaces the comment's body if it is still at the given version,
+// keeping the previous text as a revision, and returns the new version. A
+// version mismatch yields ErrEditConflict and a missing record ErrNoRecord.
+func (m *CommentModel) Update(ctx context.Context, id string, input *models.CommentInput, version int) (int, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	err := audited(ctx, m.DB, "comments", id, models.AuditUpdate, func(tx *sql.Tx) error {
+		var commentID int
+
+		stmt := `INSERT INTO comment_revisions (comment_id, version, body, written)
+			SELECT id, version, body, COALESCE(edited, created) FROM comments WHERE id = $1 AND version = $2 RETURNING comment_id;`
+
+		if err := tx.QueryRowContext(ctx, stmt, id, version).Scan(&commentID); err != nil {
+			return updateError(ctx, tx, commentsTable, id, err)
+		}
+
+		stmt = `UPDATE comments SET body = $1, edited = now(), version = version + 1 WHERE id = $2 RETURNING version;`
+
+		if err := tx.QueryRowContext(ctx, stmt, input.Body, commentID).Scan(&version); err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM comment_mentions WHERE comment_id = $1;`, commentID); err != nil {
+			return err
+		}
+
+		return mention(ctx, tx, commentID, input.Mentions())
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return version, nil
+}
+
+// Delete removes the comment together with its replies.
+func (m *CommentModel) Delete(ctx context.Context, id string) error {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	return withTx(ctx, m.DB, func(tx *sql.Tx) error {
+		stmt := `WITH RECURSIVE thread AS (
+			SELECT id FROM comments WHERE id = $1
+			UNION
+			SELECT c.id FROM comments c JOIN thread ON c.parent_id = thread.id
+		)
+		SELECT id FROM thread;`
+
+		thread, err := snapshots(ctx, tx, "comments", stmt, id)
+		if err != nil {
+			return err
+		}
+
+		var row int
+
+		err = tx.QueryRowContext(ctx, `DELETE FROM comments WHERE id = $1 RETURNING id;`, id).Scan(&row)
+		if err != nil {
+			return deleteError(err)
+		}
+
+		return recordAll(ctx, tx, "comments", models.AuditDelete, thread)
+	})
+}
+
+// Thread returns a page of the task's top-level comments, each with its
+// replies nested in order of creation.
+func (m *CommentModel) Thread(ctx context.Context, taskID string, filters models.Filters) ([]*models.Comment, models.Metadata, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt, args, err := commentsTable.Select().WithCount().Where(query.Eq("task_id", taskID), query.IsNull("parent_id")).Page(filters).Build()
+	if err != nil {
+		return nil, models.Metadata{}, err
+	}
+
+	roots, totalRecords, err := m.list(ctx, true, stmt, args...)
+	if err != nil {
+		return nil, models.Metadata{}, err
+	}
+
+	metadata, err := pageMetadata(ctx, m.DB, filters, totalRecords, len(roots), stmt, args...)
+	if err != nil {
+		return nil, models.Metadata{}, err
+	}
+
+	ids := make([]int64, len(roots))
+	for i, c := range roots {
+		ids[i] = int64(c.ID)
+	}
+
+	stmt = `WITH RECURSIVE thread AS (
+		SELECT id, task_id, author_id, parent_id, body, created, edited, version FROM comments WHERE parent_id = ANY($1)
+		UNION ALL
+		SELECT c.id, c.task_id, c.author_id, c.parent_id, c.body, c.created, c.edited, c.version FROM comments c JOIN thread t ON c.parent_id = t.id
+	)
+	SELECT id, task_id, author_id, parent_id, body, created, edited, version FROM thread ORDER BY created, id;`
+
+	replies, _, err := m.list(ctx, false, stmt, pq.Array(ids))
+	if err != nil {
+		return nil, models.Metadata{}, err
+	}
+
+	if err := m.withMentions(ctx, append(append([]*models.Comment{}, roots...), replies...)); err != nil {
+		return nil, models.Metadata{}, err
+	}
+
+	return models.Thread(roots, replies), metadata, nil
+}
+
+// History lists the earlier texts of the comment, oldest first.
+func (m *CommentModel) History(ctx context.Context, id string) ([]*models.CommentRevision, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, `SELECT version, body, written FROM comment_revisions WHERE comment_id = $1 ORDER BY version;`, id)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	revisions := []*models.CommentRevision{}
+
+	for rows.Next() {
+		r := &models.CommentRevision{}
+		if err := rows.Scan(&r.Version, &r.Body, &r.Written); err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return revisions, nil
+}
+
+// Mentions returns the comments mentioning the user, newest first.
+// Comments on trashed tasks are left out.
+func (m *CommentModel) Mentions(ctx context.Context, userID string, filters models.Filters) ([]*models.Comment, models.Metadata, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `SELECT count(*) OVER(), c.id, c.task_id, c.author_id, c.parent_id, c.body, c.created, c.edited, c.version
+		FROM comments c
+		JOIN comment_mentions cm ON cm.comment_id = c.id
+		JOIN tasks t ON t.id = c.task_id AND t.deleted_at IS NULL
+		WHERE cm.user_id = $1
+		ORDER BY c.created DESC, c.id DESC LIMIT $2 OFFSET $3;`
+
+	args := []interface{}{userID, filters.Limit(), filters.Offset()}
+
+	comments, totalRecords, err := m.list(ctx, true, stmt, args...)
+	if err != nil {
+		return nil, models.Metadata{}, err
+	}
+
+	if err := m.withMentions(ctx, comments); err != nil {
+		return nil, models.Metadata{}, err
+	}
+
+	metadata, err := pageMetadata(ctx, m.DB, filters, totalRecords, len(comments), stmt, args...)
+	if err != nil {
+		return nil, models.Metadata{}, err
+	}
+
+	return comments, metadata, nil
+}
+
+// list runs a query returning comment rows, preceded by a window count
+// when counted is set.
+func (m *CommentModel) list(ctx context.Context, counted bool, stmt string, args ...interface{}) ([]*models.Comment, int, error) {
+	rows, err := m.DB.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	defer rows.Close()
+
+	totalRecords := 0
+	comments := []*models.Comment{}
+
+	for rows.Next() {
+		c := &models.Comment{}
+		dest := []interface{}{&c.ID, &c.TaskID, &c.AuthorID, &c.ParentID, &c.Body, &c.Created, &c.Edited, &c.Version}
+		if counted {
+			dest = append([]interface{}{&totalRecords}, dest...)
+		}
+
+		if err := rows.Scan(dest...); err != nil {
+			return nil, 0, err
+		}
+		comments = append(comments, c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return comments, totalRecords, nil
+}
+
+// withMentions loads the IDs of the users each comment mentions.
+func (m *CommentModel) withMentions(ctx context.Context, comments []*models.Comment) error {
+	byID := make(map[int64]*models.Comment, len(comments))
+	ids := make([]int64, len(comments))
+	for i, c := range comments {
+		c.Mentions = []int{}
+		byID[int64(c.ID)] = c
+		ids[i] = int64(c.ID)
+	}
+
+	rows, err := m.DB.QueryContext(ctx, `SELECT comment_id, user_id FROM comment_mentions WHERE comment_id = ANY($1) ORDER BY user_id;`, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var commentID int64
+		var userID int
+		if err := rows.Scan(&commentID, &userID); err != nil {
+			return err
+		}
+		byID[commentID].Mentions = append(byID[commentID].Mentions, userID)
+	}
+
+	return rows.Err()
+}
+
+// mention records the live users whose name or email address matches one
+// of the handles. Handles that match no one are ignored.
+func mention(ctx context.Context, tx *sql.Tx, commentID int, handles []string) error {
+	if len(handles) == 0 {
+		return nil
+	}
+
+	stmt := `INSERT INTO comment_mentions (comment_id, user_id)
+		SELECT $1, id FROM users WHERE deleted_at IS NULL AND (lower(name) = ANY($2) OR lower(email) = ANY($2))
+		ON CONFLICT DO NOTHING;`
+
+	_, err := tx.ExecContext(ctx, stmt, commentID, pq.Array(handles))
+	return err
+}
@@ -0,0 +1,16 @@
+package postgres
+
+import (
+	"context"
+	"time"
+)
+
+// withTimeout bounds a single query so that a slow statement is cancelled
+// even when the caller's context has no deadline of its own.
+func withTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if d <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, d)
+}
@@ -0,0 +1,125 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"pm-service/internal/repository/models"
+	"time"
+)
+
+type DependencyModel struct {
+	DB      *sql.DB
+	Timeout time.Duration
+}
+
+// Add makes the task wait for the blocker. It fails with
+// ErrDependencyCycle when the blocker already waits for the task, directly
+// or through other tasks; adding an existing link is a no-op.
+func (m *DependencyModel) Add(ctx context.Context, taskID, blockerID int) error {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	return audited(ctx, m.DB, "tasks", taskID, models.AuditUpdate, func(tx *sql.Tx) error {
+		// serialise writers so two links added side by side cannot close a
+		// cycle neither of them sees
+		if _, err := tx.ExecContext(ctx, `LOCK TABLE task_dependencies IN SHARE ROW EXCLUSIVE MODE;`); err != nil {
+			return err
+		}
+
+		var cycle bool
+		stmt := `WITH RECURSIVE upstream AS (
+			SELECT $1::integer AS id
+			UNION
+			SELECT d.blocker_id FROM task_dependencies d JOIN upstream ON d.task_id = upstream.id
+		)
+		SELECT EXISTS (SELECT 1 FROM upstream WHERE id = $2);`
+
+		if err := tx.QueryRowContext(ctx, stmt, blockerID, taskID).Scan(&cycle); err != nil {
+			return err
+		}
+
+		if cycle {
+			return models.ErrDependencyCycle
+		}
+
+		_, err := tx.ExecContext(ctx, `INSERT INTO task_dependencies (task_id, blocker_id) VALUES ($1, $2) ON CONFLICT DO NOTHING;`, taskID, blockerID)
+		return err
+	})
+}
+
+func (m *DependencyModel) Remove(ctx context.Context, taskID, blockerID int) error {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	return audited(ctx, m.DB, "tasks", taskID, models.AuditUpdate, func(tx *sql.Tx) error {
+		var row int
+		stmt := `DELETE FROM task_dependencies WHERE task_id = $1 AND blocker_id = $2 RETURNING task_id;`
+
+		err := tx.QueryRowContext(ctx, stmt, taskID, blockerID).Scan(&row)
+		if err != nil {
+			return deleteError(err)
+		}
+
+		return nil
+	})
+}
+
+// Blockers returns the live tasks the task waits for, ordered by id.
+func (m *DependencyModel) Blockers(ctx context.Context, taskID int) ([]*models.Task, error) {
+	return m.linked(ctx, `SELECT blocker_id FROM task_dependencies WHERE task_id = $1`, taskID)
+}
+
+// Dependents returns the live tasks waiting for the task, ordered by id.
+func (m *DependencyModel) Dependents(ctx context.Context, taskID int) ([]*models.Task, error) {
+	return m.linked(ctx, `SELECT task_id FROM task_dependencies WHERE blocker_id = $1`, taskID)
+}
+
+// Graph returns the live tasks of the project and the links between them.
+func (m *DependencyModel) Graph(ctx context.Context, projectID int) ([]*models.Task, []models.Dependency, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `SELECT count(*) OVER(), ` + taskColumns + ` FROM tasks WHERE project_id = $1 AND deleted_at IS NULL ORDER BY id;`
+
+	tasks, _, err := (&TaskModel{DB: m.DB}).list(ctx, models.Filters{Page: 1, PageSize: 1}, stmt, projectID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	stmt = `SELECT d.task_id, d.blocker_id FROM task_dependencies d
+		JOIN tasks t ON t.id = d.task_id JOIN tasks b ON b.id = d.blocker_id
+		WHERE t.project_id = $1 AND b.project_id = $1 AND t.deleted_at IS NULL AND b.deleted_at IS NULL;`
+
+	rows, err := m.DB.QueryContext(ctx, stmt, projectID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defer rows.Close()
+
+	links := []models.Dependency{}
+	for rows.Next() {
+		var d models.Dependency
+		if err := rows.Scan(&d.TaskID, &d.BlockerID); err != nil {
+			return nil, nil, err
+		}
+		links = append(links, d)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	return tasks, links, nil
+}
+
+func (m *DependencyModel) linked(ctx context.Context, ids string, taskID int) ([]*models.Task, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `SELECT count(*) OVER(), ` + taskColumns + ` FROM tasks WHERE id IN (` + ids + `) AND deleted_at IS NULL ORDER BY id;`
+
+	tasks, _, err := (&TaskModel{DB: m.DB}).list(ctx, models.Filters{Page: 1, PageSize: 1}, stmt, taskID)
+
+	return tasks, err
+}
@@ -0,0 +1,221 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"pm-service/internal/repository/models"
+	"pm-service/internal/repository/query"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// labelsTable whitelists the columns label queries may select, filter and sort on.
+var labelsTable = query.Table{
+	Name:    "labels",
+	Columns: []string{"id", "project_id", "name", "color", "created", "version"},
+}
+
+type LabelModel struct {
+	DB      *sql.DB
+	Timeout time.Duration
+}
+
+func (m *LabelModel) Insert(ctx context.Context, input *models.LabelInput) (int, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	var id int
+
+	err := withTx(ctx, m.DB, func(tx *sql.Tx) error {
+		stmt := `INSERT INTO labels (project_id, name, color) VALUES ($1, $2, $3) RETURNING id;`
+
+		if err := tx.QueryRowContext(ctx, stmt, input.ProjectID, input.Name, input.Color).Scan(&id); err != nil {
+			return labelError(err)
+		}
+
+		return record(ctx, tx, "labels", id, models.AuditInsert, nil)
+	})
+	if err != nil {
+		return -1, err
+	}
+
+	return id, nil
+}
+
+func (m *LabelModel) Get(ctx context.Context, id string) (*models.Label, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	l := &models.Label{}
+
+	stmt := `SELECT id, project_id, name, color, created, version FROM labels WHERE id = $1;`
+	err := m.DB.QueryRowContext(ctx, stmt, id).Scan(&l.ID, &l.ProjectID, &l.Name, &l.Color, &l.Created, &l.Version)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return l, models.ErrNoRecord
+		}
+
+		return l, err
+	}
+
+	return l, nil
+}
+
+// Update renames or recolours the label if it is still at the given version
+// and returns its new version. A version mismatch yields ErrEditConflict
+// and a missing record ErrNoRecord.
+func (m *LabelModel) Update(ctx context.Context, id string, input *models.LabelInput, version int) (int, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	err := audited(ctx, m.DB, "labels", id, models.AuditUpdate, func(tx *sql.Tx) error {
+		stmt := `UPDATE labels SET name = $1, color = $2, version = version + 1 WHERE id = $3 AND version = $4 RETURNING version;`
+
+		err := tx.QueryRowContext(ctx, stmt, input.Name, input.Color, id, version).Scan(&version)
+		if err != nil {
+			return labelError(updateError(ctx, tx, labelsTable, id, err))
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return version, nil
+}
+
+// Delete removes the label from the project and from all of its tasks.
+func (m *LabelModel) Delete(ctx context.Context, id string) error {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	return audited(ctx, m.DB, "labels", id, models.AuditDelete, func(tx *sql.Tx) error {
+		tasks, err := snapshots(ctx, tx, "tasks", `SELECT task_id FROM task_labels WHERE label_id = $1;`, id)
+		if err != nil {
+			return err
+		}
+
+		var row int
+
+		err = tx.QueryRowContext(ctx, `DELETE FROM labels WHERE id = $1 RETURNING id;`, id).Scan(&row)
+		if err != nil {
+			return deleteError(err)
+		}
+
+		return recordAll(ctx, tx, "tasks", models.AuditUpdate, tasks)
+	})
+}
+
+func (m *LabelModel) GetAllBy(ctx context.Context, arg, val string, filters models.Filters) ([]*models.Label, models.Metadata, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt, args, err := labelsTable.Select().WithCount().Where(query.Eq(arg, val)).Page(filters).Build()
+	if err != nil {
+		return nil, models.Metadata{}, err
+	}
+
+	labels, totalRecords, err := m.list(ctx, true, stmt, args...)
+	if err != nil {
+		return nil, models.Metadata{}, err
+	}
+
+	metadata, err := pageMetadata(ctx, m.DB, filters, totalRecords, len(labels), stmt, args...)
+	if err != nil {
+		return nil, models.Metadata{}, err
+	}
+
+	return labels, metadata, nil
+}
+
+// Attach puts the label on the task; attaching it twice is a no-op.
+func (m *LabelModel) Attach(ctx context.Context, taskID, labelID int) error {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	return audited(ctx, m.DB, "tasks", taskID, models.AuditUpdate, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, `INSERT INTO task_labels (task_id, label_id) VALUES ($1, $2) ON CONFLICT DO NOTHING;`, taskID, labelID)
+		return err
+	})
+}
+
+func (m *LabelModel) Detach(ctx context.Context, taskID, labelID int) error {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	return audited(ctx, m.DB, "tasks", taskID, models.AuditUpdate, func(tx *sql.Tx) error {
+		var row int
+
+		err := tx.QueryRowContext(ctx, `DELETE FROM task_labels WHERE task_id = $1 AND label_id = $2 RETURNING task_id;`, taskID, labelID).Scan(&row)
+		if err != nil {
+			return deleteError(err)
+		}
+
+		return nil
+	})
+}
+
+// OfTask returns the labels on the task, ordered by name. Labels of another
+// project, left over from before the task moved, are not included.
+func (m *LabelModel) OfTask(ctx context.Context, taskID int) ([]*models.Label, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `SELECT l.id, l.project_id, l.name, l.color, l.created, l.version
+		FROM labels l
+		JOIN task_labels tl ON tl.label_id = l.id
+		JOIN tasks t ON t.id = tl.task_id AND t.project_id = l.project_id
+		WHERE tl.task_id = $1
+		ORDER BY lower(l.name);`
+
+	labels, _, err := m.list(ctx, false, stmt, taskID)
+
+	return labels, err
+}
+
+// list runs a query returning label rows, preceded by a window count when
+// counted is set.
+func (m *LabelModel) list(ctx context.Context, counted bool, stmt string, args ...interface{}) ([]*models.Label, int, error) {
+	rows, err := m.DB.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	defer rows.Close()
+
+	totalRecords := 0
+	labels := []*models.Label{}
+
+	for rows.Next() {
+		l := &models.Label{}
+		dest := []interface{}{&l.ID, &l.ProjectID, &l.Name, &l.Color, &l.Created, &l.Version}
+		if counted {
+			dest = append([]interface{}{&totalRecords}, dest...)
+		}
+
+		if err := rows.Scan(dest...); err != nil {
+			return nil, 0, err
+		}
+		labels = append(labels, l)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return labels, totalRecords, nil
+}
+
+// labelError maps a unique_violation on the project's label names onto
+// ErrDuplicateName.
+func labelError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return models.ErrDuplicateName
+	}
+
+	return err
+}
@@ -0,0 +1,159 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"pm-service/internal/repository/models"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type MemberModel struct {
+	DB      *sql.DB
+	Timeout time.Duration
+}
+
+// Get returns the user's membership of the project. It fails with
+// ErrNoRecord when the user is not a member or is in the trash.
+func (m *MemberModel) Get(ctx context.Context, projectID, userID int) (*models.Member, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	mb := &models.Member{}
+
+	stmt := `SELECT pm.project_id, pm.user_id, pm.role, pm.added FROM project_members pm
+		JOIN users u ON u.id = pm.user_id AND u.deleted_at IS NULL
+		WHERE pm.project_id = $1 AND pm.user_id = $2;`
+
+	err := m.DB.QueryRowContext(ctx, stmt, projectID, userID).Scan(&mb.ProjectID, &mb.UserID, &mb.Role, &mb.Added)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return mb, models.ErrNoRecord
+		}
+
+		return mb, err
+	}
+
+	return mb, nil
+}
+
+// Set adds the user to the project with the given role, or changes the
+// role of an existing member.
+func (m *MemberModel) Set(ctx context.Context, projectID, userID int, role string) error {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	return audited(ctx, m.DB, "projects", projectID, models.AuditUpdate, func(tx *sql.Tx) error {
+		stmt := `INSERT INTO project_members (project_id, user_id, role) VALUES ($1, $2, $3)
+			ON CONFLICT (project_id, user_id) DO UPDATE SET role = EXCLUDED.role;`
+
+		_, err := tx.ExecContext(ctx, stmt, projectID, userID, role)
+		return err
+	})
+}
+
+func (m *MemberModel) Remove(ctx context.Context, projectID, userID int) error {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	return audited(ctx, m.DB, "projects", projectID, models.AuditUpdate, func(tx *sql.Tx) error {
+		var row int
+		stmt := `DELETE FROM project_members WHERE project_id = $1 AND user_id = $2 RETURNING user_id;`
+
+		err := tx.QueryRowContext(ctx, stmt, projectID, userID).Scan(&row)
+		if err != nil {
+			return deleteError(err)
+		}
+
+		return nil
+	})
+}
+
+// List returns a page of the project's members, strongest role first.
+// Users in the trash are left out.
+func (m *MemberModel) List(ctx context.Context, projectID int, filters models.Filters) ([]*models.Member, models.Metadata, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `SELECT count(*) OVER(), pm.project_id, pm.user_id, pm.role, pm.added FROM project_members pm
+		JOIN users u ON u.id = pm.user_id AND u.deleted_at IS NULL
+		WHERE pm.project_id = $1
+		ORDER BY array_position($2::text[], pm.role::text), pm.user_id LIMIT $3 OFFSET $4;`
+
+	args := []interface{}{projectID, pq.Array(models.ProjectRoles), filters.Limit(), filters.Offset()}
+
+	rows, err := m.DB.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, models.Metadata{}, err
+	}
+
+	defer rows.Close()
+
+	totalRecords := 0
+	members := []*models.Member{}
+
+	for rows.Next() {
+		mb := &models.Member{}
+		if err := rows.Scan(&totalRecords, &mb.ProjectID, &mb.UserID, &mb.Role, &mb.Added); err != nil {
+			return nil, models.Metadata{}, err
+		}
+		members = append(members, mb)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, models.Metadata{}, err
+	}
+
+	metadata, err := pageMetadata(ctx, m.DB, filters, totalRecords, len(members), stmt, args...)
+	if err != nil {
+		return nil, models.Metadata{}, err
+	}
+
+	return members, metadata, nil
+}
+
+// OfUser returns a page of the live projects the user is a member of,
+// ordered by id, each with the user's role.
+func (m *MemberModel) OfUser(ctx context.Context, userID int, filters models.Filters) ([]*models.Membership, models.Metadata, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `SELECT count(*) OVER(), p.id, p.title, p.description, p.manager_id, p.created, p.completed, p.version, pm.role, pm.added
+		FROM project_members pm
+		JOIN projects p ON p.id = pm.project_id AND p.deleted_at IS NULL
+		WHERE pm.user_id = $1
+		ORDER BY p.id LIMIT $2 OFFSET $3;`
+
+	args := []interface{}{userID, filters.Limit(), filters.Offset()}
+
+	rows, err := m.DB.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, models.Metadata{}, err
+	}
+
+	defer rows.Close()
+
+	totalRecords := 0
+	memberships := []*models.Membership{}
+
+	for rows.Next() {
+		s := &models.Membership{}
+		err := rows.Scan(&totalRecords, &s.ID, &s.Title, &s.Description, &s.ManagerID, &s.Created, &s.Completed, &s.Version, &s.Role, &s.Added)
+		if err != nil {
+			return nil, models.Metadata{}, err
+		}
+		memberships = append(memberships, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, models.Metadata{}, err
+	}
+
+	metadata, err := pageMetadata(ctx, m.DB, filters, totalRecords, len(memberships), stmt, args...)
+	if err != nil {
+		return nil, models.Metadata{}, err
+	}
+
+	return memberships, metadata, nil
+}
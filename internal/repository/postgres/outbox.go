@@ -0,0 +1,113 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"pm-service/internal/repository/models"
+	"pm-service/internal/service/audit"
+	"pm-service/internal/service/events"
+	"sort"
+	"time"
+)
+
+// emit keeps the domain events a change raises in the outbox, in the
+// transaction making the change. view is the record after the change, or
+// before a delete.
+func emit(ctx context.Context, tx *sql.Tx, resource string, id int, action string, view map[string]interface{}, diff map[string]models.Change) error {
+	for _, e := range events.FromChange(resource, id, action, view, diff) {
+		payload, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+
+		stmt := `INSERT INTO outbox (name, payload, actor_id, request_id) VALUES ($1, $2, $3, $4);`
+
+		if _, err := tx.ExecContext(ctx, stmt, e.Name(), payload, audit.Actor(ctx), audit.RequestID(ctx)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type OutboxModel struct {
+	DB      *sql.DB
+	Timeout time.Duration
+}
+
+// Claim returns up to limit events due to be relayed, oldest first, counts
+// the attempt and holds them back from other relays for the lease. Claiming commits right
+// away, so no transaction stays open while the events are handled.
+func (m *OutboxModel) Claim(ctx context.Context, limit int, lease time.Duration) ([]*models.OutboxEvent, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `WITH due AS (
+			SELECT id FROM outbox WHERE published IS NULL AND failed IS NULL AND next_attempt <= now()
+			ORDER BY id LIMIT $1 FOR UPDATE SKIP LOCKED
+		)
+		UPDATE outbox SET attempts = attempts + 1, next_attempt = now() + $2 * interval '1 millisecond'
+		FROM due WHERE outbox.id = due.id
+		RETURNING outbox.id, name, payload, actor_id, request_id, created, attempts, next_attempt;`
+
+	rows, err := m.DB.QueryContext(ctx, stmt, limit, lease.Milliseconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	claimed := []*models.OutboxEvent{}
+
+	for rows.Next() {
+		e := &models.OutboxEvent{}
+		if err := rows.Scan(&e.ID, &e.Name, &e.Payload, &e.ActorID, &e.RequestID, &e.Created, &e.Attempts, &e.NextAttempt); err != nil {
+			return nil, err
+		}
+		claimed = append(claimed, e)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// UPDATE ... RETURNING does not keep the order of the subquery
+	sort.Slice(claimed, func(i, j int) bool { return claimed[i].ID < claimed[j].ID })
+
+	return claimed, nil
+}
+
+func (m *OutboxModel) Published(ctx context.Context, id int) error {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, `UPDATE outbox SET published = now() WHERE id = $1;`, id)
+	return err
+}
+
+// Failed holds an unpublished event back until next, or marks it failed
+// for good when dead.
+func (m *OutboxModel) Failed(ctx context.Context, id int, next time.Time, dead bool) error {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `UPDATE outbox SET next_attempt = $2, failed = CASE WHEN $3 THEN now() END
+		WHERE id = $1 AND published IS NULL;`
+
+	_, err := m.DB.ExecContext(ctx, stmt, id, next, dead)
+	return err
+}
+
+// Purge deletes the events published or failed before the given time and
+// returns how many it deleted.
+func (m *OutboxModel) Purge(ctx context.Context, before time.Time) (int64, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	res, err := m.DB.ExecContext(ctx, `DELETE FROM outbox WHERE published < $1 OR failed < $1;`, before)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
@@ -0,0 +1,25 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"pm-service/internal/repository/models"
+	"strings"
+)
+
+// pageMetadata describes a page of n records listed by stmt, total being
+// its count(*) OVER(). A page past the last has no rows to count over, so
+// the records are then counted by running stmt again over all pages, which
+// is why list statements take their limit and offset as the last two args.
+func pageMetadata(ctx context.Context, db *sql.DB, filters models.Filters, total, n int, stmt string, args ...interface{}) (models.Metadata, error) {
+	if n == 0 && filters.Page > 1 {
+		args = append(append([]interface{}{}, args[:len(args)-2]...), nil, 0)
+		stmt = `SELECT count(*) FROM (` + strings.TrimSuffix(strings.TrimSpace(stmt), ";") + `) AS pages;`
+
+		if err := db.QueryRowContext(ctx, stmt, args...).Scan(&total); err != nil {
+			return models.Metadata{}, err
+		}
+	}
+
+	return models.CalculateMetadata(total, filters.Page, filters.PageSize), nil
+}
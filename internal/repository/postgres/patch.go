@@ -0,0 +1,28 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"pm-service/internal/repository/query"
+)
+
+// patch writes only the named fields of values to the row with the given id
+// if it is still at version, and returns the row's new version.
+func patch(ctx context.Context, tx *sql.Tx, table query.Table, id string, values map[string]interface{}, fields []string, version int) (int, error) {
+	changes := make(map[string]interface{}, len(fields))
+	for _, f := range fields {
+		changes[f] = values[f]
+	}
+
+	stmt, args, err := table.Update(changes).Increment("version").Where(query.Eq("id", id), query.Eq("version", version)).Returning("version").Build()
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.QueryRowContext(ctx, stmt, args...).Scan(&version)
+	if err != nil {
+		return 0, updateError(ctx, tx, table, id, err)
+	}
+
+	return version, nil
+}
@@ -1,20 +1,46 @@
 package postgres
 
 import (
+	"context"
 	"database/sql"
-	"fmt"
 	"pm-service/internal/repository/models"
+	"pm-service/internal/repository/query"
+	"time"
 )
 
+// projectsTable whitelists the columns list queries may select, filter and sort on.
+var projectsTable = query.Table{
+	Name:       "projects",
+	Columns:    []string{"id", "title", "description", "manager_id", "created", "completed", "version"},
+	SoftDelete: "deleted_at",
+}
+
 type ProjectModel struct {
-	DB *sql.DB
+	DB      *sql.DB
+	Timeout time.Duration
 }
 
-func (m *ProjectModel) Insert(input *models.ProjectInput) (int, error) {
+// Insert adds the project and makes its manager the owner.
+func (m *ProjectModel) Insert(ctx context.Context, input *models.ProjectInput) (int, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
 	var id int
-	stmt := `INSERT INTO projects (title, description, manager_id, completed) VALUES ($1, $2, $3, $4) RETURNING id;`
 
-	err := m.DB.QueryRow(stmt, input.Title, input.Description, input.ManagerID, input.Completed).Scan(&id)
+	err := withTx(ctx, m.DB, func(tx *sql.Tx) error {
+		stmt := `INSERT INTO projects (title, description, manager_id, completed) VALUES ($1, $2, $3, $4) RETURNING id;`
+
+		if err := tx.QueryRowContext(ctx, stmt, input.Title, input.Description, input.ManagerID, input.CompletedAt()).Scan(&id); err != nil {
+			return err
+		}
+
+		_, err := tx.ExecContext(ctx, `INSERT INTO project_members (project_id, user_id, role) VALUES ($1, $2, 'owner');`, id, input.ManagerID)
+		if err != nil {
+			return err
+		}
+
+		return record(ctx, tx, "projects", id, models.AuditInsert, nil)
+	})
 	if err != nil {
 		return -1, err
 	}
@@ -22,11 +48,14 @@ func (m *ProjectModel) Insert(input *models.ProjectInput) (int, error) {
 	return id, nil
 }
 
-func (m *ProjectModel) Get(id string) (*models.Project, error) {
+func (m *ProjectModel) Get(ctx context.Context, id string) (*models.Project, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
 	s := &models.Project{}
 
-	stmt := `SELECT id, title, description, manager_id, created, completed FROM projects WHERE id = $1;`
-	err := m.DB.QueryRow(stmt, id).Scan(&s.ID, &s.Title, &s.Description, &s.ManagerID, &s.Created, &s.Completed)
+	stmt := `SELECT id, title, description, manager_id, created, completed, version FROM projects WHERE id = $1 AND deleted_at IS NULL;`
+	err := m.DB.QueryRowContext(ctx, stmt, id).Scan(&s.ID, &s.Title, &s.Description, &s.ManagerID, &s.Created, &s.Completed, &s.Version)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return s, models.ErrNoRecord
@@ -38,90 +67,190 @@ func (m *ProjectModel) Get(id string) (*models.Project, error) {
 	return s, nil
 }
 
-func (m *ProjectModel) Delete(id string) error {
-	var row int
-	stmt := `DELETE FROM projects WHERE id = $1 RETURNING id;`
+func (m *ProjectModel) Delete(ctx context.Context, id string) error {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
 
-	err := m.DB.QueryRow(stmt, id).Scan(&row)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return models.ErrNoRecord
+	return audited(ctx, m.DB, "projects", id, models.AuditDelete, func(tx *sql.Tx) error {
+		var row int
+		stmt := `UPDATE projects SET deleted_at = now() WHERE id = $1 AND deleted_at IS NULL RETURNING id;`
+
+		err := tx.QueryRowContext(ctx, stmt, id).Scan(&row)
+		if err != nil {
+			return deleteError(err)
 		}
 
-		return err
-	}
+		return nil
+	})
+}
+
+// DeleteCascade moves the project together with all of its tasks to the
+// trash in a single transaction. Both share the same deleted_at, which
+// Restore relies on to bring the tasks back with the project.
+func (m *ProjectModel) DeleteCascade(ctx context.Context, id string) error {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
 
-	return nil
+	return audited(ctx, m.DB, "projects", id, models.AuditDelete, func(tx *sql.Tx) error {
+		tasks, err := snapshots(ctx, tx, "tasks", `SELECT id FROM tasks WHERE project_id = $1 AND deleted_at IS NULL;`, id)
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, `UPDATE tasks SET deleted_at = now() WHERE project_id = $1 AND deleted_at IS NULL;`, id); err != nil {
+			return err
+		}
+
+		var row int
+		err = tx.QueryRowContext(ctx, `UPDATE projects SET deleted_at = now() WHERE id = $1 AND deleted_at IS NULL RETURNING id;`, id).Scan(&row)
+		if err != nil {
+			return deleteError(err)
+		}
+
+		return recordAll(ctx, tx, "tasks", models.AuditDelete, tasks)
+	})
 }
 
-func (m *ProjectModel) Update(id string, input *models.ProjectInput) error {
-	var row int
-	stmt := `UPDATE projects SET title = $1, description = $2, manager_id = $3, completed = $4 WHERE id = $5 RETURNING id;`
+// Restore takes the project out of the trash along with the tasks that were
+// trashed by the same cascading delete. It fails with ErrTrashedReference
+// while the project's manager is in the trash.
+func (m *ProjectModel) Restore(ctx context.Context, id string) error {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
 
-	err := m.DB.QueryRow(stmt, input.Title, input.Description, input.ManagerID, input.Completed, id).Scan(&row)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return models.ErrNoRecord
+	return audited(ctx, m.DB, "projects", id, models.AuditRestore, func(tx *sql.Tx) error {
+		var managerID int
+		var deleted time.Time
+
+		err := tx.QueryRowContext(ctx, `SELECT manager_id, deleted_at FROM projects WHERE id = $1 AND deleted_at IS NOT NULL FOR UPDATE;`, id).Scan(&managerID, &deleted)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return models.ErrNoRecord
+			}
+
+			return err
 		}
 
-		return err
-	}
+		if err := requireLive(ctx, tx, "users", managerID); err != nil {
+			return err
+		}
 
-	return nil
+		if _, err := tx.ExecContext(ctx, `UPDATE projects SET deleted_at = NULL WHERE id = $1;`, id); err != nil {
+			return err
+		}
+
+		stmt := `UPDATE tasks SET deleted_at = NULL WHERE project_id = $1 AND deleted_at = $2
+			AND assignee_id IN (SELECT id FROM users WHERE deleted_at IS NULL) RETURNING id;`
+
+		// restores are recorded without a previous view, so views of the
+		// restored tasks will do
+		tasks, err := snapshots(ctx, tx, "tasks", stmt, id, deleted)
+		if err != nil {
+			return err
+		}
+
+		return recordAll(ctx, tx, "tasks", models.AuditRestore, tasks)
+	})
 }
 
-func (m *ProjectModel) GetAll() ([]*models.Project, error) {
-	stmt := `SELECT * FROM projects;`
+// Update overwrites the project if it is still at the given version and
+// returns its new version. A version mismatch yields ErrEditConflict and a
+// missing record ErrNoRecord.
+func (m *ProjectModel) Update(ctx context.Context, id string, input *models.ProjectInput, version int) (int, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	err := audited(ctx, m.DB, "projects", id, models.AuditUpdate, func(tx *sql.Tx) error {
+		stmt := `UPDATE projects SET title = $1, description = $2, manager_id = $3, completed = $4, version = version + 1 WHERE id = $5 AND version = $6 AND deleted_at IS NULL RETURNING version;`
+
+		err := tx.QueryRowContext(ctx, stmt, input.Title, input.Description, input.ManagerID, input.CompletedAt(), id, version).Scan(&version)
+		if err != nil {
+			return updateError(ctx, tx, projectsTable, id, err)
+		}
 
-	rows, err := m.DB.Query(stmt)
+		return nil
+	})
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
 
-	defer rows.Close()
+	return version, nil
+}
 
-	projects := []*models.Project{}
+// Patch writes only the given fields of input, named by column, and
+// otherwise behaves like Update.
+func (m *ProjectModel) Patch(ctx context.Context, id string, input *models.ProjectInput, fields []string, version int) (int, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
 
-	for rows.Next() {
-		s := &models.Project{}
-		err = rows.Scan(&s.ID, &s.Title, &s.Description, &s.ManagerID, &s.Created, &s.Completed)
-		if err != nil {
-			return nil, err
-		}
-		projects = append(projects, s)
+	err := audited(ctx, m.DB, "projects", id, models.AuditUpdate, func(tx *sql.Tx) error {
+		var err error
+		version, err = patch(ctx, tx, projectsTable, id, input.Values(), fields, version)
+		return err
+	})
+	if err != nil {
+		return 0, err
 	}
 
-	if err = rows.Err(); err != nil {
-		return nil, err
+	return version, nil
+}
+
+func (m *ProjectModel) GetAll(ctx context.Context, filters models.Filters) ([]*models.Project, models.Metadata, error) {
+	return m.Search(ctx, nil, filters)
+}
+
+func (m *ProjectModel) GetAllBy(ctx context.Context, arg, val string, filters models.Filters) ([]*models.Project, models.Metadata, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt, args, err := projectsTable.Select().WithCount().Where(query.Eq(arg, val)).Page(filters).Build()
+	if err != nil {
+		return nil, models.Metadata{}, err
 	}
 
-	return projects, nil
+	return m.list(ctx, filters, stmt, args...)
 }
 
-func (m *ProjectModel) GetAllBy(arg, val string) ([]*models.Project, error) {
-	stmt := fmt.Sprintf(`SELECT id, title, description, manager_id, created, completed FROM projects WHERE %s = $1;`, arg)
+func (m *ProjectModel) Search(ctx context.Context, criteria models.Criteria, filters models.Filters) ([]*models.Project, models.Metadata, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
 
-	rows, err := m.DB.Query(stmt, val)
+	stmt, args, err := projectsTable.Select().WithCount().Match(criteria).Page(filters).Build()
 	if err != nil {
-		return nil, err
+		return nil, models.Metadata{}, err
+	}
+
+	return m.list(ctx, filters, stmt, args...)
+}
+
+func (m *ProjectModel) list(ctx context.Context, filters models.Filters, stmt string, args ...interface{}) ([]*models.Project, models.Metadata, error) {
+	rows, err := m.DB.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, models.Metadata{}, err
 	}
 
 	defer rows.Close()
 
+	totalRecords := 0
 	projects := []*models.Project{}
 
 	for rows.Next() {
 		s := &models.Project{}
-		err = rows.Scan(&s.ID, &s.Title, &s.Description, &s.ManagerID, &s.Created, &s.Completed)
+		err = rows.Scan(&totalRecords, &s.ID, &s.Title, &s.Description, &s.ManagerID, &s.Created, &s.Completed, &s.Version)
 		if err != nil {
-			return nil, err
+			return nil, models.Metadata{}, err
 		}
 		projects = append(projects, s)
 	}
 
 	if err = rows.Err(); err != nil {
-		return nil, err
+		return nil, models.Metadata{}, err
+	}
+
+	metadata, err := pageMetadata(ctx, m.DB, filters, totalRecords, len(projects), stmt, args...)
+	if err != nil {
+		return nil, models.Metadata{}, err
 	}
 
-	return projects, nil
+	return projects, metadata, nil
 }
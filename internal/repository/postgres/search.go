@@ -0,0 +1,73 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"pm-service/internal/repository/models"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type SearchModel struct {
+	DB      *sql.DB
+	Timeout time.Duration
+}
+
+// FullText ranks tasks and projects of the given types against q using the
+// weighted search columns (title over description). Snippets are only
+// generated for the returned page.
+func (m *SearchModel) FullText(ctx context.Context, q string, types []string, filters models.Filters) ([]*models.SearchResult, models.Metadata, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `WITH query AS (
+		SELECT websearch_to_tsquery('english', $1) AS q
+	), matches AS (
+		SELECT 'tasks' AS type, id, title, description, ts_rank(search, q) AS rank
+		FROM tasks, query WHERE search @@ q AND deleted_at IS NULL AND 'tasks' = ANY($2::text[])
+		UNION ALL
+		SELECT 'projects' AS type, id, title, description, ts_rank(search, q) AS rank
+		FROM projects, query WHERE search @@ q AND deleted_at IS NULL AND 'projects' = ANY($2::text[])
+	), page AS (
+		SELECT count(*) OVER() AS total, * FROM matches
+		ORDER BY rank DESC, type, id LIMIT $3 OFFSET $4
+	)
+	SELECT total, type, id, title,
+		ts_headline('english', title || ': ' || description, q, 'StartSel=<mark>, StopSel=</mark>, MaxWords=20, MinWords=5'),
+		rank
+	FROM page, query
+	ORDER BY rank DESC, type, id;`
+
+	args := []interface{}{q, pq.Array(types), filters.Limit(), filters.Offset()}
+
+	rows, err := m.DB.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, models.Metadata{}, err
+	}
+
+	defer rows.Close()
+
+	totalRecords := 0
+	results := []*models.SearchResult{}
+
+	for rows.Next() {
+		s := &models.SearchResult{}
+		err = rows.Scan(&totalRecords, &s.Type, &s.ID, &s.Title, &s.Snippet, &s.Rank)
+		if err != nil {
+			return nil, models.Metadata{}, err
+		}
+		results = append(results, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, models.Metadata{}, err
+	}
+
+	metadata, err := pageMetadata(ctx, m.DB, filters, totalRecords, len(results), stmt, args...)
+	if err != nil {
+		return nil, models.Metadata{}, err
+	}
+
+	return results, metadata, nil
+}
@@ -1,20 +1,57 @@
 package postgres
 
 import (
+	"context"
 	"database/sql"
-	"fmt"
 	"pm-service/internal/repository/models"
+	"pm-service/internal/repository/query"
+	"time"
 )
 
+// blockedSQL derives Task.Blocked: a task is blocked while any live task it
+// depends on is incomplete.
+const blockedSQL = `EXISTS (SELECT 1 FROM task_dependencies d JOIN tasks b ON b.id = d.blocker_id
+	WHERE d.task_id = tasks.id AND b.completed IS NULL AND b.deleted_at IS NULL)`
+
+// labelsSQL lists the names of a task's labels for filtering. Labels of
+// another project, left over from before the task moved, do not count.
+const labelsSQL = `SELECT lower(l.name) FROM task_labels tl JOIN labels l ON l.id = tl.label_id
+	WHERE tl.task_id = tasks.id AND l.project_id = tasks.project_id`
+
+// tasksTable whitelists the columns list queries may select, filter and sort on.
+var tasksTable = query.Table{
+	Name:       "tasks",
+	Columns:    []string{"id", "title", "description", "priority", "status", "assignee_id", "project_id", "parent_task_id", "estimate", "created", "completed", "version", "blocked"},
+	SoftDelete: "deleted_at",
+	Computed:   map[string]string{"blocked": blockedSQL},
+	Sets:       map[string]string{"labels": labelsSQL},
+}
+
+// taskColumns selects a task row in the order scanTask expects, for
+// statements written by hand.
+const taskColumns = `id, title, description, priority, status, assignee_id, project_id, parent_task_id, estimate, created, completed, version, ` + blockedSQL
+
 type TaskModel struct {
-	DB *sql.DB
+	DB      *sql.DB
+	Timeout time.Duration
 }
 
-func (m *TaskModel) Insert(input *models.TaskInput) (int, error) {
+func (m *TaskModel) Insert(ctx context.Context, input *models.TaskInput) (int, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
 	var id int
-	stmt := `INSERT INTO tasks (title, description, priority, status, assignee_id, project_id, completed) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id;`
 
-	err := m.DB.QueryRow(stmt, input.Title, input.Description, input.Priority, input.Status, input.AssigneeID, input.ProjectID, input.Completed).Scan(&id)
+	err := withTx(ctx, m.DB, func(tx *sql.Tx) error {
+		stmt := `INSERT INTO tasks (title, description, priority, status, assignee_id, project_id, parent_task_id, estimate, completed) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id;`
+
+		err := tx.QueryRowContext(ctx, stmt, input.Title, input.Description, input.Priority, input.Status, input.AssigneeID, input.ProjectID, input.ParentTaskID, input.Estimate, input.CompletedAt()).Scan(&id)
+		if err != nil {
+			return err
+		}
+
+		return record(ctx, tx, "tasks", id, models.AuditInsert, nil)
+	})
 	if err != nil {
 		return -1, err
 	}
@@ -22,11 +59,14 @@ func (m *TaskModel) Insert(input *models.TaskInput) (int, error) {
 	return id, nil
 }
 
-func (m *TaskModel) Get(id string) (*models.Task, error) {
+func (m *TaskModel) Get(ctx context.Context, id string) (*models.Task, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
 	s := &models.Task{}
 
-	stmt := `SELECT id, title, description, priority, status, assignee_id, project_id, created, completed FROM tasks WHERE id = $1;`
-	err := m.DB.QueryRow(stmt, id).Scan(&s.ID, &s.Title, &s.Description, &s.Priority, &s.Status, &s.AssigneeID, &s.ProjectID, &s.Created, &s.Completed)
+	stmt := `SELECT ` + taskColumns + ` FROM tasks WHERE id = $1 AND deleted_at IS NULL;`
+	err := m.DB.QueryRowContext(ctx, stmt, id).Scan(scanTask(s)...)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return s, models.ErrNoRecord
@@ -38,90 +78,192 @@ func (m *TaskModel) Get(id string) (*models.Task, error) {
 	return s, nil
 }
 
-func (m *TaskModel) Delete(id string) error {
-	var row int
-	stmt := `DELETE FROM tasks WHERE id = $1 RETURNING id;`
+func (m *TaskModel) Delete(ctx context.Context, id string) error {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
 
-	err := m.DB.QueryRow(stmt, id).Scan(&row)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return models.ErrNoRecord
-		}
+	return audited(ctx, m.DB, "tasks", id, models.AuditDelete, func(tx *sql.Tx) error {
+		var row int
+		stmt := `UPDATE tasks SET deleted_at = now() WHERE id = $1 AND deleted_at IS NULL RETURNING id;`
 
-		return err
-	}
+		err := tx.QueryRowContext(ctx, stmt, id).Scan(&row)
+		if err != nil {
+			return deleteError(err)
+		}
 
-	return nil
+		return nil
+	})
 }
 
-func (m *TaskModel) Update(id string, input *models.TaskInput) error {
-	var row int
-	stmt := `UPDATE tasks SET title = $1, description = $2, priority = $3, status = $4, assignee_id = $5, project_id = $6, completed = $7 WHERE id = $8 RETURNING id;`
+// Restore takes the task out of the trash. It fails with
+// ErrTrashedReference while the task's project, assignee or parent task is
+// in the trash.
+func (m *TaskModel) Restore(ctx context.Context, id string) error {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
 
-	err := m.DB.QueryRow(stmt, input.Title, input.Description, input.Priority, input.Status, input.AssigneeID, input.ProjectID, input.Completed, id).Scan(&row)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return models.ErrNoRecord
+	return audited(ctx, m.DB, "tasks", id, models.AuditRestore, func(tx *sql.Tx) error {
+		var assigneeID, projectID int
+		var parentTaskID *int
+
+		err := tx.QueryRowContext(ctx, `SELECT assignee_id, project_id, parent_task_id FROM tasks WHERE id = $1 AND deleted_at IS NOT NULL FOR UPDATE;`, id).Scan(&assigneeID, &projectID, &parentTaskID)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return models.ErrNoRecord
+			}
+
+			return err
+		}
+
+		if err := requireLive(ctx, tx, "users", assigneeID); err != nil {
+			return err
+		}
+
+		if err := requireLive(ctx, tx, "projects", projectID); err != nil {
+			return err
+		}
+
+		if parentTaskID != nil {
+			if err := requireLive(ctx, tx, "tasks", *parentTaskID); err != nil {
+				return err
+			}
 		}
 
+		_, err = tx.ExecContext(ctx, `UPDATE tasks SET deleted_at = NULL WHERE id = $1;`, id)
 		return err
+	})
+}
+
+// Update overwrites the task if it is still at the given version and
+// returns its new version. A version mismatch yields ErrEditConflict and a
+// missing record ErrNoRecord.
+func (m *TaskModel) Update(ctx context.Context, id string, input *models.TaskInput, version int) (int, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	err := audited(ctx, m.DB, "tasks", id, models.AuditUpdate, func(tx *sql.Tx) error {
+		stmt := `UPDATE tasks SET title = $1, description = $2, priority = $3, status = $4, assignee_id = $5, project_id = $6, parent_task_id = $7, estimate = $8, completed = $9, version = version + 1
+			WHERE id = $10 AND version = $11 AND deleted_at IS NULL RETURNING version;`
+
+		err := tx.QueryRowContext(ctx, stmt, input.Title, input.Description, input.Priority, input.Status, input.AssigneeID, input.ProjectID, input.ParentTaskID, input.Estimate, input.CompletedAt(), id, version).Scan(&version)
+		if err != nil {
+			return updateError(ctx, tx, tasksTable, id, err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
 	}
 
-	return nil
+	return version, nil
 }
 
-func (m *TaskModel) GetAll() ([]*models.Task, error) {
-	stmt := `SELECT * FROM tasks;`
+// Patch writes only the given fields of input, named by column, and
+// otherwise behaves like Update.
+func (m *TaskModel) Patch(ctx context.Context, id string, input *models.TaskInput, fields []string, version int) (int, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
 
-	rows, err := m.DB.Query(stmt)
+	err := audited(ctx, m.DB, "tasks", id, models.AuditUpdate, func(tx *sql.Tx) error {
+		var err error
+		version, err = patch(ctx, tx, tasksTable, id, input.Values(), fields, version)
+		return err
+	})
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
 
-	defer rows.Close()
+	return version, nil
+}
 
-	tasks := []*models.Task{}
+// Tree returns the task followed by all of its live subtasks, direct or
+// nested, ordered by id.
+func (m *TaskModel) Tree(ctx context.Context, id string) ([]*models.Task, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
 
-	for rows.Next() {
-		s := &models.Task{}
-		err = rows.Scan(&s.ID, &s.Title, &s.Description, &s.Priority, &s.Status, &s.AssigneeID, &s.ProjectID, &s.Created, &s.Completed)
-		if err != nil {
-			return nil, err
-		}
-		tasks = append(tasks, s)
-	}
+	// UNION rather than UNION ALL stops the recursion should a cycle slip in
+	stmt := `WITH RECURSIVE tree AS (
+		SELECT id FROM tasks WHERE id = $1 AND deleted_at IS NULL
+		UNION
+		SELECT t.id FROM tasks t JOIN tree ON t.parent_task_id = tree.id WHERE t.deleted_at IS NULL
+	)
+	SELECT count(*) OVER(), ` + taskColumns + ` FROM tasks WHERE id IN (SELECT id FROM tree) ORDER BY id;`
 
-	if err = rows.Err(); err != nil {
+	tasks, _, err := m.list(ctx, models.Filters{Page: 1, PageSize: 1}, stmt, id)
+	if err != nil {
 		return nil, err
 	}
 
+	if len(tasks) == 0 {
+		return nil, models.ErrNoRecord
+	}
+
 	return tasks, nil
 }
 
-func (m *TaskModel) GetAllBy(arg, val string) ([]*models.Task, error) {
-	stmt := fmt.Sprintf(`SELECT id, title, description, priority, status, assignee_id, project_id, created, completed FROM tasks WHERE %s = $1;`, arg)
+func (m *TaskModel) GetAll(ctx context.Context, filters models.Filters) ([]*models.Task, models.Metadata, error) {
+	return m.Search(ctx, nil, filters)
+}
+
+func (m *TaskModel) GetAllBy(ctx context.Context, arg, val string, filters models.Filters) ([]*models.Task, models.Metadata, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
 
-	rows, err := m.DB.Query(stmt, val)
+	stmt, args, err := tasksTable.Select().WithCount().Where(query.Eq(arg, val)).Page(filters).Build()
 	if err != nil {
-		return nil, err
+		return nil, models.Metadata{}, err
+	}
+
+	return m.list(ctx, filters, stmt, args...)
+}
+
+func (m *TaskModel) Search(ctx context.Context, criteria models.Criteria, filters models.Filters) ([]*models.Task, models.Metadata, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt, args, err := tasksTable.Select().WithCount().Match(criteria).Page(filters).Build()
+	if err != nil {
+		return nil, models.Metadata{}, err
+	}
+
+	return m.list(ctx, filters, stmt, args...)
+}
+
+func (m *TaskModel) list(ctx context.Context, filters models.Filters, stmt string, args ...interface{}) ([]*models.Task, models.Metadata, error) {
+	rows, err := m.DB.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, models.Metadata{}, err
 	}
 
 	defer rows.Close()
 
+	totalRecords := 0
 	tasks := []*models.Task{}
 
 	for rows.Next() {
 		s := &models.Task{}
-		err = rows.Scan(&s.ID, &s.Title, &s.Description, &s.Priority, &s.Status, &s.AssigneeID, &s.ProjectID, &s.Created, &s.Completed)
+		err = rows.Scan(append([]interface{}{&totalRecords}, scanTask(s)...)...)
 		if err != nil {
-			return nil, err
+			return nil, models.Metadata{}, err
 		}
 		tasks = append(tasks, s)
 	}
 
 	if err = rows.Err(); err != nil {
-		return nil, err
+		return nil, models.Metadata{}, err
 	}
 
-	return tasks, nil
+	metadata, err := pageMetadata(ctx, m.DB, filters, totalRecords, len(tasks), stmt, args...)
+	if err != nil {
+		return nil, models.Metadata{}, err
+	}
+
+	return tasks, metadata, nil
+}
+
+// scanTask returns the scan destinations for the columns of a task row.
+func scanTask(s *models.Task) []interface{} {
+	return []interface{}{&s.ID, &s.Title, &s.Description, &s.Priority, &s.Status, &s.AssigneeID, &s.ProjectID, &s.ParentTaskID, &s.Estimate, &s.Created, &s.Completed, &s.Version, &s.Blocked}
 }
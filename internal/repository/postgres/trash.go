@@ -0,0 +1,126 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"pm-service/internal/repository/models"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type TrashModel struct {
+	DB      *sql.DB
+	Timeout time.Duration
+}
+
+// List returns the trashed records of the given types, most recently
+// deleted first.
+func (m *TrashModel) List(ctx context.Context, types []string, filters models.Filters) ([]*models.TrashItem, models.Metadata, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `WITH trash AS (
+		SELECT 'users' AS type, id, name AS title, deleted_at
+		FROM users WHERE deleted_at IS NOT NULL AND 'users' = ANY($1::text[])
+		UNION ALL
+		SELECT 'projects' AS type, id, title, deleted_at
+		FROM projects WHERE deleted_at IS NOT NULL AND 'projects' = ANY($1::text[])
+		UNION ALL
+		SELECT 'tasks' AS type, id, title, deleted_at
+		FROM tasks WHERE deleted_at IS NOT NULL AND 'tasks' = ANY($1::text[])
+	)
+	SELECT count(*) OVER(), type, id, title, deleted_at FROM trash
+	ORDER BY deleted_at DESC, type, id LIMIT $2 OFFSET $3;`
+
+	args := []interface{}{pq.Array(types), filters.Limit(), filters.Offset()}
+
+	rows, err := m.DB.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, models.Metadata{}, err
+	}
+
+	defer rows.Close()
+
+	totalRecords := 0
+	items := []*models.TrashItem{}
+
+	for rows.Next() {
+		s := &models.TrashItem{}
+		err = rows.Scan(&totalRecords, &s.Type, &s.ID, &s.Title, &s.Deleted)
+		if err != nil {
+			return nil, models.Metadata{}, err
+		}
+		items = append(items, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, models.Metadata{}, err
+	}
+
+	metadata, err := pageMetadata(ctx, m.DB, filters, totalRecords, len(items), stmt, args...)
+	if err != nil {
+		return nil, models.Metadata{}, err
+	}
+
+	return items, metadata, nil
+}
+
+// Purge permanently deletes records trashed before the given time and
+// returns how many rows were removed. Projects and users still referenced
+// by other rows, trashed or not, are kept until those rows are purged too.
+func (m *TrashModel) Purge(ctx context.Context, before time.Time) (int64, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmts := []string{
+		`DELETE FROM tasks WHERE deleted_at < $1;`,
+		`DELETE FROM projects p WHERE deleted_at < $1
+			AND NOT EXISTS (SELECT 1 FROM tasks t WHERE t.project_id = p.id);`,
+		`DELETE FROM users u WHERE deleted_at < $1
+			AND NOT EXISTS (SELECT 1 FROM tasks t WHERE t.assignee_id = u.id)
+			AND NOT EXISTS (SELECT 1 FROM projects p WHERE p.manager_id = u.id)
+			AND NOT EXISTS (SELECT 1 FROM comments c WHERE c.author_id = u.id);`,
+	}
+
+	var purged int64
+
+	err := withTx(ctx, m.DB, func(tx *sql.Tx) error {
+		for _, stmt := range stmts {
+			res, err := tx.ExecContext(ctx, stmt, before)
+			if err != nil {
+				return err
+			}
+
+			n, err := res.RowsAffected()
+			if err != nil {
+				return err
+			}
+			purged += n
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return purged, nil
+}
+
+// requireLive returns ErrTrashedReference unless the row with the given id
+// in table exists and is not trashed. table must be a constant.
+func requireLive(ctx context.Context, tx *sql.Tx, table string, id int) error {
+	var live bool
+
+	err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM `+table+` WHERE id = $1 AND deleted_at IS NULL);`, id).Scan(&live)
+	if err != nil {
+		return err
+	}
+
+	if !live {
+		return models.ErrTrashedReference
+	}
+
+	return nil
+}
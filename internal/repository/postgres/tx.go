@@ -0,0 +1,68 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"pm-service/internal/repository/models"
+	"pm-service/internal/repository/query"
+
+	"github.com/lib/pq"
+)
+
+// withTx runs fn inside a transaction, committing when it returns nil and
+// rolling back otherwise.
+func withTx(ctx context.Context, db *sql.DB, fn func(*sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// deleteError maps the error of a DELETE onto the models package errors. A
+// foreign_key_violation there means other rows still reference the record.
+func deleteError(err error) error {
+	if err == sql.ErrNoRows {
+		return models.ErrNoRecord
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+		return models.ErrReferenced
+	}
+
+	return err
+}
+
+// updateError maps the error of an UPDATE guarded by the row's version onto
+// the models package errors. No row came back either because the record is
+// gone, or because someone else changed it first.
+func updateError(ctx context.Context, tx *sql.Tx, table query.Table, id string, err error) error {
+	if err != sql.ErrNoRows {
+		return err
+	}
+
+	stmt, args, err := table.Select("id").Where(query.Eq("id", id)).Build()
+	if err != nil {
+		return err
+	}
+
+	var row int
+
+	err = tx.QueryRowContext(ctx, stmt, args...).Scan(&row)
+	if err == sql.ErrNoRows {
+		return models.ErrNoRecord
+	}
+	if err != nil {
+		return err
+	}
+
+	return models.ErrEditConflict
+}
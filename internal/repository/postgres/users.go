@@ -1,20 +1,42 @@
 package postgres
 
 import (
+	"context"
 	"database/sql"
-	"fmt"
 	"pm-service/internal/repository/models"
+	"pm-service/internal/repository/query"
+	"time"
+
+	"github.com/lib/pq"
 )
 
+// usersTable whitelists the columns list queries may select, filter and sort on.
+var usersTable = query.Table{
+	Name:       "users",
+	Columns:    []string{"id", "name", "email", "role", "created", "version"},
+	SoftDelete: "deleted_at",
+}
+
 type UserModel struct {
-	DB *sql.DB
+	DB      *sql.DB
+	Timeout time.Duration
 }
 
-func (m *UserModel) Insert(input *models.UserInput) (int, error) {
+func (m *UserModel) Insert(ctx context.Context, input *models.UserInput) (int, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
 	var id int
-	stmt := `INSERT INTO users (name, email, role) VALUES ($1, $2, $3) RETURNING id;`
 
-	err := m.DB.QueryRow(stmt, input.Name, input.Email, input.Role).Scan(&id)
+	err := withTx(ctx, m.DB, func(tx *sql.Tx) error {
+		stmt := `INSERT INTO users (name, email, role, password_hash) VALUES ($1, $2, $3, $4) RETURNING id;`
+
+		if err := tx.QueryRowContext(ctx, stmt, input.Name, input.Email, input.Role, input.PasswordHash).Scan(&id); err != nil {
+			return err
+		}
+
+		return record(ctx, tx, "users", id, models.AuditInsert, nil)
+	})
 	if err != nil {
 		return -1, err
 	}
@@ -22,11 +44,14 @@ func (m *UserModel) Insert(input *models.UserInput) (int, error) {
 	return id, nil
 }
 
-func (m *UserModel) Get(id string) (*models.User, error) {
+func (m *UserModel) Get(ctx context.Context, id string) (*models.User, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
 	s := &models.User{}
 
-	stmt := `SELECT id, name, email, role, created FROM users WHERE id = $1;`
-	err := m.DB.QueryRow(stmt, id).Scan(&s.ID, &s.Name, &s.Email, &s.Role, &s.Created)
+	stmt := `SELECT id, name, email, role, created, version FROM users WHERE id = $1 AND deleted_at IS NULL;`
+	err := m.DB.QueryRowContext(ctx, stmt, id).Scan(&s.ID, &s.Name, &s.Email, &s.Role, &s.Created, &s.Version)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return s, models.ErrNoRecord
@@ -38,90 +63,194 @@ func (m *UserModel) Get(id string) (*models.User, error) {
 	return s, nil
 }
 
-func (m *UserModel) Delete(id string) error {
-	var row int
-	stmt := `DELETE FROM users WHERE id = $1 RETURNING id;`
+func (m *UserModel) Delete(ctx context.Context, id string) error {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
 
-	err := m.DB.QueryRow(stmt, id).Scan(&row)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return models.ErrNoRecord
+	return audited(ctx, m.DB, "users", id, models.AuditDelete, func(tx *sql.Tx) error {
+		var row int
+		stmt := `UPDATE users SET deleted_at = now() WHERE id = $1 AND deleted_at IS NULL RETURNING id;`
+
+		err := tx.QueryRowContext(ctx, stmt, id).Scan(&row)
+		if err != nil {
+			return deleteError(err)
 		}
 
-		return err
-	}
+		return nil
+	})
+}
+
+// DeleteReassign hands the user's tasks, managed projects and project
+// memberships, trashed ones included, over to the user with id to, then
+// moves the user to the trash, all in a single transaction. Where both
+// users are members of a project the stronger role is kept.
+func (m *UserModel) DeleteReassign(ctx context.Context, id, to string) error {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
 
-	return nil
+	return audited(ctx, m.DB, "users", id, models.AuditDelete, func(tx *sql.Tx) error {
+		projects, err := snapshots(ctx, tx, "projects", `SELECT project_id FROM project_members WHERE user_id = $1
+			UNION SELECT id FROM projects WHERE manager_id = $1;`, id)
+		if err != nil {
+			return err
+		}
+
+		tasks, err := snapshots(ctx, tx, "tasks", `SELECT id FROM tasks WHERE assignee_id = $1;`, id)
+		if err != nil {
+			return err
+		}
+
+		stmt := `INSERT INTO project_members (project_id, user_id, role)
+			SELECT project_id, $1, role FROM project_members WHERE user_id = $2
+			ON CONFLICT (project_id, user_id) DO UPDATE SET role = EXCLUDED.role
+			WHERE array_position($3::text[], EXCLUDED.role::text) < array_position($3::text[], project_members.role::text);`
+
+		if _, err := tx.ExecContext(ctx, stmt, to, id, pq.Array(models.ProjectRoles)); err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, `UPDATE tasks SET assignee_id = $1, version = version + 1 WHERE assignee_id = $2;`, to, id); err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, `UPDATE projects SET manager_id = $1, version = version + 1 WHERE manager_id = $2;`, to, id); err != nil {
+			return err
+		}
+
+		var row int
+		err = tx.QueryRowContext(ctx, `UPDATE users SET deleted_at = now() WHERE id = $1 AND deleted_at IS NULL RETURNING id;`, id).Scan(&row)
+		if err != nil {
+			return deleteError(err)
+		}
+
+		if err := recordAll(ctx, tx, "projects", models.AuditUpdate, projects); err != nil {
+			return err
+		}
+
+		return recordAll(ctx, tx, "tasks", models.AuditUpdate, tasks)
+	})
 }
 
-func (m *UserModel) Update(id string, input *models.UserInput) error {
-	var row int
-	stmt := `UPDATE users SET name = $1, email = $2, role = $3 WHERE id = $4 RETURNING id;`
+// Restore takes the user out of the trash.
+func (m *UserModel) Restore(ctx context.Context, id string) error {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
 
-	err := m.DB.QueryRow(stmt, input.Name, input.Email, input.Role, id).Scan(&row)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return models.ErrNoRecord
+	return audited(ctx, m.DB, "users", id, models.AuditRestore, func(tx *sql.Tx) error {
+		var row int
+		stmt := `UPDATE users SET deleted_at = NULL WHERE id = $1 AND deleted_at IS NOT NULL RETURNING id;`
+
+		err := tx.QueryRowContext(ctx, stmt, id).Scan(&row)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return models.ErrNoRecord
+			}
+
+			return err
 		}
 
-		return err
+		return nil
+	})
+}
+
+// Update overwrites the user if it is still at the given version and
+// returns its new version. A version mismatch yields ErrEditConflict and a
+// missing record ErrNoRecord.
+func (m *UserModel) Update(ctx context.Context, id string, input *models.UserInput, version int) (int, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	err := audited(ctx, m.DB, "users", id, models.AuditUpdate, func(tx *sql.Tx) error {
+		stmt := `UPDATE users SET name = $1, email = $2, role = $3, version = version + 1 WHERE id = $4 AND version = $5 AND deleted_at IS NULL RETURNING version;`
+
+		err := tx.QueryRowContext(ctx, stmt, input.Name, input.Email, input.Role, id, version).Scan(&version)
+		if err != nil {
+			return updateError(ctx, tx, usersTable, id, err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
 	}
 
-	return nil
+	return version, nil
 }
 
-func (m *UserModel) GetAll() ([]*models.User, error) {
-	stmt := `SELECT * FROM users;`
+// Patch writes only the given fields of input, named by column, and
+// otherwise behaves like Update.
+func (m *UserModel) Patch(ctx context.Context, id string, input *models.UserInput, fields []string, version int) (int, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
 
-	rows, err := m.DB.Query(stmt)
+	err := audited(ctx, m.DB, "users", id, models.AuditUpdate, func(tx *sql.Tx) error {
+		var err error
+		version, err = patch(ctx, tx, usersTable, id, input.Values(), fields, version)
+		return err
+	})
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
 
-	defer rows.Close()
+	return version, nil
+}
 
-	users := []*models.User{}
+func (m *UserModel) GetAll(ctx context.Context, filters models.Filters) ([]*models.User, models.Metadata, error) {
+	return m.Search(ctx, nil, filters)
+}
 
-	for rows.Next() {
-		s := &models.User{}
-		err = rows.Scan(&s.ID, &s.Name, &s.Email, &s.Role, &s.Created)
-		if err != nil {
-			return nil, err
-		}
-		users = append(users, s)
-	}
+func (m *UserModel) GetAllBy(ctx context.Context, arg, val string, filters models.Filters) ([]*models.User, models.Metadata, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
 
-	if err = rows.Err(); err != nil {
-		return nil, err
+	stmt, args, err := usersTable.Select().WithCount().Where(query.Eq(arg, val)).Page(filters).Build()
+	if err != nil {
+		return nil, models.Metadata{}, err
 	}
 
-	return users, nil
+	return m.list(ctx, filters, stmt, args...)
 }
 
-func (m *UserModel) GetAllBy(arg, val string) ([]*models.User, error) {
-	stmt := fmt.Sprintf(`SELECT id, name, email, role, created FROM users WHERE %s = $1;`, arg)
+func (m *UserModel) Search(ctx context.Context, criteria models.Criteria, filters models.Filters) ([]*models.User, models.Metadata, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
 
-	rows, err := m.DB.Query(stmt, val)
+	stmt, args, err := usersTable.Select().WithCount().Match(criteria).Page(filters).Build()
 	if err != nil {
-		return nil, err
+		return nil, models.Metadata{}, err
+	}
+
+	return m.list(ctx, filters, stmt, args...)
+}
+
+func (m *UserModel) list(ctx context.Context, filters models.Filters, stmt string, args ...interface{}) ([]*models.User, models.Metadata, error) {
+	rows, err := m.DB.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, models.Metadata{}, err
 	}
 
 	defer rows.Close()
 
+	totalRecords := 0
 	users := []*models.User{}
 
 	for rows.Next() {
 		s := &models.User{}
-		err = rows.Scan(&s.ID, &s.Name, &s.Email, &s.Role, &s.Created)
+		err = rows.Scan(&totalRecords, &s.ID, &s.Name, &s.Email, &s.Role, &s.Created, &s.Version)
 		if err != nil {
-			return nil, err
+			return nil, models.Metadata{}, err
 		}
 		users = append(users, s)
 	}
 
 	if err = rows.Err(); err != nil {
-		return nil, err
+		return nil, models.Metadata{}, err
+	}
+
+	metadata, err := pageMetadata(ctx, m.DB, filters, totalRecords, len(users), stmt, args...)
+	if err != nil {
+		return nil, models.Metadata{}, err
 	}
 
-	return users, nil
+	return users, metadata, nil
 }
@@ -0,0 +1,342 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"pm-service/internal/repository/models"
+	"pm-service/internal/repository/query"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// webhooksTable whitelists the columns webhook queries may select, filter and sort on.
+var webhooksTable = query.Table{
+	Name:    "webhooks",
+	Columns: []string{"id", "url", "events", "active", "created", "version"},
+}
+
+// deliveriesTable whitelists the columns delivery queries may select, filter and sort on.
+var deliveriesTable = query.Table{
+	Name:    "webhook_deliveries",
+	Columns: []string{"id", "webhook_id", "event", "payload", "status", "attempts", "next_attempt", "created", "delivered"},
+}
+
+type WebhookModel struct {
+	DB      *sql.DB
+	Timeout time.Duration
+}
+
+// Enqueue queues a delivery of the event to each active webhook subscribed
+// to it. Deliveries already queued for the same source outbox event are
+// not queued again; a source of 0 names none.
+func (m *WebhookModel) Enqueue(ctx context.Context, event string, payload []byte, source int) error {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `INSERT INTO webhook_deliveries (webhook_id, event, payload, outbox_id)
+		SELECT id, $1, $2, NULLIF($3, 0) FROM webhooks WHERE active AND $1 = ANY(events)
+		ON CONFLICT (outbox_id, webhook_id, event) DO NOTHING;`
+
+	_, err := m.DB.ExecContext(ctx, stmt, event, payload, source)
+	return err
+}
+
+func (m *WebhookModel) Insert(ctx context.Context, input *models.WebhookInput) (int, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	var id int
+
+	err := withTx(ctx, m.DB, func(tx *sql.Tx) error {
+		stmt := `INSERT INTO webhooks (url, secret, events, active) VALUES ($1, $2, $3, $4) RETURNING id;`
+
+		if err := tx.QueryRowContext(ctx, stmt, input.URL, input.Secret, pq.Array(input.Events), input.IsActive()).Scan(&id); err != nil {
+			return err
+		}
+
+		return record(ctx, tx, "webhooks", id, models.AuditInsert, nil)
+	})
+	if err != nil {
+		return -1, err
+	}
+
+	return id, nil
+}
+
+func (m *WebhookModel) Get(ctx context.Context, id string) (*models.Webhook, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	w := &models.Webhook{}
+
+	stmt := `SELECT id, url, events, active, created, version FROM webhooks WHERE id = $1;`
+	err := m.DB.QueryRowContext(ctx, stmt, id).Scan(&w.ID, &w.URL, pq.Array(&w.Events), &w.Active, &w.Created, &w.Version)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return w, models.ErrNoRecord
+		}
+
+		return w, err
+	}
+
+	return w, nil
+}
+
+func (m *WebhookModel) GetAll(ctx context.Context, filters models.Filters) ([]*models.Webhook, models.Metadata, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt, args, err := webhooksTable.Select().WithCount().Page(filters).Build()
+	if err != nil {
+		return nil, models.Metadata{}, err
+	}
+
+	rows, err := m.DB.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, models.Metadata{}, err
+	}
+
+	defer rows.Close()
+
+	totalRecords := 0
+	webhooks := []*models.Webhook{}
+
+	for rows.Next() {
+		w := &models.Webhook{}
+
+		err = rows.Scan(&totalRecords, &w.ID, &w.URL, pq.Array(&w.Events), &w.Active, &w.Created, &w.Version)
+		if err != nil {
+			return nil, models.Metadata{}, err
+		}
+		webhooks = append(webhooks, w)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, models.Metadata{}, err
+	}
+
+	metadata, err := pageMetadata(ctx, m.DB, filters, totalRecords, len(webhooks), stmt, args...)
+	if err != nil {
+		return nil, models.Metadata{}, err
+	}
+
+	return webhooks, metadata, nil
+}
+
+// Update replaces the webhook if it is still at the given version and
+// returns its new version. A blank secret keeps the current one.
+func (m *WebhookModel) Update(ctx context.Context, id string, input *models.WebhookInput, version int) (int, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	err := audited(ctx, m.DB, "webhooks", id, models.AuditUpdate, func(tx *sql.Tx) error {
+		stmt := `UPDATE webhooks SET url = $1, secret = COALESCE(NULLIF($2, ''), secret),
+			secret_version = secret_version + CASE WHEN $2 = '' THEN 0 ELSE 1 END, events = $3, active = $4, version = version + 1
+			WHERE id = $5 AND version = $6 RETURNING version;`
+
+		err := tx.QueryRowContext(ctx, stmt, input.URL, input.Secret, pq.Array(input.Events), input.IsActive(), id, version).Scan(&version)
+		if err != nil {
+			return updateError(ctx, tx, webhooksTable, id, err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return version, nil
+}
+
+// Delete removes the webhook together with its deliveries.
+func (m *WebhookModel) Delete(ctx context.Context, id string) error {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	return audited(ctx, m.DB, "webhooks", id, models.AuditDelete, func(tx *sql.Tx) error {
+		var row int
+
+		err := tx.QueryRowContext(ctx, `DELETE FROM webhooks WHERE id = $1 RETURNING id;`, id).Scan(&row)
+		if err != nil {
+			return deleteError(err)
+		}
+
+		return nil
+	})
+}
+
+// Deliveries returns a page of the webhook's deliveries matching criteria,
+// newest first unless sorted otherwise, without their attempts.
+func (m *WebhookModel) Deliveries(ctx context.Context, webhookID int, criteria models.Criteria, filters models.Filters) ([]*models.Delivery, models.Metadata, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt, args, err := deliveriesTable.Select().WithCount().Where(query.Eq("webhook_id", webhookID)).Match(criteria).Page(filters).Build()
+	if err != nil {
+		return nil, models.Metadata{}, err
+	}
+
+	rows, err := m.DB.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, models.Metadata{}, err
+	}
+
+	defer rows.Close()
+
+	totalRecords := 0
+	deliveries := []*models.Delivery{}
+
+	for rows.Next() {
+		d := &models.Delivery{}
+
+		err = rows.Scan(&totalRecords, &d.ID, &d.WebhookID, &d.Event, &d.Payload, &d.Status, &d.Attempts, &d.NextAttempt, &d.Created, &d.Delivered)
+		if err != nil {
+			return nil, models.Metadata{}, err
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, models.Metadata{}, err
+	}
+
+	metadata, err := pageMetadata(ctx, m.DB, filters, totalRecords, len(deliveries), stmt, args...)
+	if err != nil {
+		return nil, models.Metadata{}, err
+	}
+
+	return deliveries, metadata, nil
+}
+
+// Delivery returns one of the webhook's deliveries with the log of its
+// attempts, oldest first.
+func (m *WebhookModel) Delivery(ctx context.Context, webhookID, deliveryID int) (*models.Delivery, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	d := &models.Delivery{Log: []*models.DeliveryAttempt{}}
+
+	stmt := `SELECT id, webhook_id, event, payload, status, attempts, next_attempt, created, delivered
+		FROM webhook_deliveries WHERE id = $1 AND webhook_id = $2;`
+
+	err := m.DB.QueryRowContext(ctx, stmt, deliveryID, webhookID).Scan(&d.ID, &d.WebhookID, &d.Event, &d.Payload, &d.Status, &d.Attempts, &d.NextAttempt, &d.Created, &d.Delivered)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return d, models.ErrNoRecord
+		}
+
+		return d, err
+	}
+
+	rows, err := m.DB.QueryContext(ctx, `SELECT status_code, error, duration_ms, created FROM webhook_attempts WHERE delivery_id = $1 ORDER BY id;`, deliveryID)
+	if err != nil {
+		return d, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		a := &models.DeliveryAttempt{}
+		if err := rows.Scan(&a.StatusCode, &a.Error, &a.DurationMS, &a.Created); err != nil {
+			return d, err
+		}
+		d.Log = append(d.Log, a)
+	}
+
+	return d, rows.Err()
+}
+
+// Redeliver queues the delivery again, whatever its status, to be sent
+// right away with a fresh set of attempts. Its log is kept.
+func (m *WebhookModel) Redeliver(ctx context.Context, webhookID, deliveryID int) error {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `UPDATE webhook_deliveries SET status = 'pending', attempts = 0, next_attempt = now(), delivered = NULL
+		WHERE id = $1 AND webhook_id = $2 RETURNING id;`
+
+	var row int
+
+	err := m.DB.QueryRowContext(ctx, stmt, deliveryID, webhookID).Scan(&row)
+	if err == sql.ErrNoRows {
+		return models.ErrNoRecord
+	}
+
+	return err
+}
+
+// Claim returns up to limit pending deliveries that are due, oldest due
+// first, and postpones them by the lease so that neither this nor another
+// instance sends them again before they are logged. Deliveries of inactive
+// webhooks are held until the webhook is active again.
+func (m *WebhookModel) Claim(ctx context.Context, limit int, lease time.Duration) ([]*models.DeliveryJob, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	stmt := `WITH due AS (
+			SELECT d.id FROM webhook_deliveries d JOIN webhooks w ON w.id = d.webhook_id
+			WHERE d.status = 'pending' AND d.next_attempt <= now() AND w.active
+			ORDER BY d.next_attempt, d.id LIMIT $1
+			FOR UPDATE OF d SKIP LOCKED
+		)
+		UPDATE webhook_deliveries d SET next_attempt = now() + $2 * interval '1 millisecond'
+		FROM due, webhooks w
+		WHERE d.id = due.id AND w.id = d.webhook_id
+		RETURNING d.id, d.webhook_id, d.event, d.payload, d.status, d.attempts, d.next_attempt, d.created, w.url, w.secret;`
+
+	rows, err := m.DB.QueryContext(ctx, stmt, limit, lease.Milliseconds())
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	jobs := []*models.DeliveryJob{}
+
+	for rows.Next() {
+		j := &models.DeliveryJob{}
+
+		err := rows.Scan(&j.ID, &j.WebhookID, &j.Event, &j.Payload, &j.Status, &j.Attempts, &j.NextAttempt, &j.Created, &j.URL, &j.Secret)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, j)
+	}
+
+	return jobs, rows.Err()
+}
+
+// Attempted logs an attempt at the claimed delivery and moves it to the
+// given status, to be tried again at next while it is pending. It fails
+// with models.ErrLeaseLost, logging nothing, unless the delivery is still
+// claimed by job.
+func (m *WebhookModel) Attempted(ctx context.Context, job *models.DeliveryJob, attempt *models.DeliveryAttempt, status string, next time.Time) error {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	return withTx(ctx, m.DB, func(tx *sql.Tx) error {
+		stmt := `UPDATE webhook_deliveries SET status = $1, attempts = attempts + 1, next_attempt = $2,
+			delivered = CASE WHEN $1 = 'delivered' THEN now() END
+			WHERE id = $3 AND status = 'pending' AND next_attempt = $4;`
+
+		result, err := tx.ExecContext(ctx, stmt, status, next, job.ID, job.NextAttempt)
+		if err != nil {
+			return err
+		}
+
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if rows == 0 {
+			return models.ErrLeaseLost
+		}
+
+		stmt = `INSERT INTO webhook_attempts (delivery_id, status_code, error, duration_ms, created) VALUES ($1, $2, $3, $4, $5);`
+
+		_, err = tx.ExecContext(ctx, stmt, job.ID, attempt.StatusCode, attempt.Error, attempt.DurationMS, attempt.Created)
+		return err
+	})
+}
@@ -0,0 +1,169 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"pm-service/internal/repository/models"
+	"strings"
+	"time"
+)
+
+type WorkflowModel struct {
+	DB      *sql.DB
+	Timeout time.Duration
+}
+
+// Get returns the project's workflow, or the default one when the project
+// has not configured its own.
+func (m *WorkflowModel) Get(ctx context.Context, projectID int) (*models.Workflow, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	w := &models.Workflow{States: []models.WorkflowState{}, Transitions: []models.WorkflowTransition{}}
+
+	rows, err := m.DB.QueryContext(ctx, `SELECT name, terminal FROM workflow_states WHERE project_id = $1 ORDER BY position;`, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var s models.WorkflowState
+		if err := rows.Scan(&s.Name, &s.Terminal); err != nil {
+			return nil, err
+		}
+		w.States = append(w.States, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(w.States) == 0 {
+		return models.DefaultWorkflow(), nil
+	}
+
+	rows, err = m.DB.QueryContext(ctx, `SELECT from_state, to_state FROM workflow_transitions WHERE project_id = $1 ORDER BY from_state, to_state;`, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var t models.WorkflowTransition
+		if err := rows.Scan(&t.From, &t.To); err != nil {
+			return nil, err
+		}
+		w.Transitions = append(w.Transitions, t)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// Set replaces the project's workflow in a single transaction.
+func (m *WorkflowModel) Set(ctx context.Context, projectID int, w *models.Workflow) error {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	return audited(ctx, m.DB, "workflows", projectID, models.AuditUpdate, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM workflow_states WHERE project_id = $1;`, projectID); err != nil {
+			return err
+		}
+
+		for i, s := range w.States {
+			_, err := tx.ExecContext(ctx, `INSERT INTO workflow_states (project_id, name, position, terminal) VALUES ($1, $2, $3, $4);`, projectID, s.Name, i, s.Terminal)
+			if err != nil {
+				return err
+			}
+		}
+
+		// transitions refer to the states by their exact names
+		for _, t := range w.Transitions {
+			from, _ := w.State(t.From)
+			to, _ := w.State(t.To)
+
+			_, err := tx.ExecContext(ctx, `INSERT INTO workflow_transitions (project_id, from_state, to_state) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING;`, projectID, from, to)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// States lists every status defined by the default or any project's
+// workflow.
+func (m *WorkflowModel) States(ctx context.Context) ([]string, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	states := models.DefaultWorkflow().Names()
+
+	rows, err := m.DB.QueryContext(ctx, `SELECT DISTINCT lower(name) FROM workflow_states;`)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		if !contains(states, name) {
+			states = append(states, name)
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return states, nil
+}
+
+// StatusesInUse lists the distinct statuses of the project's tasks,
+// trashed ones included.
+func (m *WorkflowModel) StatusesInUse(ctx context.Context, projectID int) ([]string, error) {
+	ctx, cancel := withTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, `SELECT DISTINCT status FROM tasks WHERE project_id = $1 ORDER BY status;`, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	statuses := []string{}
+	for rows.Next() {
+		var status string
+		if err := rows.Scan(&status); err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, status)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return statuses, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
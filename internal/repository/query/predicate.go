@@ -0,0 +1,155 @@
+package query
+
+import (
+	"fmt"
+	"pm-service/internal/repository/models"
+	"strings"
+)
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// Predicate is a single WHERE condition. The column, when set, is checked
+// against the table whitelist; each ? in the template is bound to the
+// next argument.
+type Predicate struct {
+	column   string
+	template string
+	args     []interface{}
+}
+
+func (p Predicate) render(t Table) string {
+	if p.column == "" {
+		return p.template
+	}
+	return strings.ReplaceAll(p.template, "{col}", t.expr(p.column))
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+func Eq(column string, value interface{}) Predicate {
+	return Predicate{column, "{col} = ?", []interface{}{value}}
+}
+
+func In(column string, values ...interface{}) Predicate {
+	return Predicate{column, "{col} IN (" + placeholders(len(values)) + ")", values}
+}
+
+// InFold is In compared case-insensitively.
+func InFold(column string, values ...string) Predicate {
+	list := make([]string, len(values))
+	args := make([]interface{}, len(values))
+	for i, v := range values {
+		list[i] = "lower(?)"
+		args[i] = v
+	}
+	return Predicate{column, "lower({col}) IN (" + strings.Join(list, ", ") + ")", args}
+}
+
+// Contains matches a case-insensitive substring; LIKE wildcards in
+// substring are escaped.
+func Contains(column, substring string) Predicate {
+	return Predicate{column, "{col} ILIKE ?", []interface{}{"%" + likeEscaper.Replace(substring) + "%"}}
+}
+
+// HasAny matches rows whose set column holds any of the values, ignoring
+// case.
+func HasAny(column string, values ...string) Predicate {
+	return Predicate{column, "{col} && " + lowerArray(len(values)), stringArgs(values)}
+}
+
+// HasAll matches rows whose set column holds every one of the values,
+// ignoring case.
+func HasAll(column string, values ...string) Predicate {
+	return Predicate{column, "{col} @> " + lowerArray(len(values)), stringArgs(values)}
+}
+
+func lowerArray(n int) string {
+	return "ARRAY[" + strings.TrimSuffix(strings.Repeat("lower(?), ", n), ", ") + "]::text[]"
+}
+
+func stringArgs(values []string) []interface{} {
+	args := make([]interface{}, len(values))
+	for i, v := range values {
+		args[i] = v
+	}
+	return args
+}
+
+func Gte(column string, value interface{}) Predicate {
+	return Predicate{column, "{col} >= ?", []interface{}{value}}
+}
+
+func Lt(column string, value interface{}) Predicate {
+	return Predicate{column, "{col} < ?", []interface{}{value}}
+}
+
+func IsNull(column string) Predicate {
+	return Predicate{column, "{col} IS NULL", nil}
+}
+
+func Not(p Predicate) Predicate {
+	p.template = "NOT (" + p.template + ")"
+	return p
+}
+
+// Raw is an escape hatch for conditions the helpers above cannot express,
+// such as sub-queries. sql must be a constant written in the repository
+// layer; never build it from user input.
+func Raw(sql string, args ...interface{}) Predicate {
+	return Predicate{"", sql, args}
+}
+
+// FromCondition translates a repository search condition into a predicate.
+func FromCondition(c models.Condition) (Predicate, error) {
+	if len(c.Values) == 0 {
+		return Predicate{}, fmt.Errorf("query: no values given for field %q", c.Field)
+	}
+
+	var p Predicate
+
+	switch c.Op {
+	case models.OpEqual:
+		values := make([]interface{}, len(c.Values))
+		for i, v := range c.Values {
+			values[i] = v
+		}
+		p = In(c.Field, values...)
+	case models.OpEqualFold:
+		p = InFold(c.Field, c.Values...)
+	case models.OpContains:
+		p = Contains(c.Field, c.Values[0])
+	case models.OpHasAny:
+		p = HasAny(c.Field, c.Values...)
+	case models.OpHasAll:
+		p = HasAll(c.Field, c.Values...)
+	case models.OpFrom:
+		p = Gte(c.Field, c.Values[0])
+	case models.OpBefore:
+		p = Lt(c.Field, c.Values[0])
+	default:
+		return Predicate{}, fmt.Errorf("query: unknown operator %q", c.Op)
+	}
+
+	if c.Negate {
+		p = Not(p)
+	}
+
+	return p, nil
+}
+
+// Match adds a predicate for every condition in criteria.
+func (b *Builder) Match(criteria models.Criteria) *Builder {
+	for _, c := range criteria {
+		p, err := FromCondition(c)
+		if err != nil {
+			if b.err == nil {
+				b.err = err
+			}
+			continue
+		}
+		b.Where(p)
+	}
+	return b
+}
@@ -0,0 +1,208 @@
+// Package query builds parameterised SELECT statements. Column names are
+// checked against a per-table whitelist and every value is passed as a
+// placeholder argument, so callers cannot inject SQL through field names
+// or values.
+package query
+
+import (
+	"fmt"
+	"pm-service/internal/repository/models"
+	"strings"
+)
+
+// Table names a table and whitelists the columns that may be referenced.
+type Table struct {
+	Name    string
+	Columns []string
+	// SoftDelete, when set, names the column marking trashed rows; queries
+	// on the table only return rows where it is NULL.
+	SoftDelete string
+	// Computed maps whitelisted columns that are not stored to the SQL
+	// expression deriving them. They can be selected, filtered and sorted
+	// on but not written.
+	Computed map[string]string
+	// Sets maps columns holding several values per row to a sub-query
+	// returning those values, lowercased, one per row. They can only be
+	// filtered on, with HasAny and HasAll.
+	Sets map[string]string
+}
+
+func (t Table) Has(column string) bool {
+	for _, c := range t.Columns {
+		if c == column {
+			return true
+		}
+	}
+	return false
+}
+
+// filterable reports whether predicates may reference the column.
+func (t Table) filterable(column string) bool {
+	_, set := t.Sets[column]
+	return set || t.Has(column)
+}
+
+// expr returns the SQL referring to the column.
+func (t Table) expr(column string) string {
+	if e, ok := t.Computed[column]; ok {
+		return "(" + e + ")"
+	}
+	if e, ok := t.Sets[column]; ok {
+		return "ARRAY(" + e + ")"
+	}
+	return column
+}
+
+// Select starts a query returning the given columns, or every whitelisted
+// column when none are given.
+func (t Table) Select(columns ...string) *Builder {
+	b := &Builder{table: t}
+
+	if len(columns) == 0 {
+		columns = t.Columns
+	}
+
+	for _, c := range columns {
+		if !t.Has(c) {
+			b.fail(c)
+		}
+	}
+	b.columns = columns
+
+	if t.SoftDelete != "" {
+		b.where = append(b.where, IsNull(t.SoftDelete))
+	}
+
+	return b
+}
+
+type Builder struct {
+	table   Table
+	columns []string
+	count   bool
+	where   []Predicate
+	order   []string
+	limit   *int
+	offset  *int
+	err     error
+}
+
+func (b *Builder) fail(column string) {
+	if b.err == nil {
+		b.err = fmt.Errorf("query: unknown column %q for table %s", column, b.table.Name)
+	}
+}
+
+// WithCount adds a window count of all matching rows as the first column.
+func (b *Builder) WithCount() *Builder {
+	b.count = true
+	return b
+}
+
+// Where adds predicates combined with AND.
+func (b *Builder) Where(predicates ...Predicate) *Builder {
+	for _, p := range predicates {
+		if p.column != "" && !b.table.filterable(p.column) {
+			b.fail(p.column)
+		}
+		b.where = append(b.where, p)
+	}
+	return b
+}
+
+// OrderBy appends sort columns; desc[i] selects descending order for columns[i].
+func (b *Builder) OrderBy(columns []string, desc []bool) *Builder {
+	for i, c := range columns {
+		if !b.table.Has(c) {
+			b.fail(c)
+			continue
+		}
+
+		if i < len(desc) && desc[i] {
+			b.order = append(b.order, b.table.expr(c)+" DESC")
+		} else {
+			b.order = append(b.order, b.table.expr(c)+" ASC")
+		}
+	}
+	return b
+}
+
+// Page applies the sort order, limit and offset described by filters.
+func (b *Builder) Page(filters models.Filters) *Builder {
+	limit, offset := filters.Limit(), filters.Offset()
+	b.limit, b.offset = &limit, &offset
+
+	return b.OrderBy(filters.SortFields())
+}
+
+// Build renders the statement and its arguments.
+func (b *Builder) Build() (string, []interface{}, error) {
+	if b.err != nil {
+		return "", nil, b.err
+	}
+
+	var sb strings.Builder
+	var args []interface{}
+
+	bind := func(fragment string, values []interface{}) string {
+		return bind(&args, fragment, values)
+	}
+
+	sb.WriteString("SELECT ")
+	if b.count {
+		sb.WriteString("count(*) OVER(), ")
+	}
+	for i, c := range b.columns {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(b.table.expr(c))
+		if _, ok := b.table.Computed[c]; ok {
+			sb.WriteString(" AS " + c)
+		}
+	}
+	sb.WriteString(" FROM ")
+	sb.WriteString(b.table.Name)
+
+	if len(b.where) > 0 {
+		clauses := make([]string, len(b.where))
+		for i, p := range b.where {
+			clauses[i] = bind(p.render(b.table), p.args)
+		}
+		sb.WriteString(" WHERE ")
+		sb.WriteString(strings.Join(clauses, " AND "))
+	}
+
+	if len(b.order) > 0 {
+		sb.WriteString(" ORDER BY ")
+		sb.WriteString(strings.Join(b.order, ", "))
+	}
+
+	if b.limit != nil {
+		sb.WriteString(bind(" LIMIT ?", []interface{}{*b.limit}))
+	}
+
+	if b.offset != nil {
+		sb.WriteString(bind(" OFFSET ?", []interface{}{*b.offset}))
+	}
+
+	sb.WriteString(";")
+
+	return sb.String(), args, nil
+}
+
+// bind replaces each ? in fragment with the next $n placeholder, appending
+// the matching value to args.
+func bind(args *[]interface{}, fragment string, values []interface{}) string {
+	var out strings.Builder
+	for _, r := range fragment {
+		if r == '?' && len(values) > 0 {
+			*args = append(*args, values[0])
+			values = values[1:]
+			fmt.Fprintf(&out, "$%d", len(*args))
+			continue
+		}
+		out.WriteRune(r)
+	}
+	return out.String()
+}
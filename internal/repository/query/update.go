@@ -0,0 +1,121 @@
+package query
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Update starts an UPDATE assigning values to their columns. Columns are
+// written in name order so the statement text is stable.
+func (t Table) Update(values map[string]interface{}) *UpdateBuilder {
+	b := &UpdateBuilder{table: t}
+
+	columns := make([]string, 0, len(values))
+	for c := range values {
+		columns = append(columns, c)
+	}
+	sort.Strings(columns)
+
+	for _, c := range columns {
+		if _, computed := t.Computed[c]; computed || !t.Has(c) {
+			b.fail(c)
+			continue
+		}
+		b.set = append(b.set, Predicate{c, "{col} = ?", []interface{}{values[c]}})
+	}
+
+	if t.SoftDelete != "" {
+		b.where = append(b.where, IsNull(t.SoftDelete))
+	}
+
+	return b
+}
+
+type UpdateBuilder struct {
+	table     Table
+	set       []Predicate
+	where     []Predicate
+	returning []string
+	err       error
+}
+
+func (b *UpdateBuilder) fail(column string) {
+	if b.err == nil {
+		b.err = fmt.Errorf("query: unknown column %q for table %s", column, b.table.Name)
+	}
+}
+
+// Increment adds one to the column, e.g. to bump a row version.
+func (b *UpdateBuilder) Increment(column string) *UpdateBuilder {
+	if !b.table.Has(column) {
+		b.fail(column)
+		return b
+	}
+	b.set = append(b.set, Predicate{column, "{col} = {col} + 1", nil})
+	return b
+}
+
+// Where adds predicates combined with AND.
+func (b *UpdateBuilder) Where(predicates ...Predicate) *UpdateBuilder {
+	for _, p := range predicates {
+		if p.column != "" && !b.table.Has(p.column) {
+			b.fail(p.column)
+		}
+		b.where = append(b.where, p)
+	}
+	return b
+}
+
+// Returning lists the columns the statement returns.
+func (b *UpdateBuilder) Returning(columns ...string) *UpdateBuilder {
+	for _, c := range columns {
+		if !b.table.Has(c) {
+			b.fail(c)
+		}
+	}
+	b.returning = append(b.returning, columns...)
+	return b
+}
+
+// Build renders the statement and its arguments.
+func (b *UpdateBuilder) Build() (string, []interface{}, error) {
+	if b.err != nil {
+		return "", nil, b.err
+	}
+
+	if len(b.set) == 0 {
+		return "", nil, fmt.Errorf("query: no columns to update in table %s", b.table.Name)
+	}
+
+	var sb strings.Builder
+	var args []interface{}
+
+	sb.WriteString("UPDATE ")
+	sb.WriteString(b.table.Name)
+	sb.WriteString(" SET ")
+
+	assignments := make([]string, len(b.set))
+	for i, p := range b.set {
+		assignments[i] = bind(&args, p.render(b.table), p.args)
+	}
+	sb.WriteString(strings.Join(assignments, ", "))
+
+	if len(b.where) > 0 {
+		clauses := make([]string, len(b.where))
+		for i, p := range b.where {
+			clauses[i] = bind(&args, p.render(b.table), p.args)
+		}
+		sb.WriteString(" WHERE ")
+		sb.WriteString(strings.Join(clauses, " AND "))
+	}
+
+	if len(b.returning) > 0 {
+		sb.WriteString(" RETURNING ")
+		sb.WriteString(strings.Join(b.returning, ", "))
+	}
+
+	sb.WriteString(";")
+
+	return sb.String(), args, nil
+}
@@ -0,0 +1,117 @@
+// Package audit carries who made a request, and which request it was,
+// down to the repositories recording the changes it makes, and computes
+// the before/after diffs they record.
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"pm-service/internal/repository/models"
+	"sort"
+)
+
+type contextKey string
+
+const (
+	actorContextKey     = contextKey("actor")
+	requestIDContextKey = contextKey("request_id")
+)
+
+// Redacted replaces the values of secret fields in diffs, which only tell
+// that the field changed.
+const Redacted = "[redacted]"
+
+// secrets are the view fields whose values never reach the audit log or
+// the events raised from it. The views only hold counters of how often
+// they were set to begin with, nil while there is none.
+var secrets = map[string]bool{"password": true, "secret": true}
+
+// WithActor returns a copy of ctx recording changes as made by the user.
+func WithActor(ctx context.Context, userID int) context.Context {
+	return context.WithValue(ctx, actorContextKey, userID)
+}
+
+// Actor returns the user changes are made by, nil outside of requests,
+// e.g. for background jobs.
+func Actor(ctx context.Context) *int {
+	id, ok := ctx.Value(actorContextKey).(int)
+	if !ok {
+		return nil
+	}
+	return &id
+}
+
+// WithRequestID returns a copy of ctx tying changes to the request.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDContextKey, id)
+}
+
+// RequestID returns the id of the request changes are made in, empty
+// outside of requests.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDContextKey).(string)
+	return id
+}
+
+// Diff compares two views of a record, nil when it did not exist, and
+// returns the fields whose JSON values differ.
+func Diff(before, after map[string]interface{}) map[string]models.Change {
+	diff := make(map[string]models.Change)
+
+	fields := make([]string, 0, len(before)+len(after))
+	for f := range before {
+		fields = append(fields, f)
+	}
+	for f := range after {
+		if _, ok := before[f]; !ok {
+			fields = append(fields, f)
+		}
+	}
+	sort.Strings(fields)
+
+	for _, f := range fields {
+		from, to := before[f], after[f]
+		if equal(from, to) {
+			continue
+		}
+
+		if secrets[f] {
+			from, to = redact(from), redact(to)
+		}
+		diff[f] = models.Change{From: from, To: to}
+	}
+
+	return diff
+}
+
+// Redact returns a copy of the view with the values of its secret fields
+// redacted, for views passed on beyond the audit log.
+func Redact(view map[string]interface{}) map[string]interface{} {
+	if view == nil {
+		return nil
+	}
+
+	redacted := make(map[string]interface{}, len(view))
+	for f, v := range view {
+		if secrets[f] {
+			v = redact(v)
+		}
+		redacted[f] = v
+	}
+
+	return redacted
+}
+
+func equal(a, b interface{}) bool {
+	x, errX := json.Marshal(a)
+	y, errY := json.Marshal(b)
+	return errX == nil && errY == nil && string(x) == string(y)
+}
+
+// redact hides a secret value, keeping whether there was one.
+func redact(v interface{}) interface{} {
+	if v == nil || v == "" {
+		return v
+	}
+	return Redacted
+}
@@ -0,0 +1,36 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+const (
+	// KeyPrefix starts every API key so leaked keys are easy to recognise.
+	KeyPrefix = "pmk_"
+	// keyHintSize is how many characters of a key are kept in clear text
+	// to tell a user's keys apart.
+	keyHintSize = len(KeyPrefix) + 6
+)
+
+// NewAPIKey returns a random API key together with its clear-text hint.
+// Only HashKey(key) is meant to be stored; the key itself is shown once.
+func NewAPIKey() (key, hint string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+
+	key = KeyPrefix + base64.RawURLEncoding.EncodeToString(b)
+	return key, key[:keyHintSize], nil
+}
+
+// HashKey returns the hex SHA-256 of an API key. Keys carry enough entropy
+// that a plain digest is as good as a slow password hash and can be
+// looked up directly.
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
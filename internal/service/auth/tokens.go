@@ -0,0 +1,137 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Token types, carried in the typ claim so a refresh token cannot be
+// used as an access token and the other way round.
+const (
+	AccessToken  = "access"
+	RefreshToken = "refresh"
+)
+
+var (
+	// ErrInvalidToken is returned for tokens that are malformed, signed
+	// with another key or of the wrong type.
+	ErrInvalidToken = errors.New("auth: invalid token")
+	// ErrExpiredToken is returned for well-formed tokens past their expiry.
+	ErrExpiredToken = errors.New("auth: token has expired")
+)
+
+// header is the only JOSE header Tokens issues or accepts.
+var header = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// Claims is the payload of the tokens. Subject is the user id and
+// Generation the user's token generation when the token was issued; a
+// password change moves the generation on, invalidating older tokens.
+type Claims struct {
+	Subject    string `json:"sub"`
+	ID         string `json:"jti"`
+	Type       string `json:"typ"`
+	Generation int    `json:"gen"`
+	IssuedAt   int64  `json:"iat"`
+	Expires    int64  `json:"exp"`
+}
+
+// UserID returns the subject as a user id, 0 if it is not one.
+func (c *Claims) UserID() int {
+	id, err := strconv.Atoi(c.Subject)
+	if err != nil || id < 1 {
+		return 0
+	}
+	return id
+}
+
+// ExpiresAt returns the expiry as a time.
+func (c *Claims) ExpiresAt() time.Time {
+	return time.Unix(c.Expires, 0)
+}
+
+// Tokens issues and verifies HS256-signed JWTs.
+type Tokens struct {
+	Secret     []byte
+	AccessTTL  time.Duration
+	RefreshTTL time.Duration
+}
+
+// Issue signs a token of the given type for the user in their current
+// token generation and returns it along with its claims.
+func (t *Tokens) Issue(userID, generation int, typ string) (string, *Claims, error) {
+	ttl := t.AccessTTL
+	if typ == RefreshToken {
+		ttl = t.RefreshTTL
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", nil, err
+	}
+
+	now := time.Now()
+	claims := &Claims{
+		Subject:    strconv.Itoa(userID),
+		ID:         hex.EncodeToString(id),
+		Type:       typ,
+		Generation: generation,
+		IssuedAt:   now.Unix(),
+		Expires:    now.Add(ttl).Unix(),
+	}
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", nil, err
+	}
+
+	unsigned := header + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return unsigned + "." + t.sign(unsigned), claims, nil
+}
+
+// Parse verifies the token's signature, type and expiry and returns its
+// claims.
+func (t *Tokens) Parse(token, typ string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] != header {
+		return nil, ErrInvalidToken
+	}
+
+	unsigned := parts[0] + "." + parts[1]
+	if !hmac.Equal([]byte(parts[2]), []byte(t.sign(unsigned))) {
+		return nil, ErrInvalidToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	claims := &Claims{}
+	if err := json.Unmarshal(payload, claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	if claims.Type != typ || claims.ID == "" || claims.UserID() == 0 {
+		return nil, ErrInvalidToken
+	}
+
+	if time.Now().Unix() >= claims.Expires {
+		return nil, ErrExpiredToken
+	}
+
+	return claims, nil
+}
+
+func (t *Tokens) sign(unsigned string) string {
+	mac := hmac.New(sha256.New, t.Secret)
+	mac.Write([]byte(unsigned))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,164 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"pm-service/internal/repository/models"
+	"pm-service/internal/service/audit"
+	"sync"
+	"time"
+)
+
+// All subscribes a handler to every event.
+const All = "*"
+
+// Handler handles an event. Its context carries the actor and request id
+// of the change that raised the event, see the audit package.
+type Handler func(ctx context.Context, e Event) error
+
+// Bus hands each event published on it to the handlers subscribed to its
+// name, then to those subscribed to All, each in the order they
+// subscribed. The zero value is ready to use.
+type Bus struct {
+	mu       sync.RWMutex
+	handlers map[string][]Handler
+}
+
+// Subscribe registers h for the events with the given name, or for every
+// event when name is All.
+func (b *Bus) Subscribe(name string, h Handler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.handlers == nil {
+		b.handlers = make(map[string][]Handler)
+	}
+	b.handlers[name] = append(b.handlers[name], h)
+}
+
+// Publish calls every handler subscribed to e, even after one fails, and
+// returns their errors joined.
+func (b *Bus) Publish(ctx context.Context, e Event) error {
+	b.mu.RLock()
+	handlers := append(append([]Handler{}, b.handlers[e.Name()]...), b.handlers[All]...)
+	b.mu.RUnlock()
+
+	var errs []error
+
+	for _, h := range handlers {
+		if err := h(ctx, e); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", e.Name(), err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+type contextKey string
+
+const eventIDContextKey = contextKey("event_id")
+
+// WithEventID returns a copy of ctx relaying the outbox event with the
+// given id.
+func WithEventID(ctx context.Context, id int) context.Context {
+	return context.WithValue(ctx, eventIDContextKey, id)
+}
+
+// EventID returns the outbox id of the event being relayed, 0 outside of a
+// relay. An event relayed twice keeps its id, so handlers can use it to
+// skip work they have done already.
+func EventID(ctx context.Context) int {
+	id, _ := ctx.Value(eventIDContextKey).(int)
+	return id
+}
+
+const (
+	// lease is how long claimed events are held back from other relays
+	// beyond the handlers' deadlines.
+	lease = time.Minute
+	// retry is how long an event whose handlers failed waits to be
+	// relayed again.
+	retry = time.Minute
+)
+
+// Relay publishes the events kept in an outbox on a bus.
+type Relay struct {
+	Outbox interface {
+		// Claim returns up to limit of the events due to be relayed,
+		// oldest first, counts the attempt and holds them back from other
+		// relays for the lease.
+		Claim(ctx context.Context, limit int, lease time.Duration) ([]*models.OutboxEvent, error)
+		// Published marks an event relayed.
+		Published(ctx context.Context, id int) error
+		// Failed holds an event back until next, or for good when dead.
+		Failed(ctx context.Context, id int, next time.Time, dead bool) error
+	}
+	Bus *Bus
+	// BatchSize is the number of events claimed at a time.
+	BatchSize int
+	// Timeout bounds the handlers of each event, none when not positive.
+	Timeout time.Duration
+	// MaxAttempts is how often an event is relayed before it is given up
+	// on, without limit when not positive.
+	MaxAttempts int
+}
+
+// Relay publishes a batch of the outbox's events and returns how many it
+// claimed. Events are relayed at least once: one whose handlers fail is
+// relayed again a minute later, possibly after younger events, and so is a
+// batch cut short, say by a crash, so handlers must tolerate repeats. An
+// event is given up on once out of attempts, or at once when it cannot be
+// decoded. The errors of the failed events are returned joined.
+func (r *Relay) Relay(ctx context.Context) (int, error) {
+	claimed, err := r.Outbox.Claim(ctx, r.BatchSize, time.Duration(r.BatchSize)*r.Timeout+lease)
+	if err != nil {
+		return 0, err
+	}
+
+	var errs []error
+
+	for _, o := range claimed {
+		e, err := Decode(o.Name, o.Payload)
+		dead := err != nil
+
+		if err == nil {
+			if err = r.publish(ctx, o, e); err == nil {
+				if err := r.Outbox.Published(ctx, o.ID); err != nil {
+					errs = append(errs, err)
+				}
+				continue
+			}
+			dead = r.MaxAttempts > 0 && o.Attempts >= r.MaxAttempts
+		}
+
+		if dead {
+			errs = append(errs, fmt.Errorf("event %d: giving up after %d attempts: %w", o.ID, o.Attempts, err))
+		} else {
+			errs = append(errs, fmt.Errorf("event %d: %w", o.ID, err))
+		}
+
+		if err := r.Outbox.Failed(ctx, o.ID, time.Now().Add(retry), dead); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return len(claimed), errors.Join(errs...)
+}
+
+// publish publishes an outbox event with the context of the change that
+// raised it, under the relay's deadline.
+func (r *Relay) publish(ctx context.Context, o *models.OutboxEvent, e Event) error {
+	if r.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.Timeout)
+		defer cancel()
+	}
+
+	ctx = WithEventID(audit.WithRequestID(ctx, o.RequestID), o.ID)
+	if o.ActorID != nil {
+		ctx = audit.WithActor(ctx, *o.ActorID)
+	}
+
+	return r.Bus.Publish(ctx, e)
+}
@@ -0,0 +1,128 @@
+package events
+
+import (
+	"fmt"
+	"pm-service/internal/repository/models"
+	"sort"
+	"time"
+)
+
+// FromChange returns the events a recorded change to a resource raises,
+// given the action, the record's audit view after the change (before a
+// delete) and the fields the change made. Changes to resources other than
+// tasks, projects and users only raise Recorded.
+func FromChange(resource string, id int, action string, view map[string]interface{}, diff map[string]models.Change) []Event {
+	var events []Event
+
+	switch resource {
+	case "tasks":
+		events = taskEvents(id, action, view, diff)
+	case "projects":
+		events = projectEvents(id, action, view, diff)
+	case "users":
+		events = userEvents(id, action, view, diff)
+	}
+
+	return append(events, Recorded{resource, id, action, view, diff, time.Now()})
+}
+
+func taskEvents(id int, action string, view map[string]interface{}, diff map[string]models.Change) []Event {
+	project := number(view["project_id"])
+
+	switch action {
+	case models.AuditInsert:
+		return []Event{TaskCreated{id, project, number(view["assignee_id"]), text(view["status"])}}
+	case models.AuditDelete:
+		return []Event{TaskDeleted{id, project}}
+	case models.AuditRestore:
+		return []Event{TaskRestored{id, project}}
+	}
+
+	events := []Event{TaskUpdated{id, project, fields(diff)}}
+
+	if c, ok := diff["assignee_id"]; ok {
+		events = append(events, TaskAssigned{id, project, number(c.From), number(c.To)})
+	}
+	if c, ok := diff["status"]; ok {
+		events = append(events, TaskStatusChanged{id, project, text(c.From), text(c.To)})
+	}
+	if c, ok := diff["project_id"]; ok {
+		events = append(events, TaskMoved{id, number(c.From), number(c.To)})
+	}
+	if c, ok := diff["completed"]; ok && c.From == nil && c.To != nil {
+		events = append(events, TaskCompleted{id, project})
+	}
+
+	return events
+}
+
+func projectEvents(id int, action string, view map[string]interface{}, diff map[string]models.Change) []Event {
+	switch action {
+	case models.AuditInsert:
+		return []Event{ProjectCreated{id, number(view["manager_id"])}}
+	case models.AuditDelete:
+		return []Event{ProjectDeleted{id}}
+	case models.AuditRestore:
+		return []Event{ProjectRestored{id}}
+	}
+
+	events := []Event{ProjectUpdated{id, fields(diff)}}
+
+	if c, ok := diff["manager_id"]; ok {
+		events = append(events, ProjectManagerChanged{id, number(c.From), number(c.To)})
+	}
+	if c, ok := diff["completed"]; ok && c.From == nil && c.To != nil {
+		events = append(events, ProjectCompleted{id})
+	}
+
+	return events
+}
+
+func userEvents(id int, action string, view map[string]interface{}, diff map[string]models.Change) []Event {
+	switch action {
+	case models.AuditInsert:
+		return []Event{UserCreated{id, text(view["role"])}}
+	case models.AuditDelete:
+		return []Event{UserDeleted{id}}
+	case models.AuditRestore:
+		return []Event{UserRestored{id}}
+	}
+
+	events := []Event{UserUpdated{id, fields(diff)}}
+
+	if c, ok := diff["role"]; ok {
+		events = append(events, UserRoleChanged{id, text(c.From), text(c.To)})
+	}
+
+	return events
+}
+
+// fields returns the names of the fields a change made, sorted.
+func fields(diff map[string]models.Change) []string {
+	names := make([]string, 0, len(diff))
+	for name := range diff {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// number reads an id out of an audit view, 0 when there is none. Views are
+// decoded from JSON, so numbers arrive as float64.
+func number(v interface{}) int {
+	switch n := v.(type) {
+	case float64:
+		return int(n)
+	case int:
+		return n
+	}
+	return 0
+}
+
+func text(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	return fmt.Sprint(v)
+}
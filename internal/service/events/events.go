@@ -0,0 +1,199 @@
+// Package events defines the domain events raised by changes to tasks,
+// projects and users, and the in-process bus that hands them to their
+// subscribers. Events are kept in an outbox in the transaction making the
+// change and relayed to the bus once it has committed, so subscribers see
+// every committed change and none that was rolled back.
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"pm-service/internal/repository/models"
+	"time"
+)
+
+// Event is a domain event. Name identifies its type, e.g. "task.assigned".
+type Event interface {
+	Name() string
+}
+
+type TaskCreated struct {
+	TaskID     int    `json:"task_id"`
+	ProjectID  int    `json:"project_id"`
+	AssigneeID int    `json:"assignee_id"`
+	Status     string `json:"status"`
+}
+
+// TaskUpdated lists the fields of the task a change touched. It is raised
+// along with any of the more specific task events below.
+type TaskUpdated struct {
+	TaskID    int      `json:"task_id"`
+	ProjectID int      `json:"project_id"`
+	Fields    []string `json:"fields"`
+}
+
+type TaskAssigned struct {
+	TaskID    int `json:"task_id"`
+	ProjectID int `json:"project_id"`
+	From      int `json:"from"`
+	To        int `json:"to"`
+}
+
+type TaskStatusChanged struct {
+	TaskID    int    `json:"task_id"`
+	ProjectID int    `json:"project_id"`
+	From      string `json:"from"`
+	To        string `json:"to"`
+}
+
+type TaskMoved struct {
+	TaskID int `json:"task_id"`
+	From   int `json:"from"`
+	To     int `json:"to"`
+}
+
+type TaskCompleted struct {
+	TaskID    int `json:"task_id"`
+	ProjectID int `json:"project_id"`
+}
+
+type TaskDeleted struct {
+	TaskID    int `json:"task_id"`
+	ProjectID int `json:"project_id"`
+}
+
+type TaskRestored struct {
+	TaskID    int `json:"task_id"`
+	ProjectID int `json:"project_id"`
+}
+
+type ProjectCreated struct {
+	ProjectID int `json:"project_id"`
+	ManagerID int `json:"manager_id"`
+}
+
+// ProjectUpdated lists the fields of the project a change touched,
+// "members" among them when its members changed.
+type ProjectUpdated struct {
+	ProjectID int      `json:"project_id"`
+	Fields    []string `json:"fields"`
+}
+
+type ProjectManagerChanged struct {
+	ProjectID int `json:"project_id"`
+	From      int `json:"from"`
+	To        int `json:"to"`
+}
+
+type ProjectCompleted struct {
+	ProjectID int `json:"project_id"`
+}
+
+type ProjectDeleted struct {
+	ProjectID int `json:"project_id"`
+}
+
+type ProjectRestored struct {
+	ProjectID int `json:"project_id"`
+}
+
+type UserCreated struct {
+	UserID int    `json:"user_id"`
+	Role   string `json:"role"`
+}
+
+// UserUpdated lists the fields of the user a change touched, "password"
+// among them when the password changed.
+type UserUpdated struct {
+	UserID int      `json:"user_id"`
+	Fields []string `json:"fields"`
+}
+
+type UserRoleChanged struct {
+	UserID int    `json:"user_id"`
+	From   string `json:"from"`
+	To     string `json:"to"`
+}
+
+type UserDeleted struct {
+	UserID int `json:"user_id"`
+}
+
+type UserRestored struct {
+	UserID int `json:"user_id"`
+}
+
+// Recorded is raised by every change the audit log records, to any
+// resource, along with the typed events above. It carries the record's
+// audit view after the change (before a delete) and the fields the change
+// made, for handlers such as webhooks that pass the change on as a whole.
+type Recorded struct {
+	Resource string                   `json:"resource"`
+	ID       int                      `json:"id"`
+	Action   string                   `json:"action"`
+	View     map[string]interface{}   `json:"view"`
+	Diff     map[string]models.Change `json:"diff"`
+	Occurred time.Time                `json:"occurred"`
+}
+
+func (TaskCreated) Name() string           { return "task.created" }
+func (TaskUpdated) Name() string           { return "task.updated" }
+func (TaskAssigned) Name() string          { return "task.assigned" }
+func (TaskStatusChanged) Name() string     { return "task.status_changed" }
+func (TaskMoved) Name() string             { return "task.moved" }
+func (TaskCompleted) Name() string         { return "task.completed" }
+func (TaskDeleted) Name() string           { return "task.deleted" }
+func (TaskRestored) Name() string          { return "task.restored" }
+func (ProjectCreated) Name() string        { return "project.created" }
+func (ProjectUpdated) Name() string        { return "project.updated" }
+func (ProjectManagerChanged) Name() string { return "project.manager_changed" }
+func (ProjectCompleted) Name() string      { return "project.completed" }
+func (ProjectDeleted) Name() string        { return "project.deleted" }
+func (ProjectRestored) Name() string       { return "project.restored" }
+func (UserCreated) Name() string           { return "user.created" }
+func (UserUpdated) Name() string           { return "user.updated" }
+func (UserRoleChanged) Name() string       { return "user.role_changed" }
+func (UserDeleted) Name() string           { return "user.deleted" }
+func (UserRestored) Name() string          { return "user.restored" }
+func (Recorded) Name() string              { return "change.recorded" }
+
+// decoders read the payloads of the events by name.
+var decoders = map[string]func([]byte) (Event, error){
+	TaskCreated{}.Name():           decode[TaskCreated],
+	TaskUpdated{}.Name():           decode[TaskUpdated],
+	TaskAssigned{}.Name():          decode[TaskAssigned],
+	TaskStatusChanged{}.Name():     decode[TaskStatusChanged],
+	TaskMoved{}.Name():             decode[TaskMoved],
+	TaskCompleted{}.Name():         decode[TaskCompleted],
+	TaskDeleted{}.Name():           decode[TaskDeleted],
+	TaskRestored{}.Name():          decode[TaskRestored],
+	ProjectCreated{}.Name():        decode[ProjectCreated],
+	ProjectUpdated{}.Name():        decode[ProjectUpdated],
+	ProjectManagerChanged{}.Name(): decode[ProjectManagerChanged],
+	ProjectCompleted{}.Name():      decode[ProjectCompleted],
+	ProjectDeleted{}.Name():        decode[ProjectDeleted],
+	ProjectRestored{}.Name():       decode[ProjectRestored],
+	UserCreated{}.Name():           decode[UserCreated],
+	UserUpdated{}.Name():           decode[UserUpdated],
+	UserRoleChanged{}.Name():       decode[UserRoleChanged],
+	UserDeleted{}.Name():           decode[UserDeleted],
+	UserRestored{}.Name():          decode[UserRestored],
+	Recorded{}.Name():              decode[Recorded],
+}
+
+func decode[E Event](payload []byte) (Event, error) {
+	var e E
+	err := json.Unmarshal(payload, &e)
+	return e, err
+}
+
+// Decode returns the event with the given name and JSON payload, as
+// stored in the outbox.
+func Decode(name string, payload []byte) (Event, error) {
+	fn, ok := decoders[name]
+	if !ok {
+		return nil, fmt.Errorf("events: unknown event %q", name)
+	}
+
+	return fn(payload)
+}
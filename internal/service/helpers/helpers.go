@@ -6,6 +6,9 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"pm-service/internal/service/validator"
+	"strconv"
 	"strings"
 )
 
@@ -83,3 +86,42 @@ func ReadJSON(w http.ResponseWriter, r *http.Request, dst interface{}) error {
 
 	return nil
 }
+
+// ReadString returns a string value from the query string, or the provided
+// default value if no matching key could be found.
+func ReadString(qs url.Values, key string, defaultValue string) string {
+	s := qs.Get(key)
+	if s == "" {
+		return defaultValue
+	}
+
+	return s
+}
+
+// ReadCSV reads a comma-separated value from the query string and splits it
+// into a slice, or returns the provided default value if the key is absent.
+func ReadCSV(qs url.Values, key string, defaultValue []string) []string {
+	csv := qs.Get(key)
+	if csv == "" {
+		return defaultValue
+	}
+
+	return strings.Split(csv, ",")
+}
+
+// ReadInt reads an integer from the query string. Malformed values are
+// recorded on the validator and the default value is returned instead.
+func ReadInt(qs url.Values, key string, defaultValue int, v *validator.Validator) int {
+	s := qs.Get(key)
+	if s == "" {
+		return defaultValue
+	}
+
+	i, err := strconv.Atoi(s)
+	if err != nil {
+		v.AddError(key, "must be an integer value")
+		return defaultValue
+	}
+
+	return i
+}
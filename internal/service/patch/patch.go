@@ -0,0 +1,185 @@
+// Package patch applies JSON Merge Patch (RFC 7396) and JSON Patch
+// (RFC 6902) documents to JSON objects.
+package patch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+const (
+	MergePatchType = "application/merge-patch+json"
+	JSONPatchType  = "application/json-patch+json"
+)
+
+var (
+	// ErrTestFailed is returned when a JSON Patch "test" operation does not hold.
+	ErrTestFailed = errors.New("patch: test operation failed")
+	// ErrUnsupportedType is returned for media types that are not a patch format.
+	ErrUnsupportedType = errors.New("patch: unsupported media type")
+)
+
+// For returns the function applying patches of the given media type. Plain
+// application/json is read as a merge patch.
+func For(mediaType string) (func(doc, patch []byte) ([]byte, error), error) {
+	switch mediaType {
+	case MergePatchType, "application/json":
+		return Merge, nil
+	case JSONPatchType:
+		return Apply, nil
+	}
+
+	return nil, ErrUnsupportedType
+}
+
+// Merge applies the merge patch to doc. Members set to null in the patch
+// are removed, objects are merged recursively and anything else replaces
+// the target value.
+func Merge(doc, patch []byte) ([]byte, error) {
+	var target, p interface{}
+
+	if err := json.Unmarshal(doc, &target); err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(patch, &p); err != nil {
+		return nil, fmt.Errorf("patch: %w", err)
+	}
+
+	if _, ok := p.(map[string]interface{}); !ok {
+		return nil, errors.New("patch: merge patch must be a JSON object")
+	}
+
+	return json.Marshal(merge(target, p))
+}
+
+func merge(target, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		t = map[string]interface{}{}
+	}
+
+	for key, value := range p {
+		if value == nil {
+			delete(t, key)
+			continue
+		}
+		t[key] = merge(t[key], value)
+	}
+
+	return t
+}
+
+// operation is a JSON Patch operation. Value is left empty when the
+// operation has no value and holds "null" when the value is null.
+type operation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from"`
+	Value json.RawMessage `json:"value"`
+}
+
+// Apply applies the JSON Patch operations to doc, which must be a flat JSON
+// object: paths may only address its top-level members. Operations are
+// applied in order and the whole patch fails if any of them does.
+func Apply(doc, patch []byte) ([]byte, error) {
+	var target map[string]interface{}
+	if err := json.Unmarshal(doc, &target); err != nil {
+		return nil, err
+	}
+
+	var ops []operation
+	if err := json.Unmarshal(patch, &ops); err != nil {
+		return nil, fmt.Errorf("patch: %w", err)
+	}
+
+	for i, op := range ops {
+		if err := apply(target, op); err != nil {
+			if err == ErrTestFailed {
+				return nil, err
+			}
+			return nil, fmt.Errorf("patch: operation %d: %w", i, err)
+		}
+	}
+
+	return json.Marshal(target)
+}
+
+func apply(target map[string]interface{}, op operation) error {
+	key, err := member(op.Path)
+	if err != nil {
+		return err
+	}
+
+	value := func() (interface{}, error) {
+		if len(op.Value) == 0 {
+			return nil, fmt.Errorf("%s requires a value", op.Op)
+		}
+		var v interface{}
+		err := json.Unmarshal(op.Value, &v)
+		return v, err
+	}
+
+	switch op.Op {
+	case "add", "replace":
+		if _, ok := target[key]; !ok && op.Op == "replace" {
+			return fmt.Errorf("path %q does not exist", op.Path)
+		}
+		v, err := value()
+		if err != nil {
+			return err
+		}
+		target[key] = v
+
+	case "remove":
+		if _, ok := target[key]; !ok {
+			return fmt.Errorf("path %q does not exist", op.Path)
+		}
+		delete(target, key)
+
+	case "move", "copy":
+		from, err := member(op.From)
+		if err != nil {
+			return err
+		}
+		v, ok := target[from]
+		if !ok {
+			return fmt.Errorf("path %q does not exist", op.From)
+		}
+		if op.Op == "move" {
+			delete(target, from)
+		}
+		target[key] = v
+
+	case "test":
+		v, err := value()
+		if err != nil {
+			return err
+		}
+		if !reflect.DeepEqual(target[key], v) {
+			return ErrTestFailed
+		}
+
+	default:
+		return fmt.Errorf("unknown op %q", op.Op)
+	}
+
+	return nil
+}
+
+// member decodes a JSON pointer to a top-level object member.
+func member(pointer string) (string, error) {
+	if !strings.HasPrefix(pointer, "/") || strings.Contains(pointer[1:], "/") {
+		return "", fmt.Errorf("path %q must address a top-level member such as /title", pointer)
+	}
+
+	return strings.NewReplacer("~1", "/", "~0", "~").Replace(pointer[1:]), nil
+}
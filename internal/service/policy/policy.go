@@ -0,0 +1,202 @@
+// Package policy decides what an authenticated user may do. Every user
+// holds one of models.UserRoles:
+//
+//   - admin may do anything;
+//   - manager may also create projects, which they then manage;
+//   - developer works on the projects they are a member of;
+//   - viewer may only read.
+//
+// Within a project the manager_id, and members with the owner or manager
+// role, manage the project's tasks, workflow, members and labels. Only the
+// manager_id or an admin may change or delete the project itself, and
+// assignees may move their own tasks through the workflow.
+package policy
+
+import (
+	"context"
+	"errors"
+	"pm-service/internal/repository/models"
+	"pm-service/internal/service/validator"
+	"strconv"
+)
+
+// ErrForbidden is returned when the user may not perform the action.
+var ErrForbidden = errors.New("policy: forbidden")
+
+// AssigneeFields are the task fields an assignee may change on their own
+// tasks without managing the project.
+var AssigneeFields = []string{"status"}
+
+type Policy struct {
+	Projects interface {
+		Get(context.Context, string) (*models.Project, error)
+	}
+	Members interface {
+		Get(context.Context, int, int) (*models.Member, error)
+	}
+}
+
+func isAdmin(user *models.User) bool {
+	return user.Role == "admin"
+}
+
+// writer checks the user may change anything at all.
+func writer(user *models.User) error {
+	if user.Role == "viewer" {
+		return ErrForbidden
+	}
+	return nil
+}
+
+func allow(ok bool) error {
+	if !ok {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// Admin lets only admins through, e.g. to restore records from the trash.
+func (p *Policy) Admin(user *models.User) error {
+	return allow(isAdmin(user))
+}
+
+// UpdateUser lets admins change anyone and users change themselves, but
+// only admins change roles.
+func (p *Policy) UpdateUser(user, target *models.User, input *models.UserInput) error {
+	if isAdmin(user) {
+		return nil
+	}
+
+	return allow(user.ID == target.ID && input.Role == target.Role)
+}
+
+// CreateProject lets admins create any project and managers create the
+// projects they manage themselves.
+func (p *Policy) CreateProject(user *models.User, input *models.ProjectInput) error {
+	if isAdmin(user) {
+		return nil
+	}
+
+	return allow(user.Role == "manager" && input.ManagerID == user.ID)
+}
+
+// OwnProject lets the project's manager_id and admins update or delete
+// the project itself.
+func (p *Policy) OwnProject(user *models.User, project *models.Project) error {
+	if isAdmin(user) {
+		return nil
+	}
+
+	return allow(user.Role != "viewer" && project.ManagerID == user.ID)
+}
+
+// ManageProject lets the people who own the project, and its owner and
+// manager members, run it: its workflow, members, labels and tasks.
+func (p *Policy) ManageProject(ctx context.Context, user *models.User, projectID int) error {
+	member, err := p.member(ctx, user, projectID)
+	if err != nil || member == nil {
+		return err
+	}
+
+	return allow(member.Manages())
+}
+
+// CreateTask lets anyone who may be assigned the project's tasks add one.
+func (p *Policy) CreateTask(ctx context.Context, user *models.User, projectID int) error {
+	member, err := p.member(ctx, user, projectID)
+	if err != nil || member == nil {
+		return err
+	}
+
+	return allow(member.Assignable())
+}
+
+// UpdateTask lets the people managing the task's project change any of
+// the named fields; to move the task they must manage the target project
+// as well. The assignee may change the AssigneeFields.
+func (p *Policy) UpdateTask(ctx context.Context, user *models.User, task *models.Task, input *models.TaskInput, fields []string) error {
+	err := p.ManageProject(ctx, user, task.ProjectID)
+	if err == nil {
+		if input.ProjectID != task.ProjectID {
+			return p.ManageProject(ctx, user, input.ProjectID)
+		}
+		return nil
+	}
+
+	if err != ErrForbidden || writer(user) != nil || task.AssigneeID != user.ID {
+		return err
+	}
+
+	for _, f := range fields {
+		if !validator.PermittedValue(f, AssigneeFields...) {
+			return ErrForbidden
+		}
+	}
+
+	return nil
+}
+
+// Comment lets anyone but viewers comment on a task, in their own name
+// unless they are an admin.
+func (p *Policy) Comment(user *models.User, input *models.CommentInput) error {
+	if isAdmin(user) {
+		return nil
+	}
+
+	return allow(writer(user) == nil && input.AuthorID == user.ID)
+}
+
+// EditComment lets authors edit their comments.
+func (p *Policy) EditComment(user *models.User, comment *models.Comment) error {
+	if isAdmin(user) {
+		return nil
+	}
+
+	return allow(writer(user) == nil && comment.AuthorID == user.ID)
+}
+
+// DeleteComment lets authors and the people managing the task's project
+// delete a comment.
+func (p *Policy) DeleteComment(ctx context.Context, user *models.User, comment *models.Comment, task *models.Task) error {
+	if p.EditComment(user, comment) == nil {
+		return nil
+	}
+
+	return p.ManageProject(ctx, user, task.ProjectID)
+}
+
+// member returns nil, and no error, when the user may act on the project
+// whatever their membership: as an admin or its manager_id. Otherwise it
+// returns the user's membership, failing with ErrForbidden for viewers
+// and non-members.
+func (p *Policy) member(ctx context.Context, user *models.User, projectID int) (*models.Member, error) {
+	if isAdmin(user) {
+		return nil, nil
+	}
+
+	if err := writer(user); err != nil {
+		return nil, err
+	}
+
+	project, err := p.Projects.Get(ctx, strconv.Itoa(projectID))
+	if err != nil {
+		if err == models.ErrNoRecord {
+			return nil, ErrForbidden
+		}
+		return nil, err
+	}
+
+	if project.ManagerID == user.ID {
+		return nil, nil
+	}
+
+	member, err := p.Members.Get(ctx, projectID, user.ID)
+	if err != nil {
+		if err == models.ErrNoRecord {
+			return nil, ErrForbidden
+		}
+		return nil, err
+	}
+
+	return member, nil
+}
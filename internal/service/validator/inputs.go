@@ -0,0 +1,149 @@
+package validator
+
+import (
+	"net/url"
+	"pm-service/internal/repository/models"
+	"strings"
+)
+
+// The functions below check the parts of the request inputs that need no
+// lookups; the handlers check references to other records themselves. The
+// length limits mirror the column sizes in migrations/postgres.
+
+func ProjectInput(v *Validator, i *models.ProjectInput) {
+	v.Check(NotBlank(i.Title), "title", "must be provided")
+	v.Check(MaxChars(i.Title, 50), "title", "must not be more than 50 characters long")
+	v.Check(MaxChars(i.Description, 100), "description", "must not be more than 100 characters long")
+	v.Check(i.ManagerID > 0, "manager_id", "must be a positive integer")
+	v.Check(validDate(i.Completed), "completed", "must be a date (YYYY-MM-DD) or an RFC 3339 timestamp")
+}
+
+func TaskInput(v *Validator, i *models.TaskInput) {
+	v.Check(NotBlank(i.Title), "title", "must be provided")
+	v.Check(MaxChars(i.Title, 50), "title", "must not be more than 50 characters long")
+	v.Check(MaxChars(i.Description, 100), "description", "must not be more than 100 characters long")
+	v.Check(PermittedValue(strings.ToLower(i.Priority), models.Priorities...), "priority", "must be one of: "+strings.Join(models.Priorities, ", "))
+	// the permitted statuses depend on the project's workflow
+	v.Check(NotBlank(i.Status), "status", "must be provided")
+	v.Check(MaxChars(i.Status, 50), "status", "must not be more than 50 characters long")
+	v.Check(i.AssigneeID > 0, "assignee_id", "must be a positive integer")
+	v.Check(i.ProjectID > 0, "project_id", "must be a positive integer")
+	v.Check(i.ParentTaskID == nil || *i.ParentTaskID > 0, "parent_task_id", "must be a positive integer")
+	v.Check(i.Estimate >= 0, "estimate", "must not be negative")
+	v.Check(i.Estimate <= 10_000, "estimate", "must be a maximum of 10000 hours")
+	v.Check(validDate(i.Completed), "completed", "must be a date (YYYY-MM-DD) or an RFC 3339 timestamp")
+}
+
+func UserInput(v *Validator, i *models.UserInput) {
+	v.Check(NotBlank(i.Name), "name", "must be provided")
+	v.Check(MaxChars(i.Name, 50), "name", "must not be more than 50 characters long")
+	v.Check(NotBlank(i.Email), "email", "must be provided")
+	v.Check(MaxChars(i.Email, 100), "email", "must not be more than 100 characters long")
+	v.Check(Matches(i.Email, EmailRX), "email", "must be a valid email address")
+	v.Check(PermittedValue(i.Role, models.UserRoles...), "role", "must be one of: "+strings.Join(models.UserRoles, ", "))
+	if i.Password != "" {
+		password(v, "password", i.Password)
+	}
+}
+
+func CommentInput(v *Validator, i *models.CommentInput) {
+	v.Check(NotBlank(i.Body), "body", "must be provided")
+	v.Check(MaxChars(i.Body, 1000), "body", "must not be more than 1000 characters long")
+	v.Check(i.AuthorID > 0, "author_id", "must be a positive integer")
+	v.Check(i.ParentID == nil || *i.ParentID > 0, "parent_id", "must be a positive integer")
+}
+
+func LabelInput(v *Validator, i *models.LabelInput) {
+	v.Check(NotBlank(i.Name), "name", "must be provided")
+	v.Check(MaxChars(i.Name, 50), "name", "must not be more than 50 characters long")
+	// commas separate the names in label_any and label_all
+	v.Check(!strings.Contains(i.Name, ","), "name", "must not contain commas")
+	v.Check(Matches(i.Color, ColorRX), "color", "must be a hex color code such as #1e90ff")
+}
+
+func MemberInput(v *Validator, i *models.MemberInput) {
+	v.Check(i.UserID > 0, "user_id", "must be a positive integer")
+	v.Check(PermittedValue(i.Role, models.ProjectRoles...), "role", "must be one of: "+strings.Join(models.ProjectRoles, ", "))
+}
+
+func WebhookInput(v *Validator, i *models.WebhookInput) {
+	u, err := url.Parse(i.URL)
+	v.Check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "", "url", "must be an absolute http or https URL")
+	v.Check(MaxChars(i.URL, 2000), "url", "must not be more than 2000 characters long")
+
+	if i.Secret != "" {
+		v.Check(MinChars(i.Secret, 16), "secret", "must be at least 16 characters long")
+		v.Check(MaxChars(i.Secret, 256), "secret", "must not be more than 256 characters long")
+	}
+
+	v.Check(len(i.Events) > 0, "events", "must list at least one event")
+
+	seen := make(map[string]bool)
+	for _, e := range i.Events {
+		v.Check(PermittedValue(e, models.WebhookEvents...), "events", "must only list: "+strings.Join(models.WebhookEvents, ", "))
+		v.Check(!seen[e], "events", "must not list an event twice")
+		seen[e] = true
+	}
+}
+
+func Workflow(v *Validator, w *models.Workflow) {
+	v.Check(len(w.States) > 0, "states", "must contain at least one state")
+
+	terminal := false
+	seen := map[string]bool{}
+	for _, s := range w.States {
+		name := strings.ToLower(s.Name)
+		v.Check(NotBlank(s.Name), "states", "must have a name")
+		v.Check(MaxChars(s.Name, 50), "states", "must not have names longer than 50 characters")
+		v.Check(!seen[name], "states", "must have unique names, "+s.Name+" is repeated")
+		seen[name] = true
+		terminal = terminal || s.Terminal
+	}
+	v.Check(len(w.States) == 0 || terminal, "states", "must include at least one terminal state")
+
+	for _, t := range w.Transitions {
+		v.Check(seen[strings.ToLower(t.From)] && seen[strings.ToLower(t.To)], "transitions", "must only connect listed states")
+		v.Check(!strings.EqualFold(t.From, t.To), "transitions", "must connect two different states")
+	}
+}
+
+func Filters(v *Validator, f models.Filters) {
+	v.Check(f.Page > 0, "page", "must be greater than zero")
+	v.Check(f.Page <= 10_000_000, "page", "must be a maximum of 10 million")
+	v.Check(f.PageSize > 0, "page_size", "must be greater than zero")
+	v.Check(f.PageSize <= models.MaxPageSize, "page_size", "must be a maximum of 100")
+
+	for _, s := range f.Sort {
+		v.Check(PermittedValue(strings.TrimPrefix(s, "-"), f.SortSafelist...), "sort", "invalid sort value "+s)
+	}
+}
+
+func APIKeyInput(v *Validator, i *models.APIKeyInput) {
+	v.Check(NotBlank(i.Name), "name", "must be provided")
+	v.Check(MaxChars(i.Name, 50), "name", "must not be more than 50 characters long")
+}
+
+func LoginInput(v *Validator, i *models.LoginInput) {
+	v.Check(NotBlank(i.Email), "email", "must be provided")
+	v.Check(NotBlank(i.Password), "password", "must be provided")
+}
+
+func TokenInput(v *Validator, i *models.TokenInput) {
+	v.Check(NotBlank(i.RefreshToken), "refresh_token", "must be provided")
+}
+
+func PasswordInput(v *Validator, i *models.PasswordInput) {
+	password(v, "password", i.Password)
+}
+
+func validDate(s string) bool {
+	_, err := models.ParseDate(s)
+	return err == nil
+}
+
+// password checks a new password. The upper bound only keeps hashing cheap
+// for absurd inputs.
+func password(v *Validator, key, password string) {
+	v.Check(MinChars(password, 8), key, "must be at least 8 characters long")
+	v.Check(MaxChars(password, 128), key, "must not be more than 128 characters long")
+}
@@ -0,0 +1,152 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"pm-service/internal/repository/models"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Dispatcher sends the deliveries that are due from a queue.
+type Dispatcher struct {
+	Queue interface {
+		// Claim returns up to limit due deliveries and holds them back from
+		// other dispatchers for the lease, until their NextAttempt.
+		Claim(ctx context.Context, limit int, lease time.Duration) ([]*models.DeliveryJob, error)
+		// Attempted logs an attempt at a claimed delivery and moves it to
+		// the given status, to be retried at next while pending. It fails
+		// with models.ErrLeaseLost when the claim has since been taken over.
+		Attempted(ctx context.Context, job *models.DeliveryJob, attempt *models.DeliveryAttempt, status string, next time.Time) error
+	}
+	// Client sends the requests; its timeout bounds each attempt.
+	Client *http.Client
+	// MaxAttempts is how often a delivery is tried before it is dead.
+	MaxAttempts int
+	// Backoff is the wait after the first failure, doubled after each
+	// further one up to MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// BatchSize is the number of deliveries claimed at a time.
+	BatchSize int
+}
+
+// lease is how long a claimed delivery is held back from other dispatchers
+// beyond the client timeout, in case this one stops before logging it. The
+// deliveries of a batch are sent at the same time, so the whole batch is
+// done within the client timeout.
+const lease = time.Minute
+
+// Dispatch claims a batch of the deliveries that are due and sends them,
+// returning how many were received successfully and how many failed. A
+// delivery whose lease ran out before it was logged counts as neither; the
+// dispatcher that took it over logs it.
+func (d *Dispatcher) Dispatch(ctx context.Context) (delivered, failed int, err error) {
+	jobs, err := d.Queue.Claim(ctx, d.BatchSize, d.Client.Timeout+lease)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		errs []error
+	)
+
+	for _, job := range jobs {
+		wg.Add(1)
+		go func(job *models.DeliveryJob) {
+			defer wg.Done()
+
+			ok, err := d.deliver(ctx, job)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			switch {
+			case err == models.ErrLeaseLost:
+			case err != nil:
+				errs = append(errs, fmt.Errorf("delivery %d: %w", job.ID, err))
+			case ok:
+				delivered++
+			default:
+				failed++
+			}
+		}(job)
+	}
+
+	wg.Wait()
+
+	return delivered, failed, errors.Join(errs...)
+}
+
+// deliver sends the job and logs the attempt, reporting whether it was
+// received.
+func (d *Dispatcher) deliver(ctx context.Context, job *models.DeliveryJob) (bool, error) {
+	attempt := d.send(ctx, job)
+
+	status, next := models.DeliveryDelivered, time.Now()
+	switch {
+	case attempt.Error == "":
+	case job.Attempts+1 >= d.MaxAttempts:
+		status = models.DeliveryDead
+	default:
+		status, next = models.DeliveryPending, next.Add(Backoff(job.Attempts+1, d.Backoff, d.MaxBackoff))
+	}
+
+	return attempt.Error == "", d.Queue.Attempted(ctx, job, attempt, status, next)
+}
+
+// send posts the job's payload, signed, and reports how it went. Any
+// response but a 2xx one is a failure; redirects are not followed.
+func (d *Dispatcher) send(ctx context.Context, job *models.DeliveryJob) *models.DeliveryAttempt {
+	start := time.Now()
+	attempt := &models.DeliveryAttempt{Created: start}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, job.URL, bytes.NewReader(job.Payload))
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "pm-service-webhooks")
+	req.Header.Set(EventHeader, job.Event)
+	req.Header.Set(DeliveryHeader, strconv.Itoa(job.ID))
+	req.Header.Set(TimestampHeader, strconv.FormatInt(start.Unix(), 10))
+	req.Header.Set(SignatureHeader, Sign(job.Secret, start.Unix(), job.Payload))
+
+	resp, err := d.Client.Do(req)
+	attempt.DurationMS = int(time.Since(start) / time.Millisecond)
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+
+	// drain a little of the body so the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	resp.Body.Close()
+
+	attempt.StatusCode = resp.StatusCode
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		attempt.Error = resp.Status
+	}
+
+	return attempt
+}
+
+// NewClient returns an HTTP client for delivering webhooks that gives up
+// on a request after timeout and does not follow redirects.
+func NewClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout: timeout,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
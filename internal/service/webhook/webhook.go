@@ -0,0 +1,144 @@
+// Package webhook turns recorded changes into webhook events, signs their
+// payloads and sends the queued deliveries, retrying failed ones with
+// exponential backoff until they are dead-lettered.
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"pm-service/internal/repository/models"
+	"pm-service/internal/service/events"
+	"strconv"
+	"time"
+)
+
+// Headers sent with every delivery. The signature is "sha256=" followed by
+// the hex HMAC-SHA256, under the webhook's secret, of the timestamp, a dot
+// and the body, so that receivers can reject replayed deliveries.
+const (
+	EventHeader     = "X-Webhook-Event"
+	DeliveryHeader  = "X-Webhook-Delivery"
+	TimestampHeader = "X-Webhook-Timestamp"
+	SignatureHeader = "X-Webhook-Signature"
+)
+
+// prefixes name the events of the resources that trigger any.
+var prefixes = map[string]string{"tasks": "task", "projects": "project", "comments": "comment"}
+
+// Events returns the events a change to a resource triggers, given the
+// action and the fields it changed.
+func Events(resource, action string, diff map[string]models.Change) []string {
+	prefix, ok := prefixes[resource]
+	if !ok {
+		return nil
+	}
+
+	switch action {
+	case models.AuditInsert:
+		return []string{prefix + ".created"}
+	case models.AuditDelete:
+		return []string{prefix + ".deleted"}
+	case models.AuditRestore:
+		return []string{prefix + ".restored"}
+	}
+
+	events := []string{prefix + ".updated"}
+
+	if _, ok := diff["status"]; ok && resource == "tasks" {
+		events = append(events, prefix+".status_changed")
+	}
+	if c, ok := diff["completed"]; ok && c.From == nil && resource != "comments" {
+		events = append(events, prefix+".completed")
+	}
+
+	return events
+}
+
+// Payload is the body delivered for an event: the record as it is after
+// the change, or was before a delete, and the fields the change made.
+type Payload struct {
+	Event    string                   `json:"event"`
+	Resource string                   `json:"resource"`
+	ID       int                      `json:"id"`
+	Data     map[string]interface{}   `json:"data"`
+	Changes  map[string]models.Change `json:"changes"`
+	Occurred time.Time                `json:"occurred"`
+}
+
+// Enqueue returns a bus handler queueing in store a delivery of every
+// event a recorded change triggers. The deliveries are keyed by the outbox
+// event relayed, so relaying a change twice queues them once.
+func Enqueue(store interface {
+	Enqueue(ctx context.Context, event string, payload []byte, source int) error
+}) events.Handler {
+	return func(ctx context.Context, e events.Event) error {
+		c, ok := e.(events.Recorded)
+		if !ok {
+			return nil
+		}
+
+		for _, event := range Events(c.Resource, c.Action, c.Diff) {
+			payload, err := json.Marshal(&Payload{Event: event, Resource: c.Resource, ID: c.ID, Data: c.View, Changes: c.Diff, Occurred: c.Occurred})
+			if err != nil {
+				return err
+			}
+
+			if err := store.Enqueue(ctx, event, payload, events.EventID(ctx)); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+}
+
+// NewSecret returns a random secret to sign a webhook's deliveries with.
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// Sign returns the signature header value of a delivery body sent at the
+// given unix time.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature, as sent with a delivery body at the
+// given timestamp header value, was made with the secret.
+func Verify(secret, timestamp, signature string, body []byte) bool {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+
+	return hmac.Equal([]byte(Sign(secret, ts, body)), []byte(signature))
+}
+
+// Backoff returns how long to wait before retrying a delivery that failed
+// for the given number of times: base, doubled for each further failure,
+// but never more than limit.
+func Backoff(failures int, base, limit time.Duration) time.Duration {
+	d := base
+	for i := 1; i < failures && d < limit; i++ {
+		d *= 2
+	}
+
+	if d > limit {
+		return limit
+	}
+	return d
+}
@@ -0,0 +1,191 @@
+package testing
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"pm-service/internal/repository/mock"
+	"pm-service/internal/repository/models"
+	"pm-service/internal/service/audit"
+	"pm-service/internal/service/events"
+	"strings"
+	"testing"
+)
+
+func TestAudit(t *testing.T) {
+	router := newRouter()
+
+	seed(t, router,
+		fixture{"/users", models.UserInput{Name: "bob", Email: "bob@mail.com", Role: "developer"}},
+		fixture{"/users", models.UserInput{Name: "eve", Email: "eve@mail.com", Role: "manager"}},
+		fixture{"/projects", models.ProjectInput{Title: "Alpha", Description: "first project", ManagerID: 2}},
+		fixture{"/tasks", models.TaskInput{Title: "Report", Priority: "high", Status: "to do", AssigneeID: 2, ProjectID: 1}},
+	)
+
+	tests := []struct {
+		name      string
+		method    string
+		path      string
+		body      string
+		wantCode  int
+		wantTotal int
+	}{
+		{"history of new task", http.MethodGet, "/tasks/1/history", "", http.StatusOK, 1},
+		{"create label", http.MethodPost, "/projects/1/labels", `{"name": "bug", "color": "#d73a4a"}`, http.StatusCreated, -1},
+		{"attach label", http.MethodPut, "/tasks/1/labels/1", "", http.StatusOK, -1},
+		{"attach records task change", http.MethodGet, "/tasks/1/history", "", http.StatusOK, 2},
+		{"attach twice", http.MethodPut, "/tasks/1/labels/1", "", http.StatusOK, -1},
+		{"unchanged task not recorded", http.MethodGet, "/tasks/1/history", "", http.StatusOK, 2},
+		{"delete label", http.MethodDelete, "/projects/1/labels/1", "", http.StatusOK, -1},
+		{"label delete records task change", http.MethodGet, "/tasks/1/history", "", http.StatusOK, 3},
+		{"label events", http.MethodGet, "/audit?resource=labels", "", http.StatusOK, 2},
+		{"owner recorded with project", http.MethodGet, "/audit?resource=projects&id=1", "", http.StatusOK, 1},
+		{"add member", http.MethodPost, "/projects/1/members", `{"user_id": 1, "role": "member"}`, http.StatusCreated, -1},
+		{"member recorded as project change", http.MethodGet, "/audit?resource=projects&id=1", "", http.StatusOK, 2},
+		{"changes by admin", http.MethodGet, "/audit?actor=0", "", http.StatusOK, 9},
+		{"deletes", http.MethodGet, "/audit?action=delete", "", http.StatusOK, 1},
+		{"since", http.MethodGet, "/audit?since=2999-01-01", "", http.StatusOK, 0},
+		{"history since", http.MethodGet, "/tasks/1/history?since=2000-01-01T00:00:00Z", "", http.StatusOK, 3},
+		{"invalid since", http.MethodGet, "/audit?since=yesterday", "", http.StatusUnprocessableEntity, -1},
+		{"invalid resource", http.MethodGet, "/audit?resource=teams", "", http.StatusUnprocessableEntity, -1},
+		{"history of unknown task", http.MethodGet, "/tasks/9/history", "", http.StatusNotFound, -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+
+			if rr.Code != tt.wantCode {
+				t.Fatalf("handler returned wrong status code: got %v want %v: %s", rr.Code, tt.wantCode, rr.Body.String())
+			}
+
+			if tt.wantTotal < 0 {
+				return
+			}
+
+			var resp struct {
+				Metadata models.Metadata `json:"metadata"`
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+
+			if resp.Metadata.TotalRecords != tt.wantTotal {
+				t.Errorf("got %d records want %d: %s", resp.Metadata.TotalRecords, tt.wantTotal, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestAuditEvent(t *testing.T) {
+	router := newRouter()
+
+	req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(`{"name": "bob", "email": "bob@mail.com", "role": "developer", "password": "correct horse"}`))
+	req.Header.Set("X-Request-ID", "req-42")
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("creating user returned %d: %s", rr.Code, rr.Body.String())
+	}
+	if got := rr.Header().Get("X-Request-ID"); got != "req-42" {
+		t.Errorf("got request id %q want %q", got, "req-42")
+	}
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/audit?request_id=req-42", nil))
+
+	var resp struct {
+		Events []*models.AuditEvent `json:"events"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Events) != 1 {
+		t.Fatalf("got %d events want 1: %s", len(resp.Events), rr.Body.String())
+	}
+
+	e := resp.Events[0]
+	if e.Resource != "users" || e.ResourceID != 1 || e.Action != models.AuditInsert || e.ActorID == nil || *e.ActorID != 0 {
+		t.Errorf("unexpected event: %s", rr.Body.String())
+	}
+	if c := e.Diff["name"]; c.From != nil || c.To != "bob" {
+		t.Errorf("got name change %v want <nil> -> bob", c)
+	}
+	if c := e.Diff["password"]; c.To != audit.Redacted {
+		t.Errorf("got password change %v want it redacted", c)
+	}
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/health", nil))
+	if rr.Header().Get("X-Request-ID") == "" {
+		t.Error("no request id generated")
+	}
+}
+
+func TestAuditSecrets(t *testing.T) {
+	ctx := context.Background()
+
+	users := &mock.UserModel{}
+	accounts := &mock.AuthModel{Users: users}
+	hooks := &mock.WebhookModel{}
+	outbox := &mock.OutboxModel{}
+	changes := &mock.AuditModel{Users: users, Auth: accounts, Webhooks: hooks, Outbox: outbox}
+	users.Audit, accounts.Audit, hooks.Audit = changes, changes, changes
+
+	mustDo := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err := users.Insert(ctx, &models.UserInput{Name: "bob", Email: "bob@mail.com", Role: "developer", PasswordHash: "hash-1"})
+	mustDo(err)
+	mustDo(accounts.SetPassword(ctx, 1, "hash-2"))
+	_, err = hooks.Insert(ctx, &models.WebhookInput{URL: "https://hooks.example.com", Secret: "0123456789abcdef", Events: []string{"task.created"}})
+	mustDo(err)
+	_, err = hooks.Update(ctx, "1", &models.WebhookInput{URL: "https://hooks.example.com", Secret: "fedcba9876543210", Events: []string{"task.created"}}, 1)
+	mustDo(err)
+	_, err = hooks.Update(ctx, "1", &models.WebhookInput{URL: "https://hooks.example.org", Events: []string{"task.created"}}, 2)
+	mustDo(err)
+
+	// every change to a secret is recorded, redacted, and no other change is
+	want := []struct {
+		field    string
+		from, to interface{}
+	}{
+		{"password", nil, audit.Redacted},
+		{"password", audit.Redacted, audit.Redacted},
+		{"secret", nil, audit.Redacted},
+		{"secret", audit.Redacted, audit.Redacted},
+		{"secret", nil, nil},
+	}
+
+	if len(changes.DB) != len(want) {
+		t.Fatalf("got %d audit events want %d", len(changes.DB), len(want))
+	}
+	for i, w := range want {
+		c, ok := changes.DB[i].Diff[w.field]
+		if (w.to != nil) != ok || c.From != w.from || c.To != w.to {
+			t.Errorf("event %d: got %s change %v want %v -> %v", i+1, w.field, changes.DB[i].Diff, w.from, w.to)
+		}
+	}
+
+	// the views events carry only tell that there is a secret
+	for _, o := range outbox.DB {
+		if o.Name != (events.Recorded{}).Name() {
+			continue
+		}
+
+		e, err := events.Decode(o.Name, o.Payload)
+		mustDo(err)
+
+		field := map[string]string{"users": "password", "webhooks": "secret"}[e.(events.Recorded).Resource]
+		if v := e.(events.Recorded).View[field]; v != audit.Redacted {
+			t.Errorf("event %d carries %s %v", o.ID, field, v)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package testing
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"pm-service/internal/config"
+	"pm-service/internal/handlers"
+	"pm-service/internal/repository/models"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestAuth(t *testing.T) {
+	router := config.Routing(handlers.Mock())
+
+	seed(t, signedIn(router),
+		fixture{"/users", models.UserInput{Name: "alice", Email: "alice@mail.com", Role: "developer", Password: "correct horse"}},
+		fixture{"/users", models.UserInput{Name: "bob", Email: "bob@mail.com", Role: "developer"}},
+	)
+
+	type response struct {
+		AccessToken  string            `json:"access_token"`
+		RefreshToken string            `json:"refresh_token"`
+		Key          string            `json:"key"`
+		User         *models.User      `json:"user"`
+		APIKeys      []*models.APIKey  `json:"api_keys"`
+		Error        map[string]string `json:"error"`
+	}
+
+	// tokens and keys issued by earlier cases
+	var access, refresh, staleRefresh, key string
+
+	bearer := func(token *string) func() (string, string) {
+		return func() (string, string) { return "Authorization", "Bearer " + *token }
+	}
+	apiKey := func(key *string) func() (string, string) {
+		return func() (string, string) { return "X-API-Key", *key }
+	}
+	admin := handlers.MockAPIKey
+	refreshBody := func(token *string) func() string {
+		return func() string { return `{"refresh_token": "` + *token + `"}` }
+	}
+	text := func(s string) func() string {
+		return func() string { return s }
+	}
+	signedInAs := func(name string) func(*response) bool {
+		return func(r *response) bool { return r.User != nil && r.User.Name == name }
+	}
+
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		credentials func() (string, string)
+		body        func() string
+		wantCode    int
+		want        func(*response) bool
+	}{
+		{"anonymous", http.MethodGet, "/users", nil, nil, http.StatusUnauthorized, nil},
+		{"anonymous delete", http.MethodDelete, "/users/1", nil, nil, http.StatusUnauthorized, nil},
+		{"anonymous health check", http.MethodGet, "/health", nil, nil, http.StatusOK, nil},
+		{"unknown api key", http.MethodGet, "/users", apiKey(new(string)), nil, http.StatusUnauthorized, nil},
+		{"malformed token", http.MethodGet, "/users", bearer(new(string)), nil, http.StatusUnauthorized, nil},
+		{"login without password", http.MethodPost, "/auth/login", nil, text(`{"email": "alice@mail.com"}`), http.StatusUnprocessableEntity, nil},
+		{"login with wrong password", http.MethodPost, "/auth/login", nil, text(`{"email": "alice@mail.com", "password": "battery staple"}`), http.StatusUnauthorized, nil},
+		{"login as unknown user", http.MethodPost, "/auth/login", nil, text(`{"email": "carol@mail.com", "password": "correct horse"}`), http.StatusUnauthorized, nil},
+		{"login as user without password", http.MethodPost, "/auth/login", nil, text(`{"email": "bob@mail.com", "password": "correct horse"}`), http.StatusUnauthorized, nil},
+		{"login", http.MethodPost, "/auth/login", nil, text(`{"email": "ALICE@mail.com", "password": "correct horse"}`), http.StatusOK, func(r *response) bool {
+			access, refresh = r.AccessToken, r.RefreshToken
+			return access != "" && refresh != ""
+		}},
+		{"current user", http.MethodGet, "/auth/me", bearer(&access), nil, http.StatusOK, signedInAs("alice")},
+		{"refresh token as access token", http.MethodGet, "/auth/me", bearer(&refresh), nil, http.StatusUnauthorized, nil},
+		{"access token as refresh token", http.MethodPost, "/auth/refresh", nil, refreshBody(&access), http.StatusUnauthorized, nil},
+		{"refresh", http.MethodPost, "/auth/refresh", nil, refreshBody(&refresh), http.StatusOK, func(r *response) bool {
+			staleRefresh, access, refresh = refresh, r.AccessToken, r.RefreshToken
+			return refresh != staleRefresh
+		}},
+		{"reuse refresh token", http.MethodPost, "/auth/refresh", nil, refreshBody(&staleRefresh), http.StatusUnauthorized, nil},
+		{"create api key", http.MethodPost, "/auth/keys", bearer(&access), text(`{"name": "ci"}`), http.StatusCreated, func(r *response) bool {
+			key = r.Key
+			return strings.HasPrefix(key, "pmk_")
+		}},
+		{"api key", http.MethodGet, "/auth/me", apiKey(&key), nil, http.StatusOK, signedInAs("alice")},
+		{"list api keys", http.MethodGet, "/auth/keys", apiKey(&key), nil, http.StatusOK, func(r *response) bool {
+			return len(r.APIKeys) == 1 && r.APIKeys[0].Name == "ci" && strings.HasPrefix(key, r.APIKeys[0].Hint) && r.APIKeys[0].Hint != key
+		}},
+		{"other user's api keys", http.MethodGet, "/auth/keys", apiKey(&admin), nil, http.StatusOK, func(r *response) bool {
+			return len(r.APIKeys) == 0
+		}},
+		{"logout with other user's refresh token", http.MethodPost, "/auth/logout", apiKey(&admin), refreshBody(&refresh), http.StatusUnauthorized, nil},
+		{"logout", http.MethodPost, "/auth/logout", bearer(&access), refreshBody(&refresh), http.StatusOK, nil},
+		{"revoked access token", http.MethodGet, "/auth/me", bearer(&access), nil, http.StatusUnauthorized, nil},
+		{"revoked refresh token", http.MethodPost, "/auth/refresh", nil, refreshBody(&refresh), http.StatusUnauthorized, nil},
+		{"password through user update", http.MethodPatch, "/users/1", apiKey(&admin), text(`{"password": "battery staple"}`), http.StatusUnprocessableEntity, func(r *response) bool {
+			return r.Error["password"] != ""
+		}},
+		{"change password with wrong current", http.MethodPut, "/auth/password", apiKey(&key), text(`{"current_password": "battery staple", "password": "battery staple"}`), http.StatusUnprocessableEntity, func(r *response) bool {
+			return r.Error["current_password"] != ""
+		}},
+		{"login before password change", http.MethodPost, "/auth/login", nil, text(`{"email": "alice@mail.com", "password": "correct horse"}`), http.StatusOK, func(r *response) bool {
+			access, refresh = r.AccessToken, r.RefreshToken
+			return access != "" && refresh != ""
+		}},
+		{"change password", http.MethodPut, "/auth/password", apiKey(&key), text(`{"current_password": "correct horse", "password": "battery staple"}`), http.StatusOK, nil},
+		{"access token from before password change", http.MethodGet, "/auth/me", bearer(&access), nil, http.StatusUnauthorized, nil},
+		{"refresh token from before password change", http.MethodPost, "/auth/refresh", nil, refreshBody(&refresh), http.StatusUnauthorized, nil},
+		{"login with old password", http.MethodPost, "/auth/login", nil, text(`{"email": "alice@mail.com", "password": "correct horse"}`), http.StatusUnauthorized, nil},
+		{"login with new password", http.MethodPost, "/auth/login", nil, text(`{"email": "alice@mail.com", "password": "battery staple"}`), http.StatusOK, func(r *response) bool {
+			access = r.AccessToken
+			return access != ""
+		}},
+		{"short password", http.MethodPut, "/auth/password", apiKey(&key), text(`{"current_password": "battery staple", "password": "short"}`), http.StatusUnprocessableEntity, func(r *response) bool {
+			return r.Error["password"] != ""
+		}},
+		{"delete api key of other user", http.MethodDelete, "/auth/keys/1", apiKey(&admin), nil, http.StatusNotFound, nil},
+		{"delete api key", http.MethodDelete, "/auth/keys/1", apiKey(&key), nil, http.StatusOK, nil},
+		{"deleted api key", http.MethodGet, "/auth/me", apiKey(&key), nil, http.StatusUnauthorized, nil},
+		{"delete user", http.MethodDelete, "/users/1", apiKey(&admin), nil, http.StatusOK, nil},
+		{"token of deleted user", http.MethodGet, "/auth/me", bearer(&access), nil, http.StatusUnauthorized, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := ""
+			if tt.body != nil {
+				body = tt.body()
+			}
+
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(body))
+			if tt.method == http.MethodPatch {
+				req.Header.Set("Content-Type", "application/merge-patch+json")
+			}
+			if tt.credentials != nil {
+				req.Header.Set(tt.credentials())
+			}
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantCode {
+				t.Fatalf("handler returned wrong status code: got %v want %v: %s", rr.Code, tt.wantCode, rr.Body.String())
+			}
+
+			if rr.Code == http.StatusUnauthorized && rr.Header().Get("WWW-Authenticate") != "Bearer" {
+				t.Errorf("missing WWW-Authenticate header")
+			}
+
+			if tt.want == nil {
+				return
+			}
+
+			var resp response
+			if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if !tt.want(&resp) {
+				t.Errorf("unexpected response: %s", rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestRefreshReplay(t *testing.T) {
+	router := config.Routing(handlers.Mock())
+
+	seed(t, signedIn(router),
+		fixture{"/users", models.UserInput{Name: "alice", Email: "alice@mail.com", Role: "developer", Password: "correct horse"}},
+	)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/auth/login", strings.NewReader(`{"email": "alice@mail.com", "password": "correct horse"}`)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("login returned %v: %s", rr.Code, rr.Body.String())
+	}
+
+	var tokens struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &tokens); err != nil {
+		t.Fatal(err)
+	}
+
+	// requests replaying one refresh token at once get one new pair between them
+	codes := make(chan int, 10)
+	var wg sync.WaitGroup
+	for i := 0; i < cap(codes); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/auth/refresh", strings.NewReader(`{"refresh_token": "`+tokens.RefreshToken+`"}`)))
+			codes <- rr.Code
+		}()
+	}
+	wg.Wait()
+	close(codes)
+
+	refreshed := 0
+	for code := range codes {
+		if code == http.StatusOK {
+			refreshed++
+		} else if code != http.StatusUnauthorized {
+			t.Errorf("refresh returned %v", code)
+		}
+	}
+	if refreshed != 1 {
+		t.Errorf("%d requests refreshed the token want 1", refreshed)
+	}
+}
@@ -0,0 +1,100 @@
+package testing
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"pm-service/internal/repository/models"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestComments(t *testing.T) {
+	router := newRouter()
+
+	seed(t, router,
+		fixture{"/users", models.UserInput{Name: "bob", Email: "bob@mail.com", Role: "developer"}},
+		fixture{"/users", models.UserInput{Name: "eve", Email: "eve@mail.com", Role: "manager"}},
+		fixture{"/projects", models.ProjectInput{Title: "Alpha", Description: "first project", ManagerID: 2}},
+		fixture{"/projects/1/members", models.MemberInput{UserID: 1, Role: "member"}},
+		fixture{"/tasks", models.TaskInput{Title: "Report", Priority: "high", Status: "to do", AssigneeID: 1, ProjectID: 1}},
+		fixture{"/tasks", models.TaskInput{Title: "Review", Priority: "low", Status: "to do", AssigneeID: 2, ProjectID: 1}},
+	)
+
+	type response struct {
+		models.Comment
+		Comments []*models.Comment         `json:"comments"`
+		History  []*models.CommentRevision `json:"history"`
+		Metadata models.Metadata           `json:"metadata"`
+	}
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		body     string
+		wantCode int
+		want     func(*response) bool
+	}{
+		{"comment", http.MethodPost, "/tasks/1/comments", `{"author_id": 1, "body": "@Eve can you check this? cc bob@mail.com"}`, http.StatusCreated, nil},
+		{"mentions resolved", http.MethodGet, "/tasks/1/comments/1", "", http.StatusOK, func(r *response) bool {
+			return reflect.DeepEqual(r.Mentions, []int{2})
+		}},
+		{"reply", http.MethodPost, "/tasks/1/comments", `{"author_id": 2, "parent_id": 1, "body": "done, @bob@mail.com"}`, http.StatusCreated, nil},
+		{"nested reply", http.MethodPost, "/tasks/1/comments", `{"author_id": 1, "parent_id": 2, "body": "thanks"}`, http.StatusCreated, nil},
+		{"second thread", http.MethodPost, "/tasks/1/comments", `{"author_id": 1, "body": "another topic"}`, http.StatusCreated, nil},
+		{"reply across tasks", http.MethodPost, "/tasks/2/comments", `{"author_id": 1, "parent_id": 1, "body": "wrong task"}`, http.StatusUnprocessableEntity, nil},
+		{"unknown author", http.MethodPost, "/tasks/1/comments", `{"author_id": 9, "body": "who am I"}`, http.StatusUnprocessableEntity, nil},
+		{"empty body", http.MethodPost, "/tasks/1/comments", `{"author_id": 1, "body": " "}`, http.StatusUnprocessableEntity, nil},
+		{"unknown task", http.MethodPost, "/tasks/9/comments", `{"author_id": 1, "body": "hello"}`, http.StatusNotFound, nil},
+		{"thread", http.MethodGet, "/tasks/1/comments", "", http.StatusOK, func(r *response) bool {
+			return len(r.Comments) == 2 && r.Metadata.TotalRecords == 2 &&
+				len(r.Comments[0].Replies) == 1 && len(r.Comments[0].Replies[0].Replies) == 1 &&
+				r.Comments[0].Replies[0].Replies[0].Body == "thanks"
+		}},
+		{"comment of another task", http.MethodGet, "/tasks/2/comments/1", "", http.StatusNotFound, nil},
+		{"edit", http.MethodPut, "/tasks/1/comments/1", `{"body": "@bob please check this"}`, http.StatusOK, nil},
+		{"edit cannot move comment", http.MethodPut, "/tasks/1/comments/1", `{"body": "x", "author_id": 2}`, http.StatusBadRequest, nil},
+		{"mentions parsed again", http.MethodGet, "/tasks/1/comments/1", "", http.StatusOK, func(r *response) bool {
+			return reflect.DeepEqual(r.Mentions, []int{1}) && r.Edited != nil && r.Version == 2
+		}},
+		{"history", http.MethodGet, "/tasks/1/comments/1/history", "", http.StatusOK, func(r *response) bool {
+			return len(r.History) == 1 && r.History[0].Version == 1 && strings.HasPrefix(r.History[0].Body, "@Eve")
+		}},
+		{"mentions feed", http.MethodGet, "/users/1/mentions", "", http.StatusOK, func(r *response) bool {
+			return len(r.Comments) == 2 && r.Comments[0].ID == 2 && r.Comments[1].ID == 1
+		}},
+		{"mention removed by edit", http.MethodGet, "/users/2/mentions", "", http.StatusOK, func(r *response) bool {
+			return len(r.Comments) == 0
+		}},
+		{"delete with replies", http.MethodDelete, "/tasks/1/comments/1", "", http.StatusOK, nil},
+		{"replies deleted", http.MethodGet, "/tasks/1/comments/3", "", http.StatusNotFound, nil},
+		{"thread after delete", http.MethodGet, "/tasks/1/comments", "", http.StatusOK, func(r *response) bool {
+			return len(r.Comments) == 1 && r.Comments[0].Body == "another topic"
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+
+			if rr.Code != tt.wantCode {
+				t.Fatalf("handler returned wrong status code: got %v want %v: %s", rr.Code, tt.wantCode, rr.Body.String())
+			}
+
+			if tt.want == nil {
+				return
+			}
+
+			var resp response
+			if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if !tt.want(&resp) {
+				t.Errorf("unexpected response: %s", rr.Body.String())
+			}
+		})
+	}
+}
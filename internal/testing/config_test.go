@@ -0,0 +1,163 @@
+package testing
+
+import (
+	"os"
+	"path/filepath"
+	"pm-service/internal/config"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+func TestConfigLoad(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	yaml := "port: 9000\nenv: staging\ndb:\n  user: yaml\n  name: tracker\n  query_timeout: 2s\n"
+	if err := os.WriteFile(file, []byte(yaml), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		args    []string
+		env     map[string]string
+		check   func(*config.Config) bool
+		wantErr string
+	}{
+		{"defaults", nil, map[string]string{"DB_USER": "admin", "DB_NAME": "tracker"}, func(c *config.Config) bool {
+			return c.Port == 8080 && c.Env == "development" && c.DB.QueryTimeout == 5*time.Second && c.AutoMigrate
+		}, ""},
+		{"file over defaults", []string{"-config", file}, nil, func(c *config.Config) bool {
+			return c.Port == 9000 && c.Env == "staging" && c.DB.User == "yaml" && c.DB.QueryTimeout == 2*time.Second
+		}, ""},
+		{"file from env", nil, map[string]string{"CONFIG_FILE": file}, func(c *config.Config) bool {
+			return c.Port == 9000
+		}, ""},
+		{"env over file", []string{"-config", file}, map[string]string{"PORT": "9100", "DB_USER": "env", "DB_QUERY_TIMEOUT": "3s"}, func(c *config.Config) bool {
+			return c.Port == 9100 && c.DB.User == "env" && c.DB.QueryTimeout == 3*time.Second && c.Env == "staging"
+		}, ""},
+		{"flags over env", []string{"-config", file, "-port", "9200", "-env", "production"}, map[string]string{"PORT": "9100"}, func(c *config.Config) bool {
+			return c.Port == 9200 && c.Env == "production"
+		}, ""},
+		{"dsn instead of fields", []string{"-db-dsn", "postgres://u:p@db/tracker"}, nil, func(c *config.Config) bool {
+			return c.DB.ConnString() == "postgres://u:p@db/tracker"
+		}, ""},
+		{"positional args kept", []string{"-config", file, "migrate", "up"}, nil, func(c *config.Config) bool {
+			return strings.Join(c.Args(), " ") == "migrate up"
+		}, ""},
+		{"malformed int", []string{"-config", file}, map[string]string{"PORT": "eighty"}, nil, "PORT must be an integer"},
+		{"malformed duration", []string{"-config", file}, map[string]string{"DB_QUERY_TIMEOUT": "5"}, nil, "DB_QUERY_TIMEOUT must be a duration"},
+		{"malformed bool", []string{"-config", file}, map[string]string{"AUTO_MIGRATE": "sometimes"}, nil, "AUTO_MIGRATE must be a boolean"},
+		{"missing file", []string{"-config", file + ".missing"}, nil, nil, "no such file"},
+		{"invalid after merge", []string{"-config", file, "-port", "0"}, nil, nil, "port must be between 1 and 65535"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("AUTH_SECRET", testSecret)
+			for key, val := range tt.env {
+				t.Setenv(key, val)
+			}
+
+			cfg, err := config.Load(tt.args)
+
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !tt.check(cfg) {
+				t.Errorf("unexpected configuration: %s", cfg.Redacted())
+			}
+		})
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	valid := func() *config.Config {
+		t.Setenv("AUTH_SECRET", testSecret)
+		t.Setenv("DB_USER", "admin")
+		t.Setenv("DB_NAME", "tracker")
+
+		cfg, err := config.Load(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cfg
+	}
+
+	tests := []struct {
+		name    string
+		change  func(*config.Config)
+		wantErr string
+	}{
+		{"valid", func(c *config.Config) {}, ""},
+		{"unknown env", func(c *config.Config) { c.Env = "qa" }, "env must be one of"},
+		{"no db user", func(c *config.Config) { c.DB.User = "" }, "db user must be provided"},
+		{"dsn needs no fields", func(c *config.Config) { c.DB.DSN, c.DB.User, c.DB.Name = "postgres://db/tracker", "", "" }, ""},
+		{"zero query timeout", func(c *config.Config) { c.DB.QueryTimeout = 0 }, "db query timeout must be positive"},
+		{"negative server timeout", func(c *config.Config) { c.Server.IdleTimeout = -time.Second }, "server timeouts must be positive"},
+		{"small header limit", func(c *config.Config) { c.Server.MaxHeaderBytes = 512 }, "max header bytes"},
+		{"short auth secret", func(c *config.Config) { c.Auth.Secret = "short" }, "auth secret must be at least 32 characters"},
+		{"refresh shorter than access", func(c *config.Config) { c.Auth.RefreshTTL = time.Minute }, "auth access ttl"},
+		{"backoff beyond max", func(c *config.Config) { c.Webhooks.Backoff = 7 * time.Hour }, "webhook backoff"},
+		{"no event batch", func(c *config.Config) { c.Events.BatchSize = 0 }, "events poll interval"},
+		{"every problem reported", func(c *config.Config) { c.Port, c.MigrationsDir = 70000, "" }, "port must be between 1 and 65535; migrations dir must be provided"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := valid()
+			tt.change(cfg)
+
+			err := cfg.Validate()
+
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("got error %v want none", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("got error %v want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfigRedacted(t *testing.T) {
+	tests := []struct {
+		name   string
+		db     config.DBConfig
+		want   string
+		hidden string
+	}{
+		{"url dsn", config.DBConfig{DSN: "postgres://admin:hunter2@db:5432/tracker"}, "postgres://admin:%2A%2A%2A%2A%2A@db:5432/tracker", "hunter2"},
+		{"url dsn without password", config.DBConfig{DSN: "postgres://admin@db/tracker"}, "postgres://admin@db/tracker", ""},
+		{"key value dsn", config.DBConfig{DSN: "host=db user=admin password=hunter2 dbname=tracker"}, "host=db user=admin password=***** dbname=tracker", "hunter2"},
+		{"password field", config.DBConfig{Host: "db", Password: "hunter2"}, "db.password=*****", "hunter2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{DB: tt.db, Auth: config.AuthConfig{Secret: testSecret}}
+			out := cfg.Redacted()
+
+			if !strings.Contains(out, tt.want) {
+				t.Errorf("got %s want it to contain %s", out, tt.want)
+			}
+			if tt.hidden != "" && strings.Contains(out, tt.hidden) {
+				t.Errorf("%s reveals %s", out, tt.hidden)
+			}
+			if strings.Contains(out, testSecret) {
+				t.Errorf("%s reveals the auth secret", out)
+			}
+		})
+	}
+}
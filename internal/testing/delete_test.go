@@ -0,0 +1,73 @@
+package testing
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"pm-service/internal/repository/models"
+	"testing"
+)
+
+func TestDeleteModes(t *testing.T) {
+	router := newRouter()
+
+	seed(t, router,
+		fixture{"/users", models.UserInput{Name: "bob", Email: "bob@mail.com", Role: "manager"}},
+		fixture{"/users", models.UserInput{Name: "eve", Email: "eve@mail.com", Role: "developer"}},
+		fixture{"/projects", models.ProjectInput{Title: "Alpha", Description: "first project", ManagerID: 1}},
+		fixture{"/projects/1/members", models.MemberInput{UserID: 2, Role: "member"}},
+		fixture{"/tasks", models.TaskInput{Title: "Report", Priority: "high", Status: "to do", AssigneeID: 1, ProjectID: 1}},
+		fixture{"/tasks", models.TaskInput{Title: "Review", Priority: "low", Status: "to do", AssigneeID: 2, ProjectID: 1}},
+	)
+
+	tests := []struct {
+		name         string
+		method       string
+		path         string
+		wantCode     int
+		wantBlocking map[string][]int
+	}{
+		{"unknown user mode", http.MethodDelete, "/users/1?mode=cascade", http.StatusUnprocessableEntity, nil},
+		{"reassign to self", http.MethodDelete, "/users/1?mode=reassign&to=1", http.StatusUnprocessableEntity, nil},
+		{"reassign to unknown user", http.MethodDelete, "/users/1?mode=reassign&to=9", http.StatusUnprocessableEntity, nil},
+		{"restricted user", http.MethodDelete, "/users/1", http.StatusConflict, map[string][]int{"tasks": {1}, "projects": {1}}},
+		{"reassign user", http.MethodDelete, "/users/1?mode=reassign&to=2", http.StatusOK, nil},
+		{"reassigned tasks", http.MethodGet, "/users/2/tasks", http.StatusOK, nil},
+		{"restricted project", http.MethodDelete, "/projects/1?mode=restrict", http.StatusConflict, map[string][]int{"tasks": {1, 2}}},
+		{"cascade project", http.MethodDelete, "/projects/1?mode=cascade", http.StatusOK, nil},
+		{"cascaded task", http.MethodGet, "/tasks/1", http.StatusNotFound, nil},
+		{"unblocked user", http.MethodDelete, "/users/2", http.StatusOK, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest(tt.method, tt.path, nil))
+
+			if rr.Code != tt.wantCode {
+				t.Fatalf("handler returned wrong status code: got %v want %v: %s", rr.Code, tt.wantCode, rr.Body.String())
+			}
+
+			if tt.wantBlocking == nil {
+				return
+			}
+
+			var resp struct {
+				Error map[string]json.RawMessage `json:"error"`
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+
+			for key, want := range tt.wantBlocking {
+				var got []int
+				if err := json.Unmarshal(resp.Error[key], &got); err != nil {
+					t.Fatalf("blocking %s: %v", key, err)
+				}
+				if len(got) != len(want) {
+					t.Errorf("got blocking %s %v want %v", key, got, want)
+				}
+			}
+		})
+	}
+}
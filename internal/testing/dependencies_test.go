@@ -0,0 +1,109 @@
+package testing
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"pm-service/internal/repository/models"
+	"strings"
+	"testing"
+)
+
+func TestDependencies(t *testing.T) {
+	router := newRouter()
+
+	seed(t, router,
+		fixture{"/users", models.UserInput{Name: "bob", Email: "bob@mail.com", Role: "developer"}},
+		fixture{"/projects", models.ProjectInput{Title: "Alpha", Description: "first project", ManagerID: 1}},
+		fixture{"/tasks", models.TaskInput{Title: "Design", Priority: "high", Status: "in progress", AssigneeID: 1, ProjectID: 1, Estimate: 3}},
+		fixture{"/tasks", models.TaskInput{Title: "Build", Priority: "high", Status: "to do", AssigneeID: 1, ProjectID: 1, Estimate: 5}},
+		fixture{"/tasks", models.TaskInput{Title: "Test", Priority: "low", Status: "to do", AssigneeID: 1, ProjectID: 1, Estimate: 2}},
+		fixture{"/tasks", models.TaskInput{Title: "Docs", Priority: "low", Status: "to do", AssigneeID: 1, ProjectID: 1, Estimate: 8}},
+	)
+
+	type response struct {
+		BlockedBy    []*models.Task `json:"blocked_by"`
+		Blocks       []*models.Task `json:"blocks"`
+		Blocked      bool           `json:"blocked"`
+		CriticalPath []*models.Task `json:"critical_path"`
+		Estimate     int            `json:"estimate"`
+		Remaining    int            `json:"remaining"`
+	}
+
+	ids := func(tasks []*models.Task) []int {
+		ids := []int{}
+		for _, s := range tasks {
+			ids = append(ids, s.ID)
+		}
+		return ids
+	}
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		body     string
+		wantCode int
+		wantETag string
+		want     func(*response) bool
+	}{
+		{"negative estimate", http.MethodPost, "/tasks", `{"title": "Deploy", "priority": "low", "status": "to do", "assignee_id": 1, "project_id": 1, "estimate": -1}`, http.StatusUnprocessableEntity, "", nil},
+		{"build waits for design", http.MethodPost, "/tasks/2/dependencies", `{"blocker_id": 1}`, http.StatusCreated, "", nil},
+		{"test waits for build", http.MethodPost, "/tasks/3/dependencies", `{"blocker_id": 2}`, http.StatusCreated, "", nil},
+		{"existing link", http.MethodPost, "/tasks/3/dependencies", `{"blocker_id": 2}`, http.StatusCreated, "", nil},
+		{"itself", http.MethodPost, "/tasks/1/dependencies", `{"blocker_id": 1}`, http.StatusUnprocessableEntity, "", nil},
+		{"unknown blocker", http.MethodPost, "/tasks/1/dependencies", `{"blocker_id": 9}`, http.StatusUnprocessableEntity, "", nil},
+		{"cycle", http.MethodPost, "/tasks/1/dependencies", `{"blocker_id": 3}`, http.StatusUnprocessableEntity, "", nil},
+		{"unknown task", http.MethodPost, "/tasks/9/dependencies", `{"blocker_id": 1}`, http.StatusNotFound, "", nil},
+		{"dependencies", http.MethodGet, "/tasks/2/dependencies", "", http.StatusOK, "", func(r *response) bool {
+			return r.Blocked && fmt.Sprint(ids(r.BlockedBy)) == "[1]" && fmt.Sprint(ids(r.Blocks)) == "[3]"
+		}},
+		{"blocked task", http.MethodGet, "/tasks/2", "", http.StatusOK, `"1-blocked"`, nil},
+		{"critical path", http.MethodGet, "/projects/1/critical-path", "", http.StatusOK, "", func(r *response) bool {
+			return fmt.Sprint(ids(r.CriticalPath)) == "[1 2 3]" && r.Estimate == 10 && r.Remaining == 10
+		}},
+		{"complete blocker", http.MethodPost, "/tasks/1/transitions", `{"to": "completed"}`, http.StatusOK, "", nil},
+		{"unblocked task", http.MethodGet, "/tasks/2", "", http.StatusOK, `"1"`, nil},
+		{"no longer blocked", http.MethodGet, "/tasks/2/dependencies", "", http.StatusOK, "", func(r *response) bool {
+			return !r.Blocked && r.BlockedBy[0].Completed != nil
+		}},
+		{"remaining estimate", http.MethodGet, "/projects/1/critical-path", "", http.StatusOK, "", func(r *response) bool {
+			return r.Estimate == 10 && r.Remaining == 7
+		}},
+		{"remove link", http.MethodDelete, "/tasks/3/dependencies/2", "", http.StatusOK, "", nil},
+		{"remove missing link", http.MethodDelete, "/tasks/3/dependencies/2", "", http.StatusNotFound, "", nil},
+		{"shorter critical path", http.MethodGet, "/projects/1/critical-path", "", http.StatusOK, "", func(r *response) bool {
+			return fmt.Sprint(ids(r.CriticalPath)) == "[1 2]" && r.Estimate == 8
+		}},
+		{"unknown project", http.MethodGet, "/projects/9/critical-path", "", http.StatusNotFound, "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantCode {
+				t.Fatalf("handler returned wrong status code: got %v want %v: %s", rr.Code, tt.wantCode, rr.Body.String())
+			}
+
+			if tt.wantETag != "" && rr.Header().Get("ETag") != tt.wantETag {
+				t.Errorf("got ETag %s want %s", rr.Header().Get("ETag"), tt.wantETag)
+			}
+
+			if tt.want == nil {
+				return
+			}
+
+			var resp response
+			if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if !tt.want(&resp) {
+				t.Errorf("unexpected response: %s", rr.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package testing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"pm-service/internal/repository/mock"
+	"pm-service/internal/repository/models"
+	"testing"
+)
+
+func TestConditionalRequests(t *testing.T) {
+	router := newRouter()
+
+	seed(t, router,
+		fixture{"/users", models.UserInput{Name: "bob", Email: "bob@mail.com", Role: "developer"}},
+		fixture{"/projects", models.ProjectInput{Title: "Alpha", Description: "first project", ManagerID: 1}},
+		fixture{"/tasks", models.TaskInput{Title: "Report", Priority: "high", Status: "to do", AssigneeID: 1, ProjectID: 1}},
+	)
+
+	update, err := json.Marshal(models.TaskInput{Title: "Report", Priority: "low", Status: "in progress", AssigneeID: 1, ProjectID: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		header   string
+		value    string
+		body     []byte
+		wantCode int
+		wantETag string
+	}{
+		{"etag on show", http.MethodGet, "/tasks/1", "", "", nil, http.StatusOK, `"1"`},
+		{"not modified", http.MethodGet, "/tasks/1", "If-None-Match", `"1"`, nil, http.StatusNotModified, `"1"`},
+		{"weak not modified", http.MethodGet, "/tasks/1", "If-None-Match", `W/"1", "7"`, nil, http.StatusNotModified, `"1"`},
+		{"stale if-match update", http.MethodPut, "/tasks/1", "If-Match", `"2"`, update, http.StatusPreconditionFailed, ""},
+		{"if-match update", http.MethodPut, "/tasks/1", "If-Match", `"1"`, update, http.StatusOK, `"2"`},
+		{"modified since", http.MethodGet, "/tasks/1", "If-None-Match", `"1"`, nil, http.StatusOK, `"2"`},
+		{"unconditional update", http.MethodPut, "/tasks/1", "", "", update, http.StatusOK, `"3"`},
+		{"stale if-match delete", http.MethodDelete, "/tasks/1", "If-Match", `"2"`, nil, http.StatusPreconditionFailed, ""},
+		{"wildcard if-match delete", http.MethodDelete, "/tasks/1", "If-Match", "*", nil, http.StatusOK, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, bytes.NewReader(tt.body))
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantCode {
+				t.Fatalf("handler returned wrong status code: got %v want %v: %s", rr.Code, tt.wantCode, rr.Body.String())
+			}
+
+			if got := rr.Header().Get("ETag"); got != tt.wantETag {
+				t.Errorf("got ETag %s want %s", got, tt.wantETag)
+			}
+		})
+	}
+}
+
+func TestStaleUpdate(t *testing.T) {
+	ctx := context.Background()
+	tasks := &mock.TaskModel{DB: []*models.Task{{ID: 1, Version: 2}, {ID: 2, Version: 1}}}
+
+	if err := tasks.Delete(ctx, "2"); err != nil {
+		t.Fatal(err)
+	}
+
+	input := &models.TaskInput{Title: "Report"}
+
+	tests := []struct {
+		name    string
+		id      string
+		version int
+		want    error
+	}{
+		{"current version", "1", 2, nil},
+		{"stale version", "1", 2, models.ErrEditConflict},
+		{"trashed task", "2", 1, models.ErrNoRecord},
+		{"missing task", "9", 1, models.ErrNoRecord},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tasks.Update(ctx, tt.id, input, tt.version); err != tt.want {
+				t.Errorf("got %v want %v", err, tt.want)
+			}
+		})
+	}
+}
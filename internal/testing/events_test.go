@@ -0,0 +1,213 @@
+package testing
+
+import (
+	"context"
+	"errors"
+	"pm-service/internal/repository/mock"
+	"pm-service/internal/repository/models"
+	"pm-service/internal/service/audit"
+	"pm-service/internal/service/events"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEvents(t *testing.T) {
+	ctx := audit.WithRequestID(audit.WithActor(context.Background(), 7), "req-7")
+
+	users := &mock.UserModel{}
+	projects := &mock.ProjectModel{Users: users}
+	tasks := &mock.TaskModel{Users: users, Projects: projects}
+	outbox := &mock.OutboxModel{}
+	changes := &mock.AuditModel{Users: users, Projects: projects, Tasks: tasks, Outbox: outbox}
+	users.Audit, projects.Audit, tasks.Audit = changes, changes, changes
+
+	mustDo := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err := users.Insert(ctx, &models.UserInput{Name: "bob", Email: "bob@mail.com", Role: "developer"})
+	mustDo(err)
+	_, err = users.Insert(ctx, &models.UserInput{Name: "eve", Email: "eve@mail.com", Role: "manager"})
+	mustDo(err)
+	_, err = projects.Insert(ctx, &models.ProjectInput{Title: "Alpha", ManagerID: 2})
+	mustDo(err)
+	_, err = tasks.Insert(ctx, &models.TaskInput{Title: "Report", Priority: "high", Status: "to do", AssigneeID: 1, ProjectID: 1})
+	mustDo(err)
+	_, err = tasks.Update(ctx, "1", &models.TaskInput{Title: "Report", Priority: "high", Status: "done", AssigneeID: 2, ProjectID: 1, Completed: "2024-05-01"}, 1)
+	mustDo(err)
+	_, err = projects.Update(ctx, "1", &models.ProjectInput{Title: "Alpha", ManagerID: 2, Completed: "2024-05-02"}, 1)
+	mustDo(err)
+	_, err = tasks.Update(ctx, "1", &models.TaskInput{Title: "Report", Priority: "high", Status: "done", AssigneeID: 2, ProjectID: 1, Completed: "2024-05-01"}, 2)
+	mustDo(err)
+	mustDo(users.Delete(ctx, "1"))
+
+	bus := &events.Bus{}
+
+	var names []string
+	bus.Subscribe(events.All, func(ctx context.Context, e events.Event) error {
+		names = append(names, e.Name())
+		return nil
+	})
+
+	var assigned []events.TaskAssigned
+	bus.Subscribe("task.assigned", func(ctx context.Context, e events.Event) error {
+		if id := audit.Actor(ctx); id == nil || *id != 7 || audit.RequestID(ctx) != "req-7" {
+			t.Errorf("handler got actor %v and request id %q want 7 and req-7", id, audit.RequestID(ctx))
+		}
+		assigned = append(assigned, e.(events.TaskAssigned))
+		return nil
+	})
+
+	failed := errors.New("search index unavailable")
+	var indexed []int
+	bus.Subscribe("project.completed", func(ctx context.Context, e events.Event) error {
+		if indexed == nil {
+			indexed = []int{}
+			return failed
+		}
+		indexed = append(indexed, events.EventID(ctx))
+		return nil
+	})
+
+	relay := &events.Relay{Outbox: outbox, Bus: bus, BatchSize: 4}
+
+	relayed, failures := 0, 0
+	for {
+		n, err := relay.Relay(ctx)
+		if errors.Is(err, failed) {
+			failures++
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		if n == 0 {
+			break
+		}
+		relayed += n
+	}
+
+	want := []string{
+		"user.created", "change.recorded", "user.created", "change.recorded",
+		"project.created", "change.recorded", "task.created", "change.recorded",
+		"task.updated", "task.assigned", "task.status_changed", "task.completed", "change.recorded",
+		"project.updated", "project.completed", "change.recorded",
+		"user.deleted", "change.recorded",
+	}
+
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("got events %v want %v", names, want)
+	}
+	if relayed != len(want) {
+		t.Errorf("relayed %d events want %d", relayed, len(want))
+	}
+	if failures != 1 {
+		t.Errorf("got %d failing batches want 1", failures)
+	}
+	if len(assigned) != 1 || assigned[0] != (events.TaskAssigned{TaskID: 1, ProjectID: 1, From: 1, To: 2}) {
+		t.Errorf("got %+v want task 1 reassigned from 1 to 2", assigned)
+	}
+
+	// only the event whose handler failed is left, held back for a retry
+	var pending []*models.OutboxEvent
+	for _, o := range outbox.DB {
+		if o.Published == nil {
+			pending = append(pending, o)
+		}
+	}
+	if len(pending) != 1 || pending[0].Name != "project.completed" || !pending[0].NextAttempt.After(time.Now()) {
+		t.Fatalf("got %+v unpublished want project.completed held back", pending)
+	}
+
+	pending[0].NextAttempt = time.Now()
+	if n, err := relay.Relay(ctx); n != 1 || err != nil {
+		t.Fatalf("relayed %d events, %v on retry want 1", n, err)
+	}
+	if pending[0].Published == nil || len(indexed) != 1 || indexed[0] != pending[0].ID {
+		t.Errorf("retry handled events %v, published at %v want event %d published", indexed, pending[0].Published, pending[0].ID)
+	}
+}
+
+func TestEventBus(t *testing.T) {
+	bus := &events.Bus{}
+	failed := errors.New("unavailable")
+
+	var calls []string
+	bus.Subscribe("user.role_changed", func(ctx context.Context, e events.Event) error {
+		calls = append(calls, "first")
+		return failed
+	})
+	bus.Subscribe(events.All, func(ctx context.Context, e events.Event) error {
+		calls = append(calls, "all")
+		return nil
+	})
+	bus.Subscribe("user.role_changed", func(ctx context.Context, e events.Event) error {
+		calls = append(calls, "second")
+		return nil
+	})
+
+	err := bus.Publish(context.Background(), events.UserRoleChanged{UserID: 1, From: "developer", To: "manager"})
+	if !errors.Is(err, failed) {
+		t.Errorf("got error %v want %v", err, failed)
+	}
+	if want := []string{"first", "second", "all"}; !reflect.DeepEqual(calls, want) {
+		t.Errorf("got calls %v want %v", calls, want)
+	}
+
+	calls = nil
+	if err := bus.Publish(context.Background(), events.UserDeleted{UserID: 1}); err != nil || !reflect.DeepEqual(calls, []string{"all"}) {
+		t.Errorf("got calls %v and error %v want only all", calls, err)
+	}
+
+	if _, err := events.Decode("user.renamed", []byte(`{}`)); err == nil {
+		t.Error("decoded an unknown event")
+	}
+}
+
+func TestEventRelayGivesUp(t *testing.T) {
+	ctx := context.Background()
+
+	users := &mock.UserModel{}
+	outbox := &mock.OutboxModel{}
+	users.Audit = &mock.AuditModel{Users: users, Outbox: outbox}
+
+	if _, err := users.Insert(ctx, &models.UserInput{Name: "bob", Email: "bob@mail.com", Role: "developer"}); err != nil {
+		t.Fatal(err)
+	}
+	outbox.DB = append(outbox.DB, &models.OutboxEvent{ID: 99, Name: "user.renamed", Payload: []byte(`{}`), NextAttempt: time.Now()})
+
+	failed := errors.New("unavailable")
+	bus := &events.Bus{}
+	bus.Subscribe("user.created", func(ctx context.Context, e events.Event) error {
+		return failed
+	})
+
+	relay := &events.Relay{Outbox: outbox, Bus: bus, BatchSize: 10, MaxAttempts: 2}
+
+	for attempt := 1; attempt <= 3; attempt++ {
+		for _, o := range outbox.DB {
+			o.NextAttempt = time.Now()
+		}
+		if _, err := relay.Relay(ctx); attempt < 3 && !errors.Is(err, failed) {
+			t.Fatalf("attempt %d: got error %v want %v", attempt, err, failed)
+		}
+	}
+
+	for _, o := range outbox.DB {
+		var want int
+		switch o.Name {
+		case "user.created":
+			want = 2
+		case "user.renamed":
+			// undecodable events are given up on at once
+			want = 1
+		default:
+			continue
+		}
+		if o.Published != nil || o.Failed == nil || o.Attempts != want {
+			t.Errorf("%s: published %v, failed %v after %d attempts want failed after %d", o.Name, o.Published, o.Failed, o.Attempts, want)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package testing
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"pm-service/internal/config"
+	"pm-service/internal/handlers"
+	"testing"
+)
+
+// newRouter returns the routes over fresh mock repositories, signed in as
+// the mock's administrator.
+func newRouter() http.Handler {
+	return signedIn(config.Routing(handlers.Mock()))
+}
+
+// signedIn signs requests that carry no credentials of their own in as the
+// mock's administrator.
+func signedIn(router http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" && r.Header.Get("X-API-Key") == "" {
+			r.Header.Set("X-API-Key", handlers.MockAPIKey)
+		}
+		router.ServeHTTP(w, r)
+	})
+}
+
+type fixture struct {
+	path  string
+	input interface{}
+}
+
+// seed POSTs each fixture in order and fails the test unless it is created.
+func seed(t *testing.T, router http.Handler, fixtures ...fixture) {
+	t.Helper()
+
+	for _, f := range fixtures {
+		body, err := json.Marshal(f.input)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, f.path, bytes.NewReader(body)))
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("seeding %s returned %d: %s", f.path, rr.Code, rr.Body.String())
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package testing
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"pm-service/internal/repository/models"
+	"strings"
+	"testing"
+)
+
+func TestLabels(t *testing.T) {
+	router := newRouter()
+
+	seed(t, router,
+		fixture{"/users", models.UserInput{Name: "bob", Email: "bob@mail.com", Role: "developer"}},
+		fixture{"/projects", models.ProjectInput{Title: "Alpha", Description: "first project", ManagerID: 1}},
+		fixture{"/projects", models.ProjectInput{Title: "Beta", Description: "second project", ManagerID: 1}},
+		fixture{"/tasks", models.TaskInput{Title: "Login", Priority: "high", Status: "to do", AssigneeID: 1, ProjectID: 1}},
+		fixture{"/tasks", models.TaskInput{Title: "Crash", Priority: "high", Status: "to do", AssigneeID: 1, ProjectID: 1}},
+		fixture{"/tasks", models.TaskInput{Title: "Theme", Priority: "low", Status: "to do", AssigneeID: 1, ProjectID: 1}},
+		fixture{"/projects/1/labels", models.LabelInput{Name: "bug", Color: "#d73a4a"}},
+		fixture{"/projects/1/labels", models.LabelInput{Name: "UI", Color: "#1e90ff"}},
+		fixture{"/projects/1/labels", models.LabelInput{Name: "urgent", Color: "#ff0000"}},
+		fixture{"/projects/2/labels", models.LabelInput{Name: "bug", Color: "#d73a4a"}},
+	)
+
+	for _, path := range []string{"/tasks/1/labels/1", "/tasks/1/labels/2", "/tasks/2/labels/1", "/tasks/2/labels/3", "/tasks/3/labels/2"} {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(http.MethodPut, path, nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("labelling %s returned %d: %s", path, rr.Code, rr.Body.String())
+		}
+	}
+
+	type response struct {
+		Labels []*models.Label `json:"labels"`
+		Tasks  []*models.Task  `json:"tasks"`
+	}
+
+	tasks := func(r *response) string {
+		ids := []int{}
+		for _, s := range r.Tasks {
+			ids = append(ids, s.ID)
+		}
+		return fmt.Sprint(ids)
+	}
+
+	names := func(r *response) string {
+		names := []string{}
+		for _, l := range r.Labels {
+			names = append(names, l.Name)
+		}
+		return strings.Join(names, ",")
+	}
+
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		contentType string
+		body        string
+		wantCode    int
+		want        func(*response) bool
+	}{
+		{"duplicate name", http.MethodPost, "/projects/1/labels", "", `{"name": "BUG", "color": "#000000"}`, http.StatusUnprocessableEntity, nil},
+		{"bad color", http.MethodPost, "/projects/1/labels", "", `{"name": "docs", "color": "blue"}`, http.StatusUnprocessableEntity, nil},
+		{"comma in name", http.MethodPost, "/projects/1/labels", "", `{"name": "a,b", "color": "#000000"}`, http.StatusUnprocessableEntity, nil},
+		{"unknown project", http.MethodPost, "/projects/9/labels", "", `{"name": "docs", "color": "#000000"}`, http.StatusNotFound, nil},
+		{"label of another project", http.MethodGet, "/projects/1/labels/4", "", "", http.StatusNotFound, nil},
+		{"attach label of another project", http.MethodPut, "/tasks/1/labels/4", "", "", http.StatusUnprocessableEntity, nil},
+		{"attach twice", http.MethodPut, "/tasks/1/labels/1", "", "", http.StatusOK, func(r *response) bool {
+			return names(r) == "bug,UI"
+		}},
+		{"task labels", http.MethodGet, "/tasks/2/labels", "", "", http.StatusOK, func(r *response) bool {
+			return names(r) == "bug,urgent"
+		}},
+		{"label", http.MethodGet, "/tasks/search?label=BUG", "", "", http.StatusOK, func(r *response) bool {
+			return tasks(r) == "[1 2]"
+		}},
+		{"negated label", http.MethodGet, "/tasks/search?label=!bug", "", "", http.StatusOK, func(r *response) bool {
+			return tasks(r) == "[3]"
+		}},
+		{"any label", http.MethodGet, "/tasks/search?label_any=ui,urgent", "", "", http.StatusOK, func(r *response) bool {
+			return tasks(r) == "[1 2 3]"
+		}},
+		{"all labels", http.MethodGet, "/tasks/search?label_all=bug,urgent", "", "", http.StatusOK, func(r *response) bool {
+			return tasks(r) == "[2]"
+		}},
+		{"rename", http.MethodPut, "/projects/1/labels/2", "", `{"name": "frontend", "color": "#00ff00"}`, http.StatusOK, nil},
+		{"rename to taken name", http.MethodPut, "/projects/1/labels/2", "", `{"name": "Urgent", "color": "#00ff00"}`, http.StatusUnprocessableEntity, nil},
+		{"renamed label", http.MethodGet, "/tasks/search?label=frontend&priority=low", "", "", http.StatusOK, func(r *response) bool {
+			return tasks(r) == "[3]"
+		}},
+		{"move task", http.MethodPatch, "/tasks/2", "application/merge-patch+json", `{"project_id": 2}`, http.StatusOK, nil},
+		{"labels of old project", http.MethodGet, "/tasks/search?label_all=bug,urgent", "", "", http.StatusOK, func(r *response) bool {
+			return tasks(r) == "[]"
+		}},
+		{"detach", http.MethodDelete, "/tasks/1/labels/1", "", "", http.StatusOK, nil},
+		{"detach again", http.MethodDelete, "/tasks/1/labels/1", "", "", http.StatusNotFound, nil},
+		{"delete label", http.MethodDelete, "/projects/1/labels/3", "", "", http.StatusOK, nil},
+		{"project labels", http.MethodGet, "/projects/1/labels?sort=name", "", "", http.StatusOK, func(r *response) bool {
+			return names(r) == "bug,frontend"
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantCode {
+				t.Fatalf("handler returned wrong status code: got %v want %v: %s", rr.Code, tt.wantCode, rr.Body.String())
+			}
+
+			if tt.want == nil {
+				return
+			}
+
+			var resp response
+			if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if !tt.want(&resp) {
+				t.Errorf("unexpected response: %s", rr.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package testing
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"pm-service/internal/repository/models"
+	"strings"
+	"testing"
+)
+
+func TestMembers(t *testing.T) {
+	router := newRouter()
+
+	seed(t, router,
+		fixture{"/users", models.UserInput{Name: "bob", Email: "bob@mail.com", Role: "manager"}},
+		fixture{"/users", models.UserInput{Name: "eve", Email: "eve@mail.com", Role: "developer"}},
+		fixture{"/users", models.UserInput{Name: "dan", Email: "dan@mail.com", Role: "developer"}},
+		fixture{"/projects", models.ProjectInput{Title: "Alpha", Description: "first project", ManagerID: 1}},
+		fixture{"/projects", models.ProjectInput{Title: "Beta", Description: "second project", ManagerID: 3}},
+	)
+
+	type response struct {
+		Member   *models.Member       `json:"member"`
+		Members  []*models.Member     `json:"members"`
+		Projects []*models.Membership `json:"projects"`
+		Error    map[string]string    `json:"error"`
+	}
+
+	task := func(assignee string) string {
+		return `{"title": "Report", "priority": "high", "status": "to do", "assignee_id": ` + assignee + `, "project_id": 1}`
+	}
+
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		contentType string
+		body        string
+		wantCode    int
+		want        func(*response) bool
+	}{
+		{"manager owns project", http.MethodGet, "/projects/1/members", "", "", http.StatusOK, func(r *response) bool {
+			return len(r.Members) == 1 && r.Members[0].UserID == 1 && r.Members[0].Role == "owner"
+		}},
+		{"assignee not a member", http.MethodPost, "/tasks", "", task("2"), http.StatusUnprocessableEntity, func(r *response) bool {
+			return r.Error["assignee_id"] != ""
+		}},
+		{"add member", http.MethodPost, "/projects/1/members", "", `{"user_id": 2, "role": "member"}`, http.StatusCreated, func(r *response) bool {
+			return r.Member.UserID == 2 && r.Member.Role == "member"
+		}},
+		{"add member twice", http.MethodPost, "/projects/1/members", "", `{"user_id": 2, "role": "viewer"}`, http.StatusUnprocessableEntity, nil},
+		{"unknown role", http.MethodPost, "/projects/1/members", "", `{"user_id": 3, "role": "admin"}`, http.StatusUnprocessableEntity, nil},
+		{"unknown user", http.MethodPost, "/projects/1/members", "", `{"user_id": 9, "role": "member"}`, http.StatusUnprocessableEntity, nil},
+		{"add viewer", http.MethodPost, "/projects/1/members", "", `{"user_id": 3, "role": "viewer"}`, http.StatusCreated, nil},
+		{"viewer as assignee", http.MethodPost, "/tasks", "", task("3"), http.StatusUnprocessableEntity, nil},
+		{"member as assignee", http.MethodPost, "/tasks", "", task("2"), http.StatusCreated, nil},
+		{"move task away from assignee", http.MethodPatch, "/tasks/1", "application/merge-patch+json", `{"project_id": 2}`, http.StatusUnprocessableEntity, func(r *response) bool {
+			return r.Error["project_id"] != ""
+		}},
+		{"demote assignee to viewer", http.MethodPut, "/projects/1/members/2", "", `{"role": "viewer"}`, http.StatusConflict, nil},
+		{"remove assignee", http.MethodDelete, "/projects/1/members/2", "", "", http.StatusConflict, nil},
+		{"demote manager", http.MethodPut, "/projects/1/members/1", "", `{"role": "member"}`, http.StatusConflict, nil},
+		{"member as manager", http.MethodPatch, "/projects/1", "application/merge-patch+json", `{"manager_id": 2}`, http.StatusUnprocessableEntity, nil},
+		{"promote member", http.MethodPut, "/projects/1/members/2", "", `{"role": "manager"}`, http.StatusOK, func(r *response) bool {
+			return r.Member.Role == "manager"
+		}},
+		{"hand over project", http.MethodPatch, "/projects/1", "application/merge-patch+json", `{"manager_id": 2}`, http.StatusOK, nil},
+		{"remove viewer", http.MethodDelete, "/projects/1/members/3", "", "", http.StatusOK, nil},
+		{"remove viewer again", http.MethodDelete, "/projects/1/members/3", "", "", http.StatusNotFound, nil},
+		{"members", http.MethodGet, "/projects/1/members", "", "", http.StatusOK, func(r *response) bool {
+			return len(r.Members) == 2 && r.Members[0].UserID == 1 && r.Members[1].Role == "manager"
+		}},
+		{"user projects", http.MethodGet, "/users/3/projects", "", "", http.StatusOK, func(r *response) bool {
+			return len(r.Projects) == 1 && r.Projects[0].Title == "Beta" && r.Projects[0].Role == "owner"
+		}},
+		{"unknown user projects", http.MethodGet, "/users/9/projects", "", "", http.StatusNotFound, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantCode {
+				t.Fatalf("handler returned wrong status code: got %v want %v: %s", rr.Code, tt.wantCode, rr.Body.String())
+			}
+
+			if tt.want == nil {
+				return
+			}
+
+			var resp response
+			if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if !tt.want(&resp) {
+				t.Errorf("unexpected response: %s", rr.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package testing
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"pm-service/internal/repository/migrate"
+	"strings"
+	"testing"
+)
+
+func migrationDir(t *testing.T, files ...string) string {
+	dir := t.TempDir()
+	for _, name := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("SELECT 1;"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestMigrateLoad(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    []string
+		want     string
+		wantDown []bool
+		wantErr  string
+	}{
+		{"empty", nil, "", nil, ""},
+		{"ordered by version not name", []string{
+			"00010_add_labels.up.sql", "00002_create_tasks.up.sql", "00002_create_tasks.down.sql", "00001_create_users.up.sql", "00001_create_users.down.sql",
+		}, "1 create_users, 2 create_tasks, 10 add_labels", []bool{true, true, false}, ""},
+		{"other files skipped", []string{
+			"00001_create_users.up.sql", "README.md", "00002_Create.up.sql", "2_tasks.sideways.sql",
+		}, "1 create_users", []bool{false}, ""},
+		{"versions compared as numbers", []string{"9_nine.up.sql", "00010_ten.up.sql"}, "9 nine, 10 ten", []bool{false, false}, ""},
+		{"version reused", []string{"00001_users.up.sql", "00001_tasks.up.sql"}, "", nil, "version 1 used by both"},
+		{"down without up", []string{"00001_users.up.sql", "00002_tasks.down.sql"}, "", nil, "version 2 has no up script"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := migrationDir(t, tt.files...)
+
+			migrations, err := migrate.New(nil, dir).Load()
+
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for i, mig := range migrations {
+				got = append(got, fmt.Sprintf("%d %s", mig.Version, mig.Name))
+				if filepath.Dir(mig.Up) != dir || !strings.HasSuffix(mig.Up, ".up.sql") {
+					t.Errorf("migration %d has up script %q", mig.Version, mig.Up)
+				}
+				if (mig.Down != "") != tt.wantDown[i] {
+					t.Errorf("migration %d has down script %q", mig.Version, mig.Down)
+				}
+			}
+
+			if strings.Join(got, ", ") != tt.want {
+				t.Errorf("got %q want %q", strings.Join(got, ", "), tt.want)
+			}
+		})
+	}
+}
+
+func TestMigrateLoadMissingDir(t *testing.T) {
+	if _, err := migrate.New(nil, filepath.Join(t.TempDir(), "missing")).Load(); !os.IsNotExist(err) {
+		t.Errorf("got error %v want not exist", err)
+	}
+}
+
+func TestMigrateCreate(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   []string
+		input   string
+		want    string
+		wantErr string
+	}{
+		{"first migration", nil, "create users", "00001_create_users", ""},
+		{"next free version", []string{"00001_a.up.sql", "00007_b.up.sql"}, "add_labels", "00008_add_labels", ""},
+		{"name normalised", nil, "  Add Due-Date, Index!  ", "00001_add_due_date_index", ""},
+		{"empty name", nil, " -- ", "", "must not be empty"},
+		{"broken directory", []string{"00001_a.down.sql"}, "next", "", "has no up script"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := migrationDir(t, tt.files...)
+			m := migrate.New(nil, dir)
+
+			paths, err := m.Create(tt.input)
+
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want := []string{filepath.Join(dir, tt.want+".up.sql"), filepath.Join(dir, tt.want+".down.sql")}
+			if strings.Join(paths, " ") != strings.Join(want, " ") {
+				t.Fatalf("got %v want %v", paths, want)
+			}
+			for _, path := range paths {
+				if info, err := os.Stat(path); err != nil || info.Size() != 0 {
+					t.Errorf("%s is not an empty file: %v", path, err)
+				}
+			}
+
+			// The new pair is picked up by Load as the latest migration.
+			migrations, err := m.Load()
+			if err != nil {
+				t.Fatal(err)
+			}
+			last := migrations[len(migrations)-1]
+			if last.Up != want[0] || last.Down != want[1] {
+				t.Errorf("latest migration is %+v", last)
+			}
+		})
+	}
+}
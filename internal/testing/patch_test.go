@@ -0,0 +1,110 @@
+package testing
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"pm-service/internal/repository/models"
+	"strings"
+	"testing"
+)
+
+func TestPatchTask(t *testing.T) {
+	router := newRouter()
+
+	seed(t, router,
+		fixture{"/users", models.UserInput{Name: "bob", Email: "bob@mail.com", Role: "developer"}},
+		fixture{"/projects", models.ProjectInput{Title: "Alpha", Description: "first project", ManagerID: 1}},
+		fixture{"/tasks", models.TaskInput{Title: "Report", Description: "quarterly", Priority: "high", Status: "to do", AssigneeID: 1, ProjectID: 1}},
+	)
+
+	const (
+		mergePatch = "application/merge-patch+json"
+		jsonPatch  = "application/json-patch+json"
+	)
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		wantCode    int
+		wantErrors  []string
+		want        func(*models.Task) bool
+	}{
+		{"merge patch", mergePatch, `{"status": "in progress"}`, http.StatusOK, nil, func(s *models.Task) bool {
+			return s.Status == "in progress" && s.Title == "Report" && s.Description == "quarterly" && s.Version == 2
+		}},
+		{"set completed", mergePatch, `{"completed": "2024-05-01"}`, http.StatusOK, nil, func(s *models.Task) bool {
+			return s.Completed != nil && s.Completed.Format("2006-01-02") == "2024-05-01"
+		}},
+		{"null clears completed", mergePatch, `{"completed": null}`, http.StatusOK, nil, func(s *models.Task) bool {
+			return s.Completed == nil
+		}},
+		{"no changes", mergePatch, `{"title": "Report"}`, http.StatusOK, nil, func(s *models.Task) bool {
+			return s.Version == 4
+		}},
+		{"only changed fields validated", mergePatch, `{"title": "", "assignee_id": 9}`, http.StatusUnprocessableEntity, []string{"assignee_id", "title"}, nil},
+		{"unknown field", mergePatch, `{"owner": 1}`, http.StatusBadRequest, nil, nil},
+		{"json patch", jsonPatch, `[{"op": "test", "path": "/priority", "value": "high"}, {"op": "replace", "path": "/priority", "value": "low"}]`, http.StatusOK, nil, func(s *models.Task) bool {
+			return s.Priority == "low" && s.Version == 5
+		}},
+		{"failed test op", jsonPatch, `[{"op": "test", "path": "/priority", "value": "high"}, {"op": "replace", "path": "/title", "value": "Lost"}]`, http.StatusConflict, nil, func(s *models.Task) bool {
+			return s.Title == "Report"
+		}},
+		{"json patch sets completed", jsonPatch, `[{"op": "replace", "path": "/completed", "value": "2024-06-01"}]`, http.StatusOK, nil, func(s *models.Task) bool {
+			return s.Completed != nil && s.Completed.Format("2006-01-02") == "2024-06-01"
+		}},
+		{"json patch null value", jsonPatch, `[{"op": "replace", "path": "/completed", "value": null}, {"op": "test", "path": "/completed", "value": null}]`, http.StatusOK, nil, func(s *models.Task) bool {
+			return s.Completed == nil
+		}},
+		{"missing value", jsonPatch, `[{"op": "replace", "path": "/title"}]`, http.StatusBadRequest, nil, nil},
+		{"nested path", jsonPatch, `[{"op": "replace", "path": "/title/0", "value": "x"}]`, http.StatusBadRequest, nil, nil},
+		{"unsupported media type", "text/plain", `{"status": "completed"}`, http.StatusUnsupportedMediaType, nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPatch, "/tasks/1", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantCode {
+				t.Fatalf("handler returned wrong status code: got %v want %v: %s", rr.Code, tt.wantCode, rr.Body.String())
+			}
+
+			if tt.wantErrors != nil {
+				var resp struct {
+					Error map[string]string `json:"error"`
+				}
+				if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+					t.Fatal(err)
+				}
+				if len(resp.Error) != len(tt.wantErrors) {
+					t.Errorf("got errors %v want keys %v", resp.Error, tt.wantErrors)
+				}
+				for _, key := range tt.wantErrors {
+					if _, ok := resp.Error[key]; !ok {
+						t.Errorf("missing error for %s: %v", key, resp.Error)
+					}
+				}
+			}
+
+			if tt.want == nil {
+				return
+			}
+
+			rr = httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/tasks/1", nil))
+
+			var task models.Task
+			if err := json.Unmarshal(rr.Body.Bytes(), &task); err != nil {
+				t.Fatal(err)
+			}
+			if !tt.want(&task) {
+				t.Errorf("unexpected task after patch: %s", rr.Body.String())
+			}
+		})
+	}
+}
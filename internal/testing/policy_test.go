@@ -0,0 +1,164 @@
+package testing
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"pm-service/internal/config"
+	"pm-service/internal/handlers"
+	"pm-service/internal/repository/mock"
+	"pm-service/internal/repository/models"
+	"pm-service/internal/service/policy"
+	"strings"
+	"testing"
+)
+
+func TestPolicy(t *testing.T) {
+	var (
+		admin   = &models.User{ID: 1, Role: "admin"}
+		maria   = &models.User{ID: 2, Role: "manager"}
+		dan     = &models.User{ID: 3, Role: "developer"}
+		vera    = &models.User{ID: 4, Role: "viewer"}
+		eve     = &models.User{ID: 5, Role: "developer"}
+		lead    = &models.User{ID: 6, Role: "developer"}
+		project = &models.Project{ID: 1, ManagerID: maria.ID}
+		other   = &models.Project{ID: 2, ManagerID: eve.ID}
+		task    = &models.Task{ID: 1, ProjectID: project.ID, AssigneeID: dan.ID}
+		comment = &models.Comment{ID: 1, TaskID: task.ID, AuthorID: dan.ID}
+	)
+
+	p := &policy.Policy{
+		Projects: &mock.ProjectModel{DB: []*models.Project{project, other}},
+		Members: &mock.MemberModel{DB: []*models.Member{
+			{ProjectID: project.ID, UserID: maria.ID, Role: "owner"},
+			{ProjectID: project.ID, UserID: dan.ID, Role: "member"},
+			{ProjectID: project.ID, UserID: vera.ID, Role: "viewer"},
+			{ProjectID: project.ID, UserID: lead.ID, Role: "manager"},
+		}},
+	}
+
+	ctx := context.Background()
+	moved := func(projectID int) *models.TaskInput { return &models.TaskInput{ProjectID: projectID} }
+
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"admin", p.Admin(admin), nil},
+		{"admin as manager", p.Admin(maria), policy.ErrForbidden},
+		{"update self", p.UpdateUser(dan, dan, &models.UserInput{Role: "developer"}), nil},
+		{"promote self", p.UpdateUser(dan, dan, &models.UserInput{Role: "admin"}), policy.ErrForbidden},
+		{"update other user", p.UpdateUser(maria, dan, &models.UserInput{Role: "developer"}), policy.ErrForbidden},
+		{"admin changes role", p.UpdateUser(admin, dan, &models.UserInput{Role: "manager"}), nil},
+		{"manager creates own project", p.CreateProject(maria, &models.ProjectInput{ManagerID: maria.ID}), nil},
+		{"manager creates project for other", p.CreateProject(maria, &models.ProjectInput{ManagerID: dan.ID}), policy.ErrForbidden},
+		{"developer creates project", p.CreateProject(dan, &models.ProjectInput{ManagerID: dan.ID}), policy.ErrForbidden},
+		{"manager_id owns project", p.OwnProject(maria, project), nil},
+		{"manager member owns project", p.OwnProject(lead, project), policy.ErrForbidden},
+		{"admin owns project", p.OwnProject(admin, project), nil},
+		{"manager member manages project", p.ManageProject(ctx, lead, project.ID), nil},
+		{"member manages project", p.ManageProject(ctx, dan, project.ID), policy.ErrForbidden},
+		{"non-member manages project", p.ManageProject(ctx, eve, project.ID), policy.ErrForbidden},
+		{"manage unknown project", p.ManageProject(ctx, dan, 9), policy.ErrForbidden},
+		{"member creates task", p.CreateTask(ctx, dan, project.ID), nil},
+		{"viewer member creates task", p.CreateTask(ctx, vera, project.ID), policy.ErrForbidden},
+		{"non-member creates task", p.CreateTask(ctx, eve, project.ID), policy.ErrForbidden},
+		{"assignee changes status", p.UpdateTask(ctx, dan, task, moved(project.ID), []string{"status"}), nil},
+		{"assignee changes title", p.UpdateTask(ctx, dan, task, moved(project.ID), []string{"status", "title"}), policy.ErrForbidden},
+		{"member changes status of other's task", p.UpdateTask(ctx, dan, &models.Task{ProjectID: project.ID, AssigneeID: lead.ID}, moved(project.ID), []string{"status"}), policy.ErrForbidden},
+		{"manager changes title", p.UpdateTask(ctx, lead, task, moved(project.ID), []string{"title"}), nil},
+		{"manager moves task to unmanaged project", p.UpdateTask(ctx, lead, task, moved(other.ID), []string{"project_id"}), policy.ErrForbidden},
+		{"admin moves task", p.UpdateTask(ctx, admin, task, moved(other.ID), []string{"project_id"}), nil},
+		{"comment as self", p.Comment(dan, &models.CommentInput{AuthorID: dan.ID}), nil},
+		{"comment as other", p.Comment(dan, &models.CommentInput{AuthorID: maria.ID}), policy.ErrForbidden},
+		{"viewer comments", p.Comment(vera, &models.CommentInput{AuthorID: vera.ID}), policy.ErrForbidden},
+		{"admin comments as other", p.Comment(admin, &models.CommentInput{AuthorID: dan.ID}), nil},
+		{"author edits comment", p.EditComment(dan, comment), nil},
+		{"manager edits comment", p.EditComment(maria, comment), policy.ErrForbidden},
+		{"manager deletes comment", p.DeleteComment(ctx, lead, comment, task), nil},
+		{"non-member deletes comment", p.DeleteComment(ctx, eve, comment, task), policy.ErrForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.err != tt.want {
+				t.Errorf("got %v want %v", tt.err, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthorization(t *testing.T) {
+	router := config.Routing(handlers.Mock())
+
+	seed(t, signedIn(router),
+		fixture{"/users", models.UserInput{Name: "maria", Email: "maria@mail.com", Role: "manager", Password: "correct horse"}},
+		fixture{"/users", models.UserInput{Name: "dan", Email: "dan@mail.com", Role: "developer", Password: "correct horse"}},
+		fixture{"/users", models.UserInput{Name: "vera", Email: "vera@mail.com", Role: "viewer", Password: "correct horse"}},
+		fixture{"/projects", models.ProjectInput{Title: "Alpha", Description: "first project", ManagerID: 1}},
+		fixture{"/projects/1/members", models.MemberInput{UserID: 2, Role: "member"}},
+		fixture{"/projects/1/members", models.MemberInput{UserID: 3, Role: "viewer"}},
+		fixture{"/tasks", models.TaskInput{Title: "Report", Description: "quarterly", Priority: "high", Status: "to do", AssigneeID: 2, ProjectID: 1}},
+	)
+
+	tokens := make(map[string]string)
+	for _, name := range []string{"maria", "dan", "vera"} {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/auth/login", strings.NewReader(`{"email": "`+name+`@mail.com", "password": "correct horse"}`)))
+
+		var resp struct {
+			AccessToken string `json:"access_token"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil || resp.AccessToken == "" {
+			t.Fatalf("login as %s returned %d: %s", name, rr.Code, rr.Body.String())
+		}
+		tokens[name] = resp.AccessToken
+	}
+
+	tests := []struct {
+		name     string
+		user     string
+		method   string
+		path     string
+		body     string
+		wantCode int
+	}{
+		{"viewer reads", "vera", http.MethodGet, "/tasks/1", "", http.StatusOK},
+		{"viewer comments", "vera", http.MethodPost, "/tasks/1/comments", `{"author_id": 3, "body": "looks good"}`, http.StatusForbidden},
+		{"developer creates user", "dan", http.MethodPost, "/users", `{"name": "eve", "email": "eve@mail.com", "role": "admin"}`, http.StatusForbidden},
+		{"developer promotes self", "dan", http.MethodPatch, "/users/2", `{"role": "admin"}`, http.StatusForbidden},
+		{"developer renames self", "dan", http.MethodPatch, "/users/2", `{"name": "daniel"}`, http.StatusOK},
+		{"developer updates project", "dan", http.MethodPatch, "/projects/1", `{"title": "Omega"}`, http.StatusForbidden},
+		{"assignee changes title", "dan", http.MethodPatch, "/tasks/1", `{"title": "Summary"}`, http.StatusForbidden},
+		{"assignee changes status", "dan", http.MethodPatch, "/tasks/1", `{"status": "in progress"}`, http.StatusOK},
+		{"assignee deletes task", "dan", http.MethodDelete, "/tasks/1", "", http.StatusForbidden},
+		{"developer creates task in unknown project", "dan", http.MethodPost, "/tasks", `{"title": "Probe", "description": "x", "priority": "high", "status": "to do", "assignee_id": 9, "project_id": 9}`, http.StatusForbidden},
+		{"developer restores from trash", "dan", http.MethodPost, "/tasks/1/restore", "", http.StatusForbidden},
+		{"developer reads audit log", "dan", http.MethodGet, "/audit", "", http.StatusForbidden},
+		{"developer reads task history", "dan", http.MethodGet, "/tasks/1/history", "", http.StatusOK},
+		{"developer lists webhooks", "dan", http.MethodGet, "/webhooks", "", http.StatusForbidden},
+		{"manager creates webhook", "maria", http.MethodPost, "/webhooks", `{"url": "https://hooks.example.com", "events": ["task.created"]}`, http.StatusForbidden},
+		{"manager updates project", "maria", http.MethodPatch, "/projects/1", `{"title": "Omega"}`, http.StatusOK},
+		{"manager changes title", "maria", http.MethodPatch, "/tasks/1", `{"title": "Summary"}`, http.StatusOK},
+		{"manager creates project for other", "maria", http.MethodPost, "/projects", `{"title": "Beta", "manager_id": 2}`, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Authorization", "Bearer "+tokens[tt.user])
+			if tt.method == http.MethodPatch {
+				req.Header.Set("Content-Type", "application/merge-patch+json")
+			}
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantCode {
+				t.Errorf("handler returned wrong status code: got %v want %v: %s", rr.Code, tt.wantCode, rr.Body.String())
+			}
+		})
+	}
+}
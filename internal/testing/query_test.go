@@ -0,0 +1,137 @@
+package testing
+
+import (
+	"fmt"
+	"pm-service/internal/repository/models"
+	"pm-service/internal/repository/query"
+	"testing"
+)
+
+// builder is implemented by the select and update builders.
+type builder interface {
+	Build() (string, []interface{}, error)
+}
+
+func TestQueryBuilder(t *testing.T) {
+	table := query.Table{Name: "tasks", Columns: []string{"id", "title", "status", "assignee_id", "created", "version", "deleted_at"}}
+	trash := query.Table{Name: "tasks", Columns: table.Columns, SoftDelete: "deleted_at"}
+	computed := query.Table{Name: "tasks", Columns: []string{"id", "late"}, Computed: map[string]string{"late": "due < now()"}}
+	sets := query.Table{Name: "tasks", Columns: []string{"id"}, Sets: map[string]string{"tags": "SELECT lower(name) FROM tags WHERE task_id = tasks.id"}}
+	filters := models.Filters{Page: 2, PageSize: 10, Sort: []string{"-created"}, SortSafelist: []string{"created"}}
+
+	tests := []struct {
+		name     string
+		builder  builder
+		wantSQL  string
+		wantArgs []interface{}
+		wantErr  bool
+	}{
+		{
+			name:     "equality",
+			builder:  table.Select("id", "title").Where(query.Eq("assignee_id", "3")),
+			wantSQL:  "SELECT id, title FROM tasks WHERE assignee_id = $1;",
+			wantArgs: []interface{}{"3"},
+		},
+		{
+			name: "criteria and page",
+			builder: table.Select("id").WithCount().Match(models.Criteria{
+				{Field: "status", Op: models.OpEqualFold, Values: []string{"to do", "in progress"}},
+				{Field: "title", Op: models.OpContains, Values: []string{"50%"}, Negate: true},
+			}).Page(filters),
+			wantSQL:  "SELECT count(*) OVER(), id FROM tasks WHERE lower(status) IN (lower($1), lower($2)) AND NOT (title ILIKE $3) ORDER BY created DESC, id ASC LIMIT $4 OFFSET $5;",
+			wantArgs: []interface{}{"to do", "in progress", `%50\%%`, 10, 10},
+		},
+		{
+			name:     "soft delete",
+			builder:  trash.Select("id").Where(query.Eq("status", "to do")),
+			wantSQL:  "SELECT id FROM tasks WHERE deleted_at IS NULL AND status = $1;",
+			wantArgs: []interface{}{"to do"},
+		},
+		{
+			name:     "update",
+			builder:  trash.Update(map[string]interface{}{"title": "Report", "status": "done"}).Increment("version").Where(query.Eq("id", "4"), query.Eq("version", 2)).Returning("version"),
+			wantSQL:  "UPDATE tasks SET status = $1, title = $2, version = version + 1 WHERE deleted_at IS NULL AND id = $3 AND version = $4 RETURNING version;",
+			wantArgs: []interface{}{"done", "Report", "4", 2},
+		},
+		{
+			name:     "computed column",
+			builder:  computed.Select().Where(query.Eq("late", true)).OrderBy([]string{"late"}, []bool{true}),
+			wantSQL:  "SELECT id, (due < now()) AS late FROM tasks WHERE (due < now()) = $1 ORDER BY (due < now()) DESC;",
+			wantArgs: []interface{}{true},
+		},
+		{
+			name:    "computed column not writable",
+			builder: computed.Update(map[string]interface{}{"late": false}),
+			wantErr: true,
+		},
+		{
+			name: "set column",
+			builder: sets.Select().Match(models.Criteria{
+				{Field: "tags", Op: models.OpHasAny, Values: []string{"Bug", "ui"}},
+				{Field: "tags", Op: models.OpHasAll, Values: []string{"urgent"}, Negate: true},
+			}),
+			wantSQL:  "SELECT id FROM tasks WHERE ARRAY(SELECT lower(name) FROM tags WHERE task_id = tasks.id) && ARRAY[lower($1), lower($2)]::text[] AND NOT (ARRAY(SELECT lower(name) FROM tags WHERE task_id = tasks.id) @> ARRAY[lower($3)]::text[]);",
+			wantArgs: []interface{}{"Bug", "ui", "urgent"},
+		},
+		{
+			name:    "set column not selectable",
+			builder: sets.Select("id", "tags"),
+			wantErr: true,
+		},
+		{
+			name:    "set column not sortable",
+			builder: sets.Select().OrderBy([]string{"tags"}, nil),
+			wantErr: true,
+		},
+		{
+			name:    "unknown update column",
+			builder: table.Update(map[string]interface{}{"title = 'x', role": "admin"}),
+			wantErr: true,
+		},
+		{
+			name:    "empty update",
+			builder: table.Update(nil).Where(query.Eq("id", "4")),
+			wantErr: true,
+		},
+		{
+			name:    "unknown column in where",
+			builder: table.Select().Where(query.Eq("id = id; DROP TABLE tasks; --", "1")),
+			wantErr: true,
+		},
+		{
+			name:    "unknown select column",
+			builder: table.Select("password"),
+			wantErr: true,
+		},
+		{
+			name:    "unknown sort column",
+			builder: table.Select().OrderBy([]string{"1; DROP TABLE tasks"}, nil),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stmt, args, err := tt.builder.Build()
+
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %q", stmt)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if stmt != tt.wantSQL {
+				t.Errorf("got statement\n%s\nwant\n%s", stmt, tt.wantSQL)
+			}
+
+			if fmt.Sprint(args) != fmt.Sprint(tt.wantArgs) {
+				t.Errorf("got args %v want %v", args, tt.wantArgs)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package testing
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"pm-service/internal/repository/models"
+	"strings"
+	"testing"
+)
+
+func TestFullTextSearch(t *testing.T) {
+	router := newRouter()
+
+	seed(t, router,
+		fixture{"/users", models.UserInput{Name: "bob", Email: "bob@mail.com", Role: "developer"}},
+		fixture{"/projects", models.ProjectInput{Title: "Billing", Description: "invoice generation", ManagerID: 1}},
+		fixture{"/tasks", models.TaskInput{Title: "Invoice export", Description: "export to csv", Priority: "high", Status: "to do", AssigneeID: 1, ProjectID: 1}},
+		fixture{"/tasks", models.TaskInput{Title: "Login page", Description: "show unpaid invoice banner", Priority: "low", Status: "to do", AssigneeID: 1, ProjectID: 1}},
+	)
+
+	tests := []struct {
+		name      string
+		query     string
+		wantCode  int
+		wantFirst string
+		wantCount int
+	}{
+		{"title ranks first", "q=invoice", http.StatusOK, "tasks/Invoice export", 3},
+		{"type filter", "q=invoice&type=projects", http.StatusOK, "projects/Billing", 1},
+		{"no match", "q=payroll", http.StatusOK, "", 0},
+		{"missing query", "q=", http.StatusUnprocessableEntity, "", 0},
+		{"unknown type", "q=invoice&type=users", http.StatusUnprocessableEntity, "", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/search?"+tt.query, nil))
+
+			if rr.Code != tt.wantCode {
+				t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, tt.wantCode)
+			}
+
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+
+			var resp struct {
+				Results []*models.SearchResult `json:"results"`
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(resp.Results) != tt.wantCount {
+				t.Fatalf("got %d results want %d", len(resp.Results), tt.wantCount)
+			}
+
+			if tt.wantCount == 0 {
+				return
+			}
+
+			first := resp.Results[0]
+			if got := first.Type + "/" + first.Title; got != tt.wantFirst {
+				t.Errorf("got first result %s want %s", got, tt.wantFirst)
+			}
+
+			if !strings.Contains(first.Snippet, "<mark>") {
+				t.Errorf("snippet %q has no highlighted terms", first.Snippet)
+			}
+		})
+	}
+}
+
+func TestSearchHighlight(t *testing.T) {
+	router := newRouter()
+
+	seed(t, router,
+		fixture{"/users", models.UserInput{Name: "bob", Email: "bob@mail.com", Role: "developer"}},
+		fixture{"/projects", models.ProjectInput{Title: "Billing", Description: "invoice generation", ManagerID: 1}},
+		fixture{"/tasks", models.TaskInput{Title: "Invoice export", Description: "export to csv", Priority: "high", Status: "to do", AssigneeID: 1, ProjectID: 1}},
+		fixture{"/tasks", models.TaskInput{Title: "Login page", Description: "show unpaid invoice banner", Priority: "low", Status: "to do", AssigneeID: 1, ProjectID: 1}},
+	)
+
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{"single term", "q=invoice&type=tasks", "<mark>Invoice</mark> export: export to csv"},
+		{"term repeated", "q=export&type=tasks", "Invoice <mark>export</mark>: <mark>export</mark> to csv"},
+		{"term inside another", "q=exp+export&type=tasks", "Invoice <mark>export</mark>: <mark>export</mark> to csv"},
+		{"term inside the markup", "q=invoice+a&type=tasks", "Login p<mark>a</mark>ge: show unp<mark>a</mark>id <mark>invoice</mark> b<mark>a</mark>nner"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/search?"+tt.query, nil))
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+			}
+
+			var resp struct {
+				Results []*models.SearchResult `json:"results"`
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(resp.Results) == 0 || resp.Results[0].Snippet != tt.want {
+				t.Errorf("got results %s want first snippet %q", rr.Body.String(), tt.want)
+			}
+		})
+	}
+}
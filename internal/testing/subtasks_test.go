@@ -0,0 +1,89 @@
+package testing
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"pm-service/internal/repository/models"
+	"strings"
+	"testing"
+)
+
+func TestSubtasks(t *testing.T) {
+	router := newRouter()
+
+	parent := func(id int) *int { return &id }
+
+	seed(t, router,
+		fixture{"/users", models.UserInput{Name: "bob", Email: "bob@mail.com", Role: "developer"}},
+		fixture{"/projects", models.ProjectInput{Title: "Alpha", Description: "first project", ManagerID: 1}},
+		fixture{"/tasks", models.TaskInput{Title: "Launch", Priority: "high", Status: "in progress", AssigneeID: 1, ProjectID: 1}},
+		fixture{"/tasks", models.TaskInput{Title: "Backend", Priority: "high", Status: "to do", AssigneeID: 1, ProjectID: 1, ParentTaskID: parent(1)}},
+		fixture{"/tasks", models.TaskInput{Title: "Schema", Priority: "low", Status: "in progress", AssigneeID: 1, ProjectID: 1, ParentTaskID: parent(2)}},
+		fixture{"/tasks", models.TaskInput{Title: "Frontend", Priority: "low", Status: "in progress", AssigneeID: 1, ProjectID: 1, ParentTaskID: parent(1)}},
+	)
+
+	type response struct {
+		Subtasks []*models.Task  `json:"subtasks"`
+		Progress models.Progress `json:"progress"`
+		Tree     models.TaskNode `json:"tree"`
+	}
+
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		contentType string
+		body        string
+		wantCode    int
+		want        func(*response) bool
+	}{
+		{"unknown parent", http.MethodPost, "/tasks", "", `{"title": "Docs", "priority": "low", "status": "to do", "assignee_id": 1, "project_id": 1, "parent_task_id": 9}`, http.StatusUnprocessableEntity, nil},
+		{"subtasks", http.MethodGet, "/tasks/1/subtasks", "", "", http.StatusOK, func(r *response) bool {
+			return len(r.Subtasks) == 2 && r.Subtasks[0].ID == 2 && r.Subtasks[1].ID == 4 && r.Progress == models.Progress{Total: 3}
+		}},
+		{"own subtask as parent", http.MethodPatch, "/tasks/1", "application/merge-patch+json", `{"parent_task_id": 3}`, http.StatusUnprocessableEntity, nil},
+		{"itself as parent", http.MethodPatch, "/tasks/2", "application/merge-patch+json", `{"parent_task_id": 2}`, http.StatusUnprocessableEntity, nil},
+		{"complete with open subtasks", http.MethodPost, "/tasks/1/transitions", "", `{"to": "completed"}`, http.StatusConflict, nil},
+		{"complete leaf", http.MethodPost, "/tasks/3/transitions", "", `{"to": "completed"}`, http.StatusOK, nil},
+		{"tree", http.MethodGet, "/tasks/1/tree", "", "", http.StatusOK, func(r *response) bool {
+			root := r.Tree
+			return root.ID == 1 && len(root.Subtasks) == 2 && root.Progress == models.Progress{Total: 3, Completed: 1, Percent: 33} &&
+				len(root.Subtasks[0].Subtasks) == 1 && root.Subtasks[0].Progress.Percent == 100 && len(root.Subtasks[1].Subtasks) == 0
+		}},
+		{"delete with subtasks", http.MethodDelete, "/tasks/2", "", "", http.StatusConflict, nil},
+		{"detach subtask", http.MethodPatch, "/tasks/3", "application/merge-patch+json", `{"parent_task_id": null}`, http.StatusOK, nil},
+		{"delete without subtasks", http.MethodDelete, "/tasks/2", "", "", http.StatusOK, nil},
+		{"complete remaining subtask", http.MethodPost, "/tasks/4/transitions", "", `{"to": "completed"}`, http.StatusOK, nil},
+		{"complete parent", http.MethodPost, "/tasks/1/transitions", "", `{"to": "completed"}`, http.StatusOK, nil},
+		{"unknown task tree", http.MethodGet, "/tasks/9/tree", "", "", http.StatusNotFound, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantCode {
+				t.Fatalf("handler returned wrong status code: got %v want %v: %s", rr.Code, tt.wantCode, rr.Body.String())
+			}
+
+			if tt.want == nil {
+				return
+			}
+
+			var resp response
+			if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if !tt.want(&resp) {
+				t.Errorf("unexpected response: %s", rr.Body.String())
+			}
+		})
+	}
+}
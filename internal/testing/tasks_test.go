@@ -0,0 +1,153 @@
+package testing
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"pm-service/internal/repository/models"
+	"testing"
+)
+
+func TestCreateTask(t *testing.T) {
+	router := newRouter()
+
+	seed(t, router,
+		fixture{"/users", models.UserInput{Name: "bob", Email: "bob@mail.com", Role: "developer"}},
+		fixture{"/projects", models.ProjectInput{Title: "Alpha", Description: "first project", ManagerID: 1}},
+	)
+
+	valid := models.TaskInput{Title: "Report", Description: "quarterly report", Priority: "High", Status: "to do", AssigneeID: 1, ProjectID: 1, Completed: "2024-07-10"}
+
+	tests := []struct {
+		name       string
+		input      models.TaskInput
+		wantCode   int
+		wantFields []string
+	}{
+		{
+			name:       "only a valid date",
+			input:      models.TaskInput{Completed: "2024-07-10"},
+			wantCode:   http.StatusUnprocessableEntity,
+			wantFields: []string{"title", "priority", "status", "assignee_id", "project_id"},
+		},
+		{
+			name: "title too long",
+			input: func() models.TaskInput {
+				in := valid
+				in.Title = "a title that is definitely longer than fifty characters"
+				return in
+			}(),
+			wantCode:   http.StatusUnprocessableEntity,
+			wantFields: []string{"title"},
+		},
+		{
+			name: "unknown references",
+			input: func() models.TaskInput {
+				in := valid
+				in.AssigneeID, in.ProjectID = 7, 9
+				return in
+			}(),
+			wantCode:   http.StatusUnprocessableEntity,
+			wantFields: []string{"assignee_id", "project_id"},
+		},
+		{
+			name:     "valid",
+			input:    valid,
+			wantCode: http.StatusCreated,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, err := json.Marshal(tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/tasks", bytes.NewReader(body)))
+
+			if rr.Code != tt.wantCode {
+				t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, tt.wantCode)
+			}
+
+			var resp struct {
+				Error map[string]string `json:"error"`
+			}
+			if len(tt.wantFields) > 0 {
+				if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			for _, field := range tt.wantFields {
+				if _, ok := resp.Error[field]; !ok {
+					t.Errorf("expected an error for field %q, got %v", field, resp.Error)
+				}
+			}
+		})
+	}
+}
+
+func TestSearchTasks(t *testing.T) {
+	router := newRouter()
+
+	seed(t, router,
+		fixture{"/users", models.UserInput{Name: "bob", Email: "bob@mail.com", Role: "developer"}},
+		fixture{"/users", models.UserInput{Name: "eve", Email: "eve@mail.com", Role: "developer"}},
+		fixture{"/projects", models.ProjectInput{Title: "Alpha", Description: "first project", ManagerID: 1}},
+		fixture{"/projects/1/members", models.MemberInput{UserID: 2, Role: "member"}},
+		fixture{"/tasks", models.TaskInput{Title: "Write report", Priority: "high", Status: "in progress", AssigneeID: 1, ProjectID: 1}},
+		fixture{"/tasks", models.TaskInput{Title: "Review report", Priority: "low", Status: "in progress", AssigneeID: 2, ProjectID: 1}},
+		fixture{"/tasks", models.TaskInput{Title: "Deploy", Priority: "high", Status: "completed", AssigneeID: 1, ProjectID: 1, Completed: "2024-07-10"}},
+	)
+
+	tests := []struct {
+		name     string
+		query    string
+		wantCode int
+		wantIDs  []int
+	}{
+		{"combined", "status=in%20progress&priority=high&assignee=1", http.StatusOK, []int{1}},
+		{"in list", "assignee=1,2&sort=-id", http.StatusOK, []int{3, 2, 1}},
+		{"negated", "status=!completed", http.StatusOK, []int{1, 2}},
+		{"partial title", "title=REPORT", http.StatusOK, []int{1, 2}},
+		{"completed range", "completed_from=2024-07-01&completed_to=2024-07-10", http.StatusOK, []int{3}},
+		{"bad status", "status=done", http.StatusUnprocessableEntity, nil},
+		{"bad assignee", "assignee=bob", http.StatusUnprocessableEntity, nil},
+		{"no criteria", "", http.StatusBadRequest, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/tasks/search?"+tt.query, nil))
+
+			if rr.Code != tt.wantCode {
+				t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, tt.wantCode)
+			}
+
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+
+			var resp struct {
+				Tasks []*models.Task `json:"tasks"`
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+
+			var ids []int
+			for _, task := range resp.Tasks {
+				ids = append(ids, task.ID)
+			}
+
+			if fmt.Sprint(ids) != fmt.Sprint(tt.wantIDs) {
+				t.Errorf("got tasks %v want %v", ids, tt.wantIDs)
+			}
+		})
+	}
+}
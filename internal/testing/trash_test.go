@@ -0,0 +1,72 @@
+package testing
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"pm-service/internal/repository/models"
+	"testing"
+)
+
+func TestTrash(t *testing.T) {
+	router := newRouter()
+
+	seed(t, router,
+		fixture{"/users", models.UserInput{Name: "bob", Email: "bob@mail.com", Role: "developer"}},
+		fixture{"/users", models.UserInput{Name: "eve", Email: "eve@mail.com", Role: "manager"}},
+		fixture{"/projects", models.ProjectInput{Title: "Alpha", Description: "first project", ManagerID: 2}},
+		fixture{"/projects/1/members", models.MemberInput{UserID: 1, Role: "member"}},
+		fixture{"/tasks", models.TaskInput{Title: "Report", Priority: "high", Status: "to do", AssigneeID: 1, ProjectID: 1}},
+		fixture{"/tasks", models.TaskInput{Title: "Review", Priority: "low", Status: "to do", AssigneeID: 2, ProjectID: 1}},
+	)
+
+	tests := []struct {
+		name      string
+		method    string
+		path      string
+		wantCode  int
+		wantTotal int
+	}{
+		{"delete task", http.MethodDelete, "/tasks/1", http.StatusOK, -1},
+		{"trashed task hidden", http.MethodGet, "/tasks/1", http.StatusNotFound, -1},
+		{"trashed task not listed", http.MethodGet, "/tasks", http.StatusOK, 1},
+		{"list trash", http.MethodGet, "/trash", http.StatusOK, 1},
+		{"cascade project", http.MethodDelete, "/projects/1?mode=cascade", http.StatusOK, -1},
+		{"list trashed tasks", http.MethodGet, "/trash?type=tasks", http.StatusOK, 2},
+		{"list trashed users", http.MethodGet, "/trash?type=users", http.StatusOK, 0},
+		{"invalid trash type", http.MethodGet, "/trash?type=comments", http.StatusUnprocessableEntity, -1},
+		{"restore task of trashed project", http.MethodPost, "/tasks/1/restore", http.StatusConflict, -1},
+		{"restore project", http.MethodPost, "/projects/1/restore", http.StatusOK, -1},
+		{"cascaded task restored", http.MethodGet, "/tasks/2", http.StatusOK, -1},
+		{"separately trashed task kept", http.MethodGet, "/tasks/1", http.StatusNotFound, -1},
+		{"restore task", http.MethodPost, "/tasks/1/restore", http.StatusOK, -1},
+		{"restore live task", http.MethodPost, "/tasks/1/restore", http.StatusNotFound, -1},
+		{"trash emptied", http.MethodGet, "/trash", http.StatusOK, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest(tt.method, tt.path, nil))
+
+			if rr.Code != tt.wantCode {
+				t.Fatalf("handler returned wrong status code: got %v want %v: %s", rr.Code, tt.wantCode, rr.Body.String())
+			}
+
+			if tt.wantTotal < 0 {
+				return
+			}
+
+			var resp struct {
+				Metadata models.Metadata `json:"metadata"`
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+
+			if resp.Metadata.TotalRecords != tt.wantTotal {
+				t.Errorf("got %d records want %d: %s", resp.Metadata.TotalRecords, tt.wantTotal, rr.Body.String())
+			}
+		})
+	}
+}
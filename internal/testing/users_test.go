@@ -3,15 +3,14 @@ package testing
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
-	"pm-service/internal/config"
-	"pm-service/internal/handlers"
 	"pm-service/internal/repository/models"
 	"testing"
 )
 
-var router = config.Routing(handlers.Mock())
+var router = newRouter()
 
 func TestCreateUser(t *testing.T) {
 	tests := []struct {
@@ -47,14 +46,14 @@ func TestCreateUser(t *testing.T) {
 			path:     "/users",
 			method:   "POST",
 			input:    models.UserInput{},
-			wantCode: http.StatusBadRequest,
+			wantCode: http.StatusUnprocessableEntity,
 		},
 		{
 			name:     "test5",
 			path:     "/users",
 			method:   "POST",
 			input:    models.UserInput{Name: "alice", Email: "alice02@mail.", Role: "manager"},
-			wantCode: http.StatusBadRequest,
+			wantCode: http.StatusUnprocessableEntity,
 		},
 		{
 			name:     "test6",
@@ -86,3 +85,93 @@ func TestCreateUser(t *testing.T) {
 		})
 	}
 }
+
+func TestListUsers(t *testing.T) {
+	router := newRouter()
+
+	for _, name := range []string{"carol", "alice", "bob"} {
+		seed(t, router, fixture{"/users", models.UserInput{Name: name, Email: name + "@mail.com", Role: "developer"}})
+	}
+
+	tests := []struct {
+		name      string
+		query     string
+		wantCode  int
+		wantNames []string
+		wantMeta  models.Metadata
+	}{
+		{
+			name:      "default",
+			query:     "",
+			wantCode:  http.StatusOK,
+			wantNames: []string{"carol", "alice", "bob"},
+			wantMeta:  models.Metadata{CurrentPage: 1, PageSize: 20, FirstPage: 1, LastPage: 1, TotalRecords: 3},
+		},
+		{
+			name:      "sorted and paged",
+			query:     "?sort=-name&page=1&page_size=2",
+			wantCode:  http.StatusOK,
+			wantNames: []string{"carol", "bob"},
+			wantMeta:  models.Metadata{CurrentPage: 1, PageSize: 2, FirstPage: 1, LastPage: 2, NextPage: 2, TotalRecords: 3},
+		},
+		{
+			name:      "last page",
+			query:     "?sort=-name&page=2&page_size=2",
+			wantCode:  http.StatusOK,
+			wantNames: []string{"alice"},
+			wantMeta:  models.Metadata{CurrentPage: 2, PageSize: 2, FirstPage: 1, LastPage: 2, TotalRecords: 3},
+		},
+		{
+			name:     "page past the last",
+			query:    "?page=3&page_size=2",
+			wantCode: http.StatusOK,
+			wantMeta: models.Metadata{CurrentPage: 3, PageSize: 2, FirstPage: 1, LastPage: 2, TotalRecords: 3},
+		},
+		{
+			name:     "unknown sort field",
+			query:    "?sort=password",
+			wantCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:     "page size too large",
+			query:    "?page_size=1000",
+			wantCode: http.StatusUnprocessableEntity,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/users"+tt.query, nil))
+
+			if rr.Code != tt.wantCode {
+				t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, tt.wantCode)
+			}
+
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+
+			var resp struct {
+				Users    []*models.User  `json:"users"`
+				Metadata models.Metadata `json:"metadata"`
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+
+			var names []string
+			for _, u := range resp.Users {
+				names = append(names, u.Name)
+			}
+
+			if fmt.Sprint(names) != fmt.Sprint(tt.wantNames) {
+				t.Errorf("got users %v want %v", names, tt.wantNames)
+			}
+
+			if resp.Metadata != tt.wantMeta {
+				t.Errorf("got metadata %+v want %+v", resp.Metadata, tt.wantMeta)
+			}
+		})
+	}
+}
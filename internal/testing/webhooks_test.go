@@ -0,0 +1,334 @@
+package testing
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"pm-service/internal/repository/mock"
+	"pm-service/internal/repository/models"
+	"pm-service/internal/service/events"
+	"pm-service/internal/service/webhook"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWebhooks(t *testing.T) {
+	router := newRouter()
+	inactive := false
+
+	seed(t, router,
+		fixture{"/users", models.UserInput{Name: "bob", Email: "bob@mail.com", Role: "developer"}},
+		fixture{"/users", models.UserInput{Name: "eve", Email: "eve@mail.com", Role: "manager"}},
+		fixture{"/projects", models.ProjectInput{Title: "Alpha", Description: "first project", ManagerID: 2}},
+		fixture{"/webhooks", models.WebhookInput{URL: "https://hooks.example.com/pm", Secret: "0123456789abcdef", Events: []string{"task.created", "task.status_changed"}}},
+		fixture{"/webhooks", models.WebhookInput{URL: "https://hooks.example.com/off", Events: []string{"task.created"}, Active: &inactive}},
+	)
+
+	tests := []struct {
+		name      string
+		method    string
+		path      string
+		body      string
+		wantCode  int
+		wantTotal int
+	}{
+		{"list webhooks", http.MethodGet, "/webhooks", "", http.StatusOK, 2},
+		{"relative url", http.MethodPost, "/webhooks", `{"url": "/pm", "events": ["task.created"]}`, http.StatusUnprocessableEntity, -1},
+		{"ftp url", http.MethodPost, "/webhooks", `{"url": "ftp://hooks.example.com", "events": ["task.created"]}`, http.StatusUnprocessableEntity, -1},
+		{"unknown event", http.MethodPost, "/webhooks", `{"url": "https://hooks.example.com", "events": ["task.renamed"]}`, http.StatusUnprocessableEntity, -1},
+		{"no events", http.MethodPost, "/webhooks", `{"url": "https://hooks.example.com", "events": []}`, http.StatusUnprocessableEntity, -1},
+		{"repeated event", http.MethodPost, "/webhooks", `{"url": "https://hooks.example.com", "events": ["task.created", "task.created"]}`, http.StatusUnprocessableEntity, -1},
+		{"short secret", http.MethodPost, "/webhooks", `{"url": "https://hooks.example.com", "secret": "hunter2", "events": ["task.created"]}`, http.StatusUnprocessableEntity, -1},
+		{"no deliveries yet", http.MethodGet, "/webhooks/1/deliveries", "", http.StatusOK, 0},
+		{"create task", http.MethodPost, "/tasks", `{"title": "Report", "priority": "high", "status": "to do", "assignee_id": 2, "project_id": 1}`, http.StatusCreated, -1},
+		{"created delivered", http.MethodGet, "/webhooks/1/deliveries", "", http.StatusOK, 1},
+		{"inactive webhook skipped", http.MethodGet, "/webhooks/2/deliveries", "", http.StatusOK, 0},
+		{"rename task", http.MethodPatch, "/tasks/1", `{"title": "Summary"}`, http.StatusOK, -1},
+		{"unsubscribed event skipped", http.MethodGet, "/webhooks/1/deliveries", "", http.StatusOK, 1},
+		{"change status", http.MethodPatch, "/tasks/1", `{"status": "in progress"}`, http.StatusOK, -1},
+		{"status change delivered", http.MethodGet, "/webhooks/1/deliveries?event=task.status_changed", "", http.StatusOK, 1},
+		{"pending", http.MethodGet, "/webhooks/1/deliveries?status=pending", "", http.StatusOK, 2},
+		{"delivered", http.MethodGet, "/webhooks/1/deliveries?status=delivered", "", http.StatusOK, 0},
+		{"invalid status", http.MethodGet, "/webhooks/1/deliveries?status=lost", "", http.StatusUnprocessableEntity, -1},
+		{"get delivery", http.MethodGet, "/webhooks/1/deliveries/1", "", http.StatusOK, -1},
+		{"delivery of other webhook", http.MethodGet, "/webhooks/2/deliveries/1", "", http.StatusNotFound, -1},
+		{"redeliver", http.MethodPost, "/webhooks/1/deliveries/2/redeliver", "", http.StatusAccepted, -1},
+		{"redeliver unknown", http.MethodPost, "/webhooks/1/deliveries/9/redeliver", "", http.StatusNotFound, -1},
+		{"activate webhook", http.MethodPut, "/webhooks/2", `{"url": "https://hooks.example.com/on", "events": ["task.created", "task.deleted"]}`, http.StatusOK, -1},
+		{"delete task", http.MethodDelete, "/tasks/1", "", http.StatusOK, -1},
+		{"deleted delivered", http.MethodGet, "/webhooks/2/deliveries", "", http.StatusOK, 1},
+		{"webhook changes audited", http.MethodGet, "/audit?resource=webhooks", "", http.StatusOK, 3},
+		{"delete webhook", http.MethodDelete, "/webhooks/1", "", http.StatusOK, -1},
+		{"deliveries of deleted webhook", http.MethodGet, "/webhooks/1/deliveries", "", http.StatusNotFound, -1},
+		{"unknown webhook", http.MethodGet, "/webhooks/9", "", http.StatusNotFound, -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.method == http.MethodPatch {
+				req.Header.Set("Content-Type", "application/merge-patch+json")
+			}
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantCode {
+				t.Fatalf("handler returned wrong status code: got %v want %v: %s", rr.Code, tt.wantCode, rr.Body.String())
+			}
+
+			if tt.wantTotal < 0 {
+				return
+			}
+
+			var resp struct {
+				Metadata models.Metadata `json:"metadata"`
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+
+			if resp.Metadata.TotalRecords != tt.wantTotal {
+				t.Errorf("got %d records want %d: %s", resp.Metadata.TotalRecords, tt.wantTotal, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestWebhookSecret(t *testing.T) {
+	router := newRouter()
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/webhooks", strings.NewReader(`{"url": "https://hooks.example.com", "events": ["task.created"]}`)))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("creating webhook returned %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var created struct {
+		Secret string `json:"secret"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &created); err != nil {
+		t.Fatal(err)
+	}
+	if len(created.Secret) != 64 {
+		t.Errorf("got secret %q want 64 hex characters", created.Secret)
+	}
+
+	for _, path := range []string{"/webhooks/1", "/webhooks", "/audit?resource=webhooks"} {
+		rr = httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+		if strings.Contains(rr.Body.String(), created.Secret) {
+			t.Errorf("%s reveals the secret: %s", path, rr.Body.String())
+		}
+	}
+}
+
+func TestWebhookDispatch(t *testing.T) {
+	const secret = "0123456789abcdef"
+
+	var status atomic.Int32
+	status.Store(http.StatusInternalServerError)
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		if !webhook.Verify(secret, r.Header.Get(webhook.TimestampHeader), r.Header.Get(webhook.SignatureHeader), body) {
+			t.Errorf("bad signature %q", r.Header.Get(webhook.SignatureHeader))
+		}
+
+		var payload webhook.Payload
+		if err := json.Unmarshal(body, &payload); err != nil || payload.Event != "task.created" || payload.ID != 1 || payload.Data["title"] != "Report" {
+			t.Errorf("unexpected payload: %s", body)
+		}
+		if r.Header.Get(webhook.EventHeader) != "task.created" {
+			t.Errorf("got event header %q want task.created", r.Header.Get(webhook.EventHeader))
+		}
+
+		w.WriteHeader(int(status.Load()))
+	}))
+	defer receiver.Close()
+
+	ctx := context.Background()
+
+	tasks := &mock.TaskModel{}
+	hooks := &mock.WebhookModel{}
+	bus := &events.Bus{}
+	bus.Subscribe(events.Recorded{}.Name(), webhook.Enqueue(hooks))
+	audit := &mock.AuditModel{Tasks: tasks, Webhooks: hooks, Outbox: &mock.OutboxModel{Bus: bus}}
+	tasks.Audit, hooks.Audit = audit, audit
+
+	if _, err := hooks.Insert(ctx, &models.WebhookInput{URL: receiver.URL, Secret: secret, Events: []string{"task.created"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tasks.Insert(ctx, &models.TaskInput{Title: "Report", Priority: "high", Status: "to do"}); err != nil {
+		t.Fatal(err)
+	}
+
+	dispatcher := &webhook.Dispatcher{
+		Queue:       hooks,
+		Client:      webhook.NewClient(time.Second),
+		MaxAttempts: 2,
+		Backoff:     time.Nanosecond,
+		MaxBackoff:  time.Nanosecond,
+		BatchSize:   10,
+	}
+
+	dispatch := func(wantDelivered, wantFailed int) {
+		t.Helper()
+
+		time.Sleep(time.Millisecond)
+		delivered, failed, err := dispatcher.Dispatch(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if delivered != wantDelivered || failed != wantFailed {
+			t.Fatalf("got %d delivered, %d failed want %d, %d", delivered, failed, wantDelivered, wantFailed)
+		}
+	}
+
+	check := func(wantStatus string, wantLog int) {
+		t.Helper()
+
+		d, err := hooks.Delivery(ctx, 1, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d.Status != wantStatus || len(d.Log) != wantLog {
+			t.Fatalf("got %s after %d attempts want %s after %d", d.Status, len(d.Log), wantStatus, wantLog)
+		}
+	}
+
+	// a failed delivery is retried
+	dispatch(0, 1)
+	check(models.DeliveryPending, 1)
+	status.Store(http.StatusNoContent)
+	dispatch(1, 0)
+	check(models.DeliveryDelivered, 2)
+	dispatch(0, 0)
+
+	// it is dead-lettered once out of attempts, and can be redelivered
+	status.Store(http.StatusBadGateway)
+	if err := hooks.Redeliver(ctx, 1, 1); err != nil {
+		t.Fatal(err)
+	}
+	dispatch(0, 1)
+	dispatch(0, 1)
+	check(models.DeliveryDead, 4)
+	dispatch(0, 0)
+
+	d, _ := hooks.Delivery(ctx, 1, 1)
+	if d.Log[0].StatusCode != http.StatusInternalServerError || d.Log[0].Error == "" || d.Log[1].Error != "" {
+		t.Errorf("unexpected attempts: %+v %+v", d.Log[0], d.Log[1])
+	}
+}
+
+func TestWebhookLease(t *testing.T) {
+	var received atomic.Int32
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received.Add(1)
+		time.Sleep(100 * time.Millisecond)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	ctx := context.Background()
+
+	tasks := &mock.TaskModel{}
+	hooks := &mock.WebhookModel{}
+	bus := &events.Bus{}
+	bus.Subscribe(events.Recorded{}.Name(), webhook.Enqueue(hooks))
+	audit := &mock.AuditModel{Tasks: tasks, Webhooks: hooks, Outbox: &mock.OutboxModel{Bus: bus}}
+	tasks.Audit, hooks.Audit = audit, audit
+
+	if _, err := hooks.Insert(ctx, &models.WebhookInput{URL: receiver.URL, Events: []string{"task.created"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tasks.Insert(ctx, &models.TaskInput{Title: "Report", Priority: "high", Status: "to do"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// a claim taken over once its lease ran out can no longer be logged
+	time.Sleep(time.Millisecond)
+	stale, err := hooks.Claim(ctx, 1, 0)
+	if err != nil || len(stale) != 1 {
+		t.Fatalf("got %d jobs, %v want 1", len(stale), err)
+	}
+	time.Sleep(time.Millisecond)
+	current, err := hooks.Claim(ctx, 1, time.Minute)
+	if err != nil || len(current) != 1 || current[0].ID != stale[0].ID {
+		t.Fatalf("got %d jobs, %v want delivery %d again", len(current), err, stale[0].ID)
+	}
+
+	attempt := &models.DeliveryAttempt{StatusCode: http.StatusNoContent, Created: time.Now()}
+	if err := hooks.Attempted(ctx, stale[0], attempt, models.DeliveryDelivered, time.Now()); err != models.ErrLeaseLost {
+		t.Fatalf("got %v logging a lost claim want %v", err, models.ErrLeaseLost)
+	}
+	if err := hooks.Attempted(ctx, current[0], attempt, models.DeliveryDelivered, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if err := hooks.Attempted(ctx, current[0], attempt, models.DeliveryDelivered, time.Now()); err != models.ErrLeaseLost {
+		t.Fatalf("got %v logging a claim twice want %v", err, models.ErrLeaseLost)
+	}
+
+	// the deliveries of a batch are sent at once, well within the client timeout
+	for i := 0; i < 2; i++ {
+		if _, err := tasks.Insert(ctx, &models.TaskInput{Title: "Review", Priority: "low", Status: "to do"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(time.Millisecond)
+
+	dispatcher := &webhook.Dispatcher{
+		Queue:       hooks,
+		Client:      webhook.NewClient(time.Second),
+		MaxAttempts: 2,
+		Backoff:     time.Second,
+		MaxBackoff:  time.Second,
+		BatchSize:   10,
+	}
+
+	start := time.Now()
+	delivered, failed, err := dispatcher.Dispatch(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if delivered != 2 || failed != 0 || received.Load() != 2 {
+		t.Errorf("got %d delivered, %d failed, %d received want 2, 0, 2", delivered, failed, received.Load())
+	}
+	if elapsed := time.Since(start); elapsed > 190*time.Millisecond {
+		t.Errorf("batch took %v, deliveries were sent one after another", elapsed)
+	}
+}
+
+func TestWebhookEnqueueOnce(t *testing.T) {
+	ctx := context.Background()
+
+	hooks := &mock.WebhookModel{}
+	if _, err := hooks.Insert(ctx, &models.WebhookInput{URL: "http://example.com/hook", Events: []string{"task.created", "task.updated"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	enqueue := webhook.Enqueue(hooks)
+	created := events.Recorded{Resource: "tasks", ID: 1, Action: models.AuditInsert, Occurred: time.Now()}
+
+	// the same outbox event relayed twice, then another change
+	for _, id := range []int{1, 1, 2} {
+		if err := enqueue(events.WithEventID(ctx, id), created); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := enqueue(events.WithEventID(ctx, 3), events.TaskCreated{TaskID: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(time.Millisecond)
+	jobs, err := hooks.Claim(ctx, 10, time.Minute)
+	if err != nil || len(jobs) != 2 {
+		t.Fatalf("got %d deliveries, %v want 2", len(jobs), err)
+	}
+}
@@ -0,0 +1,85 @@
+package testing
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"pm-service/internal/repository/models"
+	"strings"
+	"testing"
+)
+
+func TestWorkflow(t *testing.T) {
+	router := newRouter()
+
+	seed(t, router,
+		fixture{"/users", models.UserInput{Name: "bob", Email: "bob@mail.com", Role: "developer"}},
+		fixture{"/projects", models.ProjectInput{Title: "Alpha", Description: "first project", ManagerID: 1}},
+		fixture{"/tasks", models.TaskInput{Title: "Report", Priority: "high", Status: "to do", AssigneeID: 1, ProjectID: 1}},
+	)
+
+	const workflow = `{
+		"states": [{"name": "to do"}, {"name": "review"}, {"name": "done", "terminal": true}],
+		"transitions": [{"from": "to do", "to": "review"}, {"from": "review", "to": "done"}, {"from": "done", "to": "to do"}]
+	}`
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		body     string
+		wantCode int
+		want     func(*models.Task) bool
+	}{
+		{"default workflow", http.MethodGet, "/projects/1/workflow", "", http.StatusOK, nil},
+		{"unknown project", http.MethodGet, "/projects/9/workflow", "", http.StatusNotFound, nil},
+		{"no terminal state", http.MethodPut, "/projects/1/workflow", `{"states": [{"name": "to do"}]}`, http.StatusUnprocessableEntity, nil},
+		{"dangling transition", http.MethodPut, "/projects/1/workflow", `{"states": [{"name": "to do", "terminal": true}], "transitions": [{"from": "to do", "to": "gone"}]}`, http.StatusUnprocessableEntity, nil},
+		{"status in use dropped", http.MethodPut, "/projects/1/workflow", `{"states": [{"name": "done", "terminal": true}]}`, http.StatusUnprocessableEntity, nil},
+		{"custom workflow", http.MethodPut, "/projects/1/workflow", workflow, http.StatusOK, nil},
+		{"allowed transitions", http.MethodGet, "/tasks/1/transitions", "", http.StatusOK, nil},
+		{"unknown status", http.MethodPost, "/tasks/1/transitions", `{"to": "archived"}`, http.StatusUnprocessableEntity, nil},
+		{"illegal transition", http.MethodPost, "/tasks/1/transitions", `{"to": "done"}`, http.StatusConflict, func(s *models.Task) bool {
+			return s.Status == "to do" && s.Completed == nil
+		}},
+		{"transition", http.MethodPost, "/tasks/1/transitions", `{"to": "Review"}`, http.StatusOK, func(s *models.Task) bool {
+			return s.Status == "review" && s.Completed == nil
+		}},
+		{"entering terminal state completes", http.MethodPost, "/tasks/1/transitions", `{"to": "done"}`, http.StatusOK, func(s *models.Task) bool {
+			return s.Status == "done" && s.Completed != nil
+		}},
+		{"illegal update", http.MethodPut, "/tasks/1", `{"title": "Report", "priority": "high", "status": "review", "assignee_id": 1, "project_id": 1}`, http.StatusConflict, nil},
+		{"reopening clears completion", http.MethodPut, "/tasks/1", `{"title": "Report", "priority": "high", "status": "to do", "assignee_id": 1, "project_id": 1}`, http.StatusOK, func(s *models.Task) bool {
+			return s.Status == "to do" && s.Completed == nil
+		}},
+		{"create with status outside workflow", http.MethodPost, "/tasks", `{"title": "Audit", "priority": "low", "status": "in progress", "assignee_id": 1, "project_id": 1}`, http.StatusUnprocessableEntity, nil},
+		{"search custom status", http.MethodGet, "/tasks/search?status=review", "", http.StatusOK, nil},
+		{"search unknown status", http.MethodGet, "/tasks/search?status=archived", "", http.StatusUnprocessableEntity, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+
+			if rr.Code != tt.wantCode {
+				t.Fatalf("handler returned wrong status code: got %v want %v: %s", rr.Code, tt.wantCode, rr.Body.String())
+			}
+
+			if tt.want == nil {
+				return
+			}
+
+			rr = httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/tasks/1", nil))
+
+			var task models.Task
+			if err := json.Unmarshal(rr.Body.Bytes(), &task); err != nil {
+				t.Fatal(err)
+			}
+			if !tt.want(&task) {
+				t.Errorf("unexpected task: %s", rr.Body.String())
+			}
+		})
+	}
+}
@@ -1,24 +1,41 @@
 package main
 
 import (
-	"flag"
 	"fmt"
 	"log"
-	"net/http"
+	"os"
 	"pm-service/internal/app"
+	"pm-service/internal/config"
 )
 
 func main() {
-	port := flag.Int("port", 8080, "port for api")
-	flag.Parse()
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		log.Fatalln(err)
+	}
 
-	app := app.NewApp(*port, "migrations/postgres/00001_initial.up.sql")
+	if args := cfg.Args(); len(args) > 0 {
+		switch args[0] {
+		case "migrate":
+			if err := app.Migrate(cfg, args[1:]); err != nil {
+				log.Fatalln(err)
+			}
+		case "apikey":
+			if err := app.APIKey(cfg, args[1:]); err != nil {
+				log.Fatalln(err)
+			}
+		default:
+			log.Fatalf("unknown command %q", args[0])
+		}
+		return
+	}
+
+	log.Printf("configuration: %s", cfg.Redacted())
 
-	defer app.DB.Close()
+	app := app.NewApp(cfg)
 
 	fmt.Printf("Server starting on http://localhost:%d\n\n", app.Port)
-	err := http.ListenAndServe(":8080", app.Routes)
-	if err != nil {
+	if err := app.Serve(); err != nil {
 		log.Fatalln(err)
 	}
 }
@@ -1,5 +1,5 @@
-DROP TABLE IF EXISTS users;
+DROP TABLE IF EXISTS tasks;
 
 DROP TABLE IF EXISTS projects;
 
-DROP TABLE IF EXISTS tasks;
\ No newline at end of file
+DROP TABLE IF EXISTS users;
\ No newline at end of file
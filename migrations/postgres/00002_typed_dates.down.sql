@@ -0,0 +1,22 @@
+DROP INDEX IF EXISTS projects_completed_idx;
+
+DROP INDEX IF EXISTS tasks_completed_idx;
+
+ALTER TABLE tasks ALTER COLUMN completed TYPE VARCHAR(50) USING COALESCE(to_char(completed, 'YYYY-MM-DD'), '');
+ALTER TABLE tasks ALTER COLUMN completed SET NOT NULL;
+ALTER TABLE tasks ALTER COLUMN created DROP DEFAULT;
+ALTER TABLE tasks ALTER COLUMN created DROP NOT NULL;
+ALTER TABLE tasks ALTER COLUMN created TYPE VARCHAR(50) USING to_char(created, 'YYYY-MM-DD');
+ALTER TABLE tasks ALTER COLUMN created SET DEFAULT CURRENT_DATE;
+
+ALTER TABLE projects ALTER COLUMN completed TYPE VARCHAR(50) USING COALESCE(to_char(completed, 'YYYY-MM-DD'), '');
+ALTER TABLE projects ALTER COLUMN completed SET NOT NULL;
+ALTER TABLE projects ALTER COLUMN created DROP DEFAULT;
+ALTER TABLE projects ALTER COLUMN created DROP NOT NULL;
+ALTER TABLE projects ALTER COLUMN created TYPE VARCHAR(50) USING to_char(created, 'YYYY-MM-DD');
+ALTER TABLE projects ALTER COLUMN created SET DEFAULT CURRENT_DATE;
+
+ALTER TABLE users ALTER COLUMN created DROP DEFAULT;
+ALTER TABLE users ALTER COLUMN created DROP NOT NULL;
+ALTER TABLE users ALTER COLUMN created TYPE VARCHAR(50) USING to_char(created, 'YYYY-MM-DD');
+ALTER TABLE users ALTER COLUMN created SET DEFAULT CURRENT_DATE;
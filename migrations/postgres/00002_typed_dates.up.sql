@@ -0,0 +1,30 @@
+CREATE OR REPLACE FUNCTION pg_temp.to_timestamptz(value TEXT) RETURNS TIMESTAMPTZ AS $$
+BEGIN
+    RETURN NULLIF(trim(value), '')::TIMESTAMPTZ;
+EXCEPTION WHEN others THEN
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+ALTER TABLE users ALTER COLUMN created DROP DEFAULT;
+ALTER TABLE users ALTER COLUMN created TYPE TIMESTAMPTZ USING COALESCE(pg_temp.to_timestamptz(created), now());
+ALTER TABLE users ALTER COLUMN created SET DEFAULT now();
+ALTER TABLE users ALTER COLUMN created SET NOT NULL;
+
+ALTER TABLE projects ALTER COLUMN created DROP DEFAULT;
+ALTER TABLE projects ALTER COLUMN created TYPE TIMESTAMPTZ USING COALESCE(pg_temp.to_timestamptz(created), now());
+ALTER TABLE projects ALTER COLUMN created SET DEFAULT now();
+ALTER TABLE projects ALTER COLUMN created SET NOT NULL;
+ALTER TABLE projects ALTER COLUMN completed DROP NOT NULL;
+ALTER TABLE projects ALTER COLUMN completed TYPE TIMESTAMPTZ USING pg_temp.to_timestamptz(completed);
+
+ALTER TABLE tasks ALTER COLUMN created DROP DEFAULT;
+ALTER TABLE tasks ALTER COLUMN created TYPE TIMESTAMPTZ USING COALESCE(pg_temp.to_timestamptz(created), now());
+ALTER TABLE tasks ALTER COLUMN created SET DEFAULT now();
+ALTER TABLE tasks ALTER COLUMN created SET NOT NULL;
+ALTER TABLE tasks ALTER COLUMN completed DROP NOT NULL;
+ALTER TABLE tasks ALTER COLUMN completed TYPE TIMESTAMPTZ USING pg_temp.to_timestamptz(completed);
+
+CREATE INDEX IF NOT EXISTS tasks_completed_idx ON tasks (completed);
+
+CREATE INDEX IF NOT EXISTS projects_completed_idx ON projects (completed);
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS projects_search_idx;
+
+ALTER TABLE projects DROP COLUMN IF EXISTS search;
+
+DROP INDEX IF EXISTS tasks_search_idx;
+
+ALTER TABLE tasks DROP COLUMN IF EXISTS search;
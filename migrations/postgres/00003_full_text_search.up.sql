@@ -0,0 +1,13 @@
+ALTER TABLE tasks ADD COLUMN search TSVECTOR GENERATED ALWAYS AS (
+    setweight(to_tsvector('english', coalesce(title, '')), 'A') ||
+    setweight(to_tsvector('english', coalesce(description, '')), 'B')
+) STORED;
+
+CREATE INDEX tasks_search_idx ON tasks USING GIN (search);
+
+ALTER TABLE projects ADD COLUMN search TSVECTOR GENERATED ALWAYS AS (
+    setweight(to_tsvector('english', coalesce(title, '')), 'A') ||
+    setweight(to_tsvector('english', coalesce(description, '')), 'B')
+) STORED;
+
+CREATE INDEX projects_search_idx ON projects USING GIN (search);
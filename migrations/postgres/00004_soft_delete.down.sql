@@ -0,0 +1,11 @@
+DELETE FROM tasks WHERE deleted_at IS NOT NULL;
+
+DELETE FROM projects WHERE deleted_at IS NOT NULL;
+
+DELETE FROM users WHERE deleted_at IS NOT NULL;
+
+ALTER TABLE tasks DROP COLUMN deleted_at;
+
+ALTER TABLE projects DROP COLUMN deleted_at;
+
+ALTER TABLE users DROP COLUMN deleted_at;
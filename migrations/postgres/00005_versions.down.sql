@@ -0,0 +1,5 @@
+ALTER TABLE tasks DROP COLUMN version;
+
+ALTER TABLE projects DROP COLUMN version;
+
+ALTER TABLE users DROP COLUMN version;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS workflow_transitions;
+
+DROP TABLE IF EXISTS workflow_states;
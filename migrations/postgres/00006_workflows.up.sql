@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS workflow_states (
+    project_id INTEGER NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    name VARCHAR(50) NOT NULL,
+    position INTEGER NOT NULL,
+    terminal BOOLEAN NOT NULL DEFAULT false,
+    PRIMARY KEY (project_id, name)
+);
+
+GRANT ALL PRIVILEGES ON workflow_states TO admin;
+
+CREATE TABLE IF NOT EXISTS workflow_transitions (
+    project_id INTEGER NOT NULL,
+    from_state VARCHAR(50) NOT NULL,
+    to_state VARCHAR(50) NOT NULL,
+    PRIMARY KEY (project_id, from_state, to_state),
+    FOREIGN KEY (project_id, from_state) REFERENCES workflow_states(project_id, name) ON DELETE CASCADE,
+    FOREIGN KEY (project_id, to_state) REFERENCES workflow_states(project_id, name) ON DELETE CASCADE
+);
+
+GRANT ALL PRIVILEGES ON workflow_transitions TO admin;
@@ -0,0 +1,38 @@
+CREATE TABLE IF NOT EXISTS comments (
+    id SERIAL PRIMARY KEY,
+    task_id INTEGER NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    author_id INTEGER NOT NULL REFERENCES users(id),
+    parent_id INTEGER REFERENCES comments(id) ON DELETE CASCADE,
+    body VARCHAR(1000) NOT NULL,
+    created TIMESTAMPTZ NOT NULL DEFAULT now(),
+    edited TIMESTAMPTZ,
+    version INTEGER NOT NULL DEFAULT 1
+);
+
+CREATE INDEX comments_task_id_idx ON comments (task_id, parent_id);
+
+CREATE INDEX comments_parent_id_idx ON comments (parent_id) WHERE parent_id IS NOT NULL;
+
+GRANT ALL PRIVILEGES ON comments TO admin;
+
+GRANT ALL PRIVILEGES ON SEQUENCE comments_id_seq TO admin;
+
+CREATE TABLE IF NOT EXISTS comment_revisions (
+    comment_id INTEGER NOT NULL REFERENCES comments(id) ON DELETE CASCADE,
+    version INTEGER NOT NULL,
+    body VARCHAR(1000) NOT NULL,
+    written TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (comment_id, version)
+);
+
+GRANT ALL PRIVILEGES ON comment_revisions TO admin;
+
+CREATE TABLE IF NOT EXISTS comment_mentions (
+    comment_id INTEGER NOT NULL REFERENCES comments(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    PRIMARY KEY (comment_id, user_id)
+);
+
+CREATE INDEX comment_mentions_user_id_idx ON comment_mentions (user_id);
+
+GRANT ALL PRIVILEGES ON comment_mentions TO admin;
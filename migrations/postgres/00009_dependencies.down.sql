@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS task_dependencies;
+
+ALTER TABLE tasks DROP COLUMN estimate;
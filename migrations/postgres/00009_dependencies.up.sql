@@ -0,0 +1,13 @@
+ALTER TABLE tasks ADD COLUMN estimate INTEGER NOT NULL DEFAULT 0 CHECK (estimate >= 0);
+
+CREATE TABLE IF NOT EXISTS task_dependencies (
+    task_id INTEGER NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    blocker_id INTEGER NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    created TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (task_id, blocker_id),
+    CHECK (task_id <> blocker_id)
+);
+
+CREATE INDEX task_dependencies_blocker_id_idx ON task_dependencies (blocker_id);
+
+GRANT ALL PRIVILEGES ON task_dependencies TO admin;
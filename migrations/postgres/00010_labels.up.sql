@@ -0,0 +1,24 @@
+CREATE TABLE IF NOT EXISTS labels (
+    id SERIAL PRIMARY KEY,
+    project_id INTEGER NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    name VARCHAR(50) NOT NULL,
+    color CHAR(7) NOT NULL,
+    created TIMESTAMPTZ NOT NULL DEFAULT now(),
+    version INTEGER NOT NULL DEFAULT 1
+);
+
+CREATE UNIQUE INDEX labels_project_id_name_idx ON labels (project_id, lower(name));
+
+GRANT ALL PRIVILEGES ON labels TO admin;
+
+GRANT ALL PRIVILEGES ON SEQUENCE labels_id_seq TO admin;
+
+CREATE TABLE IF NOT EXISTS task_labels (
+    task_id INTEGER NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    label_id INTEGER NOT NULL REFERENCES labels(id) ON DELETE CASCADE,
+    PRIMARY KEY (task_id, label_id)
+);
+
+CREATE INDEX task_labels_label_id_idx ON task_labels (label_id);
+
+GRANT ALL PRIVILEGES ON task_labels TO admin;
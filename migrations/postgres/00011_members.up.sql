@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS project_members (
+    project_id INTEGER NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role VARCHAR(20) NOT NULL CHECK (role IN ('owner', 'manager', 'member', 'viewer')),
+    added TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (project_id, user_id)
+);
+
+CREATE INDEX project_members_user_id_idx ON project_members (user_id);
+
+GRANT ALL PRIVILEGES ON project_members TO admin;
+
+-- managers own their projects and assignees keep working on theirs
+INSERT INTO project_members (project_id, user_id, role)
+SELECT id, manager_id, 'owner' FROM projects;
+
+INSERT INTO project_members (project_id, user_id, role)
+SELECT DISTINCT project_id, assignee_id, 'member' FROM tasks
+ON CONFLICT DO NOTHING;
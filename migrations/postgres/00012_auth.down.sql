@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS revoked_tokens;
+
+DROP TABLE IF EXISTS api_keys;
+
+DROP INDEX IF EXISTS users_lower_email_idx;
+
+ALTER TABLE users DROP COLUMN IF EXISTS token_generation;
+
+ALTER TABLE users DROP COLUMN IF EXISTS password_hash;
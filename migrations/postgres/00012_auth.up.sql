@@ -0,0 +1,34 @@
+-- users without a password sign in with an API key until they set one
+ALTER TABLE users ADD COLUMN password_hash TEXT NOT NULL DEFAULT '';
+
+-- tokens carry the generation they were issued in; changing the password
+-- moves it on, invalidating every token issued before
+ALTER TABLE users ADD COLUMN token_generation INTEGER NOT NULL DEFAULT 0;
+
+CREATE INDEX users_lower_email_idx ON users (lower(email));
+
+CREATE TABLE IF NOT EXISTS api_keys (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(50) NOT NULL,
+    hint VARCHAR(20) NOT NULL,
+    key_hash CHAR(64) NOT NULL UNIQUE,
+    created TIMESTAMPTZ NOT NULL DEFAULT now(),
+    last_used TIMESTAMPTZ
+);
+
+CREATE INDEX api_keys_user_id_idx ON api_keys (user_id);
+
+GRANT ALL PRIVILEGES ON api_keys TO admin;
+
+GRANT ALL PRIVILEGES ON SEQUENCE api_keys_id_seq TO admin;
+
+-- revoked JWTs by id, kept until they would have expired anyway
+CREATE TABLE IF NOT EXISTS revoked_tokens (
+    jti TEXT PRIMARY KEY,
+    expires TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX revoked_tokens_expires_idx ON revoked_tokens (expires);
+
+GRANT ALL PRIVILEGES ON revoked_tokens TO admin;
@@ -0,0 +1,2 @@
+-- the original free-text roles are not restored
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_role_check;
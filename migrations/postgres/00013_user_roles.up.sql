@@ -0,0 +1,6 @@
+-- roles were free text until now; anything outside the new set becomes developer
+UPDATE users SET role = lower(role) WHERE lower(role) IN ('admin', 'manager', 'developer', 'viewer');
+
+UPDATE users SET role = 'developer' WHERE role NOT IN ('admin', 'manager', 'developer', 'viewer');
+
+ALTER TABLE users ADD CONSTRAINT users_role_check CHECK (role IN ('admin', 'manager', 'developer', 'viewer'));
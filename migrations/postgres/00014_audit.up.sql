@@ -0,0 +1,21 @@
+-- no foreign keys: the log outlives the records and users it mentions
+CREATE TABLE IF NOT EXISTS audit_events (
+    id SERIAL PRIMARY KEY,
+    actor_id INTEGER,
+    resource VARCHAR(20) NOT NULL,
+    resource_id INTEGER NOT NULL,
+    action VARCHAR(10) NOT NULL CHECK (action IN ('insert', 'update', 'delete', 'restore')),
+    diff JSONB NOT NULL,
+    request_id VARCHAR(64) NOT NULL DEFAULT '',
+    created TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX audit_events_resource_idx ON audit_events (resource, resource_id, id);
+
+CREATE INDEX audit_events_actor_id_idx ON audit_events (actor_id);
+
+CREATE INDEX audit_events_created_idx ON audit_events (created);
+
+GRANT ALL PRIVILEGES ON audit_events TO admin;
+
+GRANT ALL PRIVILEGES ON SEQUENCE audit_events_id_seq TO admin;
@@ -0,0 +1,55 @@
+CREATE TABLE IF NOT EXISTS webhooks (
+    id SERIAL PRIMARY KEY,
+    url TEXT NOT NULL,
+    -- kept as is, unlike passwords and API keys, as it signs the deliveries
+    secret TEXT NOT NULL,
+    -- counts the secrets set, so the audit log tells a rotation apart
+    -- without a digest of the secret
+    secret_version INTEGER NOT NULL DEFAULT 1,
+    events TEXT[] NOT NULL,
+    active BOOLEAN NOT NULL DEFAULT true,
+    created TIMESTAMPTZ NOT NULL DEFAULT now(),
+    version INTEGER NOT NULL DEFAULT 1
+);
+
+GRANT ALL PRIVILEGES ON webhooks TO admin;
+
+GRANT ALL PRIVILEGES ON SEQUENCE webhooks_id_seq TO admin;
+
+-- the delivery queue: pending deliveries are sent once next_attempt has
+-- passed, delivered and dead ones are kept as the delivery log
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id SERIAL PRIMARY KEY,
+    webhook_id INTEGER NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+    event VARCHAR(40) NOT NULL,
+    payload JSONB NOT NULL,
+    status VARCHAR(10) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'delivered', 'dead')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt TIMESTAMPTZ NOT NULL DEFAULT now(),
+    created TIMESTAMPTZ NOT NULL DEFAULT now(),
+    delivered TIMESTAMPTZ
+);
+
+CREATE INDEX webhook_deliveries_webhook_id_idx ON webhook_deliveries (webhook_id, id);
+
+CREATE INDEX webhook_deliveries_due_idx ON webhook_deliveries (next_attempt) WHERE status = 'pending';
+
+GRANT ALL PRIVILEGES ON webhook_deliveries TO admin;
+
+GRANT ALL PRIVILEGES ON SEQUENCE webhook_deliveries_id_seq TO admin;
+
+-- status_code is 0 when the receiver could not be reached
+CREATE TABLE IF NOT EXISTS webhook_attempts (
+    id SERIAL PRIMARY KEY,
+    delivery_id INTEGER NOT NULL REFERENCES webhook_deliveries(id) ON DELETE CASCADE,
+    status_code INTEGER NOT NULL,
+    error TEXT NOT NULL DEFAULT '',
+    duration_ms INTEGER NOT NULL,
+    created TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX webhook_attempts_delivery_id_idx ON webhook_attempts (delivery_id, id);
+
+GRANT ALL PRIVILEGES ON webhook_attempts TO admin;
+
+GRANT ALL PRIVILEGES ON SEQUENCE webhook_attempts_id_seq TO admin;
@@ -0,0 +1,32 @@
+-- domain events, written in the transaction making the change and
+-- relayed to the in-process subscribers once it has committed. A relay
+-- claims events until next_attempt; they are published once their
+-- handlers succeed, retried otherwise, and failed once out of attempts.
+CREATE TABLE IF NOT EXISTS outbox (
+    id BIGSERIAL PRIMARY KEY,
+    name VARCHAR(40) NOT NULL,
+    payload JSONB NOT NULL,
+    actor_id INTEGER,
+    request_id VARCHAR(64) NOT NULL DEFAULT '',
+    created TIMESTAMPTZ NOT NULL DEFAULT now(),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt TIMESTAMPTZ NOT NULL DEFAULT now(),
+    published TIMESTAMPTZ,
+    failed TIMESTAMPTZ
+);
+
+CREATE INDEX outbox_due_idx ON outbox (next_attempt, id) WHERE published IS NULL AND failed IS NULL;
+
+CREATE INDEX outbox_published_idx ON outbox (published);
+
+CREATE INDEX outbox_failed_idx ON outbox (failed);
+
+GRANT ALL PRIVILEGES ON outbox TO admin;
+
+GRANT ALL PRIVILEGES ON SEQUENCE outbox_id_seq TO admin;
+
+-- webhook deliveries are queued by an event handler; the outbox event they
+-- come from keeps a change relayed twice from being delivered twice
+ALTER TABLE webhook_deliveries ADD COLUMN IF NOT EXISTS outbox_id BIGINT;
+
+CREATE UNIQUE INDEX webhook_deliveries_outbox_idx ON webhook_deliveries (outbox_id, webhook_id, event);